@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -11,6 +12,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"backend-go/internal/application/file"
 	"backend-go/internal/shared/config"
 	"backend-go/internal/infrastructure/database/postgres"
 	"backend-go/internal/infrastructure/database/redis"
@@ -50,6 +52,15 @@ func main() {
 		os.Exit(0)
 	}
 
+	// Handle garbage collection command
+	if len(os.Args) > 1 && os.Args[1] == "gc" {
+		if err := collectGarbage(cfg, logger, os.Args[2:]); err != nil {
+			logger.Error("Garbage collection failed", "error", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	// Initialize database
 	db, err := postgres.Connect(cfg.Database)
 	if err != nil {
@@ -110,6 +121,9 @@ func main() {
 	// Shutdown WebSocket hub
 	wsHub.Shutdown()
 
+	// Stop background work such as signing key rotation
+	httpServerInstance.Close()
+
 	// Shutdown HTTP server
 	if err := srv.Shutdown(ctx); err != nil {
 		logger.Fatal("Server forced to shutdown", "error", err)
@@ -142,6 +156,51 @@ func healthCheck(cfg *config.Config) error {
 	return nil
 }
 
+// collectGarbage removes files never sent with a message and stored content
+// nothing references, printing each item and the totals. With -dry-run
+// nothing is removed.
+func collectGarbage(cfg *config.Config, logger *logger.Logger, args []string) error {
+	flags := flag.NewFlagSet("gc", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "report garbage without removing it")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := postgres.Connect(cfg.Database)
+	if err != nil {
+		return fmt.Errorf("database connection failed: %w", err)
+	}
+	defer postgres.Close(db)
+
+	fileUseCase, _, err := httpServer.NewFileUseCase(cfg, db, logger)
+	if err != nil {
+		return err
+	}
+
+	report, err := fileUseCase.CollectGarbage(context.Background(), file.CollectGarbageInput{DryRun: *dryRun})
+	if err != nil {
+		return err
+	}
+
+	for _, item := range report.Items {
+		fmt.Printf("%-16s %s %d\n", item.Kind, item.Key, item.Size)
+	}
+
+	verb := "Removed"
+	if report.DryRun {
+		verb = "Would remove"
+	}
+	fmt.Printf("%s %d unattached files, %d orphaned blobs and %d stray objects, %d bytes in total\n",
+		verb,
+		report.Counts[file.GarbageUnattachedFile],
+		report.Counts[file.GarbageOrphanedBlob],
+		report.Counts[file.GarbageStrayObject],
+		report.Bytes,
+	)
+	fmt.Printf("Checked %d stored objects in %s\n", report.Scanned, report.Duration.Round(time.Millisecond))
+	return nil
+}
+
 func init() {
 	// Set Gin mode based on environment
 	if os.Getenv("GIN_MODE") == "" {
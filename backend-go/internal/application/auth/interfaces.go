@@ -5,49 +5,119 @@ import (
 	"time"
 
 	"backend-go/internal/domain/user"
+	"backend-go/internal/shared/oidc"
+	"backend-go/internal/shared/password"
+	"backend-go/internal/shared/throttle"
 )
 
 // UseCase defines the interface for authentication use cases
 type UseCase interface {
 	Register(ctx context.Context, input RegisterInput) (*RegisterOutput, error)
 	Login(ctx context.Context, input LoginInput) (*LoginOutput, error)
-	Logout(ctx context.Context, token string) error
-	RefreshToken(ctx context.Context, token string) (*RefreshTokenOutput, error)
+	Logout(ctx context.Context, input LogoutInput) error
+	RefreshToken(ctx context.Context, refreshToken string) (*RefreshTokenOutput, error)
 	ValidateToken(ctx context.Context, token string) (*ValidateTokenOutput, error)
+	ListSessions(ctx context.Context, input ListSessionsInput) (*ListSessionsOutput, error)
+	RevokeSession(ctx context.Context, input RevokeSessionInput) error
+	RevokeOtherSessions(ctx context.Context, input RevokeOtherSessionsInput) (*RevokeOtherSessionsOutput, error)
+	RequestPasswordReset(ctx context.Context, input RequestPasswordResetInput) error
+	ResetPassword(ctx context.Context, input ResetPasswordInput) (*ResetPasswordOutput, error)
+	ChangePassword(ctx context.Context, input ChangePasswordInput) (*ChangePasswordOutput, error)
+	SendEmailVerification(ctx context.Context, input SendEmailVerificationInput) error
+	VerifyEmail(ctx context.Context, input VerifyEmailInput) error
+	SetupTwoFactor(ctx context.Context, input SetupTwoFactorInput) (*SetupTwoFactorOutput, error)
+	ConfirmTwoFactor(ctx context.Context, input ConfirmTwoFactorInput) (*ConfirmTwoFactorOutput, error)
+	DisableTwoFactor(ctx context.Context, input DisableTwoFactorInput) error
+	VerifyTwoFactorLogin(ctx context.Context, input VerifyTwoFactorLoginInput) (*LoginOutput, error)
+	StartOIDCLogin(ctx context.Context, input StartOIDCLoginInput) (*StartOIDCLoginOutput, error)
+	CompleteOIDCLogin(ctx context.Context, input CompleteOIDCLoginInput) (*LoginOutput, error)
+	UnlockLogin(ctx context.Context, input UnlockLoginInput) error
+}
+
+// OIDCSettings configures single sign-on through an OpenID Connect provider.
+// Single sign-on is disabled when Provider is nil.
+type OIDCSettings struct {
+	Provider       *oidc.Provider
+	States         oidc.StateStore
+	AllowedDomains []string // Email domains allowed to sign in; empty allows any
+	AutoProvision  bool     // Create an account on first login for unknown users
+}
+
+// LoginProtection slows down and locks out repeated failed logins. A nil
+// limiter disables its check.
+type LoginProtection struct {
+	Accounts *throttle.Limiter // Keyed by email, and by user for two-factor codes
+	IPs      *throttle.Limiter // Keyed by client IP
+}
+
+// PasswordSettings configures which passwords users may choose and how they are hashed
+type PasswordSettings struct {
+	Policy   password.Policy
+	Breached password.BreachChecker // Nil skips the breached-password check
+	Hasher   *password.Hasher
+}
+
+// DeviceInfo describes the client a session is started from
+type DeviceInfo struct {
+	DeviceName string `json:"device_name,omitempty" validate:"max=100"`
+	UserAgent  string `json:"user_agent,omitempty"`
+	IPAddress  string `json:"ip_address,omitempty"`
 }
 
 // RegisterInput represents the input for user registration
 type RegisterInput struct {
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Username string     `json:"username" validate:"required,min=3,max=50"`
+	Email    string     `json:"email" validate:"required,email"`
+	Password string     `json:"password" validate:"required"`
+	Device   DeviceInfo `json:"device"`
 }
 
 // RegisterOutput represents the output for user registration
 type RegisterOutput struct {
-	User      *UserOutput `json:"user"`
-	Token     string      `json:"token"`
-	ExpiresAt time.Time   `json:"expires_at"`
+	User             *UserOutput `json:"user"`
+	SessionID        string      `json:"session_id"`
+	Token            string      `json:"token"`
+	ExpiresAt        time.Time   `json:"expires_at"`
+	RefreshToken     string      `json:"refresh_token"`
+	RefreshExpiresAt time.Time   `json:"refresh_expires_at"`
 }
 
 // LoginInput represents the input for user login
 type LoginInput struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string     `json:"email" validate:"required,email"`
+	Password string     `json:"password" validate:"required"`
+	Device   DeviceInfo `json:"device"`
 }
 
-// LoginOutput represents the output for user login
+// LoginOutput represents the output for user login. When two-factor
+// authentication is required only the challenge fields are set.
 type LoginOutput struct {
-	User      *UserOutput `json:"user"`
-	Token     string      `json:"token"`
-	ExpiresAt time.Time   `json:"expires_at"`
+	User             *UserOutput `json:"user"`
+	SessionID        string      `json:"session_id"`
+	Token            string      `json:"token"`
+	ExpiresAt        time.Time   `json:"expires_at"`
+	RefreshToken     string      `json:"refresh_token"`
+	RefreshExpiresAt time.Time   `json:"refresh_expires_at"`
+
+	TwoFactorRequired  bool       `json:"two_factor_required"`
+	ChallengeToken     string     `json:"challenge_token,omitempty"`
+	ChallengeExpiresAt *time.Time `json:"challenge_expires_at,omitempty"`
+}
+
+// LogoutInput represents the input for user logout
+type LogoutInput struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"` // Identifies the session for tokens issued without one
 }
 
 // RefreshTokenOutput represents the output for token refresh
 type RefreshTokenOutput struct {
-	User      *UserOutput `json:"user"`
-	Token     string      `json:"token"`
-	ExpiresAt time.Time   `json:"expires_at"`
+	User             *UserOutput `json:"user"`
+	SessionID        string      `json:"session_id"`
+	Token            string      `json:"token"`
+	ExpiresAt        time.Time   `json:"expires_at"`
+	RefreshToken     string      `json:"refresh_token"`
+	RefreshExpiresAt time.Time   `json:"refresh_expires_at"`
 }
 
 // ValidateTokenOutput represents the output for token validation
@@ -57,18 +127,174 @@ type ValidateTokenOutput struct {
 	Claims map[string]interface{} `json:"claims,omitempty"`
 }
 
+// ListSessionsInput represents the input for listing a user's sessions
+type ListSessionsInput struct {
+	UserID           string `json:"user_id" validate:"required"`
+	CurrentSessionID string `json:"current_session_id,omitempty"`
+}
+
+// SessionOutput represents a session in the output
+type SessionOutput struct {
+	ID         string    `json:"id"`
+	DeviceName string    `json:"device_name"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	IPAddress  string    `json:"ip_address,omitempty"`
+	Current    bool      `json:"current"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+// ListSessionsOutput represents the output for listing a user's sessions
+type ListSessionsOutput struct {
+	Sessions []*SessionOutput `json:"sessions"`
+}
+
+// RevokeSessionInput represents the input for revoking a single session
+type RevokeSessionInput struct {
+	UserID    string `json:"user_id" validate:"required"`
+	SessionID string `json:"session_id" validate:"required"`
+}
+
+// RevokeOtherSessionsInput represents the input for revoking all but the current session
+type RevokeOtherSessionsInput struct {
+	UserID           string `json:"user_id" validate:"required"`
+	CurrentSessionID string `json:"current_session_id" validate:"required"`
+}
+
+// RevokeOtherSessionsOutput represents the output for revoking all but the current session
+type RevokeOtherSessionsOutput struct {
+	RevokedSessionIDs []string `json:"revoked_session_ids"`
+}
+
+// RequestPasswordResetInput represents the input for requesting a password reset email
+type RequestPasswordResetInput struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResetPasswordInput represents the input for setting a new password with a reset token
+type ResetPasswordInput struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required"`
+}
+
+// ResetPasswordOutput represents the output for a password reset
+type ResetPasswordOutput struct {
+	UserID            string   `json:"user_id"`
+	RevokedSessionIDs []string `json:"revoked_session_ids"`
+}
+
+// ChangePasswordInput represents the input for changing a password while signed in
+type ChangePasswordInput struct {
+	UserID           string `json:"user_id" validate:"required"`
+	CurrentSessionID string `json:"current_session_id"`
+	CurrentPassword  string `json:"current_password" validate:"required"`
+	NewPassword      string `json:"new_password" validate:"required"`
+}
+
+// ChangePasswordOutput represents the output for a password change
+type ChangePasswordOutput struct {
+	RevokedSessionIDs []string `json:"revoked_session_ids"`
+}
+
+// SendEmailVerificationInput represents the input for (re)sending the verification email
+type SendEmailVerificationInput struct {
+	UserID string `json:"user_id" validate:"required"`
+}
+
+// VerifyEmailInput represents the input for confirming an email address
+type VerifyEmailInput struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// SetupTwoFactorInput represents the input for starting TOTP enrolment
+type SetupTwoFactorInput struct {
+	UserID string `json:"user_id" validate:"required"`
+}
+
+// SetupTwoFactorOutput represents the output for starting TOTP enrolment
+type SetupTwoFactorOutput struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// ConfirmTwoFactorInput represents the input for confirming TOTP enrolment
+type ConfirmTwoFactorInput struct {
+	UserID string `json:"user_id" validate:"required"`
+	Code   string `json:"code" validate:"required,len=6,numeric"`
+}
+
+// ConfirmTwoFactorOutput represents the output for confirming TOTP enrolment
+type ConfirmTwoFactorOutput struct {
+	RecoveryCodes []string `json:"recovery_codes"` // Shown once; only hashes are stored
+}
+
+// DisableTwoFactorInput represents the input for turning off two-factor authentication
+type DisableTwoFactorInput struct {
+	UserID string `json:"user_id" validate:"required"`
+	Code   string `json:"code" validate:"required"` // TOTP or recovery code
+}
+
+// VerifyTwoFactorLoginInput represents the input for completing a two-factor login
+type VerifyTwoFactorLoginInput struct {
+	ChallengeToken string     `json:"challenge_token" validate:"required"`
+	Code           string     `json:"code" validate:"required"` // TOTP or recovery code
+	Device         DeviceInfo `json:"device"`
+}
+
+// StartOIDCLoginInput represents the input for starting a single sign-on login
+type StartOIDCLoginInput struct {
+	DeviceName string `json:"device_name,omitempty" validate:"max=100"`
+}
+
+// StartOIDCLoginOutput represents the output for starting a single sign-on login
+type StartOIDCLoginOutput struct {
+	AuthorizationURL string    `json:"authorization_url"`
+	State            string    `json:"state"`
+	ExpiresAt        time.Time `json:"expires_at"`
+}
+
+// CompleteOIDCLoginInput represents the callback parameters from the identity provider
+type CompleteOIDCLoginInput struct {
+	Code   string     `json:"code" validate:"required"`
+	State  string     `json:"state" validate:"required"`
+	Device DeviceInfo `json:"device"`
+}
+
+// UnlockLoginInput represents an admin request to lift login lockouts for an
+// account, a client IP, or both
+type UnlockLoginInput struct {
+	AdminID   string `json:"admin_id" validate:"required"`
+	Email     string `json:"email,omitempty" validate:"omitempty,email"`
+	IPAddress string `json:"ip_address,omitempty" validate:"omitempty,ip"`
+}
+
 // UserOutput represents user information in the output
 type UserOutput struct {
-	ID       string `json:"id"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
+	ID            string `json:"id"`
+	Username      string `json:"username"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
 }
 
 // ToUserOutput converts a user entity to user output
 func ToUserOutput(u *user.User) *UserOutput {
 	return &UserOutput{
-		ID:       u.ID,
-		Username: u.Username,
-		Email:    u.Email,
+		ID:            u.ID,
+		Username:      u.Username,
+		Email:         u.Email,
+		EmailVerified: u.IsEmailVerified(),
+	}
+}
+
+// ToSessionOutput converts a session entity to session output
+func ToSessionOutput(s *user.Session, currentSessionID string) *SessionOutput {
+	return &SessionOutput{
+		ID:         s.ID,
+		DeviceName: s.DeviceName,
+		UserAgent:  s.UserAgent,
+		IPAddress:  s.IPAddress,
+		Current:    s.ID == currentSessionID,
+		CreatedAt:  s.CreatedAt,
+		LastUsedAt: s.LastUsedAt,
 	}
 }
\ No newline at end of file
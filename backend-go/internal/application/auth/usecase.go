@@ -2,36 +2,94 @@ package auth
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 
 	"backend-go/internal/domain/user"
 	"backend-go/internal/shared/jwt"
 	"backend-go/internal/shared/logger"
+	"backend-go/internal/shared/mail"
+	"backend-go/internal/shared/oidc"
+	"backend-go/internal/shared/password"
+	"backend-go/internal/shared/throttle"
+	"backend-go/internal/shared/totp"
 	"backend-go/internal/shared/validation"
 )
 
+// totpIssuer names the account in authenticator apps
+const totpIssuer = "WhatsApp Chat"
+
+var (
+	// ErrInvalidCredentials is the one error for an unknown email or a wrong password
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrAdminRequired      = errors.New("admin privileges required")
+
+	errInvalidTwoFactorCode = errors.New("invalid two-factor code")
+	errOIDCDisabled         = errors.New("single sign-on is not configured")
+)
+
 type useCase struct {
-	userRepo  user.Repository
-	jwtSvc    jwt.Service
-	validator validation.Validator
-	logger    logger.Logger
+	userRepo         user.Repository
+	refreshTokenRepo user.RefreshTokenRepository
+	sessionRepo      user.SessionRepository
+	accountTokenRepo user.AccountTokenRepository
+	twoFactorRepo    user.TwoFactorRepository
+	identityRepo     user.ExternalIdentityRepository
+	jwtSvc           jwt.Service
+	mailer           mail.Sender
+	validator        validation.Validator
+	logger           logger.Logger
+	refreshTokenTTL  time.Duration
+	appURL           string
+	oidc             OIDCSettings
+	loginProtection  LoginProtection
+	passwords        PasswordSettings
 }
 
 // NewUseCase creates a new authentication use case
 func NewUseCase(
 	userRepo user.Repository,
+	refreshTokenRepo user.RefreshTokenRepository,
+	sessionRepo user.SessionRepository,
+	accountTokenRepo user.AccountTokenRepository,
+	twoFactorRepo user.TwoFactorRepository,
+	identityRepo user.ExternalIdentityRepository,
 	jwtSvc jwt.Service,
+	mailer mail.Sender,
 	validator validation.Validator,
 	logger logger.Logger,
+	refreshTokenTTL time.Duration,
+	appURL string,
+	oidcSettings OIDCSettings,
+	loginProtection LoginProtection,
+	passwordSettings PasswordSettings,
 ) UseCase {
 	return &useCase{
-		userRepo:  userRepo,
-		jwtSvc:    jwtSvc,
-		validator: validator,
-		logger:    logger,
+		userRepo:         userRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		sessionRepo:      sessionRepo,
+		accountTokenRepo: accountTokenRepo,
+		twoFactorRepo:    twoFactorRepo,
+		identityRepo:     identityRepo,
+		jwtSvc:           jwtSvc,
+		mailer:           mailer,
+		validator:        validator,
+		logger:           logger,
+		refreshTokenTTL:  refreshTokenTTL,
+		appURL:           strings.TrimRight(appURL, "/"),
+		oidc:             oidcSettings,
+		loginProtection:  loginProtection,
+		passwords:        passwordSettings,
 	}
 }
 
@@ -42,55 +100,73 @@ func (uc *useCase) Register(ctx context.Context, input RegisterInput) (*Register
 		return nil, fmt.Errorf("invalid input: %w", err)
 	}
 
+	if err := uc.checkNewPassword(ctx, input.Password, input.Username, input.Email); err != nil {
+		return nil, err
+	}
+
+	// Conflicts count as failures so the form can't be used to probe for accounts
+	clientIP := input.Device.IPAddress
+	if err := uc.checkAttempts(ctx, uc.loginProtection.IPs, clientIP); err != nil {
+		return nil, err
+	}
+
 	// Check if user already exists by email
 	exists, err := uc.userRepo.ExistsByEmail(ctx, input.Email)
 	if err != nil {
 		uc.logger.Error("Failed to check if user exists by email", "error", err, "email", input.Email)
 		return nil, fmt.Errorf("failed to check user existence: %w", err)
 	}
-	if exists {
-		return nil, user.ErrUserAlreadyExists
-	}
-
-	// Check if username is taken
-	exists, err = uc.userRepo.ExistsByUsername(ctx, input.Username)
-	if err != nil {
-		uc.logger.Error("Failed to check if user exists by username", "error", err, "username", input.Username)
-		return nil, fmt.Errorf("failed to check username availability: %w", err)
+	if !exists {
+		// Check if username is taken
+		exists, err = uc.userRepo.ExistsByUsername(ctx, input.Username)
+		if err != nil {
+			uc.logger.Error("Failed to check if user exists by username", "error", err, "username", input.Username)
+			return nil, fmt.Errorf("failed to check username availability: %w", err)
+		}
 	}
 	if exists {
-		return nil, fmt.Errorf("username already taken")
+		// The same error either way, so it doesn't say which of the two is registered
+		uc.logger.Warn("Registration with existing email or username", "email", input.Email, "username", input.Username)
+		uc.recordFailedAttempt(ctx, uc.loginProtection.IPs, clientIP)
+		return nil, user.ErrUserAlreadyExists
 	}
 
 	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	hashedPassword, err := uc.passwords.Hasher.Hash(input.Password)
 	if err != nil {
 		uc.logger.Error("Failed to hash password", "error", err)
-		return nil, fmt.Errorf("failed to hash password: %w", err)
+		return nil, err
 	}
 
 	// Create user
 	userID := uuid.New().String()
-	newUser := user.NewUser(userID, input.Username, input.Email, string(hashedPassword))
+	newUser := user.NewUser(userID, input.Username, input.Email, hashedPassword)
 
 	if err := uc.userRepo.Create(ctx, newUser); err != nil {
 		uc.logger.Error("Failed to create user", "error", err, "email", input.Email)
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
-	// Generate JWT token
-	token, expiresAt, err := uc.jwtSvc.GenerateToken(userID, input.Email)
+	// Ask the user to confirm their address; registration succeeds regardless
+	if err := uc.sendVerificationEmail(ctx, newUser); err != nil {
+		uc.logger.Error("Failed to send verification email", "error", err, "user_id", userID)
+	}
+
+	// Sign the new user in on the registering device
+	creds, err := uc.startSession(ctx, newUser, input.Device)
 	if err != nil {
-		uc.logger.Error("Failed to generate token", "error", err, "user_id", userID)
-		return nil, fmt.Errorf("failed to generate token: %w", err)
+		return nil, err
 	}
 
 	uc.logger.Info("User registered successfully", "user_id", userID, "email", input.Email)
 
 	return &RegisterOutput{
-		User:      ToUserOutput(newUser),
-		Token:     token,
-		ExpiresAt: expiresAt,
+		User:             ToUserOutput(newUser),
+		SessionID:        creds.sessionID,
+		Token:            creds.token,
+		ExpiresAt:        creds.expiresAt,
+		RefreshToken:     creds.refreshToken,
+		RefreshExpiresAt: creds.refreshExpiresAt,
 	}, nil
 }
 
@@ -101,27 +177,68 @@ func (uc *useCase) Login(ctx context.Context, input LoginInput) (*LoginOutput, e
 		return nil, fmt.Errorf("invalid input: %w", err)
 	}
 
+	// Failed attempts are tracked per account, whether or not it exists, and per client IP
+	accountKey := loginAttemptKey(input.Email)
+	clientIP := input.Device.IPAddress
+	if err := uc.checkAttempts(ctx, uc.loginProtection.Accounts, accountKey); err != nil {
+		return nil, err
+	}
+	if err := uc.checkAttempts(ctx, uc.loginProtection.IPs, clientIP); err != nil {
+		return nil, err
+	}
+
 	// Get user by email
 	u, err := uc.userRepo.GetByEmail(ctx, input.Email)
 	if err != nil {
 		if err == user.ErrUserNotFound {
 			uc.logger.Warn("Login attempt with non-existent email", "email", input.Email)
-			return nil, fmt.Errorf("invalid credentials")
+			uc.passwords.Hasher.CompareDummy(input.Password)
+			uc.recordFailedLogin(ctx, accountKey, clientIP)
+			return nil, ErrInvalidCredentials
 		}
 		uc.logger.Error("Failed to get user by email", "error", err, "email", input.Email)
 		return nil, fmt.Errorf("failed to authenticate: %w", err)
 	}
 
-	// Check if user is active
+	// Verify password
+	if err := uc.passwords.Hasher.Compare(u.PasswordHash, input.Password); err != nil {
+		uc.logger.Warn("Login attempt with invalid password", "user_id", u.ID, "email", input.Email)
+		uc.recordFailedLogin(ctx, accountKey, clientIP)
+		return nil, ErrInvalidCredentials
+	}
+
+	// Checked after the password so deactivated accounts can't be discovered
 	if !u.IsActive {
 		uc.logger.Warn("Login attempt with inactive account", "user_id", u.ID, "email", input.Email)
 		return nil, fmt.Errorf("account is deactivated")
 	}
 
-	// Verify password
-	if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(input.Password)); err != nil {
-		uc.logger.Warn("Login attempt with invalid password", "user_id", u.ID, "email", input.Email)
-		return nil, fmt.Errorf("invalid credentials")
+	uc.resetAttempts(ctx, uc.loginProtection.Accounts, accountKey)
+
+	// Hashes made at an older cost are upgraded while the password is at hand
+	if uc.passwords.Hasher.NeedsRehash(u.PasswordHash) {
+		uc.rehashPassword(ctx, u, input.Password)
+	}
+
+	// With two-factor authentication the password only earns a challenge
+	twoFactor, err := uc.twoFactorRepo.Get(ctx, u.ID)
+	if err != nil && err != user.ErrTwoFactorNotFound {
+		uc.logger.Error("Failed to get two-factor enrolment", "error", err, "user_id", u.ID)
+		return nil, fmt.Errorf("failed to authenticate: %w", err)
+	}
+	if err == nil && twoFactor.IsEnabled() {
+		challenge, challengeExpiresAt, err := uc.jwtSvc.GenerateChallengeToken(u.ID)
+		if err != nil {
+			uc.logger.Error("Failed to generate challenge token", "error", err, "user_id", u.ID)
+			return nil, fmt.Errorf("failed to generate token: %w", err)
+		}
+
+		uc.logger.Info("Password accepted, two-factor code required", "user_id", u.ID)
+		return &LoginOutput{
+			TwoFactorRequired:  true,
+			ChallengeToken:     challenge,
+			ChallengeExpiresAt: &challengeExpiresAt,
+		}, nil
 	}
 
 	// Update last seen
@@ -130,25 +247,27 @@ func (uc *useCase) Login(ctx context.Context, input LoginInput) (*LoginOutput, e
 		// Don't fail login for this error
 	}
 
-	// Generate JWT token
-	token, expiresAt, err := uc.jwtSvc.GenerateToken(u.ID, u.Email)
+	// Each login is a separate session for the device
+	creds, err := uc.startSession(ctx, u, input.Device)
 	if err != nil {
-		uc.logger.Error("Failed to generate token", "error", err, "user_id", u.ID)
-		return nil, fmt.Errorf("failed to generate token: %w", err)
+		return nil, err
 	}
 
-	uc.logger.Info("User logged in successfully", "user_id", u.ID, "email", input.Email)
+	uc.logger.Info("User logged in successfully", "user_id", u.ID, "email", input.Email, "session_id", creds.sessionID)
 
 	return &LoginOutput{
-		User:      ToUserOutput(u),
-		Token:     token,
-		ExpiresAt: expiresAt,
+		User:             ToUserOutput(u),
+		SessionID:        creds.sessionID,
+		Token:            creds.token,
+		ExpiresAt:        creds.expiresAt,
+		RefreshToken:     creds.refreshToken,
+		RefreshExpiresAt: creds.refreshExpiresAt,
 	}, nil
 }
 
-func (uc *useCase) Logout(ctx context.Context, token string) error {
+func (uc *useCase) Logout(ctx context.Context, input LogoutInput) error {
 	// Validate and parse token
-	claims, err := uc.jwtSvc.ValidateToken(token)
+	claims, err := uc.jwtSvc.ValidateToken(input.AccessToken)
 	if err != nil {
 		uc.logger.Error("Invalid token for logout", "error", err)
 		return fmt.Errorf("invalid token: %w", err)
@@ -161,70 +280,112 @@ func (uc *useCase) Logout(ctx context.Context, token string) error {
 	}
 
 	// Blacklist token (if JWT service supports it)
-	if err := uc.jwtSvc.BlacklistToken(token); err != nil {
+	if err := uc.jwtSvc.BlacklistToken(input.AccessToken); err != nil {
 		uc.logger.Error("Failed to blacklist token", "error", err, "user_id", userID)
 		// Don't fail logout for this error, just log it
 	}
 
+	// End the session so none of its tokens can be used or renewed
+	sessionID, _ := claims["sid"].(string)
+	if sessionID == "" && input.RefreshToken != "" {
+		refreshToken, err := uc.refreshTokenRepo.GetByHash(ctx, hashToken(input.RefreshToken))
+		if err != nil && err != user.ErrRefreshTokenNotFound {
+			uc.logger.Error("Failed to get refresh token for logout", "error", err, "user_id", userID)
+			return fmt.Errorf("failed to revoke refresh token: %w", err)
+		}
+		if err == nil && refreshToken.UserID == userID {
+			sessionID = refreshToken.FamilyID
+		}
+	}
+	if sessionID != "" {
+		if err := uc.revokeSession(ctx, sessionID); err != nil {
+			uc.logger.Error("Failed to revoke session for logout", "error", err, "user_id", userID, "session_id", sessionID)
+			return err
+		}
+	}
+
 	uc.logger.Info("User logged out successfully", "user_id", userID)
 	return nil
 }
 
-func (uc *useCase) RefreshToken(ctx context.Context, token string) (*RefreshTokenOutput, error) {
-	// Validate current token
-	claims, err := uc.jwtSvc.ValidateToken(token)
+func (uc *useCase) RefreshToken(ctx context.Context, refreshTokenValue string) (*RefreshTokenOutput, error) {
+	if refreshTokenValue == "" {
+		return nil, fmt.Errorf("invalid refresh token")
+	}
+
+	// Look up the refresh token by its hash
+	current, err := uc.refreshTokenRepo.GetByHash(ctx, hashToken(refreshTokenValue))
 	if err != nil {
-		uc.logger.Error("Invalid token for refresh", "error", err)
-		return nil, fmt.Errorf("invalid token: %w", err)
+		if err == user.ErrRefreshTokenNotFound {
+			uc.logger.Warn("Token refresh attempt with unknown refresh token")
+			return nil, fmt.Errorf("invalid refresh token")
+		}
+		uc.logger.Error("Failed to get refresh token", "error", err)
+		return nil, fmt.Errorf("failed to refresh token: %w", err)
 	}
 
-	userID, ok := claims["user_id"].(string)
-	if !ok {
-		uc.logger.Error("Invalid user_id in token claims")
-		return nil, fmt.Errorf("invalid token claims")
+	// A rotated token being presented again means it was stolen; revoke the whole family
+	if current.IsRevoked() {
+		uc.revokeReusedFamily(ctx, current)
+		return nil, fmt.Errorf("invalid refresh token")
 	}
 
-	email, ok := claims["email"].(string)
-	if !ok {
-		uc.logger.Error("Invalid email in token claims")
-		return nil, fmt.Errorf("invalid token claims")
+	if current.IsExpired() {
+		return nil, fmt.Errorf("refresh token expired")
 	}
 
 	// Get user to ensure they still exist and are active
-	u, err := uc.userRepo.GetByID(ctx, userID)
+	u, err := uc.userRepo.GetByID(ctx, current.UserID)
 	if err != nil {
 		if err == user.ErrUserNotFound {
-			uc.logger.Warn("Token refresh attempt for non-existent user", "user_id", userID)
+			uc.logger.Warn("Token refresh attempt for non-existent user", "user_id", current.UserID)
 			return nil, fmt.Errorf("user not found")
 		}
-		uc.logger.Error("Failed to get user for token refresh", "error", err, "user_id", userID)
+		uc.logger.Error("Failed to get user for token refresh", "error", err, "user_id", current.UserID)
 		return nil, fmt.Errorf("failed to refresh token: %w", err)
 	}
 
 	if !u.IsActive {
-		uc.logger.Warn("Token refresh attempt for inactive user", "user_id", userID)
+		uc.logger.Warn("Token refresh attempt for inactive user", "user_id", u.ID)
 		return nil, fmt.Errorf("account is deactivated")
 	}
 
-	// Blacklist old token
-	if err := uc.jwtSvc.BlacklistToken(token); err != nil {
-		uc.logger.Error("Failed to blacklist old token", "error", err, "user_id", userID)
-		// Continue with refresh even if blacklisting fails
+	// Rotate the refresh token within its family
+	next, nextValue, err := uc.newRefreshToken(u.ID, current.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := uc.refreshTokenRepo.Rotate(ctx, current.ID, next); err != nil {
+		if err == user.ErrRefreshTokenReused {
+			// Lost a race with another use of the same token
+			uc.revokeReusedFamily(ctx, current)
+			return nil, fmt.Errorf("invalid refresh token")
+		}
+		uc.logger.Error("Failed to rotate refresh token", "error", err, "user_id", u.ID)
+		return nil, fmt.Errorf("failed to refresh token: %w", err)
+	}
+
+	// The session lives as long as its newest refresh token
+	if err := uc.sessionRepo.Touch(ctx, current.FamilyID, next.ExpiresAt); err != nil {
+		uc.logger.Error("Failed to update session", "error", err, "session_id", current.FamilyID)
+		// Don't fail the refresh for this error
 	}
 
-	// Generate new token
-	newToken, expiresAt, err := uc.jwtSvc.GenerateToken(userID, email)
+	// Generate new access token
+	newToken, expiresAt, err := uc.jwtSvc.GenerateToken(u.ID, u.Email, current.FamilyID)
 	if err != nil {
-		uc.logger.Error("Failed to generate new token", "error", err, "user_id", userID)
+		uc.logger.Error("Failed to generate new token", "error", err, "user_id", u.ID)
 		return nil, fmt.Errorf("failed to generate new token: %w", err)
 	}
 
-	uc.logger.Info("Token refreshed successfully", "user_id", userID)
+	uc.logger.Info("Token refreshed successfully", "user_id", u.ID)
 
 	return &RefreshTokenOutput{
-		User:      ToUserOutput(u),
-		Token:     newToken,
-		ExpiresAt: expiresAt,
+		User:             ToUserOutput(u),
+		Token:            newToken,
+		ExpiresAt:        expiresAt,
+		RefreshToken:     nextValue,
+		RefreshExpiresAt: next.ExpiresAt,
 	}, nil
 }
 
@@ -271,4 +432,1202 @@ func (uc *useCase) ValidateToken(ctx context.Context, token string) (*ValidateTo
 		Valid:  true,
 		Claims: claims,
 	}, nil
+}
+
+func (uc *useCase) ListSessions(ctx context.Context, input ListSessionsInput) (*ListSessionsOutput, error) {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid list sessions input", "error", err)
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	sessions, err := uc.sessionRepo.ListActiveByUser(ctx, input.UserID)
+	if err != nil {
+		uc.logger.Error("Failed to list sessions", "error", err, "user_id", input.UserID)
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	output := &ListSessionsOutput{
+		Sessions: make([]*SessionOutput, 0, len(sessions)),
+	}
+	for _, s := range sessions {
+		output.Sessions = append(output.Sessions, ToSessionOutput(s, input.CurrentSessionID))
+	}
+
+	return output, nil
+}
+
+func (uc *useCase) RevokeSession(ctx context.Context, input RevokeSessionInput) error {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid revoke session input", "error", err)
+		return fmt.Errorf("invalid input: %w", err)
+	}
+
+	session, err := uc.sessionRepo.GetByID(ctx, input.SessionID)
+	if err != nil {
+		if err == user.ErrSessionNotFound {
+			return user.ErrSessionNotFound
+		}
+		uc.logger.Error("Failed to get session", "error", err, "session_id", input.SessionID)
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	// Other users' sessions are reported as missing rather than forbidden
+	if session.UserID != input.UserID {
+		return user.ErrSessionNotFound
+	}
+
+	if err := uc.revokeSession(ctx, session.ID); err != nil {
+		uc.logger.Error("Failed to revoke session", "error", err, "session_id", session.ID)
+		return err
+	}
+
+	uc.logger.Info("Session revoked", "user_id", input.UserID, "session_id", session.ID)
+	return nil
+}
+
+func (uc *useCase) RevokeOtherSessions(ctx context.Context, input RevokeOtherSessionsInput) (*RevokeOtherSessionsOutput, error) {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid revoke other sessions input", "error", err)
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	revokedIDs, err := uc.sessionRepo.RevokeAllExcept(ctx, input.UserID, input.CurrentSessionID)
+	if err != nil {
+		uc.logger.Error("Failed to revoke sessions", "error", err, "user_id", input.UserID)
+		return nil, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	for _, sessionID := range revokedIDs {
+		if err := uc.revokeSessionTokens(ctx, sessionID); err != nil {
+			uc.logger.Error("Failed to revoke session tokens", "error", err, "session_id", sessionID)
+			return nil, err
+		}
+	}
+
+	uc.logger.Info("Other sessions revoked", "user_id", input.UserID, "count", len(revokedIDs))
+
+	return &RevokeOtherSessionsOutput{
+		RevokedSessionIDs: revokedIDs,
+	}, nil
+}
+
+func (uc *useCase) RequestPasswordReset(ctx context.Context, input RequestPasswordResetInput) error {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid password reset request input", "error", err)
+		return fmt.Errorf("invalid input: %w", err)
+	}
+
+	// Unknown or deactivated accounts get no email, but the caller is not told
+	u, err := uc.userRepo.GetByEmail(ctx, input.Email)
+	if err != nil {
+		if err == user.ErrUserNotFound {
+			uc.logger.Warn("Password reset requested for non-existent email", "email", input.Email)
+			return nil
+		}
+		uc.logger.Error("Failed to get user by email", "error", err, "email", input.Email)
+		return fmt.Errorf("failed to request password reset: %w", err)
+	}
+	if u.IsBot {
+		uc.logger.Warn("Password reset requested for bot", "user_id", u.ID)
+		return nil
+	}
+	if !u.IsActive {
+		uc.logger.Warn("Password reset requested for inactive account", "user_id", u.ID)
+		return nil
+	}
+
+	token, err := uc.issueAccountToken(ctx, u.ID, user.TokenPurposePasswordReset, user.PasswordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	err = uc.mailer.Send(ctx, mail.Message{
+		To:      u.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nWe received a request to reset your password. Open the link below to choose a new one. "+
+				"The link expires in 1 hour and can only be used once.\n\n%s\n\n"+
+				"If you did not request this, you can ignore this email.",
+			u.Username, uc.accountLink("reset-password", token),
+		),
+	})
+	if err != nil {
+		uc.logger.Error("Failed to send password reset email", "error", err, "user_id", u.ID)
+		return fmt.Errorf("failed to send password reset email: %w", err)
+	}
+
+	uc.logger.Info("Password reset email sent", "user_id", u.ID)
+	return nil
+}
+
+func (uc *useCase) ResetPassword(ctx context.Context, input ResetPasswordInput) (*ResetPasswordOutput, error) {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid reset password input", "error", err)
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	// The link stays usable until a new password is accepted, so a rejected
+	// password can be corrected without requesting another email
+	tokenHash := hashToken(input.Token)
+	token, err := uc.accountTokenRepo.Get(ctx, user.TokenPurposePasswordReset, tokenHash)
+	if err != nil {
+		if err == user.ErrAccountTokenInvalid {
+			uc.logger.Warn("Password reset attempt with invalid token")
+			return nil, user.ErrAccountTokenInvalid
+		}
+		return nil, fmt.Errorf("failed to reset password: %w", err)
+	}
+
+	u, err := uc.userRepo.GetByID(ctx, token.UserID)
+	if err != nil {
+		uc.logger.Error("Failed to get user for password reset", "error", err, "user_id", token.UserID)
+		return nil, fmt.Errorf("failed to reset password: %w", err)
+	}
+
+	if !u.IsActive {
+		return nil, fmt.Errorf("account is deactivated")
+	}
+
+	if err := uc.checkNewPassword(ctx, input.NewPassword, u.Username, u.Email); err != nil {
+		return nil, err
+	}
+	if u.PasswordHash != "" && uc.passwords.Hasher.Compare(u.PasswordHash, input.NewPassword) == nil {
+		return nil, fmt.Errorf("new password must differ from the current password")
+	}
+
+	// Consuming the token makes it single-use, even if the link is submitted twice at once
+	if _, err := uc.accountTokenRepo.Consume(ctx, user.TokenPurposePasswordReset, tokenHash); err != nil {
+		if err == user.ErrAccountTokenInvalid {
+			uc.logger.Warn("Password reset attempt with invalid token")
+			return nil, user.ErrAccountTokenInvalid
+		}
+		return nil, fmt.Errorf("failed to reset password: %w", err)
+	}
+
+	hashedPassword, err := uc.passwords.Hasher.Hash(input.NewPassword)
+	if err != nil {
+		uc.logger.Error("Failed to hash password", "error", err)
+		return nil, err
+	}
+
+	u.ChangePassword(hashedPassword)
+	if err := uc.userRepo.Update(ctx, u); err != nil {
+		uc.logger.Error("Failed to update password", "error", err, "user_id", u.ID)
+		return nil, fmt.Errorf("failed to reset password: %w", err)
+	}
+
+	// Whoever knew the old password must not stay signed in
+	revokedIDs, err := uc.sessionRepo.RevokeAllExcept(ctx, u.ID, "")
+	if err != nil {
+		uc.logger.Error("Failed to revoke sessions after password reset", "error", err, "user_id", u.ID)
+		return nil, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	for _, sessionID := range revokedIDs {
+		if err := uc.revokeSessionTokens(ctx, sessionID); err != nil {
+			uc.logger.Error("Failed to revoke session tokens", "error", err, "session_id", sessionID)
+			return nil, err
+		}
+	}
+
+	// Refresh tokens issued before sessions existed have no session to revoke
+	if err := uc.refreshTokenRepo.RevokeAllForUser(ctx, u.ID); err != nil {
+		uc.logger.Error("Failed to revoke refresh tokens after password reset", "error", err, "user_id", u.ID)
+		return nil, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	// Any other reset links sent before this one are now stale
+	if err := uc.accountTokenRepo.InvalidateForUser(ctx, u.ID, user.TokenPurposePasswordReset); err != nil {
+		uc.logger.Error("Failed to invalidate password reset tokens", "error", err, "user_id", u.ID)
+	}
+
+	uc.logger.Info("Password reset successfully", "user_id", u.ID, "revoked_sessions", len(revokedIDs))
+
+	return &ResetPasswordOutput{
+		UserID:            u.ID,
+		RevokedSessionIDs: revokedIDs,
+	}, nil
+}
+
+func (uc *useCase) ChangePassword(ctx context.Context, input ChangePasswordInput) (*ChangePasswordOutput, error) {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid change password input", "error", err)
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	u, err := uc.userRepo.GetByID(ctx, input.UserID)
+	if err != nil {
+		if err == user.ErrUserNotFound {
+			return nil, fmt.Errorf("user not found")
+		}
+		uc.logger.Error("Failed to get user", "error", err, "user_id", input.UserID)
+		return nil, fmt.Errorf("failed to change password: %w", err)
+	}
+
+	// A stolen access token alone must not be enough to take over the account
+	attemptKey := changePasswordAttemptKey(u.ID)
+	if err := uc.checkAttempts(ctx, uc.loginProtection.Accounts, attemptKey); err != nil {
+		return nil, err
+	}
+	if err := uc.passwords.Hasher.Compare(u.PasswordHash, input.CurrentPassword); err != nil {
+		uc.logger.Warn("Password change with wrong current password", "user_id", u.ID)
+		uc.recordFailedAttempt(ctx, uc.loginProtection.Accounts, attemptKey)
+		return nil, fmt.Errorf("current password is incorrect")
+	}
+	uc.resetAttempts(ctx, uc.loginProtection.Accounts, attemptKey)
+
+	if input.NewPassword == input.CurrentPassword {
+		return nil, fmt.Errorf("new password must differ from the current password")
+	}
+
+	if err := uc.checkNewPassword(ctx, input.NewPassword, u.Username, u.Email); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := uc.passwords.Hasher.Hash(input.NewPassword)
+	if err != nil {
+		uc.logger.Error("Failed to hash password", "error", err)
+		return nil, err
+	}
+
+	u.ChangePassword(hashedPassword)
+	if err := uc.userRepo.Update(ctx, u); err != nil {
+		uc.logger.Error("Failed to update password", "error", err, "user_id", u.ID)
+		return nil, fmt.Errorf("failed to change password: %w", err)
+	}
+
+	// Other devices may have been signed in by whoever knew the old password
+	revokedIDs, err := uc.sessionRepo.RevokeAllExcept(ctx, u.ID, input.CurrentSessionID)
+	if err != nil {
+		uc.logger.Error("Failed to revoke sessions after password change", "error", err, "user_id", u.ID)
+		return nil, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	for _, sessionID := range revokedIDs {
+		if err := uc.revokeSessionTokens(ctx, sessionID); err != nil {
+			uc.logger.Error("Failed to revoke session tokens", "error", err, "session_id", sessionID)
+			return nil, err
+		}
+	}
+
+	uc.logger.Info("Password changed successfully", "user_id", u.ID, "revoked_sessions", len(revokedIDs))
+
+	return &ChangePasswordOutput{
+		RevokedSessionIDs: revokedIDs,
+	}, nil
+}
+
+func (uc *useCase) SendEmailVerification(ctx context.Context, input SendEmailVerificationInput) error {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid send email verification input", "error", err)
+		return fmt.Errorf("invalid input: %w", err)
+	}
+
+	u, err := uc.userRepo.GetByID(ctx, input.UserID)
+	if err != nil {
+		if err == user.ErrUserNotFound {
+			return fmt.Errorf("user not found")
+		}
+		uc.logger.Error("Failed to get user", "error", err, "user_id", input.UserID)
+		return fmt.Errorf("failed to send verification email: %w", err)
+	}
+
+	if u.IsEmailVerified() {
+		return fmt.Errorf("email already verified")
+	}
+
+	if err := uc.sendVerificationEmail(ctx, u); err != nil {
+		uc.logger.Error("Failed to send verification email", "error", err, "user_id", u.ID)
+		return fmt.Errorf("failed to send verification email: %w", err)
+	}
+
+	return nil
+}
+
+func (uc *useCase) VerifyEmail(ctx context.Context, input VerifyEmailInput) error {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid verify email input", "error", err)
+		return fmt.Errorf("invalid input: %w", err)
+	}
+
+	token, err := uc.accountTokenRepo.Consume(ctx, user.TokenPurposeEmailVerification, hashToken(input.Token))
+	if err != nil {
+		if err == user.ErrAccountTokenInvalid {
+			uc.logger.Warn("Email verification attempt with invalid token")
+			return user.ErrAccountTokenInvalid
+		}
+		return fmt.Errorf("failed to verify email: %w", err)
+	}
+
+	u, err := uc.userRepo.GetByID(ctx, token.UserID)
+	if err != nil {
+		uc.logger.Error("Failed to get user for email verification", "error", err, "user_id", token.UserID)
+		return fmt.Errorf("failed to verify email: %w", err)
+	}
+
+	if u.IsEmailVerified() {
+		return nil
+	}
+
+	u.VerifyEmail()
+	if err := uc.userRepo.Update(ctx, u); err != nil {
+		uc.logger.Error("Failed to mark email as verified", "error", err, "user_id", u.ID)
+		return fmt.Errorf("failed to verify email: %w", err)
+	}
+
+	uc.logger.Info("Email verified successfully", "user_id", u.ID)
+	return nil
+}
+
+// sendVerificationEmail issues a fresh verification token and mails its link
+func (uc *useCase) sendVerificationEmail(ctx context.Context, u *user.User) error {
+	token, err := uc.issueAccountToken(ctx, u.ID, user.TokenPurposeEmailVerification, user.EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	return uc.mailer.Send(ctx, mail.Message{
+		To:      u.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease confirm your email address by opening the link below. "+
+				"The link expires in 24 hours.\n\n%s",
+			u.Username, uc.accountLink("verify-email", token),
+		),
+	})
+}
+
+// issueAccountToken replaces any outstanding token of the purpose with a new
+// one, returning the opaque value to send to the user
+func (uc *useCase) issueAccountToken(ctx context.Context, userID, purpose string, ttl time.Duration) (string, error) {
+	if err := uc.accountTokenRepo.InvalidateForUser(ctx, userID, purpose); err != nil {
+		return "", fmt.Errorf("failed to issue token: %w", err)
+	}
+
+	value, err := newOpaqueToken()
+	if err != nil {
+		uc.logger.Error("Failed to generate account token", "error", err, "user_id", userID)
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	token := user.NewAccountToken(uuid.New().String(), userID, purpose, hashToken(value), ttl)
+	if err := uc.accountTokenRepo.Create(ctx, token); err != nil {
+		return "", fmt.Errorf("failed to issue token: %w", err)
+	}
+
+	return value, nil
+}
+
+// accountLink builds a client app link carrying an account token
+func (uc *useCase) accountLink(path, token string) string {
+	return fmt.Sprintf("%s/%s?token=%s", uc.appURL, path, url.QueryEscape(token))
+}
+
+func (uc *useCase) SetupTwoFactor(ctx context.Context, input SetupTwoFactorInput) (*SetupTwoFactorOutput, error) {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid setup two-factor input", "error", err)
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	u, err := uc.userRepo.GetByID(ctx, input.UserID)
+	if err != nil {
+		if err == user.ErrUserNotFound {
+			return nil, fmt.Errorf("user not found")
+		}
+		uc.logger.Error("Failed to get user", "error", err, "user_id", input.UserID)
+		return nil, fmt.Errorf("failed to set up two-factor authentication: %w", err)
+	}
+
+	existing, err := uc.twoFactorRepo.Get(ctx, u.ID)
+	if err != nil && err != user.ErrTwoFactorNotFound {
+		return nil, fmt.Errorf("failed to set up two-factor authentication: %w", err)
+	}
+	if err == nil && existing.IsEnabled() {
+		return nil, fmt.Errorf("two-factor authentication is already enabled")
+	}
+
+	// Starting over replaces any enrolment that was never confirmed
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		uc.logger.Error("Failed to generate TOTP secret", "error", err, "user_id", u.ID)
+		return nil, fmt.Errorf("failed to set up two-factor authentication: %w", err)
+	}
+
+	if err := uc.twoFactorRepo.Save(ctx, user.NewTwoFactor(u.ID, secret)); err != nil {
+		return nil, fmt.Errorf("failed to set up two-factor authentication: %w", err)
+	}
+
+	uc.logger.Info("Two-factor enrolment started", "user_id", u.ID)
+
+	return &SetupTwoFactorOutput{
+		Secret:     secret,
+		OTPAuthURI: totp.URI(totpIssuer, u.Email, secret),
+	}, nil
+}
+
+func (uc *useCase) ConfirmTwoFactor(ctx context.Context, input ConfirmTwoFactorInput) (*ConfirmTwoFactorOutput, error) {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid confirm two-factor input", "error", err)
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	twoFactor, err := uc.twoFactorRepo.Get(ctx, input.UserID)
+	if err != nil {
+		if err == user.ErrTwoFactorNotFound {
+			return nil, user.ErrTwoFactorNotFound
+		}
+		return nil, fmt.Errorf("failed to confirm two-factor authentication: %w", err)
+	}
+	if twoFactor.IsEnabled() {
+		return nil, fmt.Errorf("two-factor authentication is already enabled")
+	}
+
+	// Only a TOTP code proves the authenticator app was set up correctly
+	step, ok := totp.Validate(twoFactor.Secret, input.Code, time.Now())
+	if !ok {
+		return nil, errInvalidTwoFactorCode
+	}
+	if _, err := uc.twoFactorRepo.UseStep(ctx, twoFactor.UserID, step); err != nil {
+		return nil, fmt.Errorf("failed to confirm two-factor authentication: %w", err)
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		uc.logger.Error("Failed to generate recovery codes", "error", err, "user_id", input.UserID)
+		return nil, fmt.Errorf("failed to confirm two-factor authentication: %w", err)
+	}
+
+	if err := uc.twoFactorRepo.Enable(ctx, twoFactor.UserID, hashes); err != nil {
+		return nil, fmt.Errorf("failed to confirm two-factor authentication: %w", err)
+	}
+
+	uc.logger.Info("Two-factor authentication confirmed", "user_id", input.UserID)
+
+	return &ConfirmTwoFactorOutput{
+		RecoveryCodes: codes,
+	}, nil
+}
+
+func (uc *useCase) DisableTwoFactor(ctx context.Context, input DisableTwoFactorInput) error {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid disable two-factor input", "error", err)
+		return fmt.Errorf("invalid input: %w", err)
+	}
+
+	twoFactor, err := uc.twoFactorRepo.Get(ctx, input.UserID)
+	if err != nil && err != user.ErrTwoFactorNotFound {
+		return fmt.Errorf("failed to disable two-factor authentication: %w", err)
+	}
+	if err == user.ErrTwoFactorNotFound || !twoFactor.IsEnabled() {
+		return fmt.Errorf("two-factor authentication is not enabled")
+	}
+
+	// A stolen access token alone must not be enough to turn 2FA off, so
+	// guesses count against the same lockout as codes entered at login
+	attemptKey := twoFactorAttemptKey(input.UserID)
+	if err := uc.checkAttempts(ctx, uc.loginProtection.Accounts, attemptKey); err != nil {
+		return err
+	}
+
+	if err := uc.verifySecondFactor(ctx, twoFactor, input.Code); err != nil {
+		uc.logger.Warn("Invalid code when disabling two-factor authentication", "user_id", input.UserID)
+		if err == errInvalidTwoFactorCode {
+			uc.recordFailedAttempt(ctx, uc.loginProtection.Accounts, attemptKey)
+		}
+		return err
+	}
+
+	uc.resetAttempts(ctx, uc.loginProtection.Accounts, attemptKey)
+
+	if err := uc.twoFactorRepo.Delete(ctx, input.UserID); err != nil {
+		return fmt.Errorf("failed to disable two-factor authentication: %w", err)
+	}
+
+	return nil
+}
+
+func (uc *useCase) VerifyTwoFactorLogin(ctx context.Context, input VerifyTwoFactorLoginInput) (*LoginOutput, error) {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid two-factor login input", "error", err)
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	userID, err := uc.jwtSvc.ValidateChallengeToken(input.ChallengeToken)
+	if err != nil {
+		uc.logger.Warn("Two-factor login with invalid challenge token", "error", err)
+		return nil, fmt.Errorf("invalid or expired challenge")
+	}
+
+	u, err := uc.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if err == user.ErrUserNotFound {
+			return nil, fmt.Errorf("invalid or expired challenge")
+		}
+		uc.logger.Error("Failed to get user for two-factor login", "error", err, "user_id", userID)
+		return nil, fmt.Errorf("failed to authenticate: %w", err)
+	}
+
+	if !u.IsActive {
+		return nil, fmt.Errorf("account is deactivated")
+	}
+
+	twoFactor, err := uc.twoFactorRepo.Get(ctx, u.ID)
+	if err != nil && err != user.ErrTwoFactorNotFound {
+		return nil, fmt.Errorf("failed to authenticate: %w", err)
+	}
+	if err == user.ErrTwoFactorNotFound || !twoFactor.IsEnabled() {
+		return nil, fmt.Errorf("invalid or expired challenge")
+	}
+
+	// Codes are short, so failures lock the account like failed passwords do
+	attemptKey := twoFactorAttemptKey(u.ID)
+	if err := uc.checkAttempts(ctx, uc.loginProtection.Accounts, attemptKey); err != nil {
+		return nil, err
+	}
+
+	if err := uc.verifySecondFactor(ctx, twoFactor, input.Code); err != nil {
+		uc.logger.Warn("Two-factor login with invalid code", "user_id", u.ID)
+		if err == errInvalidTwoFactorCode {
+			uc.recordFailedAttempt(ctx, uc.loginProtection.Accounts, attemptKey)
+		}
+		return nil, err
+	}
+
+	uc.resetAttempts(ctx, uc.loginProtection.Accounts, attemptKey)
+
+	// Each challenge completes at most one login
+	if err := uc.jwtSvc.BlacklistToken(input.ChallengeToken); err != nil {
+		uc.logger.Error("Failed to revoke challenge token", "error", err, "user_id", u.ID)
+		return nil, fmt.Errorf("failed to authenticate: %w", err)
+	}
+
+	if err := uc.userRepo.UpdateLastSeen(ctx, u.ID); err != nil {
+		uc.logger.Error("Failed to update last seen", "error", err, "user_id", u.ID)
+		// Don't fail login for this error
+	}
+
+	creds, err := uc.startSession(ctx, u, input.Device)
+	if err != nil {
+		return nil, err
+	}
+
+	uc.logger.Info("User logged in with two-factor authentication", "user_id", u.ID, "session_id", creds.sessionID)
+
+	return &LoginOutput{
+		User:             ToUserOutput(u),
+		SessionID:        creds.sessionID,
+		Token:            creds.token,
+		ExpiresAt:        creds.expiresAt,
+		RefreshToken:     creds.refreshToken,
+		RefreshExpiresAt: creds.refreshExpiresAt,
+	}, nil
+}
+
+// verifySecondFactor accepts a current TOTP code that has not been used yet,
+// or else an unused recovery code, which is consumed
+func (uc *useCase) verifySecondFactor(ctx context.Context, twoFactor *user.TwoFactor, code string) error {
+	if step, ok := totp.Validate(twoFactor.Secret, code, time.Now()); ok {
+		accepted, err := uc.twoFactorRepo.UseStep(ctx, twoFactor.UserID, step)
+		if err != nil {
+			return fmt.Errorf("failed to verify code: %w", err)
+		}
+		if !accepted {
+			return errInvalidTwoFactorCode
+		}
+		return nil
+	}
+
+	used, err := uc.twoFactorRepo.UseRecoveryCode(ctx, twoFactor.UserID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return fmt.Errorf("failed to verify code: %w", err)
+	}
+	if !used {
+		return errInvalidTwoFactorCode
+	}
+
+	uc.logger.Info("Recovery code used", "user_id", twoFactor.UserID)
+	return nil
+}
+
+func (uc *useCase) StartOIDCLogin(ctx context.Context, input StartOIDCLoginInput) (*StartOIDCLoginOutput, error) {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid start OIDC login input", "error", err)
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	if uc.oidc.Provider == nil {
+		return nil, errOIDCDisabled
+	}
+
+	state, err := oidc.NewState()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start single sign-on: %w", err)
+	}
+	nonce, err := oidc.NewState()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start single sign-on: %w", err)
+	}
+	codeVerifier, err := oidc.NewCodeVerifier()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start single sign-on: %w", err)
+	}
+
+	authorizationURL, err := uc.oidc.Provider.AuthCodeURL(ctx, state, nonce, codeVerifier)
+	if err != nil {
+		uc.logger.Error("Failed to build OIDC authorization URL", "error", err)
+		return nil, fmt.Errorf("failed to start single sign-on: %w", err)
+	}
+
+	login := oidc.LoginState{
+		CodeVerifier: codeVerifier,
+		Nonce:        nonce,
+		DeviceName:   input.DeviceName,
+	}
+	if err := uc.oidc.States.Save(ctx, state, login, oidc.StateTTL); err != nil {
+		uc.logger.Error("Failed to save OIDC login state", "error", err)
+		return nil, fmt.Errorf("failed to start single sign-on: %w", err)
+	}
+
+	return &StartOIDCLoginOutput{
+		AuthorizationURL: authorizationURL,
+		State:            state,
+		ExpiresAt:        time.Now().Add(oidc.StateTTL),
+	}, nil
+}
+
+// CompleteOIDCLogin signs a user in with the authorization code returned by
+// the identity provider. Local two-factor authentication is not applied; the
+// identity provider is responsible for multi-factor authentication.
+func (uc *useCase) CompleteOIDCLogin(ctx context.Context, input CompleteOIDCLoginInput) (*LoginOutput, error) {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid complete OIDC login input", "error", err)
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	if uc.oidc.Provider == nil {
+		return nil, errOIDCDisabled
+	}
+
+	// The state is single-use, so a replayed callback fails here
+	login, err := uc.oidc.States.Take(ctx, input.State)
+	if err != nil {
+		if err == oidc.ErrStateNotFound {
+			uc.logger.Warn("OIDC callback with unknown state")
+			return nil, oidc.ErrStateNotFound
+		}
+		return nil, fmt.Errorf("failed to complete single sign-on: %w", err)
+	}
+
+	identity, err := uc.oidc.Provider.Exchange(ctx, input.Code, login.CodeVerifier, login.Nonce)
+	if err != nil {
+		uc.logger.Warn("OIDC code exchange failed", "error", err)
+		return nil, fmt.Errorf("failed to verify identity: %w", err)
+	}
+
+	if !uc.emailDomainAllowed(identity) {
+		uc.logger.Warn("OIDC login from disallowed email domain", "email", identity.Email, "email_verified", identity.EmailVerified, "subject", identity.Subject)
+		return nil, fmt.Errorf("email domain is not allowed")
+	}
+
+	u, err := uc.externalUser(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	if !u.IsActive {
+		return nil, fmt.Errorf("account is deactivated")
+	}
+
+	if err := uc.userRepo.UpdateLastSeen(ctx, u.ID); err != nil {
+		uc.logger.Error("Failed to update last seen", "error", err, "user_id", u.ID)
+		// Don't fail login for this error
+	}
+
+	device := input.Device
+	if device.DeviceName == "" {
+		device.DeviceName = login.DeviceName
+	}
+
+	creds, err := uc.startSession(ctx, u, device)
+	if err != nil {
+		return nil, err
+	}
+
+	uc.logger.Info("User logged in with single sign-on", "user_id", u.ID, "session_id", creds.sessionID)
+
+	return &LoginOutput{
+		User:             ToUserOutput(u),
+		SessionID:        creds.sessionID,
+		Token:            creds.token,
+		ExpiresAt:        creds.expiresAt,
+		RefreshToken:     creds.refreshToken,
+		RefreshExpiresAt: creds.refreshExpiresAt,
+	}, nil
+}
+
+// externalUser returns the user linked to an external identity. On first login
+// the identity is linked to the account with the same verified email, or to a
+// newly provisioned account when auto-provisioning is enabled.
+func (uc *useCase) externalUser(ctx context.Context, identity *oidc.Identity) (*user.User, error) {
+	link, err := uc.identityRepo.GetBySubject(ctx, identity.Issuer, identity.Subject)
+	if err == nil {
+		if err := uc.identityRepo.Touch(ctx, link.ID, identity.Email); err != nil {
+			uc.logger.Error("Failed to update external identity", "error", err, "user_id", link.UserID)
+		}
+
+		u, err := uc.userRepo.GetByID(ctx, link.UserID)
+		if err != nil {
+			uc.logger.Error("Failed to get user for external identity", "error", err, "user_id", link.UserID)
+			return nil, fmt.Errorf("failed to authenticate: %w", err)
+		}
+		return u, nil
+	}
+	if err != user.ErrExternalIdentityNotFound {
+		return nil, fmt.Errorf("failed to authenticate: %w", err)
+	}
+
+	if identity.Email == "" {
+		return nil, fmt.Errorf("identity provider did not share an email address")
+	}
+
+	u, err := uc.userRepo.GetByEmail(ctx, identity.Email)
+	switch {
+	case err == nil:
+		// Only a provider-verified address proves the person owns the existing account
+		if !identity.EmailVerified {
+			uc.logger.Warn("Refusing to link unverified external email", "user_id", u.ID)
+			return nil, fmt.Errorf("email address is not verified by the identity provider")
+		}
+		// An account whose address was never confirmed may have been registered
+		// by someone else, who would keep signing in with its password
+		if !u.IsEmailVerified() {
+			uc.logger.Warn("Refusing to link external identity to unverified account", "user_id", u.ID)
+			return nil, fmt.Errorf("the existing account must verify its email address before single sign-on")
+		}
+	case err == user.ErrUserNotFound:
+		if !uc.oidc.AutoProvision {
+			return nil, fmt.Errorf("no account exists for this identity")
+		}
+		u, err = uc.provisionExternalUser(ctx, identity)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		uc.logger.Error("Failed to get user by email", "error", err, "email", identity.Email)
+		return nil, fmt.Errorf("failed to authenticate: %w", err)
+	}
+
+	link = user.NewExternalIdentity(uuid.New().String(), u.ID, identity.Issuer, identity.Subject, identity.Email)
+	now := time.Now()
+	link.LastLoginAt = &now
+
+	if err := uc.identityRepo.Create(ctx, link); err != nil {
+		return nil, fmt.Errorf("failed to link identity: %w", err)
+	}
+
+	uc.logger.Info("External identity linked", "user_id", u.ID, "issuer", identity.Issuer)
+	return u, nil
+}
+
+// provisionExternalUser creates an account for a first-time single sign-on user
+func (uc *useCase) provisionExternalUser(ctx context.Context, identity *oidc.Identity) (*user.User, error) {
+	username, err := uc.availableUsername(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	// The account has no password; an empty hash never matches at password login
+	newUser := user.NewUser(uuid.New().String(), username, identity.Email, "")
+	if identity.EmailVerified {
+		newUser.VerifyEmail()
+	}
+
+	if err := uc.userRepo.Create(ctx, newUser); err != nil {
+		uc.logger.Error("Failed to create user", "error", err, "email", identity.Email)
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	uc.logger.Info("User provisioned from single sign-on", "user_id", newUser.ID, "email", identity.Email)
+	return newUser, nil
+}
+
+// availableUsername derives a free username from the identity's preferred
+// username or email, adding a random suffix when it is taken
+func (uc *useCase) availableUsername(ctx context.Context, identity *oidc.Identity) (string, error) {
+	name := identity.PreferredUsername
+	if name == "" {
+		name = identity.Email
+	}
+	base := sanitizeUsername(name)
+
+	candidate := base
+	for attempt := 0; attempt < 5; attempt++ {
+		exists, err := uc.userRepo.ExistsByUsername(ctx, candidate)
+		if err != nil {
+			uc.logger.Error("Failed to check if user exists by username", "error", err, "username", candidate)
+			return "", fmt.Errorf("failed to check username availability: %w", err)
+		}
+		if !exists {
+			return candidate, nil
+		}
+
+		suffix := make([]byte, 2)
+		if _, err := rand.Read(suffix); err != nil {
+			return "", fmt.Errorf("failed to generate username: %w", err)
+		}
+		candidate = base + "-" + hex.EncodeToString(suffix)
+	}
+
+	return "", fmt.Errorf("failed to find an available username")
+}
+
+// emailDomainAllowed checks the verified email of an identity against the
+// configured single sign-on domains
+func (uc *useCase) emailDomainAllowed(identity *oidc.Identity) bool {
+	if len(uc.oidc.AllowedDomains) == 0 {
+		return true
+	}
+
+	// Anyone can put an address at any domain on their account with some
+	// providers, so only a provider-verified address counts
+	if !identity.EmailVerified {
+		return false
+	}
+
+	email := identity.Email
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[at+1:])
+
+	for _, allowed := range uc.oidc.AllowedDomains {
+		if domain == strings.ToLower(strings.TrimPrefix(strings.TrimSpace(allowed), "@")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (uc *useCase) UnlockLogin(ctx context.Context, input UnlockLoginInput) error {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid unlock login input", "error", err)
+		return fmt.Errorf("invalid input: %w", err)
+	}
+
+	if input.Email == "" && input.IPAddress == "" {
+		return fmt.Errorf("invalid input: email or IP address is required")
+	}
+
+	admin, err := uc.userRepo.GetByID(ctx, input.AdminID)
+	if err != nil {
+		if err == user.ErrUserNotFound {
+			return ErrAdminRequired
+		}
+		return fmt.Errorf("failed to unlock login: %w", err)
+	}
+	if !admin.IsAdmin || !admin.IsActive {
+		uc.logger.Warn("Non-admin attempted to unlock login", "user_id", input.AdminID)
+		return ErrAdminRequired
+	}
+
+	if input.Email != "" {
+		keys := []string{loginAttemptKey(input.Email)}
+
+		u, err := uc.userRepo.GetByEmail(ctx, strings.TrimSpace(input.Email))
+		if err != nil && err != user.ErrUserNotFound {
+			return fmt.Errorf("failed to unlock login: %w", err)
+		}
+		if err == nil {
+			keys = append(keys, twoFactorAttemptKey(u.ID), changePasswordAttemptKey(u.ID))
+		}
+
+		for _, key := range keys {
+			if err := uc.loginProtection.Accounts.Reset(ctx, key); err != nil {
+				return fmt.Errorf("failed to unlock login: %w", err)
+			}
+		}
+	}
+
+	if input.IPAddress != "" {
+		if err := uc.loginProtection.IPs.Reset(ctx, input.IPAddress); err != nil {
+			return fmt.Errorf("failed to unlock login: %w", err)
+		}
+	}
+
+	uc.logger.Info("Login lockout lifted", "admin_id", admin.ID, "email", input.Email, "ip_address", input.IPAddress)
+	return nil
+}
+
+// checkAttempts rejects an attempt while the key is blocked. Store failures
+// are logged and let the attempt through, so a Redis outage doesn't stop all logins.
+func (uc *useCase) checkAttempts(ctx context.Context, limiter *throttle.Limiter, key string) error {
+	if key == "" {
+		return nil
+	}
+
+	err := limiter.Check(ctx, key)
+	var blocked *throttle.BlockedError
+	if errors.As(err, &blocked) {
+		uc.logger.Warn("Attempt rejected while blocked", "key", key, "retry_after", blocked.RetryAfter)
+		return err
+	}
+	if err != nil {
+		uc.logger.Error("Failed to check failed attempts", "error", err, "key", key)
+	}
+
+	return nil
+}
+
+// recordFailedAttempt counts a failure against the key, blocking it as the policy requires
+func (uc *useCase) recordFailedAttempt(ctx context.Context, limiter *throttle.Limiter, key string) {
+	if key == "" {
+		return
+	}
+
+	blockedFor, err := limiter.Fail(ctx, key)
+	if err != nil {
+		uc.logger.Error("Failed to record failed attempt", "error", err, "key", key)
+		return
+	}
+	if blockedFor > 0 {
+		uc.logger.Warn("Attempts blocked after repeated failures", "key", key, "blocked_for", blockedFor)
+	}
+}
+
+// recordFailedLogin counts a failed password login against the account and the client IP
+func (uc *useCase) recordFailedLogin(ctx context.Context, accountKey, clientIP string) {
+	uc.recordFailedAttempt(ctx, uc.loginProtection.Accounts, accountKey)
+	uc.recordFailedAttempt(ctx, uc.loginProtection.IPs, clientIP)
+}
+
+func (uc *useCase) resetAttempts(ctx context.Context, limiter *throttle.Limiter, key string) {
+	if err := limiter.Reset(ctx, key); err != nil {
+		uc.logger.Error("Failed to reset failed attempts", "error", err, "key", key)
+	}
+}
+
+// checkNewPassword applies the password policy and the breached-password list
+// to a password a user is about to set
+func (uc *useCase) checkNewPassword(ctx context.Context, newPassword, username, email string) error {
+	localPart, _, _ := strings.Cut(email, "@")
+	if err := uc.passwords.Policy.Validate(newPassword, username, email, localPart); err != nil {
+		return err
+	}
+
+	if uc.passwords.Breached == nil {
+		return nil
+	}
+
+	breached, err := uc.passwords.Breached.IsBreached(ctx, newPassword)
+	if err != nil {
+		// An unreadable list must not stop users from setting passwords
+		uc.logger.Error("Failed to check breached passwords", "error", err)
+		return nil
+	}
+	if breached {
+		return password.ErrBreached
+	}
+
+	return nil
+}
+
+// rehashPassword stores a new hash of a verified password at the current cost.
+// Failures are logged only, since the old hash still works.
+func (uc *useCase) rehashPassword(ctx context.Context, u *user.User, plainPassword string) {
+	hashedPassword, err := uc.passwords.Hasher.Hash(plainPassword)
+	if err != nil {
+		uc.logger.Error("Failed to rehash password", "error", err, "user_id", u.ID)
+		return
+	}
+
+	u.ChangePassword(hashedPassword)
+	if err := uc.userRepo.Update(ctx, u); err != nil {
+		uc.logger.Error("Failed to store rehashed password", "error", err, "user_id", u.ID)
+		return
+	}
+
+	uc.logger.Info("Password rehashed", "user_id", u.ID)
+}
+
+// sessionCredentials holds the tokens handed out when a session starts
+type sessionCredentials struct {
+	sessionID        string
+	token            string
+	expiresAt        time.Time
+	refreshToken     string
+	refreshExpiresAt time.Time
+}
+
+// startSession records a new session for the device and issues its first
+// access and refresh tokens. The session ID doubles as the refresh token family.
+func (uc *useCase) startSession(ctx context.Context, u *user.User, device DeviceInfo) (*sessionCredentials, error) {
+	sessionID := uuid.New().String()
+
+	refreshToken, refreshTokenValue, err := uc.newRefreshToken(u.ID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	session := user.NewSession(sessionID, u.ID, device.DeviceName, device.UserAgent, device.IPAddress, refreshToken.ExpiresAt)
+	if err := uc.sessionRepo.Create(ctx, session); err != nil {
+		uc.logger.Error("Failed to create session", "error", err, "user_id", u.ID)
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	if err := uc.refreshTokenRepo.Create(ctx, refreshToken); err != nil {
+		uc.logger.Error("Failed to store refresh token", "error", err, "user_id", u.ID)
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	token, expiresAt, err := uc.jwtSvc.GenerateToken(u.ID, u.Email, sessionID)
+	if err != nil {
+		uc.logger.Error("Failed to generate token", "error", err, "user_id", u.ID)
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	return &sessionCredentials{
+		sessionID:        sessionID,
+		token:            token,
+		expiresAt:        expiresAt,
+		refreshToken:     refreshTokenValue,
+		refreshExpiresAt: refreshToken.ExpiresAt,
+	}, nil
+}
+
+// revokeSession marks a session as revoked and invalidates its tokens
+func (uc *useCase) revokeSession(ctx context.Context, sessionID string) error {
+	if err := uc.sessionRepo.Revoke(ctx, sessionID); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	return uc.revokeSessionTokens(ctx, sessionID)
+}
+
+// revokeSessionTokens revokes the refresh token family of a session and
+// rejects its outstanding access tokens
+func (uc *useCase) revokeSessionTokens(ctx context.Context, sessionID string) error {
+	if err := uc.refreshTokenRepo.RevokeFamily(ctx, sessionID); err != nil {
+		return fmt.Errorf("failed to revoke refresh token: %w", err)
+	}
+
+	if err := uc.jwtSvc.RevokeSession(sessionID); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	return nil
+}
+
+// newRefreshToken creates a refresh token in the given family, returning the
+// entity to store and the opaque value to hand to the client
+func (uc *useCase) newRefreshToken(userID, familyID string) (*user.RefreshToken, string, error) {
+	value, err := newOpaqueToken()
+	if err != nil {
+		uc.logger.Error("Failed to generate refresh token", "error", err, "user_id", userID)
+		return nil, "", fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	token := user.NewRefreshToken(uuid.New().String(), userID, familyID, hashToken(value), uc.refreshTokenTTL)
+	return token, value, nil
+}
+
+// revokeReusedFamily ends the session of a reused refresh token, revoking every token in its family
+func (uc *useCase) revokeReusedFamily(ctx context.Context, token *user.RefreshToken) {
+	uc.logger.Warn("Refresh token reuse detected, revoking token family", "user_id", token.UserID, "family_id", token.FamilyID)
+	if err := uc.revokeSession(ctx, token.FamilyID); err != nil {
+		uc.logger.Error("Failed to revoke refresh token family", "error", err, "family_id", token.FamilyID)
+	}
+}
+
+// newOpaqueToken returns a random URL-safe token value
+func newOpaqueToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// newRecoveryCodes returns a fresh set of recovery codes along with their stored hashes
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, user.RecoveryCodeCount)
+	hashes := make([]string, 0, user.RecoveryCodeCount)
+
+	for i := 0; i < user.RecoveryCodeCount; i++ {
+		buf := make([]byte, 6)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+
+		raw := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf))
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+		hashes = append(hashes, hashToken(raw))
+	}
+
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode accepts recovery codes regardless of case, spacing or dashes
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// sanitizeUsername turns a preferred username or email into a valid username
+func sanitizeUsername(name string) string {
+	if at := strings.Index(name, "@"); at >= 0 {
+		name = name[:at]
+	}
+
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '.' || r == '_' || r == '-' {
+			b.WriteRune(r)
+		}
+	}
+
+	// Leave room for a suffix within the 50 character limit
+	username := b.String()
+	if len(username) > 40 {
+		username = username[:40]
+	}
+	if len(username) < 3 {
+		username = "user-" + username
+	}
+
+	return username
+}
+
+// loginAttemptKey keys failed logins by email, whether or not an account has it
+func loginAttemptKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// twoFactorAttemptKey keys failed two-factor codes apart from failed passwords
+func twoFactorAttemptKey(userID string) string {
+	return "2fa:" + userID
+}
+
+// changePasswordAttemptKey keys wrong current passwords at a password change
+func changePasswordAttemptKey(userID string) string {
+	return "password:" + userID
+}
+
+// hashToken returns the stored form of an opaque refresh or account token
+func hashToken(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
 }
\ No newline at end of file
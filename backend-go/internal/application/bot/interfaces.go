@@ -0,0 +1,151 @@
+package bot
+
+import (
+	"context"
+	"time"
+
+	"backend-go/internal/domain/user"
+)
+
+// MaxBotsPerOwner limits how many active bots one user may manage
+const MaxBotsPerOwner = 10
+
+// UseCase defines the interface for bot and API key use cases
+type UseCase interface {
+	CreateBot(ctx context.Context, input CreateBotInput) (*BotOutput, error)
+	ListBots(ctx context.Context, input ListBotsInput) (*ListBotsOutput, error)
+	DeleteBot(ctx context.Context, input DeleteBotInput) error
+	CreateAPIKey(ctx context.Context, input CreateAPIKeyInput) (*CreateAPIKeyOutput, error)
+	ListAPIKeys(ctx context.Context, input ListAPIKeysInput) (*ListAPIKeysOutput, error)
+	RotateAPIKey(ctx context.Context, input RotateAPIKeyInput) (*CreateAPIKeyOutput, error)
+	RevokeAPIKey(ctx context.Context, input RevokeAPIKeyInput) error
+	AuthenticateAPIKey(ctx context.Context, key string) (*APIKeyPrincipal, error)
+}
+
+// CreateBotInput represents the input for creating a bot
+type CreateBotInput struct {
+	OwnerID  string `json:"owner_id" validate:"required"`
+	Username string `json:"username" validate:"required,min=3,max=50"`
+}
+
+// BotOutput represents a bot
+type BotOutput struct {
+	ID        string    `json:"id"`
+	Username  string    `json:"username"`
+	OwnerID   string    `json:"owner_id"`
+	IsActive  bool      `json:"is_active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ListBotsInput represents the input for listing a user's bots
+type ListBotsInput struct {
+	OwnerID string `json:"owner_id" validate:"required"`
+}
+
+// ListBotsOutput represents the output for listing a user's bots
+type ListBotsOutput struct {
+	Bots []*BotOutput `json:"bots"`
+}
+
+// DeleteBotInput represents the input for deleting a bot
+type DeleteBotInput struct {
+	OwnerID string `json:"owner_id" validate:"required"`
+	BotID   string `json:"bot_id" validate:"required"`
+}
+
+// CreateAPIKeyInput represents the input for issuing an API key to a bot.
+// Without room IDs the key works in every room the bot is a member of.
+type CreateAPIKeyInput struct {
+	OwnerID       string   `json:"owner_id" validate:"required"`
+	BotID         string   `json:"bot_id" validate:"required"`
+	Name          string   `json:"name" validate:"required,min=1,max=100"`
+	Scopes        []string `json:"scopes" validate:"required,min=1,dive,oneof=rooms:read messages:read messages:write"`
+	RoomIDs       []string `json:"room_ids,omitempty" validate:"max=50,dive,required"`
+	ExpiresInDays int      `json:"expires_in_days,omitempty" validate:"min=0,max=365"` // Zero never expires
+}
+
+// CreateAPIKeyOutput carries a new API key, which is only ever shown once
+type CreateAPIKeyOutput struct {
+	Key    string        `json:"key"`
+	APIKey *APIKeyOutput `json:"api_key"`
+}
+
+// APIKeyOutput represents an API key without its secret
+type APIKeyOutput struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	RoomIDs    []string   `json:"room_ids"`
+	Active     bool       `json:"active"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// ListAPIKeysInput represents the input for listing a bot's API keys
+type ListAPIKeysInput struct {
+	OwnerID string `json:"owner_id" validate:"required"`
+	BotID   string `json:"bot_id" validate:"required"`
+}
+
+// ListAPIKeysOutput represents the output for listing a bot's API keys
+type ListAPIKeysOutput struct {
+	APIKeys []*APIKeyOutput `json:"api_keys"`
+}
+
+// RotateAPIKeyInput represents the input for replacing an API key with a new
+// one. The old key keeps working for the grace period, so scripts can be
+// switched over without downtime.
+type RotateAPIKeyInput struct {
+	OwnerID            string `json:"owner_id" validate:"required"`
+	BotID              string `json:"bot_id" validate:"required"`
+	KeyID              string `json:"key_id" validate:"required"`
+	GracePeriodSeconds int    `json:"grace_period_seconds" validate:"min=0,max=604800"`
+}
+
+// RevokeAPIKeyInput represents the input for revoking an API key
+type RevokeAPIKeyInput struct {
+	OwnerID string `json:"owner_id" validate:"required"`
+	BotID   string `json:"bot_id" validate:"required"`
+	KeyID   string `json:"key_id" validate:"required"`
+}
+
+// APIKeyPrincipal is the bot an API key authenticates, and what the key allows
+type APIKeyPrincipal struct {
+	UserID  string
+	KeyID   string
+	Scopes  []string
+	RoomIDs []string
+}
+
+// ToBotOutput converts a bot user to its output
+func ToBotOutput(u *user.User) *BotOutput {
+	output := &BotOutput{
+		ID:        u.ID,
+		Username:  u.Username,
+		IsActive:  u.IsActive,
+		CreatedAt: u.CreatedAt,
+	}
+	if u.OwnerID != nil {
+		output.OwnerID = *u.OwnerID
+	}
+	return output
+}
+
+// ToAPIKeyOutput converts an API key to its output
+func ToAPIKeyOutput(k *user.APIKey) *APIKeyOutput {
+	return &APIKeyOutput{
+		ID:         k.ID,
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		Scopes:     k.Scopes,
+		RoomIDs:    k.RoomIDs,
+		Active:     k.IsActive(),
+		CreatedAt:  k.CreatedAt,
+		ExpiresAt:  k.ExpiresAt,
+		LastUsedAt: k.LastUsedAt,
+		RevokedAt:  k.RevokedAt,
+	}
+}
\ No newline at end of file
@@ -0,0 +1,395 @@
+package bot
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"backend-go/internal/domain/user"
+	"backend-go/internal/shared/logger"
+	"backend-go/internal/shared/validation"
+)
+
+// apiKeyTouchInterval limits how often a key's last use is written, since
+// bots may call the API many times a second
+const apiKeyTouchInterval = time.Minute
+
+type useCase struct {
+	userRepo   user.Repository
+	apiKeyRepo user.APIKeyRepository
+	validator  validation.Validator
+	logger     logger.Logger
+}
+
+// NewUseCase creates a new bot use case
+func NewUseCase(
+	userRepo user.Repository,
+	apiKeyRepo user.APIKeyRepository,
+	validator validation.Validator,
+	logger logger.Logger,
+) UseCase {
+	return &useCase{
+		userRepo:   userRepo,
+		apiKeyRepo: apiKeyRepo,
+		validator:  validator,
+		logger:     logger,
+	}
+}
+
+func (uc *useCase) CreateBot(ctx context.Context, input CreateBotInput) (*BotOutput, error) {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid create bot input", "error", err)
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	owner, err := uc.userRepo.GetByID(ctx, input.OwnerID)
+	if err != nil {
+		if err == user.ErrUserNotFound {
+			return nil, fmt.Errorf("owner not found")
+		}
+		uc.logger.Error("Failed to get owner", "error", err, "user_id", input.OwnerID)
+		return nil, fmt.Errorf("failed to create bot: %w", err)
+	}
+	if !owner.IsActive || owner.IsBot {
+		return nil, fmt.Errorf("only active human accounts can own bots")
+	}
+
+	bots, err := uc.userRepo.ListBotsByOwner(ctx, owner.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bot: %w", err)
+	}
+	active := 0
+	for _, b := range bots {
+		if b.IsActive {
+			active++
+		}
+	}
+	if active >= MaxBotsPerOwner {
+		return nil, fmt.Errorf("bot limit reached: at most %d bots per user", MaxBotsPerOwner)
+	}
+
+	exists, err := uc.userRepo.ExistsByUsername(ctx, input.Username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check username availability: %w", err)
+	}
+	if exists {
+		return nil, fmt.Errorf("username already taken")
+	}
+
+	newBot := user.NewBot(uuid.New().String(), input.Username, owner.ID)
+	if err := uc.userRepo.Create(ctx, newBot); err != nil {
+		uc.logger.Error("Failed to create bot", "error", err, "owner_id", owner.ID)
+		return nil, fmt.Errorf("failed to create bot: %w", err)
+	}
+
+	uc.logger.Info("Bot created", "bot_id", newBot.ID, "owner_id", owner.ID)
+	return ToBotOutput(newBot), nil
+}
+
+func (uc *useCase) ListBots(ctx context.Context, input ListBotsInput) (*ListBotsOutput, error) {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid list bots input", "error", err)
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	bots, err := uc.userRepo.ListBotsByOwner(ctx, input.OwnerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bots: %w", err)
+	}
+
+	output := &ListBotsOutput{Bots: make([]*BotOutput, 0, len(bots))}
+	for _, b := range bots {
+		output.Bots = append(output.Bots, ToBotOutput(b))
+	}
+
+	return output, nil
+}
+
+func (uc *useCase) DeleteBot(ctx context.Context, input DeleteBotInput) error {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid delete bot input", "error", err)
+		return fmt.Errorf("invalid input: %w", err)
+	}
+
+	b, err := uc.ownedBot(ctx, input.OwnerID, input.BotID)
+	if err != nil {
+		return err
+	}
+
+	// The bot is deactivated rather than deleted so its messages keep their sender
+	if err := uc.apiKeyRepo.RevokeAllForUser(ctx, b.ID); err != nil {
+		return fmt.Errorf("failed to delete bot: %w", err)
+	}
+
+	if b.IsActive {
+		b.Deactivate()
+		if err := uc.userRepo.Update(ctx, b); err != nil {
+			uc.logger.Error("Failed to deactivate bot", "error", err, "bot_id", b.ID)
+			return fmt.Errorf("failed to delete bot: %w", err)
+		}
+	}
+
+	uc.logger.Info("Bot deleted", "bot_id", b.ID, "owner_id", input.OwnerID)
+	return nil
+}
+
+func (uc *useCase) CreateAPIKey(ctx context.Context, input CreateAPIKeyInput) (*CreateAPIKeyOutput, error) {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid create API key input", "error", err)
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	b, err := uc.ownedBot(ctx, input.OwnerID, input.BotID)
+	if err != nil {
+		return nil, err
+	}
+	if !b.IsActive {
+		return nil, fmt.Errorf("bot is deactivated")
+	}
+
+	var expiresAt *time.Time
+	if input.ExpiresInDays > 0 {
+		t := time.Now().AddDate(0, 0, input.ExpiresInDays)
+		expiresAt = &t
+	}
+
+	return uc.issueAPIKey(ctx, b.ID, input.Name, dedupe(input.Scopes), dedupe(input.RoomIDs), expiresAt)
+}
+
+func (uc *useCase) ListAPIKeys(ctx context.Context, input ListAPIKeysInput) (*ListAPIKeysOutput, error) {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid list API keys input", "error", err)
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	b, err := uc.ownedBot(ctx, input.OwnerID, input.BotID)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := uc.apiKeyRepo.ListByUser(ctx, b.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+
+	output := &ListAPIKeysOutput{APIKeys: make([]*APIKeyOutput, 0, len(keys))}
+	for _, k := range keys {
+		output.APIKeys = append(output.APIKeys, ToAPIKeyOutput(k))
+	}
+
+	return output, nil
+}
+
+func (uc *useCase) RotateAPIKey(ctx context.Context, input RotateAPIKeyInput) (*CreateAPIKeyOutput, error) {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid rotate API key input", "error", err)
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	b, err := uc.ownedBot(ctx, input.OwnerID, input.BotID)
+	if err != nil {
+		return nil, err
+	}
+	if !b.IsActive {
+		return nil, fmt.Errorf("bot is deactivated")
+	}
+
+	old, err := uc.botAPIKey(ctx, b.ID, input.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if !old.IsActive() {
+		return nil, fmt.Errorf("API key is no longer active")
+	}
+
+	// The new key gets the same grants and, if the old one expires, the same lifetime
+	var expiresAt *time.Time
+	if old.ExpiresAt != nil {
+		t := time.Now().Add(old.ExpiresAt.Sub(old.CreatedAt))
+		expiresAt = &t
+	}
+
+	output, err := uc.issueAPIKey(ctx, b.ID, old.Name, old.Scopes, old.RoomIDs, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.GracePeriodSeconds == 0 {
+		err = uc.apiKeyRepo.Revoke(ctx, old.ID)
+	} else {
+		err = uc.apiKeyRepo.Expire(ctx, old.ID, time.Now().Add(time.Duration(input.GracePeriodSeconds)*time.Second))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retire old API key: %w", err)
+	}
+
+	uc.logger.Info("API key rotated", "bot_id", b.ID, "old_key_id", old.ID, "new_key_id", output.APIKey.ID)
+	return output, nil
+}
+
+func (uc *useCase) RevokeAPIKey(ctx context.Context, input RevokeAPIKeyInput) error {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid revoke API key input", "error", err)
+		return fmt.Errorf("invalid input: %w", err)
+	}
+
+	b, err := uc.ownedBot(ctx, input.OwnerID, input.BotID)
+	if err != nil {
+		return err
+	}
+
+	k, err := uc.botAPIKey(ctx, b.ID, input.KeyID)
+	if err != nil {
+		return err
+	}
+
+	if err := uc.apiKeyRepo.Revoke(ctx, k.ID); err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+
+	return nil
+}
+
+func (uc *useCase) AuthenticateAPIKey(ctx context.Context, key string) (*APIKeyPrincipal, error) {
+	k, err := uc.apiKeyRepo.GetByHash(ctx, hashAPIKey(key))
+	if err != nil {
+		if err == user.ErrAPIKeyNotFound {
+			return nil, user.ErrAPIKeyInvalid
+		}
+		return nil, fmt.Errorf("failed to authenticate API key: %w", err)
+	}
+	if !k.IsActive() {
+		return nil, user.ErrAPIKeyInvalid
+	}
+
+	// A key stops working with its bot, and with the bot's owner
+	b, err := uc.userRepo.GetByID(ctx, k.UserID)
+	if err != nil {
+		if err == user.ErrUserNotFound {
+			return nil, user.ErrAPIKeyInvalid
+		}
+		return nil, fmt.Errorf("failed to authenticate API key: %w", err)
+	}
+	if !b.IsActive || !b.IsBot || b.OwnerID == nil {
+		return nil, user.ErrAPIKeyInvalid
+	}
+
+	owner, err := uc.userRepo.GetByID(ctx, *b.OwnerID)
+	if err != nil {
+		if err == user.ErrUserNotFound {
+			return nil, user.ErrAPIKeyInvalid
+		}
+		return nil, fmt.Errorf("failed to authenticate API key: %w", err)
+	}
+	if !owner.IsActive {
+		return nil, user.ErrAPIKeyInvalid
+	}
+
+	if k.LastUsedAt == nil || time.Since(*k.LastUsedAt) > apiKeyTouchInterval {
+		if err := uc.apiKeyRepo.Touch(ctx, k.ID); err != nil {
+			uc.logger.Error("Failed to record API key use", "error", err, "key_id", k.ID)
+			// Don't fail the request for this error
+		}
+	}
+
+	return &APIKeyPrincipal{
+		UserID:  b.ID,
+		KeyID:   k.ID,
+		Scopes:  k.Scopes,
+		RoomIDs: k.RoomIDs,
+	}, nil
+}
+
+// ownedBot returns a bot managed by the owner. Other users' bots are reported
+// as not found so their IDs can't be probed.
+func (uc *useCase) ownedBot(ctx context.Context, ownerID, botID string) (*user.User, error) {
+	b, err := uc.userRepo.GetByID(ctx, botID)
+	if err != nil {
+		if err == user.ErrUserNotFound {
+			return nil, user.ErrBotNotFound
+		}
+		uc.logger.Error("Failed to get bot", "error", err, "bot_id", botID)
+		return nil, fmt.Errorf("failed to get bot: %w", err)
+	}
+
+	if !b.IsOwnedBy(ownerID) {
+		return nil, user.ErrBotNotFound
+	}
+
+	return b, nil
+}
+
+// botAPIKey returns one of a bot's API keys
+func (uc *useCase) botAPIKey(ctx context.Context, botID, keyID string) (*user.APIKey, error) {
+	k, err := uc.apiKeyRepo.GetByID(ctx, keyID)
+	if err != nil {
+		if err == user.ErrAPIKeyNotFound {
+			return nil, user.ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	if k.UserID != botID {
+		return nil, user.ErrAPIKeyNotFound
+	}
+
+	return k, nil
+}
+
+// issueAPIKey generates and stores a new API key for a bot
+func (uc *useCase) issueAPIKey(ctx context.Context, botID, name string, scopes, roomIDs []string, expiresAt *time.Time) (*CreateAPIKeyOutput, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate API key: %w", err)
+	}
+	key := user.APIKeyTokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	if roomIDs == nil {
+		roomIDs = []string{}
+	}
+
+	apiKey := user.NewAPIKey(uuid.New().String(), botID, name, key[:12], hashAPIKey(key), scopes, roomIDs, expiresAt)
+	if err := uc.apiKeyRepo.Create(ctx, apiKey); err != nil {
+		return nil, fmt.Errorf("failed to create API key: %w", err)
+	}
+
+	uc.logger.Info("API key issued", "bot_id", botID, "key_id", apiKey.ID, "scopes", scopes)
+
+	return &CreateAPIKeyOutput{
+		Key:    key,
+		APIKey: ToAPIKeyOutput(apiKey),
+	}, nil
+}
+
+// hashAPIKey returns the hex-encoded SHA-256 of an API key. Keys carry 256
+// bits of randomness, so a fast hash is enough.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// dedupe removes repeated values, keeping the first occurrence
+func dedupe(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	return result
+}
\ No newline at end of file
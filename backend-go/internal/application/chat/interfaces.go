@@ -3,6 +3,8 @@ package chat
 import (
 	"context"
 	"time"
+
+	"backend-go/internal/domain/chat"
 )
 
 // UseCase defines the interface for chat use cases
@@ -13,7 +15,17 @@ type UseCase interface {
 	JoinChatRoom(ctx context.Context, input JoinChatRoomInput) error
 	LeaveChatRoom(ctx context.Context, input LeaveChatRoomInput) error
 	UpdateChatRoom(ctx context.Context, input UpdateChatRoomInput) (*UpdateChatRoomOutput, error)
-	DeleteChatRoom(ctx context.Context, input DeleteChatRoomInput) error
+	DeleteChatRoom(ctx context.Context, input DeleteChatRoomInput) (*DeleteChatRoomOutput, error)
+	RestoreChatRoom(ctx context.Context, input RestoreChatRoomInput) (*GetChatRoomOutput, error)
+	PurgeDeletedChatRooms(ctx context.Context) (int64, error)
+	AddMembers(ctx context.Context, input AddMembersInput) (*AddMembersOutput, error)
+	KickMember(ctx context.Context, input KickMemberInput) error
+	BanMember(ctx context.Context, input BanMemberInput) error
+	UnbanMember(ctx context.Context, input UnbanMemberInput) error
+	UpdateMemberRole(ctx context.Context, input UpdateMemberRoleInput) error
+	ListMembers(ctx context.Context, input ListMembersInput) (*ListMembersOutput, error)
+	GetMemberSettings(ctx context.Context, input GetMemberSettingsInput) (*MemberSettingsOutput, error)
+	UpdateMemberSettings(ctx context.Context, input UpdateMemberSettingsInput) (*MemberSettingsOutput, error)
 }
 
 // CreateChatRoomInput represents the input for creating a chat room
@@ -33,6 +45,7 @@ type CreateChatRoomOutput struct {
 	IsPrivate   bool      `json:"is_private"`
 	CreatedBy   string    `json:"created_by"`
 	Members     []string  `json:"members"`
+	MemberCount int       `json:"member_count"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
@@ -45,21 +58,25 @@ type GetChatRoomInput struct {
 
 // GetChatRoomOutput represents the output for getting a chat room
 type GetChatRoomOutput struct {
-	ID          string    `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description,omitempty"`
-	IsPrivate   bool      `json:"is_private"`
-	CreatedBy   string    `json:"created_by"`
-	Members     []string  `json:"members"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID               string                `json:"id"`
+	Name             string                `json:"name"`
+	Description      string                `json:"description,omitempty"`
+	IsPrivate        bool                  `json:"is_private"`
+	CreatedBy        string                `json:"created_by"`
+	MemberCount      int                   `json:"member_count"`
+	AnnouncementOnly bool                  `json:"announcement_only"`
+	SlowModeSeconds  int                   `json:"slow_mode_seconds"`
+	Settings         *MemberSettingsOutput `json:"settings,omitempty"`
+	CreatedAt        time.Time             `json:"created_at"`
+	UpdatedAt        time.Time             `json:"updated_at"`
 }
 
 // GetUserChatRoomsInput represents the input for getting user's chat rooms
 type GetUserChatRoomsInput struct {
-	UserID string `json:"user_id" validate:"required"`
-	Page   int    `json:"page" validate:"min=1"`
-	Limit  int    `json:"limit" validate:"min=1,max=100"`
+	UserID   string `json:"user_id" validate:"required"`
+	Archived bool   `json:"archived"` // List archived rooms instead of active ones
+	Page     int    `json:"page" validate:"min=1"`
+	Limit    int    `json:"limit" validate:"min=1,max=100"`
 }
 
 // GetUserChatRoomsOutput represents the output for getting user's chat rooms
@@ -87,22 +104,148 @@ type UpdateChatRoomInput struct {
 	Name        string `json:"name,omitempty" validate:"omitempty,min=1,max=100"`
 	Description string `json:"description,omitempty" validate:"max=500"`
 	IsPrivate   *bool  `json:"is_private,omitempty"`
+
+	// Posting restrictions; nil leaves the current value unchanged
+	AnnouncementOnly *bool `json:"announcement_only,omitempty"`
+	SlowModeSeconds  *int  `json:"slow_mode_seconds,omitempty" validate:"omitempty,min=0,max=21600"`
 }
 
 // UpdateChatRoomOutput represents the output for updating a chat room
 type UpdateChatRoomOutput struct {
-	ID          string    `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description,omitempty"`
-	IsPrivate   bool      `json:"is_private"`
-	CreatedBy   string    `json:"created_by"`
-	Members     []string  `json:"members"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID               string    `json:"id"`
+	Name             string    `json:"name"`
+	Description      string    `json:"description,omitempty"`
+	IsPrivate        bool      `json:"is_private"`
+	CreatedBy        string    `json:"created_by"`
+	MemberCount      int       `json:"member_count"`
+	AnnouncementOnly bool      `json:"announcement_only"`
+	SlowModeSeconds  int       `json:"slow_mode_seconds"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
 }
 
 // DeleteChatRoomInput represents the input for deleting a chat room
 type DeleteChatRoomInput struct {
 	RoomID string `json:"room_id" validate:"required"`
 	UserID string `json:"user_id" validate:"required"`
+}
+
+// DeleteChatRoomOutput represents the output for deleting a chat room
+type DeleteChatRoomOutput struct {
+	DeletedAt       time.Time `json:"deleted_at"`
+	RestorableUntil time.Time `json:"restorable_until"`
+}
+
+// RestoreChatRoomInput represents the input for restoring a deleted chat room
+type RestoreChatRoomInput struct {
+	RoomID string `json:"room_id" validate:"required"`
+	UserID string `json:"user_id" validate:"required"`
+}
+
+// AddMembersInput represents the input for adding members to a chat room
+type AddMembersInput struct {
+	RoomID    string   `json:"room_id" validate:"required"`
+	UserID    string   `json:"user_id" validate:"required"`
+	MemberIDs []string `json:"member_ids" validate:"required,min=1,max=100"`
+}
+
+// AddMembersOutput represents the output for adding members to a chat room
+type AddMembersOutput struct {
+	Added   []string `json:"added"`
+	Skipped []string `json:"skipped"`
+}
+
+// KickMemberInput represents the input for removing a member from a chat room
+type KickMemberInput struct {
+	RoomID   string `json:"room_id" validate:"required"`
+	UserID   string `json:"user_id" validate:"required"`
+	MemberID string `json:"member_id" validate:"required"`
+}
+
+// BanMemberInput represents the input for banning a user from a chat room
+type BanMemberInput struct {
+	RoomID    string     `json:"room_id" validate:"required"`
+	UserID    string     `json:"user_id" validate:"required"`
+	MemberID  string     `json:"member_id" validate:"required"`
+	Reason    string     `json:"reason,omitempty" validate:"max=500"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// UnbanMemberInput represents the input for lifting a ban
+type UnbanMemberInput struct {
+	RoomID   string `json:"room_id" validate:"required"`
+	UserID   string `json:"user_id" validate:"required"`
+	MemberID string `json:"member_id" validate:"required"`
+}
+
+// UpdateMemberRoleInput represents the input for changing a member's role
+type UpdateMemberRoleInput struct {
+	RoomID   string `json:"room_id" validate:"required"`
+	UserID   string `json:"user_id" validate:"required"`
+	MemberID string `json:"member_id" validate:"required"`
+	Role     string `json:"role" validate:"required,oneof=admin member"`
+}
+
+// ListMembersInput represents the input for listing chat room members
+type ListMembersInput struct {
+	RoomID string `json:"room_id" validate:"required"`
+	UserID string `json:"user_id" validate:"required"`
+	After  string `json:"after,omitempty"` // NextCursor of the previous page
+	Limit  int    `json:"limit" validate:"min=1,max=100"`
+}
+
+// MemberOutput represents a chat room member in the output
+type MemberOutput struct {
+	UserID     string     `json:"user_id"`
+	Username   string     `json:"username"`
+	Role       string     `json:"role"`
+	JoinedAt   time.Time  `json:"joined_at"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+}
+
+// ListMembersOutput represents the output for listing chat room members
+type ListMembersOutput struct {
+	Members    []*MemberOutput `json:"members"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+	HasMore    bool            `json:"has_more"`
+}
+
+// GetMemberSettingsInput represents the input for getting a member's room settings
+type GetMemberSettingsInput struct {
+	RoomID string `json:"room_id" validate:"required"`
+	UserID string `json:"user_id" validate:"required"`
+}
+
+// UpdateMemberSettingsInput represents the input for replacing a member's room settings
+type UpdateMemberSettingsInput struct {
+	RoomID            string     `json:"room_id" validate:"required"`
+	UserID            string     `json:"user_id" validate:"required"`
+	MutedUntil        *time.Time `json:"muted_until,omitempty"`
+	Pinned            bool       `json:"pinned"`
+	Archived          bool       `json:"archived"`
+	NotificationLevel string     `json:"notification_level" validate:"required,oneof=all mentions none"`
+}
+
+// MemberSettingsOutput represents a member's room settings in the output
+type MemberSettingsOutput struct {
+	Muted             bool       `json:"muted"`
+	MutedUntil        *time.Time `json:"muted_until,omitempty"`
+	Pinned            bool       `json:"pinned"`
+	Archived          bool       `json:"archived"`
+	NotificationLevel string     `json:"notification_level"`
+}
+
+// ToMemberSettingsOutput converts member settings to output
+func ToMemberSettingsOutput(s *chat.MemberSettings) *MemberSettingsOutput {
+	if s == nil {
+		return nil
+	}
+
+	return &MemberSettingsOutput{
+		Muted:             s.IsMuted(),
+		MutedUntil:        s.MutedUntil,
+		Pinned:            s.Pinned,
+		Archived:          s.Archived,
+		NotificationLevel: s.NotificationLevel,
+	}
 }
\ No newline at end of file
@@ -3,6 +3,7 @@ package chat
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -91,6 +92,7 @@ func (uc *useCase) CreateChatRoom(ctx context.Context, input CreateChatRoomInput
 		IsPrivate:   chatRoom.IsPrivate,
 		CreatedBy:   chatRoom.CreatedBy,
 		Members:     chatRoom.Members,
+		MemberCount: chatRoom.TotalMembers,
 		CreatedAt:   chatRoom.CreatedAt,
 		UpdatedAt:   chatRoom.UpdatedAt,
 	}, nil
@@ -113,20 +115,28 @@ func (uc *useCase) GetChatRoom(ctx context.Context, input GetChatRoomInput) (*Ge
 		return nil, fmt.Errorf("failed to get chat room: %w", err)
 	}
 
-	// Check if user is a member
-	if !chatRoom.IsMember(input.UserID) {
-		return nil, fmt.Errorf("access denied: not a member of this chat room")
+	// Check if user is a member, loading their room settings
+	settings, err := uc.chatRepo.GetMemberSettings(ctx, input.RoomID, input.UserID)
+	if err != nil {
+		if err == chat.ErrMemberNotFound {
+			return nil, fmt.Errorf("access denied: not a member of this chat room")
+		}
+		uc.logger.Error("Failed to get member settings", "error", err, "room_id", input.RoomID, "user_id", input.UserID)
+		return nil, fmt.Errorf("failed to verify access: %w", err)
 	}
 
 	return &GetChatRoomOutput{
-		ID:          chatRoom.ID,
-		Name:        chatRoom.Name,
-		Description: chatRoom.Description,
-		IsPrivate:   chatRoom.IsPrivate,
-		CreatedBy:   chatRoom.CreatedBy,
-		Members:     chatRoom.Members,
-		CreatedAt:   chatRoom.CreatedAt,
-		UpdatedAt:   chatRoom.UpdatedAt,
+		ID:               chatRoom.ID,
+		Name:             chatRoom.Name,
+		Description:      chatRoom.Description,
+		IsPrivate:        chatRoom.IsPrivate,
+		CreatedBy:        chatRoom.CreatedBy,
+		MemberCount:      chatRoom.TotalMembers,
+		AnnouncementOnly: chatRoom.AnnouncementOnly,
+		SlowModeSeconds:  chatRoom.SlowModeSeconds,
+		Settings:         ToMemberSettingsOutput(settings),
+		CreatedAt:        chatRoom.CreatedAt,
+		UpdatedAt:        chatRoom.UpdatedAt,
 	}, nil
 }
 
@@ -141,7 +151,7 @@ func (uc *useCase) GetUserChatRooms(ctx context.Context, input GetUserChatRoomsI
 	offset := (input.Page - 1) * input.Limit
 
 	// Get user's chat rooms
-	chatRooms, total, err := uc.chatRepo.GetUserChatRooms(ctx, input.UserID, input.Limit, offset)
+	chatRooms, total, err := uc.chatRepo.GetUserChatRooms(ctx, input.UserID, input.Archived, input.Limit, offset)
 	if err != nil {
 		uc.logger.Error("Failed to get user chat rooms", "error", err, "user_id", input.UserID)
 		return nil, fmt.Errorf("failed to get user chat rooms: %w", err)
@@ -151,14 +161,17 @@ func (uc *useCase) GetUserChatRooms(ctx context.Context, input GetUserChatRoomsI
 	var result []*GetChatRoomOutput
 	for _, room := range chatRooms {
 		result = append(result, &GetChatRoomOutput{
-			ID:          room.ID,
-			Name:        room.Name,
-			Description: room.Description,
-			IsPrivate:   room.IsPrivate,
-			CreatedBy:   room.CreatedBy,
-			Members:     room.Members,
-			CreatedAt:   room.CreatedAt,
-			UpdatedAt:   room.UpdatedAt,
+			ID:               room.ID,
+			Name:             room.Name,
+			Description:      room.Description,
+			IsPrivate:        room.IsPrivate,
+			CreatedBy:        room.CreatedBy,
+			MemberCount:      room.TotalMembers,
+			AnnouncementOnly: room.AnnouncementOnly,
+			SlowModeSeconds:  room.SlowModeSeconds,
+			Settings:         ToMemberSettingsOutput(room.Settings),
+			CreatedAt:        room.CreatedAt,
+			UpdatedAt:        room.UpdatedAt,
 		})
 	}
 
@@ -185,14 +198,28 @@ func (uc *useCase) JoinChatRoom(ctx context.Context, input JoinChatRoomInput) er
 		return fmt.Errorf("failed to get chat room: %w", err)
 	}
 
-	// Check if user can join
-	if !chatRoom.CanJoin(input.UserID) {
+	// Check if user is already a member
+	isMember, err := uc.chatRepo.IsMember(ctx, input.RoomID, input.UserID)
+	if err != nil {
+		uc.logger.Error("Failed to check membership", "error", err, "room_id", input.RoomID, "user_id", input.UserID)
+		return fmt.Errorf("failed to check membership: %w", err)
+	}
+	if isMember {
+		return fmt.Errorf("already a member of this chat room")
+	}
+
+	// Private rooms can only be joined by invitation
+	if chatRoom.IsPrivate {
 		return fmt.Errorf("cannot join this chat room")
 	}
 
-	// Check if user is already a member
-	if chatRoom.IsMember(input.UserID) {
-		return fmt.Errorf("already a member of this chat room")
+	// Banned users cannot rejoin
+	banned, err := uc.isBanned(ctx, input.RoomID, input.UserID)
+	if err != nil {
+		return err
+	}
+	if banned {
+		return chat.ErrUserBanned
 	}
 
 	// Verify user exists and is active
@@ -237,12 +264,17 @@ func (uc *useCase) LeaveChatRoom(ctx context.Context, input LeaveChatRoomInput)
 	}
 
 	// Check if user is a member
-	if !chatRoom.IsMember(input.UserID) {
+	isMember, err := uc.chatRepo.IsMember(ctx, input.RoomID, input.UserID)
+	if err != nil {
+		uc.logger.Error("Failed to check membership", "error", err, "room_id", input.RoomID, "user_id", input.UserID)
+		return fmt.Errorf("failed to check membership: %w", err)
+	}
+	if !isMember {
 		return fmt.Errorf("not a member of this chat room")
 	}
 
 	// Don't allow creator to leave if there are other members
-	if chatRoom.IsCreator(input.UserID) && chatRoom.MemberCount() > 1 {
+	if chatRoom.IsCreator(input.UserID) && chatRoom.TotalMembers > 1 {
 		return fmt.Errorf("creator cannot leave chat room with other members")
 	}
 
@@ -253,7 +285,7 @@ func (uc *useCase) LeaveChatRoom(ctx context.Context, input LeaveChatRoomInput)
 	}
 
 	// If creator left and was the last member, delete the chat room
-	if chatRoom.IsCreator(input.UserID) && chatRoom.MemberCount() == 1 {
+	if chatRoom.IsCreator(input.UserID) && chatRoom.TotalMembers == 1 {
 		if err := uc.chatRepo.Delete(ctx, input.RoomID); err != nil {
 			uc.logger.Error("Failed to delete empty chat room", "error", err, "room_id", input.RoomID)
 			// Don't return error, user has already left
@@ -281,9 +313,13 @@ func (uc *useCase) UpdateChatRoom(ctx context.Context, input UpdateChatRoomInput
 		return nil, fmt.Errorf("failed to get chat room: %w", err)
 	}
 
-	// Check if user is the creator (only creator can update)
-	if !chatRoom.IsCreator(input.UserID) {
-		return nil, fmt.Errorf("only creator can update chat room")
+	// Only admins can update the chat room
+	role, err := uc.memberRole(ctx, input.RoomID, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !chat.IsAdminRole(role) {
+		return nil, fmt.Errorf("only chat room admins can update chat room")
 	}
 
 	// Update chat room
@@ -304,6 +340,13 @@ func (uc *useCase) UpdateChatRoom(ctx context.Context, input UpdateChatRoomInput
 
 	chatRoom.Update(name, description, isPrivate)
 
+	if input.AnnouncementOnly != nil {
+		chatRoom.AnnouncementOnly = *input.AnnouncementOnly
+	}
+	if input.SlowModeSeconds != nil {
+		chatRoom.SlowModeSeconds = *input.SlowModeSeconds
+	}
+
 	// Save changes
 	if err := uc.chatRepo.Update(ctx, chatRoom); err != nil {
 		uc.logger.Error("Failed to update chat room", "error", err, "room_id", input.RoomID)
@@ -313,45 +356,474 @@ func (uc *useCase) UpdateChatRoom(ctx context.Context, input UpdateChatRoomInput
 	uc.logger.Info("Chat room updated successfully", "room_id", input.RoomID, "user_id", input.UserID)
 
 	return &UpdateChatRoomOutput{
-		ID:          chatRoom.ID,
-		Name:        chatRoom.Name,
-		Description: chatRoom.Description,
-		IsPrivate:   chatRoom.IsPrivate,
-		CreatedBy:   chatRoom.CreatedBy,
-		Members:     chatRoom.Members,
-		CreatedAt:   chatRoom.CreatedAt,
-		UpdatedAt:   chatRoom.UpdatedAt,
+		ID:               chatRoom.ID,
+		Name:             chatRoom.Name,
+		Description:      chatRoom.Description,
+		IsPrivate:        chatRoom.IsPrivate,
+		CreatedBy:        chatRoom.CreatedBy,
+		MemberCount:      chatRoom.TotalMembers,
+		AnnouncementOnly: chatRoom.AnnouncementOnly,
+		SlowModeSeconds:  chatRoom.SlowModeSeconds,
+		CreatedAt:        chatRoom.CreatedAt,
+		UpdatedAt:        chatRoom.UpdatedAt,
 	}, nil
 }
 
-func (uc *useCase) DeleteChatRoom(ctx context.Context, input DeleteChatRoomInput) error {
+func (uc *useCase) DeleteChatRoom(ctx context.Context, input DeleteChatRoomInput) (*DeleteChatRoomOutput, error) {
 	// Validate input
 	if err := uc.validator.Struct(input); err != nil {
 		uc.logger.Error("Invalid delete chat room input", "error", err)
-		return fmt.Errorf("invalid input: %w", err)
+		return nil, fmt.Errorf("invalid input: %w", err)
 	}
 
-	// Get chat room
-	chatRoom, err := uc.chatRepo.GetByID(ctx, input.RoomID)
+	// Only the owner can delete the chat room
+	role, err := uc.memberRole(ctx, input.RoomID, input.UserID)
 	if err != nil {
+		return nil, err
+	}
+	if role != chat.RoleOwner {
+		return nil, fmt.Errorf("only the chat room owner can delete chat room")
+	}
+
+	// Soft-delete chat room; it is purged once the restore grace period passes
+	if err := uc.chatRepo.Delete(ctx, input.RoomID); err != nil {
 		if err == chat.ErrChatRoomNotFound {
-			return fmt.Errorf("chat room not found")
+			return nil, fmt.Errorf("chat room not found")
 		}
-		uc.logger.Error("Failed to get chat room", "error", err, "room_id", input.RoomID)
-		return fmt.Errorf("failed to get chat room: %w", err)
+		uc.logger.Error("Failed to delete chat room", "error", err, "room_id", input.RoomID)
+		return nil, fmt.Errorf("failed to delete chat room: %w", err)
+	}
+
+	deletedAt := time.Now()
+	uc.logger.Info("Chat room deleted successfully", "room_id", input.RoomID, "user_id", input.UserID)
+
+	return &DeleteChatRoomOutput{
+		DeletedAt:       deletedAt,
+		RestorableUntil: deletedAt.Add(chat.RestoreGracePeriod),
+	}, nil
+}
+
+func (uc *useCase) RestoreChatRoom(ctx context.Context, input RestoreChatRoomInput) (*GetChatRoomOutput, error) {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid restore chat room input", "error", err)
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	// Get deleted chat room
+	chatRoom, err := uc.chatRepo.GetDeletedByID(ctx, input.RoomID)
+	if err != nil {
+		if err == chat.ErrChatRoomNotFound {
+			return nil, fmt.Errorf("deleted chat room not found")
+		}
+		uc.logger.Error("Failed to get deleted chat room", "error", err, "room_id", input.RoomID)
+		return nil, fmt.Errorf("failed to get chat room: %w", err)
 	}
 
-	// Check if user is the creator (only creator can delete)
+	// Check if user is the creator (only the owner can restore)
 	if !chatRoom.IsCreator(input.UserID) {
-		return fmt.Errorf("only creator can delete chat room")
+		return nil, fmt.Errorf("only the chat room owner can restore chat room")
 	}
 
-	// Delete chat room
-	if err := uc.chatRepo.Delete(ctx, input.RoomID); err != nil {
-		uc.logger.Error("Failed to delete chat room", "error", err, "room_id", input.RoomID)
-		return fmt.Errorf("failed to delete chat room: %w", err)
+	if !chatRoom.CanRestore() {
+		return nil, fmt.Errorf("restore period for this chat room has expired")
 	}
 
-	uc.logger.Info("Chat room deleted successfully", "room_id", input.RoomID, "user_id", input.UserID)
+	if err := uc.chatRepo.Restore(ctx, input.RoomID); err != nil {
+		if err == chat.ErrChatRoomNotFound {
+			return nil, fmt.Errorf("deleted chat room not found")
+		}
+		uc.logger.Error("Failed to restore chat room", "error", err, "room_id", input.RoomID)
+		return nil, fmt.Errorf("failed to restore chat room: %w", err)
+	}
+
+	uc.logger.Info("Chat room restored successfully", "room_id", input.RoomID, "user_id", input.UserID)
+
+	return &GetChatRoomOutput{
+		ID:               chatRoom.ID,
+		Name:             chatRoom.Name,
+		Description:      chatRoom.Description,
+		IsPrivate:        chatRoom.IsPrivate,
+		CreatedBy:        chatRoom.CreatedBy,
+		MemberCount:      chatRoom.TotalMembers,
+		AnnouncementOnly: chatRoom.AnnouncementOnly,
+		SlowModeSeconds:  chatRoom.SlowModeSeconds,
+		CreatedAt:        chatRoom.CreatedAt,
+		UpdatedAt:        time.Now(),
+	}, nil
+}
+
+func (uc *useCase) PurgeDeletedChatRooms(ctx context.Context) (int64, error) {
+	purged, err := uc.chatRepo.PurgeDeleted(ctx, time.Now().Add(-chat.RestoreGracePeriod))
+	if err != nil {
+		uc.logger.Error("Failed to purge deleted chat rooms", "error", err)
+		return 0, fmt.Errorf("failed to purge deleted chat rooms: %w", err)
+	}
+
+	if purged > 0 {
+		uc.logger.Info("Deleted chat rooms purged", "count", purged)
+	}
+	return purged, nil
+}
+
+func (uc *useCase) AddMembers(ctx context.Context, input AddMembersInput) (*AddMembersOutput, error) {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid add members input", "error", err)
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	// Only room admins can add members
+	if _, err := uc.requireAdmin(ctx, input.RoomID, input.UserID); err != nil {
+		return nil, err
+	}
+
+	output := &AddMembersOutput{
+		Added:   []string{},
+		Skipped: []string{},
+	}
+
+	seen := make(map[string]bool)
+	for _, memberID := range input.MemberIDs {
+		if seen[memberID] {
+			continue
+		}
+		seen[memberID] = true
+
+		// Skip existing members
+		isMember, err := uc.chatRepo.IsMember(ctx, input.RoomID, memberID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check membership: %w", err)
+		}
+		if isMember {
+			output.Skipped = append(output.Skipped, memberID)
+			continue
+		}
+
+		// Banned users cannot be invited back
+		banned, err := uc.isBanned(ctx, input.RoomID, memberID)
+		if err != nil {
+			return nil, err
+		}
+		if banned {
+			uc.logger.Warn("Member is banned, skipping", "room_id", input.RoomID, "member_id", memberID)
+			output.Skipped = append(output.Skipped, memberID)
+			continue
+		}
+
+		// Verify member exists and is active
+		member, err := uc.userRepo.GetByID(ctx, memberID)
+		if err != nil {
+			uc.logger.Warn("Member not found, skipping", "member_id", memberID)
+			output.Skipped = append(output.Skipped, memberID)
+			continue
+		}
+		if !member.IsActive {
+			uc.logger.Warn("Member account inactive, skipping", "member_id", memberID)
+			output.Skipped = append(output.Skipped, memberID)
+			continue
+		}
+
+		if err := uc.chatRepo.AddMember(ctx, input.RoomID, memberID); err != nil {
+			uc.logger.Error("Failed to add member to chat room", "error", err, "room_id", input.RoomID, "member_id", memberID)
+			return nil, fmt.Errorf("failed to add member: %w", err)
+		}
+		output.Added = append(output.Added, memberID)
+	}
+
+	uc.logger.Info("Members added to chat room", "room_id", input.RoomID, "user_id", input.UserID, "added", len(output.Added), "skipped", len(output.Skipped))
+	return output, nil
+}
+
+func (uc *useCase) KickMember(ctx context.Context, input KickMemberInput) error {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid kick member input", "error", err)
+		return fmt.Errorf("invalid input: %w", err)
+	}
+
+	if input.MemberID == input.UserID {
+		return fmt.Errorf("cannot remove yourself, leave the chat room instead")
+	}
+
+	actorRole, err := uc.requireAdmin(ctx, input.RoomID, input.UserID)
+	if err != nil {
+		return err
+	}
+
+	// Check the target is a member the actor outranks
+	targetRole, err := uc.chatRepo.GetMemberRole(ctx, input.RoomID, input.MemberID)
+	if err != nil {
+		if err == chat.ErrMemberNotFound {
+			return fmt.Errorf("user is not a member of this chat room")
+		}
+		return fmt.Errorf("failed to get member role: %w", err)
+	}
+
+	if !chat.CanModerate(actorRole, targetRole) {
+		return fmt.Errorf("insufficient permissions to remove this member")
+	}
+
+	// Remove member from chat room
+	if err := uc.chatRepo.RemoveMember(ctx, input.RoomID, input.MemberID); err != nil {
+		uc.logger.Error("Failed to remove member from chat room", "error", err, "room_id", input.RoomID, "member_id", input.MemberID)
+		return fmt.Errorf("failed to remove member: %w", err)
+	}
+
+	uc.logger.Info("Member removed from chat room", "room_id", input.RoomID, "member_id", input.MemberID, "removed_by", input.UserID)
+	return nil
+}
+
+func (uc *useCase) BanMember(ctx context.Context, input BanMemberInput) error {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid ban member input", "error", err)
+		return fmt.Errorf("invalid input: %w", err)
+	}
+
+	if input.MemberID == input.UserID {
+		return fmt.Errorf("cannot ban yourself")
+	}
+
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		return fmt.Errorf("ban expiry must be in the future")
+	}
+
+	actorRole, err := uc.requireAdmin(ctx, input.RoomID, input.UserID)
+	if err != nil {
+		return err
+	}
+
+	// Users who are not (or no longer) members can still be banned
+	targetRole, err := uc.chatRepo.GetMemberRole(ctx, input.RoomID, input.MemberID)
+	if err != nil && err != chat.ErrMemberNotFound {
+		return fmt.Errorf("failed to get member role: %w", err)
+	}
+	if err == nil && !chat.CanModerate(actorRole, targetRole) {
+		return fmt.Errorf("insufficient permissions to ban this member")
+	}
+
+	ban := chat.NewBan(input.RoomID, input.MemberID, input.UserID, input.Reason, input.ExpiresAt)
+	if err := uc.chatRepo.BanMember(ctx, ban); err != nil {
+		uc.logger.Error("Failed to ban member", "error", err, "room_id", input.RoomID, "member_id", input.MemberID)
+		return fmt.Errorf("failed to ban member: %w", err)
+	}
+
+	uc.logger.Info("Member banned from chat room", "room_id", input.RoomID, "member_id", input.MemberID, "banned_by", input.UserID)
+	return nil
+}
+
+func (uc *useCase) UnbanMember(ctx context.Context, input UnbanMemberInput) error {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid unban member input", "error", err)
+		return fmt.Errorf("invalid input: %w", err)
+	}
+
+	if _, err := uc.requireAdmin(ctx, input.RoomID, input.UserID); err != nil {
+		return err
+	}
+
+	if err := uc.chatRepo.RemoveBan(ctx, input.RoomID, input.MemberID); err != nil {
+		if err == chat.ErrBanNotFound {
+			return fmt.Errorf("user is not banned from this chat room")
+		}
+		uc.logger.Error("Failed to remove ban", "error", err, "room_id", input.RoomID, "member_id", input.MemberID)
+		return fmt.Errorf("failed to unban member: %w", err)
+	}
+
+	uc.logger.Info("Member unbanned from chat room", "room_id", input.RoomID, "member_id", input.MemberID, "unbanned_by", input.UserID)
+	return nil
+}
+
+func (uc *useCase) UpdateMemberRole(ctx context.Context, input UpdateMemberRoleInput) error {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid update member role input", "error", err)
+		return fmt.Errorf("invalid input: %w", err)
+	}
+
+	actorRole, err := uc.requireAdmin(ctx, input.RoomID, input.UserID)
+	if err != nil {
+		return err
+	}
+
+	// Only the owner can promote or demote admins
+	if actorRole != chat.RoleOwner {
+		return fmt.Errorf("only the owner can change member roles")
+	}
+
+	if input.MemberID == input.UserID {
+		return fmt.Errorf("cannot change your own role")
+	}
+
+	if err := uc.chatRepo.UpdateMemberRole(ctx, input.RoomID, input.MemberID, input.Role); err != nil {
+		if err == chat.ErrMemberNotFound {
+			return fmt.Errorf("user is not a member of this chat room")
+		}
+		uc.logger.Error("Failed to update member role", "error", err, "room_id", input.RoomID, "member_id", input.MemberID)
+		return fmt.Errorf("failed to update member role: %w", err)
+	}
+
+	uc.logger.Info("Member role updated", "room_id", input.RoomID, "member_id", input.MemberID, "role", input.Role, "updated_by", input.UserID)
 	return nil
+}
+
+// requireAdmin verifies the chat room exists and the user is one of its admins, returning the user's role
+func (uc *useCase) requireAdmin(ctx context.Context, roomID, userID string) (string, error) {
+	role, err := uc.memberRole(ctx, roomID, userID)
+	if err != nil {
+		return "", err
+	}
+
+	if !chat.IsAdminRole(role) {
+		return "", fmt.Errorf("only chat room admins can manage members")
+	}
+
+	return role, nil
+}
+
+// memberRole returns the user's role in the chat room, failing if the room does not exist or the user is not a member
+func (uc *useCase) memberRole(ctx context.Context, roomID, userID string) (string, error) {
+	role, err := uc.chatRepo.GetMemberRole(ctx, roomID, userID)
+	if err != nil {
+		if err == chat.ErrMemberNotFound {
+			// Distinguish a missing room from a non-member
+			if _, err := uc.chatRepo.GetByID(ctx, roomID); err == chat.ErrChatRoomNotFound {
+				return "", fmt.Errorf("chat room not found")
+			}
+			return "", fmt.Errorf("not a member of this chat room")
+		}
+		uc.logger.Error("Failed to get member role", "error", err, "room_id", roomID, "user_id", userID)
+		return "", fmt.Errorf("failed to verify permissions: %w", err)
+	}
+
+	return role, nil
+}
+
+// isBanned checks if the user has an active ban in the chat room
+func (uc *useCase) isBanned(ctx context.Context, roomID, userID string) (bool, error) {
+	ban, err := uc.chatRepo.GetBan(ctx, roomID, userID)
+	if err != nil {
+		if err == chat.ErrBanNotFound {
+			return false, nil
+		}
+		uc.logger.Error("Failed to get ban", "error", err, "room_id", roomID, "user_id", userID)
+		return false, fmt.Errorf("failed to check ban status: %w", err)
+	}
+
+	return ban.IsActive(), nil
+}
+
+func (uc *useCase) ListMembers(ctx context.Context, input ListMembersInput) (*ListMembersOutput, error) {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid list members input", "error", err)
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	// Check if chat room exists
+	if _, err := uc.chatRepo.GetByID(ctx, input.RoomID); err != nil {
+		if err == chat.ErrChatRoomNotFound {
+			return nil, fmt.Errorf("chat room not found")
+		}
+		uc.logger.Error("Failed to get chat room", "error", err, "room_id", input.RoomID)
+		return nil, fmt.Errorf("failed to get chat room: %w", err)
+	}
+
+	// Only members can see who else is in the room
+	isMember, err := uc.chatRepo.IsMember(ctx, input.RoomID, input.UserID)
+	if err != nil {
+		uc.logger.Error("Failed to check membership", "error", err, "room_id", input.RoomID, "user_id", input.UserID)
+		return nil, fmt.Errorf("failed to verify access: %w", err)
+	}
+	if !isMember {
+		return nil, fmt.Errorf("access denied: not a member of this chat room")
+	}
+
+	var after *chat.MemberCursor
+	if input.After != "" {
+		cursor, err := chat.ParseMemberCursor(input.After)
+		if err != nil {
+			return nil, fmt.Errorf("invalid input: %w", err)
+		}
+		after = cursor
+	}
+
+	members, hasMore, err := uc.chatRepo.ListMembers(ctx, input.RoomID, after, input.Limit)
+	if err != nil {
+		uc.logger.Error("Failed to list members", "error", err, "room_id", input.RoomID)
+		return nil, fmt.Errorf("failed to list members: %w", err)
+	}
+
+	// Convert to output format
+	result := make([]*MemberOutput, 0, len(members))
+	for _, member := range members {
+		result = append(result, &MemberOutput{
+			UserID:     member.UserID,
+			Username:   member.Username,
+			Role:       member.Role,
+			JoinedAt:   member.JoinedAt,
+			LastSeenAt: member.LastSeenAt,
+		})
+	}
+
+	output := &ListMembersOutput{
+		Members: result,
+		HasMore: hasMore,
+	}
+	if hasMore && len(result) > 0 {
+		last := result[len(result)-1]
+		output.NextCursor = chat.MemberCursor{JoinedAt: last.JoinedAt, UserID: last.UserID}.Encode()
+	}
+
+	return output, nil
+}
+
+func (uc *useCase) GetMemberSettings(ctx context.Context, input GetMemberSettingsInput) (*MemberSettingsOutput, error) {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid get member settings input", "error", err)
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	settings, err := uc.chatRepo.GetMemberSettings(ctx, input.RoomID, input.UserID)
+	if err != nil {
+		if err == chat.ErrMemberNotFound {
+			return nil, fmt.Errorf("not a member of this chat room")
+		}
+		uc.logger.Error("Failed to get member settings", "error", err, "room_id", input.RoomID, "user_id", input.UserID)
+		return nil, fmt.Errorf("failed to get member settings: %w", err)
+	}
+
+	return ToMemberSettingsOutput(settings), nil
+}
+
+func (uc *useCase) UpdateMemberSettings(ctx context.Context, input UpdateMemberSettingsInput) (*MemberSettingsOutput, error) {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid update member settings input", "error", err)
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	settings := chat.NewMemberSettings(input.RoomID, input.UserID)
+	settings.Pinned = input.Pinned
+	settings.Archived = input.Archived
+	settings.NotificationLevel = input.NotificationLevel
+
+	// A mute that has already expired is the same as no mute
+	if input.MutedUntil != nil && input.MutedUntil.After(time.Now()) {
+		settings.MutedUntil = input.MutedUntil
+	}
+
+	if err := uc.chatRepo.UpdateMemberSettings(ctx, settings); err != nil {
+		if err == chat.ErrMemberNotFound {
+			return nil, fmt.Errorf("not a member of this chat room")
+		}
+		uc.logger.Error("Failed to update member settings", "error", err, "room_id", input.RoomID, "user_id", input.UserID)
+		return nil, fmt.Errorf("failed to update member settings: %w", err)
+	}
+
+	uc.logger.Info("Member settings updated successfully", "room_id", input.RoomID, "user_id", input.UserID)
+	return ToMemberSettingsOutput(settings), nil
 }
\ No newline at end of file
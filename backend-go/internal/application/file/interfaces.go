@@ -0,0 +1,352 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"backend-go/internal/domain/attachment"
+)
+
+// UseCase defines the interface for file attachment use cases
+type UseCase interface {
+	UploadFile(ctx context.Context, input UploadFileInput) (*FileOutput, error)
+	CreateUpload(ctx context.Context, input CreateUploadInput) (*CreateUploadOutput, error)
+	CompleteUpload(ctx context.Context, input CompleteUploadInput) (*FileOutput, error)
+	CreateResumableUpload(ctx context.Context, input CreateUploadInput) (*ResumableUploadOutput, error)
+	GetResumableUpload(ctx context.Context, input ResumableUploadInput) (*ResumableUploadOutput, error)
+	WriteChunk(ctx context.Context, input WriteChunkInput) (*ResumableUploadOutput, error)
+	CompleteResumableUpload(ctx context.Context, input ResumableUploadInput) (*FileOutput, error)
+	// UploadByChecksum creates a file from content the user already has
+	// access to, returning attachment.ErrBlobNotFound if the content has to
+	// be uploaded
+	UploadByChecksum(ctx context.Context, input UploadByChecksumInput) (*FileOutput, error)
+	GetFile(ctx context.Context, input GetFileInput) (*FileOutput, error)
+	OpenFile(ctx context.Context, input GetFileInput) (*OpenFileOutput, error)
+	OpenThumbnail(ctx context.Context, input OpenThumbnailInput) (*OpenFileOutput, error)
+	DeleteFile(ctx context.Context, input DeleteFileInput) error
+	GetStorageUsage(ctx context.Context, input GetStorageUsageInput) (*StorageUsageOutput, error)
+	PurgeStaleUploads(ctx context.Context) (int, error)
+	// ScanPendingFiles scans files held back until they are checked for
+	// malware and returns those whose scan finished, clean, quarantined or
+	// failed for good
+	ScanPendingFiles(ctx context.Context) ([]*FileOutput, error)
+	// CollectGarbage removes files never sent with a message and stored
+	// content nothing references, or only reports them in a dry run
+	CollectGarbage(ctx context.Context, input CollectGarbageInput) (*GarbageReport, error)
+}
+
+// ContentCacheControl lets clients keep downloaded content for good, since
+// the content of a file never changes. Only private caches may store it, as
+// access follows chat room membership.
+const ContentCacheControl = "private, max-age=31536000, immutable"
+
+// UploadSettings configures file uploads
+type UploadSettings struct {
+	MaxSize          int64         // Largest accepted upload in bytes
+	URLExpiry        time.Duration // Lifetime of pre-signed upload and download URLs
+	ResumableMaxSize int64         // Largest file accepted through resumable uploads
+	ChunkMaxSize     int64         // Largest chunk accepted in one request
+	ResumableExpiry  time.Duration // How long a resumable upload may sit idle before it is discarded
+	ProxyDownloads   bool          // Serve content through the API even when storage can pre-sign download URLs
+
+	// TypeMaxSizes lowers the size limit for a MIME type, such as
+	// "application/pdf", or a top-level type, such as "video"
+	TypeMaxSizes map[string]int64
+
+	UserQuota int64 // Bytes each user may store, including uploads in progress; 0 for unlimited
+	RoomQuota int64 // Bytes each chat room may hold; 0 for unlimited
+
+	GarbageGrace time.Duration // How long unsent files and unreferenced content are kept before garbage collection
+}
+
+// SizeLimit returns the largest file of a MIME type accepted by an upload
+// method that allows maxSize. A limit for the exact type takes precedence
+// over one for its top-level type.
+func (s UploadSettings) SizeLimit(mimeType string, maxSize int64) int64 {
+	limit, ok := s.TypeMaxSizes[mimeType]
+	if !ok {
+		topLevel, _, _ := strings.Cut(mimeType, "/")
+		limit, ok = s.TypeMaxSizes[topLevel]
+	}
+	if ok && limit < maxSize {
+		return limit
+	}
+	return maxSize
+}
+
+// FileTooLargeError is returned when a file exceeds the size limit for its type
+type FileTooLargeError struct {
+	MaxSize int64
+}
+
+func (e *FileTooLargeError) Error() string {
+	return fmt.Sprintf("file size exceeds maximum allowed size of %d bytes", e.MaxSize)
+}
+
+func (e *FileTooLargeError) Unwrap() error {
+	return attachment.ErrFileTooLarge
+}
+
+// QuotaExceededError is returned when a file would take its uploader or chat
+// room over the storage quota
+type QuotaExceededError struct {
+	Scope string // attachment.UsageScopeUser or attachment.UsageScopeRoom
+	Quota int64
+	Used  int64
+	Size  int64 // Size of the rejected file
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("%s, %d of %d bytes remaining", e.Unwrap(), e.Remaining(), e.Quota)
+}
+
+// Remaining returns the bytes that may still be uploaded
+func (e *QuotaExceededError) Remaining() int64 {
+	return remainingQuota(e.Quota, e.Used)
+}
+
+func (e *QuotaExceededError) Unwrap() error {
+	if e.Scope == attachment.UsageScopeRoom {
+		return attachment.ErrRoomQuotaExceeded
+	}
+	return attachment.ErrUserQuotaExceeded
+}
+
+// OffsetMismatchError is returned when a chunk does not start where the
+// resumable upload currently ends
+type OffsetMismatchError struct {
+	Offset int64
+}
+
+func (e *OffsetMismatchError) Error() string {
+	return fmt.Sprintf("upload offset mismatch, the upload is at offset %d", e.Offset)
+}
+
+// UploadFileInput represents the input for uploading a file to a chat room
+// through the API
+type UploadFileInput struct {
+	UserID     string    `json:"user_id" validate:"required"`
+	ChatRoomID string    `json:"chat_room_id" validate:"required"`
+	Filename   string    `json:"filename" validate:"required"`
+	Size       int64     `json:"size" validate:"min=0"`
+	Content    io.Reader `json:"-" validate:"required"`
+}
+
+// CreateUploadInput represents the input for starting a direct upload to storage
+type CreateUploadInput struct {
+	UserID     string `json:"user_id" validate:"required"`
+	ChatRoomID string `json:"chat_room_id" validate:"required"`
+	Filename   string `json:"filename" validate:"required"`
+	Size       int64  `json:"size" validate:"min=0"`
+}
+
+// CreateUploadOutput tells the client where to upload the file content.
+// The content must be sent with a PUT request carrying Headers.
+type CreateUploadOutput struct {
+	File      *FileOutput       `json:"file"`
+	UploadURL string            `json:"upload_url"`
+	Method    string            `json:"method"`
+	Headers   map[string]string `json:"headers"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+// CompleteUploadInput represents the input for finishing a direct upload
+type CompleteUploadInput struct {
+	FileID string `json:"file_id" validate:"required"`
+	UserID string `json:"user_id" validate:"required"`
+}
+
+// ResumableUploadInput represents the input for reading or finishing a resumable upload
+type ResumableUploadInput struct {
+	FileID string `json:"file_id" validate:"required"`
+	UserID string `json:"user_id" validate:"required"`
+}
+
+// WriteChunkInput represents the input for appending a chunk to a resumable upload
+type WriteChunkInput struct {
+	FileID  string    `json:"file_id" validate:"required"`
+	UserID  string    `json:"user_id" validate:"required"`
+	Offset  int64     `json:"offset" validate:"min=0"` // Where the chunk starts; must match the upload's offset
+	Size    int64     `json:"size" validate:"min=0"`
+	Content io.Reader `json:"-" validate:"required"`
+}
+
+// UploadByChecksumInput represents the input for creating a file from
+// stored content with the given SHA-256, instead of uploading it again
+type UploadByChecksumInput struct {
+	UserID     string `json:"user_id" validate:"required"`
+	ChatRoomID string `json:"chat_room_id" validate:"required"`
+	Filename   string `json:"filename" validate:"required"`
+	Size       int64  `json:"size" validate:"min=0"`
+	Checksum   string `json:"checksum" validate:"required,len=64,hexadecimal"`
+}
+
+// ResumableUploadOutput represents the progress of a resumable upload
+type ResumableUploadOutput struct {
+	File      *FileOutput `json:"file"`
+	Offset    int64       `json:"offset"`
+	ExpiresAt time.Time   `json:"expires_at"`
+}
+
+// FileOutput represents an uploaded file
+type FileOutput struct {
+	ID           string    `json:"id"`
+	OwnerID      string    `json:"owner_id"`
+	ChatRoomID   string    `json:"chat_room_id"`
+	OriginalName string    `json:"original_name"`
+	Size         int64     `json:"size"`
+	MimeType     string    `json:"mime_type"`
+	Checksum     string    `json:"checksum"`
+	Status       string    `json:"status"`
+	Width        int       `json:"width,omitempty"`
+	Height       int       `json:"height,omitempty"`
+	RenderType   string    `json:"render_type,omitempty"` // Set when a thumbnail and preview are available
+	MessageID    string    `json:"message_id,omitempty"`  // Set once the file is sent with a message
+	ScanStatus   string    `json:"scan_status,omitempty"` // Set when uploads are scanned for malware
+	Threat       string    `json:"threat,omitempty"`      // Malware found in a quarantined file
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// GetFileInput represents the input for reading a file
+type GetFileInput struct {
+	FileID string `json:"file_id" validate:"required"`
+	UserID string `json:"user_id" validate:"required"`
+}
+
+// OpenThumbnailInput represents the input for reading a rendered version of an image
+type OpenThumbnailInput struct {
+	FileID  string `json:"file_id" validate:"required"`
+	UserID  string `json:"user_id" validate:"required"`
+	Variant string `json:"variant" validate:"required,oneof=thumbnail preview"`
+}
+
+// OpenFileOutput represents a file opened for download. Either URL is a
+// pre-signed download link, or Content holds the file and must be closed by
+// the caller.
+type OpenFileOutput struct {
+	File    *FileOutput
+	URL     string
+	Content io.ReadSeekCloser
+	Size    int64
+}
+
+// DeleteFileInput represents the input for deleting a file
+type DeleteFileInput struct {
+	FileID string `json:"file_id" validate:"required"`
+	UserID string `json:"user_id" validate:"required"`
+}
+
+// GetStorageUsageInput represents the input for reading a user's storage usage
+type GetStorageUsageInput struct {
+	UserID string `json:"user_id" validate:"required"`
+}
+
+// StorageUsageOutput summarises the storage taken by a user's files and the
+// limits that apply to their uploads. Quotas are zero, and remaining amounts
+// omitted, when unlimited.
+type StorageUsageOutput struct {
+	Used      int64                `json:"used"`
+	Files     int                  `json:"files"`
+	Quota     int64                `json:"quota"`
+	Remaining *int64               `json:"remaining,omitempty"`
+	Rooms     []*RoomStorageOutput `json:"rooms"`
+	Limits    StorageLimitsOutput  `json:"limits"`
+}
+
+// RoomStorageOutput represents the storage taken by a user's files in a chat room
+type RoomStorageOutput struct {
+	ChatRoomID    string `json:"chat_room_id"`
+	Used          int64  `json:"used"`
+	Files         int    `json:"files"`
+	RoomUsed      int64  `json:"room_used"` // Taken by every member's files
+	RoomQuota     int64  `json:"room_quota"`
+	RoomRemaining *int64 `json:"room_remaining,omitempty"`
+}
+
+// StorageLimitsOutput represents the size limits for uploaded files
+type StorageLimitsOutput struct {
+	MaxFileSize      int64            `json:"max_file_size"`
+	ResumableMaxSize int64            `json:"resumable_max_size"`
+	TypeMaxSizes     map[string]int64 `json:"type_max_sizes,omitempty"`
+}
+
+// Kinds of garbage removed by garbage collection
+const (
+	GarbageUnattachedFile = "unattached_file" // A file not attached to any message
+	GarbageOrphanedBlob   = "orphaned_blob"   // Shared content no file references
+	GarbageStrayObject    = "stray_object"    // A stored object no file or blob records
+)
+
+// CollectGarbageInput represents the input for collecting garbage
+type CollectGarbageInput struct {
+	DryRun bool `json:"dry_run"` // Report what would be removed without removing it
+}
+
+// GarbageItem represents a file or stored object removed as garbage
+type GarbageItem struct {
+	Kind string `json:"kind"`
+	Key  string `json:"key"` // File ID for unattached files, storage key otherwise
+	Size int64  `json:"size"`
+}
+
+// GarbageReport describes what a garbage collection removed, or would
+// remove in a dry run. The size of an unattached file whose content is
+// shared is only freed once the content is orphaned and collected.
+type GarbageReport struct {
+	DryRun   bool           `json:"dry_run"`
+	Items    []*GarbageItem `json:"items"`
+	Counts   map[string]int `json:"counts"`  // Items of each kind
+	Bytes    int64          `json:"bytes"`   // Total size of the items
+	Scanned  int            `json:"scanned"` // Stored objects checked for references
+	Duration time.Duration  `json:"duration"`
+}
+
+// publish adds what was collected to the exported garbage metrics
+func (r *GarbageReport) publish() {
+	garbageMetrics.Add("runs", 1)
+	for _, kind := range []string{GarbageUnattachedFile, GarbageOrphanedBlob, GarbageStrayObject} {
+		garbageMetrics.Add(kind+"s", int64(r.Counts[kind]))
+	}
+	garbageMetrics.Add("bytes", r.Bytes)
+	garbageMetrics.Add("scanned", int64(r.Scanned))
+	garbageMetrics.AddFloat("seconds", r.Duration.Seconds())
+}
+
+func (r *GarbageReport) add(kind, key string, size int64) {
+	r.Items = append(r.Items, &GarbageItem{Kind: kind, Key: key, Size: size})
+	r.Counts[kind]++
+	r.Bytes += size
+}
+
+// remainingQuota returns the bytes left in a quota, which may be overdrawn
+// if it was lowered
+func remainingQuota(quota, used int64) int64 {
+	if used >= quota {
+		return 0
+	}
+	return quota - used
+}
+
+// ToFileOutput converts an attachment entity to its output form
+func ToFileOutput(a *attachment.Attachment) *FileOutput {
+	return &FileOutput{
+		ID:           a.ID,
+		OwnerID:      a.OwnerID,
+		ChatRoomID:   a.ChatRoomID,
+		OriginalName: a.OriginalName,
+		Size:         a.Size,
+		MimeType:     a.MimeType,
+		Checksum:     a.Checksum,
+		Status:       a.Status,
+		Width:        a.Width,
+		Height:       a.Height,
+		RenderType:   a.RenderType,
+		MessageID:    a.MessageID,
+		ScanStatus:   a.ScanStatus,
+		Threat:       a.Threat,
+		CreatedAt:    a.CreatedAt,
+	}
+}
\ No newline at end of file
@@ -0,0 +1,1335 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"backend-go/internal/domain/attachment"
+	"backend-go/internal/domain/chat"
+	"backend-go/internal/shared/logger"
+	"backend-go/internal/shared/media"
+	"backend-go/internal/shared/scan"
+	"backend-go/internal/shared/storage"
+	"backend-go/internal/shared/validation"
+)
+
+const (
+	// pendingUploadGrace is how long after its upload URL expires a direct
+	// upload may still be completed before it is purged
+	pendingUploadGrace = time.Hour
+
+	// purgeBatchSize limits how many stale uploads are purged per run
+	purgeBatchSize = 100
+
+	// maxImageSize limits images whatever the upload limit, since their
+	// metadata is removed in memory
+	maxImageSize = 64 << 20
+
+	// orphanedBlobGrace is how long content no attachment references is kept,
+	// which leaves uploads that reserved it time to record their reference
+	orphanedBlobGrace = time.Hour
+
+	// scanBatchSize limits how many files are scanned for malware per run
+	scanBatchSize = 20
+
+	// scanRetryDelay is how long a file waits to be scanned again after its
+	// first failed scan, doubling with each further failure up to
+	// maxScanRetryDelay
+	scanRetryDelay    = time.Minute
+	maxScanRetryDelay = time.Hour
+
+	// referenceBatchSize limits how many stored objects are checked for
+	// references at once during garbage collection
+	referenceBatchSize = 500
+)
+
+// garbageMetrics publishes the totals of garbage collection in this process
+// through expvar: runs and failed runs, items collected of each kind, bytes
+// freed, stored objects scanned and time spent
+var garbageMetrics = expvar.NewMap("garbage_collection")
+
+type useCase struct {
+	attachmentRepo attachment.Repository
+	sessionRepo    attachment.UploadSessionRepository
+	chatRepo       chat.Repository
+	blob           storage.Blob
+	scanner        scan.Scanner
+	validator      validation.Validator
+	logger         logger.Logger
+	settings       UploadSettings
+}
+
+// NewUseCase creates a new file use case
+func NewUseCase(
+	attachmentRepo attachment.Repository,
+	sessionRepo attachment.UploadSessionRepository,
+	chatRepo chat.Repository,
+	blob storage.Blob,
+	scanner scan.Scanner,
+	validator validation.Validator,
+	logger logger.Logger,
+	settings UploadSettings,
+) UseCase {
+	return &useCase{
+		attachmentRepo: attachmentRepo,
+		sessionRepo:    sessionRepo,
+		chatRepo:       chatRepo,
+		blob:           blob,
+		scanner:        scanner,
+		validator:      validator,
+		logger:         logger,
+		settings:       settings,
+	}
+}
+
+func (uc *useCase) UploadFile(ctx context.Context, input UploadFileInput) (*FileOutput, error) {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid upload file input", "error", err)
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	name, mimeType, err := uc.checkUpload(ctx, input.UserID, input.ChatRoomID, input.Filename, input.Size, uc.settings.MaxSize)
+	if err != nil {
+		return nil, err
+	}
+
+	// Record the upload first so its size counts towards the quota while it is stored
+	a := attachment.NewPendingAttachment(uuid.New().String(), input.UserID, input.ChatRoomID, name, input.Size, mimeType)
+	if err := uc.createAttachment(ctx, a); err != nil {
+		return nil, err
+	}
+
+	checksum, sourceChecksum, err := uc.storeContent(ctx, a, input.Content)
+	if err != nil {
+		uc.deleteAttachment(ctx, a.ID)
+		return nil, err
+	}
+
+	if err := uc.completeUpload(ctx, a, checksum, sourceChecksum); err != nil {
+		uc.deleteStoredFile(ctx, a)
+		uc.deleteAttachment(ctx, a.ID)
+		return nil, err
+	}
+
+	uc.logger.Info("File uploaded successfully", "file_id", a.ID, "room_id", input.ChatRoomID, "user_id", input.UserID, "size", a.Size)
+	return ToFileOutput(a), nil
+}
+
+func (uc *useCase) CreateUpload(ctx context.Context, input CreateUploadInput) (*CreateUploadOutput, error) {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid create upload input", "error", err)
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	name, mimeType, err := uc.checkUpload(ctx, input.UserID, input.ChatRoomID, input.Filename, input.Size, uc.settings.MaxSize)
+	if err != nil {
+		return nil, err
+	}
+
+	id := uuid.New().String()
+	uploadURL, err := uc.blob.PresignPut(ctx, id, mimeType, uc.settings.URLExpiry)
+	if err != nil {
+		if err == storage.ErrPresignNotSupported {
+			return nil, err
+		}
+		uc.logger.Error("Failed to pre-sign upload", "error", err, "file_id", id)
+		return nil, fmt.Errorf("failed to create upload: %w", err)
+	}
+
+	a := attachment.NewPendingAttachment(id, input.UserID, input.ChatRoomID, name, input.Size, mimeType)
+	if err := uc.createAttachment(ctx, a); err != nil {
+		return nil, err
+	}
+
+	uc.logger.Info("Upload created successfully", "file_id", id, "room_id", input.ChatRoomID, "user_id", input.UserID, "size", a.Size)
+	return &CreateUploadOutput{
+		File:      ToFileOutput(a),
+		UploadURL: uploadURL,
+		Method:    http.MethodPut,
+		Headers:   map[string]string{"Content-Type": mimeType},
+		ExpiresAt: a.CreatedAt.Add(uc.settings.URLExpiry),
+	}, nil
+}
+
+func (uc *useCase) CompleteUpload(ctx context.Context, input CompleteUploadInput) (*FileOutput, error) {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid complete upload input", "error", err)
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	a, err := uc.uploaderAttachment(ctx, input.FileID, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if a.IsReady() {
+		return ToFileOutput(a), nil
+	}
+
+	// The pre-signed URL cannot limit the size or type, so verify what the client stored
+	checksum, sourceChecksum, err := uc.verifyContent(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.completeUpload(ctx, a, checksum, sourceChecksum); err != nil {
+		return nil, err
+	}
+
+	uc.logger.Info("Upload completed successfully", "file_id", a.ID, "room_id", a.ChatRoomID, "user_id", input.UserID, "size", a.Size)
+	return ToFileOutput(a), nil
+}
+
+func (uc *useCase) CreateResumableUpload(ctx context.Context, input CreateUploadInput) (*ResumableUploadOutput, error) {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid create resumable upload input", "error", err)
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	name, mimeType, err := uc.checkUpload(ctx, input.UserID, input.ChatRoomID, input.Filename, input.Size, uc.settings.ResumableMaxSize)
+	if err != nil {
+		return nil, err
+	}
+
+	a := attachment.NewPendingAttachment(uuid.New().String(), input.UserID, input.ChatRoomID, name, input.Size, mimeType)
+	if err := uc.createAttachment(ctx, a); err != nil {
+		return nil, err
+	}
+
+	session := attachment.NewUploadSession(a.ID, time.Now().Add(uc.settings.ResumableExpiry))
+	if err := uc.sessionRepo.Create(ctx, session); err != nil {
+		uc.deleteAttachment(ctx, a.ID)
+		uc.logger.Error("Failed to create upload session", "error", err, "file_id", a.ID)
+		return nil, fmt.Errorf("failed to create upload: %w", err)
+	}
+
+	uc.logger.Info("Resumable upload created successfully", "file_id", a.ID, "room_id", input.ChatRoomID, "user_id", input.UserID, "size", a.Size)
+	return toResumableUploadOutput(a, session), nil
+}
+
+func (uc *useCase) GetResumableUpload(ctx context.Context, input ResumableUploadInput) (*ResumableUploadOutput, error) {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid get resumable upload input", "error", err)
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	a, session, err := uc.uploadSession(ctx, input.FileID, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return toResumableUploadOutput(a, session), nil
+}
+
+func (uc *useCase) WriteChunk(ctx context.Context, input WriteChunkInput) (*ResumableUploadOutput, error) {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid write chunk input", "error", err)
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	a, session, err := uc.uploadSession(ctx, input.FileID, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if input.Offset != session.Offset {
+		return nil, &OffsetMismatchError{Offset: session.Offset}
+	}
+	if input.Size > uc.settings.ChunkMaxSize || session.Offset+input.Size > a.Size {
+		return nil, attachment.ErrChunkTooLarge
+	}
+	if input.Size == 0 {
+		return toResumableUploadOutput(a, session), nil
+	}
+
+	// Reject content of the wrong type before the rest of the file is sent
+	content := input.Content
+	if input.Offset == 0 && (input.Size >= media.SniffLen || input.Size == a.Size) {
+		if content, err = uc.checkContent(a, content); err != nil {
+			return nil, err
+		}
+	}
+
+	// A chunk is only recorded once it is stored in full; an interrupted
+	// request leaves the offset unchanged so the client resends the chunk
+	key := session.ChunkKey(session.Offset, uuid.New().String())
+	if err := uc.blob.Put(ctx, key, content, input.Size, "application/octet-stream"); err != nil {
+		uc.logger.Error("Failed to store upload chunk", "error", err, "file_id", a.ID, "offset", session.Offset)
+		return nil, fmt.Errorf("failed to store chunk: %w", err)
+	}
+
+	offset := session.Offset + input.Size
+	expiresAt := time.Now().Add(uc.settings.ResumableExpiry)
+	if err := uc.sessionRepo.AppendChunk(ctx, a.ID, session.Offset, offset, key, expiresAt); err != nil {
+		uc.deleteContent(ctx, key)
+		if err == attachment.ErrOffsetConflict {
+			// Another request for the same range won; report where the upload is now
+			if current, err := uc.sessionRepo.GetByAttachmentID(ctx, a.ID); err == nil {
+				return nil, &OffsetMismatchError{Offset: current.Offset}
+			}
+			return nil, attachment.ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("failed to store chunk: %w", err)
+	}
+	session.Offset = offset
+	session.ChunkKeys = append(session.ChunkKeys, key)
+	session.ExpiresAt = expiresAt
+
+	return toResumableUploadOutput(a, session), nil
+}
+
+func (uc *useCase) CompleteResumableUpload(ctx context.Context, input ResumableUploadInput) (*FileOutput, error) {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid complete resumable upload input", "error", err)
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	a, err := uc.uploaderAttachment(ctx, input.FileID, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if a.IsReady() {
+		return ToFileOutput(a), nil
+	}
+
+	_, session, err := uc.uploadSession(ctx, input.FileID, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Offset != a.Size {
+		return nil, attachment.ErrIncompleteUpload
+	}
+
+	// Join the chunks into the attachment content
+	chunks := &chunkReader{ctx: ctx, blob: uc.blob, keys: session.ChunkKeys}
+	defer chunks.Close()
+	checksum, sourceChecksum, err := uc.storeContent(ctx, a, chunks)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.completeUpload(ctx, a, checksum, sourceChecksum); err != nil {
+		return nil, err
+	}
+	uc.discardSession(ctx, session)
+
+	uc.logger.Info("Resumable upload completed successfully", "file_id", a.ID, "room_id", a.ChatRoomID, "user_id", input.UserID, "size", a.Size)
+	return ToFileOutput(a), nil
+}
+
+func (uc *useCase) UploadByChecksum(ctx context.Context, input UploadByChecksumInput) (*FileOutput, error) {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid upload by checksum input", "error", err)
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	// Nothing is transferred, so the largest size any upload method accepts applies
+	name, mimeType, err := uc.checkUpload(ctx, input.UserID, input.ChatRoomID, input.Filename, input.Size, max(uc.settings.MaxSize, uc.settings.ResumableMaxSize))
+	if err != nil {
+		return nil, err
+	}
+
+	blob, err := uc.attachmentRepo.GetBlobByChecksum(ctx, strings.ToLower(input.Checksum), input.UserID)
+	if err != nil {
+		if err == attachment.ErrBlobNotFound {
+			return nil, err
+		}
+		uc.logger.Error("Failed to get blob", "error", err, "checksum", input.Checksum)
+		return nil, fmt.Errorf("failed to upload file: %w", err)
+	}
+	// The content was only checked against the type it was uploaded as
+	if blob.MimeType != mimeType {
+		return nil, attachment.ErrBlobNotFound
+	}
+
+	a := attachment.NewSharedAttachment(uuid.New().String(), input.UserID, input.ChatRoomID, name, blob)
+	uc.holdForScan(a)
+	if err := uc.createAttachment(ctx, a); err != nil {
+		return nil, err
+	}
+
+	uc.logger.Info("File uploaded by checksum successfully", "file_id", a.ID, "room_id", input.ChatRoomID, "user_id", input.UserID, "size", a.Size)
+	return ToFileOutput(a), nil
+}
+
+func (uc *useCase) GetFile(ctx context.Context, input GetFileInput) (*FileOutput, error) {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid get file input", "error", err)
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	a, err := uc.visibleAttachment(ctx, input.FileID, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return ToFileOutput(a), nil
+}
+
+func (uc *useCase) OpenFile(ctx context.Context, input GetFileInput) (*OpenFileOutput, error) {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid open file input", "error", err)
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	a, err := uc.availableAttachment(ctx, input.FileID, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return uc.openContent(ctx, a, a.StorageKey, storage.GetOptions{
+		ContentType:        a.MimeType,
+		ContentDisposition: storage.ContentDisposition(a.OriginalName),
+		CacheControl:       ContentCacheControl,
+	})
+}
+
+func (uc *useCase) OpenThumbnail(ctx context.Context, input OpenThumbnailInput) (*OpenFileOutput, error) {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid open thumbnail input", "error", err)
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	a, err := uc.availableAttachment(ctx, input.FileID, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !a.HasThumbnail() {
+		return nil, attachment.ErrThumbnailNotFound
+	}
+
+	return uc.openContent(ctx, a, a.VariantKey(input.Variant), storage.GetOptions{
+		ContentType:  a.RenderType,
+		CacheControl: ContentCacheControl,
+	})
+}
+
+func (uc *useCase) DeleteFile(ctx context.Context, input DeleteFileInput) error {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid delete file input", "error", err)
+		return fmt.Errorf("invalid input: %w", err)
+	}
+
+	a, err := uc.getAttachment(ctx, input.FileID)
+	if err != nil {
+		return err
+	}
+
+	// Uploaders may always remove their own files, including unfinished
+	// uploads and quarantined files; otherwise only room admins may
+	if !a.IsUploadedBy(input.UserID) {
+		if !a.IsAvailable() {
+			return attachment.ErrAttachmentNotFound
+		}
+		role, err := uc.chatRepo.GetMemberRole(ctx, a.ChatRoomID, input.UserID)
+		if err != nil {
+			if err == chat.ErrMemberNotFound {
+				return attachment.ErrAttachmentNotFound
+			}
+			uc.logger.Error("Failed to get member role", "error", err, "room_id", a.ChatRoomID, "user_id", input.UserID)
+			return fmt.Errorf("failed to delete file: %w", err)
+		}
+		if !chat.IsAdminRole(role) {
+			return attachment.ErrNotAuthorized
+		}
+	}
+
+	// Chunks of a resumable upload are only known through its session
+	if !a.IsReady() {
+		session, err := uc.sessionRepo.GetByAttachmentID(ctx, a.ID)
+		if err != nil && err != attachment.ErrSessionNotFound {
+			return fmt.Errorf("failed to delete file: %w", err)
+		}
+		if session != nil {
+			uc.discardSession(ctx, session)
+		}
+	}
+
+	if err := uc.attachmentRepo.Delete(ctx, a.ID); err != nil {
+		uc.logger.Error("Failed to delete attachment", "error", err, "file_id", a.ID)
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	uc.deleteStoredFile(ctx, a)
+
+	uc.logger.Info("File deleted successfully", "file_id", a.ID, "room_id", a.ChatRoomID, "user_id", input.UserID)
+	return nil
+}
+
+func (uc *useCase) GetStorageUsage(ctx context.Context, input GetStorageUsageInput) (*StorageUsageOutput, error) {
+	// Validate input
+	if err := uc.validator.Struct(input); err != nil {
+		uc.logger.Error("Invalid get storage usage input", "error", err)
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	usage, err := uc.attachmentRepo.GetUsage(ctx, attachment.UsageScopeUser, input.UserID)
+	if err != nil {
+		uc.logger.Error("Failed to get storage usage", "error", err, "user_id", input.UserID)
+		return nil, fmt.Errorf("failed to get storage usage: %w", err)
+	}
+
+	rooms, err := uc.attachmentRepo.ListRoomUsage(ctx, input.UserID)
+	if err != nil {
+		uc.logger.Error("Failed to list room storage usage", "error", err, "user_id", input.UserID)
+		return nil, fmt.Errorf("failed to get storage usage: %w", err)
+	}
+
+	output := &StorageUsageOutput{
+		Used:      usage.Bytes,
+		Files:     usage.Files,
+		Quota:     uc.settings.UserQuota,
+		Remaining: optionalRemaining(uc.settings.UserQuota, usage.Bytes),
+		Rooms:     make([]*RoomStorageOutput, 0, len(rooms)),
+		Limits: StorageLimitsOutput{
+			MaxFileSize:      uc.settings.MaxSize,
+			ResumableMaxSize: uc.settings.ResumableMaxSize,
+			TypeMaxSizes:     uc.settings.TypeMaxSizes,
+		},
+	}
+	for _, room := range rooms {
+		output.Rooms = append(output.Rooms, &RoomStorageOutput{
+			ChatRoomID:    room.ChatRoomID,
+			Used:          room.Bytes,
+			Files:         room.Files,
+			RoomUsed:      room.RoomBytes,
+			RoomQuota:     uc.settings.RoomQuota,
+			RoomRemaining: optionalRemaining(uc.settings.RoomQuota, room.RoomBytes),
+		})
+	}
+
+	return output, nil
+}
+
+// PurgeStaleUploads removes direct uploads that were never completed and
+// resumable uploads that expired, along with the content of deleted files
+// that no other file shares
+func (uc *useCase) PurgeStaleUploads(ctx context.Context) (int, error) {
+	before := time.Now().Add(-(uc.settings.URLExpiry + pendingUploadGrace))
+	stale, err := uc.attachmentRepo.ListPendingBefore(ctx, before, purgeBatchSize)
+	if err != nil {
+		uc.logger.Error("Failed to list stale uploads", "error", err)
+		return 0, fmt.Errorf("failed to purge stale uploads: %w", err)
+	}
+
+	expired, err := uc.sessionRepo.ListExpired(ctx, time.Now(), purgeBatchSize)
+	if err != nil {
+		uc.logger.Error("Failed to list expired upload sessions", "error", err)
+		return 0, fmt.Errorf("failed to purge stale uploads: %w", err)
+	}
+
+	ids := make([]string, 0, len(stale)+len(expired))
+	for _, a := range stale {
+		ids = append(ids, a.ID)
+	}
+	for _, session := range expired {
+		uc.discardSession(ctx, session)
+		ids = append(ids, session.AttachmentID)
+	}
+
+	purged := 0
+	for _, id := range ids {
+		if err := uc.attachmentRepo.Delete(ctx, id); err != nil && err != attachment.ErrAttachmentNotFound {
+			uc.logger.Error("Failed to delete stale upload", "error", err, "file_id", id)
+			continue
+		}
+		uc.deleteContent(ctx, id)
+		purged++
+	}
+
+	if purged > 0 {
+		uc.logger.Info("Stale uploads purged", "count", purged)
+	}
+
+	blobs := 0
+	err = uc.purgeOrphanedBlobs(ctx, time.Now().Add(-orphanedBlobGrace), false, func(*attachment.Blob) { blobs++ })
+	if err == nil && blobs > 0 {
+		uc.logger.Info("Orphaned blobs purged", "count", blobs)
+	}
+	return purged, nil
+}
+
+// CollectGarbage removes ready files that are not attached to a message,
+// whether they were never sent or their message was deleted, then content
+// no file references, and finally stored objects left behind by anything
+// else, such as chat rooms that were purged. Only garbage older than the
+// grace period is collected.
+func (uc *useCase) CollectGarbage(ctx context.Context, input CollectGarbageInput) (*GarbageReport, error) {
+	started := time.Now()
+	before := started.Add(-uc.settings.GarbageGrace)
+	report := &GarbageReport{DryRun: input.DryRun, Items: []*GarbageItem{}, Counts: map[string]int{}}
+
+	if err := uc.collectUnattachedFiles(ctx, before, report); err != nil {
+		garbageMetrics.Add("failures", 1)
+		return nil, fmt.Errorf("failed to collect garbage: %w", err)
+	}
+
+	blobsBefore := before
+	if recent := started.Add(-orphanedBlobGrace); recent.Before(blobsBefore) {
+		blobsBefore = recent
+	}
+	err := uc.purgeOrphanedBlobs(ctx, blobsBefore, input.DryRun, func(b *attachment.Blob) {
+		report.add(GarbageOrphanedBlob, b.Key, b.Size)
+	})
+	if err != nil {
+		garbageMetrics.Add("failures", 1)
+		return nil, fmt.Errorf("failed to collect garbage: %w", err)
+	}
+
+	if err := uc.collectStrayObjects(ctx, before, report); err != nil {
+		garbageMetrics.Add("failures", 1)
+		return nil, fmt.Errorf("failed to collect garbage: %w", err)
+	}
+
+	report.Duration = time.Since(started)
+	if !report.DryRun {
+		report.publish()
+	}
+	uc.logger.Info("Garbage collected",
+		"dry_run", report.DryRun,
+		"unattached_files", report.Counts[GarbageUnattachedFile],
+		"orphaned_blobs", report.Counts[GarbageOrphanedBlob],
+		"stray_objects", report.Counts[GarbageStrayObject],
+		"bytes", report.Bytes,
+		"scanned", report.Scanned,
+		"duration_ms", report.Duration.Milliseconds(),
+	)
+	return report, nil
+}
+
+// ScanPendingFiles scans files waiting for a malware scan and returns those
+// whose scan finished, so their uploaders can be told the outcome. Files
+// whose content can never be scanned finish as failed, while other failures
+// are tried again later, waiting longer after each attempt.
+func (uc *useCase) ScanPendingFiles(ctx context.Context) ([]*FileOutput, error) {
+	if uc.scanner == nil {
+		return nil, nil
+	}
+
+	pending, err := uc.attachmentRepo.ListScanPending(ctx, time.Now(), scanBatchSize)
+	if err != nil {
+		uc.logger.Error("Failed to list files pending scan", "error", err)
+		return nil, fmt.Errorf("failed to scan files: %w", err)
+	}
+
+	scanned := make([]*FileOutput, 0, len(pending))
+	for _, a := range pending {
+		if err := uc.scanFile(ctx, a); err != nil {
+			continue
+		}
+		scanned = append(scanned, ToFileOutput(a))
+	}
+	return scanned, nil
+}
+
+// checkUpload validates a new file and returns its cleaned name and MIME type
+func (uc *useCase) checkUpload(ctx context.Context, userID, chatRoomID, filename string, size, maxSize int64) (string, string, error) {
+	name := attachment.CleanFileName(filename)
+	mimeType, ok := attachment.ContentType(name)
+	if name == "" || !ok {
+		return "", "", attachment.ErrFileTypeNotAllowed
+	}
+	limit := uc.settings.SizeLimit(mimeType, maxSize)
+	if media.IsImage(mimeType) && limit > maxImageSize {
+		limit = maxImageSize
+	}
+	if size > limit {
+		return "", "", &FileTooLargeError{MaxSize: limit}
+	}
+
+	// Only members may post files to a room
+	isMember, err := uc.chatRepo.IsMember(ctx, chatRoomID, userID)
+	if err != nil {
+		uc.logger.Error("Failed to check membership", "error", err, "room_id", chatRoomID, "user_id", userID)
+		return "", "", fmt.Errorf("failed to verify chat room: %w", err)
+	}
+	if !isMember {
+		return "", "", chat.ErrChatRoomNotFound
+	}
+
+	return name, mimeType, nil
+}
+
+// createAttachment records a new attachment within the storage quota,
+// reporting how much of the quota is left if it does not fit
+func (uc *useCase) createAttachment(ctx context.Context, a *attachment.Attachment) error {
+	err := uc.attachmentRepo.Create(ctx, a, attachment.Quota{
+		UserBytes: uc.settings.UserQuota,
+		RoomBytes: uc.settings.RoomQuota,
+	})
+	if err == nil {
+		return nil
+	}
+
+	var scope, ownerID string
+	var quota int64
+	switch err {
+	case attachment.ErrUserQuotaExceeded:
+		scope, ownerID, quota = attachment.UsageScopeUser, a.OwnerID, uc.settings.UserQuota
+	case attachment.ErrRoomQuotaExceeded:
+		scope, ownerID, quota = attachment.UsageScopeRoom, a.ChatRoomID, uc.settings.RoomQuota
+	default:
+		uc.logger.Error("Failed to create attachment", "error", err, "file_id", a.ID)
+		return fmt.Errorf("failed to create upload: %w", err)
+	}
+
+	usage, usageErr := uc.attachmentRepo.GetUsage(ctx, scope, ownerID)
+	if usageErr != nil {
+		uc.logger.Error("Failed to get storage usage", "error", usageErr, "scope", scope, "owner_id", ownerID)
+		return err
+	}
+
+	uc.logger.Info("Storage quota exceeded", "scope", scope, "owner_id", ownerID, "used", usage.Bytes, "size", a.Size)
+	return &QuotaExceededError{Scope: scope, Quota: quota, Used: usage.Bytes, Size: a.Size}
+}
+
+// completeUpload marks an attachment ready once its content is stored and
+// verified under the attachment ID. The content moves to the blob of its
+// checksum, where it is stored once however many files share it.
+func (uc *useCase) completeUpload(ctx context.Context, a *attachment.Attachment, checksum, sourceChecksum string) error {
+	ready := *a
+	ready.MarkReady(checksum)
+	uc.holdForScan(&ready)
+
+	blob, err := uc.attachmentRepo.ReserveBlob(ctx, attachment.NewBlob(&ready, sourceChecksum))
+	if err != nil {
+		uc.logger.Error("Failed to reserve blob", "error", err, "file_id", a.ID)
+		return fmt.Errorf("failed to complete upload: %w", err)
+	}
+
+	// Content of a referenced blob is already stored. Otherwise it may have
+	// been purged or still be on its way from another upload, and storing it
+	// again is harmless since the content is identical.
+	if blob.RefCount == 0 {
+		if err := uc.copyStoredFile(ctx, a, &ready); err != nil {
+			return err
+		}
+	} else {
+		ready.RenderType = blob.RenderType
+	}
+
+	if err := uc.attachmentRepo.MarkReady(ctx, &ready); err != nil {
+		if err == attachment.ErrAttachmentNotFound {
+			return err
+		}
+		uc.logger.Error("Failed to complete upload", "error", err, "file_id", a.ID)
+		return fmt.Errorf("failed to complete upload: %w", err)
+	}
+
+	uc.deleteStoredFile(ctx, a)
+	*a = ready
+	return nil
+}
+
+// holdForScan keeps a new file from being served until it is scanned for
+// malware, if uploads are scanned
+func (uc *useCase) holdForScan(a *attachment.Attachment) {
+	if uc.scanner != nil {
+		a.ScanStatus = attachment.ScanPending
+	}
+}
+
+// scanFile scans the stored content of an attachment and records the outcome
+func (uc *useCase) scanFile(ctx context.Context, a *attachment.Attachment) error {
+	threat, err := uc.scanContent(ctx, a.StorageKey)
+	switch {
+	case err == storage.ErrNotFound || errors.Is(err, scan.ErrScanFailed):
+		uc.logger.Error("File cannot be scanned", "error", err, "file_id", a.ID, "user_id", a.OwnerID)
+		a.RecordScanFailure()
+	case err != nil:
+		uc.logger.Error("Failed to scan file", "error", err, "file_id", a.ID, "attempts", a.ScanAttempts+1)
+		uc.postponeScan(ctx, a)
+		return err
+	default:
+		a.RecordScan(threat)
+	}
+
+	if err := uc.attachmentRepo.RecordScan(ctx, a); err != nil {
+		// Files deleted while they were scanned are skipped
+		if err != attachment.ErrAttachmentNotFound {
+			uc.logger.Error("Failed to record scan", "error", err, "file_id", a.ID)
+		}
+		return err
+	}
+
+	if a.ScanStatus == attachment.ScanQuarantined {
+		uc.logger.Info("Quarantined file with malware", "file_id", a.ID, "room_id", a.ChatRoomID, "user_id", a.OwnerID, "threat", threat)
+	}
+	return nil
+}
+
+// scanContent scans the content stored under key, returning the name of the
+// malware found in it
+func (uc *useCase) scanContent(ctx context.Context, key string) (string, error) {
+	object, err := uc.blob.Get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	defer object.Content.Close()
+
+	return uc.scanner.Scan(ctx, object.Content)
+}
+
+// postponeScan schedules the next scan of a file that failed to scan, so
+// files that keep failing make way for others
+func (uc *useCase) postponeScan(ctx context.Context, a *attachment.Attachment) {
+	// Scans cut short by shutdown are not held against the file
+	if ctx.Err() != nil {
+		return
+	}
+
+	delay := scanRetryDelay
+	for i := 0; i < a.ScanAttempts && delay < maxScanRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxScanRetryDelay {
+		delay = maxScanRetryDelay
+	}
+
+	a.PostponeScan(time.Now().Add(delay))
+	if err := uc.attachmentRepo.PostponeScan(ctx, a); err != nil && err != attachment.ErrAttachmentNotFound {
+		uc.logger.Error("Failed to postpone scan", "error", err, "file_id", a.ID)
+	}
+}
+
+// copyStoredFile copies the content of an upload and its thumbnails to
+// where the ready attachment is stored
+func (uc *useCase) copyStoredFile(ctx context.Context, upload, ready *attachment.Attachment) error {
+	keys := map[string]string{upload.StorageKey: ready.StorageKey}
+	if ready.HasThumbnail() {
+		for _, variant := range []string{attachment.VariantThumbnail, attachment.VariantPreview} {
+			keys[upload.VariantKey(variant)] = ready.VariantKey(variant)
+		}
+	}
+
+	for src, dst := range keys {
+		if err := uc.blob.Copy(ctx, src, dst); err != nil {
+			uc.logger.Error("Failed to copy stored file", "error", err, "file_id", upload.ID, "key", dst)
+			return fmt.Errorf("failed to complete upload: %w", err)
+		}
+	}
+	return nil
+}
+
+// verifyContent checks that a direct upload stored exactly the declared
+// size of content matching its type, and returns the checksums of the
+// stored and the uploaded content. Rejected content is removed so the client
+// can upload again while the URL is valid.
+func (uc *useCase) verifyContent(ctx context.Context, a *attachment.Attachment) (string, string, error) {
+	object, err := uc.blob.Get(ctx, a.ID)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return "", "", attachment.ErrIncompleteUpload
+		}
+		uc.logger.Error("Failed to read upload", "error", err, "file_id", a.ID)
+		return "", "", fmt.Errorf("failed to complete upload: %w", err)
+	}
+	defer object.Content.Close()
+
+	checksum, sourceChecksum, err := uc.readStoredContent(ctx, a, object.Content)
+	if err == attachment.ErrIncompleteUpload || err == attachment.ErrContentMismatch {
+		uc.deleteContent(ctx, a.ID)
+	}
+	return checksum, sourceChecksum, err
+}
+
+// readStoredContent checks content that is already stored under the
+// attachment ID, rewriting it only if it is an image
+func (uc *useCase) readStoredContent(ctx context.Context, a *attachment.Attachment, content io.Reader) (string, string, error) {
+	content, err := uc.checkContent(a, content)
+	if err != nil {
+		return "", "", err
+	}
+	if media.IsImage(a.MimeType) {
+		return uc.storeImage(ctx, a, content)
+	}
+
+	hash := sha256.New()
+	n, err := io.Copy(hash, io.LimitReader(content, a.Size+1))
+	if err != nil {
+		uc.logger.Error("Failed to read upload", "error", err, "file_id", a.ID)
+		return "", "", fmt.Errorf("failed to complete upload: %w", err)
+	}
+	if n != a.Size {
+		return "", "", attachment.ErrIncompleteUpload
+	}
+
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	return checksum, checksum, nil
+}
+
+// storeContent stores exactly the declared size of content under the
+// attachment ID, rejecting content that does not match the attachment's
+// type, and returns the checksums of the stored and the uploaded content,
+// which differ for images
+func (uc *useCase) storeContent(ctx context.Context, a *attachment.Attachment, content io.Reader) (string, string, error) {
+	content, err := uc.checkContent(a, content)
+	if err != nil {
+		return "", "", err
+	}
+	if media.IsImage(a.MimeType) {
+		return uc.storeImage(ctx, a, content)
+	}
+
+	// Hash and count the content while it is stored
+	hash := sha256.New()
+	counter := &countingWriter{}
+	if err := uc.blob.Put(ctx, a.ID, io.TeeReader(content, io.MultiWriter(hash, counter)), a.Size, a.MimeType); err != nil {
+		uc.logger.Error("Failed to store file", "error", err, "file_id", a.ID)
+		return "", "", fmt.Errorf("failed to store file: %w", err)
+	}
+	if counter.n != a.Size {
+		uc.deleteContent(ctx, a.ID)
+		return "", "", attachment.ErrIncompleteUpload
+	}
+
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	return checksum, checksum, nil
+}
+
+// storeImage stores an image without its metadata, along with a rendered
+// thumbnail and preview. The attachment's size and image details are updated
+// to describe what was stored.
+func (uc *useCase) storeImage(ctx context.Context, a *attachment.Attachment, content io.Reader) (string, string, error) {
+	data, err := io.ReadAll(io.LimitReader(content, a.Size+1))
+	if err != nil {
+		uc.logger.Error("Failed to read image", "error", err, "file_id", a.ID)
+		return "", "", fmt.Errorf("failed to store file: %w", err)
+	}
+	if int64(len(data)) != a.Size {
+		return "", "", attachment.ErrIncompleteUpload
+	}
+
+	img, err := media.ProcessImage(data, a.MimeType)
+	if err != nil {
+		return "", "", attachment.ErrContentMismatch
+	}
+	if err := uc.blob.Put(ctx, a.ID, bytes.NewReader(img.Data), int64(len(img.Data)), a.MimeType); err != nil {
+		uc.logger.Error("Failed to store file", "error", err, "file_id", a.ID)
+		return "", "", fmt.Errorf("failed to store file: %w", err)
+	}
+	a.Size = int64(len(img.Data))
+	a.Width, a.Height = img.Width, img.Height
+
+	// Thumbnails are a convenience, so failing to store them does not fail the upload
+	a.RenderType = ""
+	if img.Thumbnail != nil {
+		thumbnailKey, previewKey := a.VariantKey(attachment.VariantThumbnail), a.VariantKey(attachment.VariantPreview)
+		err := uc.blob.Put(ctx, thumbnailKey, bytes.NewReader(img.Thumbnail), int64(len(img.Thumbnail)), img.RenderType)
+		if err == nil {
+			err = uc.blob.Put(ctx, previewKey, bytes.NewReader(img.Preview), int64(len(img.Preview)), img.RenderType)
+		}
+		if err != nil {
+			uc.logger.Error("Failed to store thumbnail", "error", err, "file_id", a.ID)
+			uc.deleteContent(ctx, thumbnailKey)
+		} else {
+			a.RenderType = img.RenderType
+		}
+	}
+
+	checksum, sourceChecksum := sha256.Sum256(img.Data), sha256.Sum256(data)
+	return hex.EncodeToString(checksum[:]), hex.EncodeToString(sourceChecksum[:]), nil
+}
+
+// checkContent rejects content whose leading bytes do not match the
+// attachment's type. The returned reader still yields all of the content.
+func (uc *useCase) checkContent(a *attachment.Attachment, content io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReaderSize(content, media.SniffLen)
+	head, err := buffered.Peek(media.SniffLen)
+	if err != nil && err != io.EOF {
+		uc.logger.Error("Failed to read file", "error", err, "file_id", a.ID)
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	if detected := media.DetectContentType(head); !attachment.MatchesContent(a.MimeType, detected) {
+		uc.logger.Info("Rejected file with mismatched content", "file_id", a.ID, "mime_type", a.MimeType, "detected", detected)
+		return nil, attachment.ErrContentMismatch
+	}
+	return buffered, nil
+}
+
+// openContent returns a pre-signed URL for stored content or, if the storage
+// cannot be reached by clients or downloads are proxied, the content itself
+func (uc *useCase) openContent(ctx context.Context, a *attachment.Attachment, key string, options storage.GetOptions) (*OpenFileOutput, error) {
+	// Send clients straight to storage when it can be reached directly
+	if !uc.settings.ProxyDownloads {
+		downloadURL, err := uc.blob.PresignGet(ctx, key, uc.settings.URLExpiry, options)
+		if err == nil {
+			return &OpenFileOutput{File: ToFileOutput(a), URL: downloadURL}, nil
+		}
+		if err != storage.ErrPresignNotSupported {
+			uc.logger.Error("Failed to pre-sign download", "error", err, "file_id", a.ID)
+			return nil, fmt.Errorf("failed to open file: %w", err)
+		}
+	}
+
+	object, err := uc.blob.Get(ctx, key)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			uc.logger.Error("Attachment content is missing", "file_id", a.ID, "key", key)
+			return nil, attachment.ErrAttachmentNotFound
+		}
+		uc.logger.Error("Failed to open file", "error", err, "file_id", a.ID)
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	return &OpenFileOutput{File: ToFileOutput(a), Content: object.Content, Size: object.Size}, nil
+}
+
+// getAttachment loads an attachment by ID
+func (uc *useCase) getAttachment(ctx context.Context, id string) (*attachment.Attachment, error) {
+	a, err := uc.attachmentRepo.GetByID(ctx, id)
+	if err != nil {
+		if err == attachment.ErrAttachmentNotFound {
+			return nil, err
+		}
+		uc.logger.Error("Failed to get attachment", "error", err, "file_id", id)
+		return nil, fmt.Errorf("failed to get file: %w", err)
+	}
+	return a, nil
+}
+
+// uploaderAttachment loads an attachment for the user who uploaded it.
+// Other users are told it does not exist.
+func (uc *useCase) uploaderAttachment(ctx context.Context, id, userID string) (*attachment.Attachment, error) {
+	a, err := uc.getAttachment(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !a.IsUploadedBy(userID) {
+		return nil, attachment.ErrAttachmentNotFound
+	}
+	return a, nil
+}
+
+// uploadSession loads an unexpired resumable upload of the user
+func (uc *useCase) uploadSession(ctx context.Context, id, userID string) (*attachment.Attachment, *attachment.UploadSession, error) {
+	a, err := uc.uploaderAttachment(ctx, id, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if a.IsReady() {
+		return nil, nil, attachment.ErrSessionNotFound
+	}
+
+	session, err := uc.sessionRepo.GetByAttachmentID(ctx, a.ID)
+	if err != nil {
+		if err == attachment.ErrSessionNotFound {
+			return nil, nil, err
+		}
+		uc.logger.Error("Failed to get upload session", "error", err, "file_id", a.ID)
+		return nil, nil, fmt.Errorf("failed to get upload: %w", err)
+	}
+	if session.IsExpired() {
+		return nil, nil, attachment.ErrSessionNotFound
+	}
+
+	return a, session, nil
+}
+
+// discardSession removes the stored chunks and the session of a resumable upload
+func (uc *useCase) discardSession(ctx context.Context, session *attachment.UploadSession) {
+	for _, key := range session.ChunkKeys {
+		uc.deleteContent(ctx, key)
+	}
+	if err := uc.sessionRepo.Delete(ctx, session.AttachmentID); err != nil {
+		uc.logger.Error("Failed to delete upload session", "error", err, "file_id", session.AttachmentID)
+	}
+}
+
+// visibleAttachment loads an uploaded attachment the user may read. Files
+// in rooms the user is not a member of are reported as not found so their
+// existence is not revealed, as are files held back by a malware scan to
+// anyone but their uploader.
+func (uc *useCase) visibleAttachment(ctx context.Context, id, userID string) (*attachment.Attachment, error) {
+	a, err := uc.getAttachment(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !a.IsReady() || (!a.IsAvailable() && !a.IsUploadedBy(userID)) {
+		return nil, attachment.ErrAttachmentNotFound
+	}
+
+	isMember, err := uc.chatRepo.IsMember(ctx, a.ChatRoomID, userID)
+	if err != nil {
+		uc.logger.Error("Failed to check membership", "error", err, "room_id", a.ChatRoomID, "user_id", userID)
+		return nil, fmt.Errorf("failed to get file: %w", err)
+	}
+	if !isMember {
+		return nil, attachment.ErrAttachmentNotFound
+	}
+
+	return a, nil
+}
+
+// availableAttachment loads an attachment whose content the user may
+// download, telling uploaders why their file cannot be downloaded yet
+func (uc *useCase) availableAttachment(ctx context.Context, id, userID string) (*attachment.Attachment, error) {
+	a, err := uc.visibleAttachment(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch a.ScanStatus {
+	case attachment.ScanPending:
+		return nil, attachment.ErrScanPending
+	case attachment.ScanQuarantined:
+		return nil, attachment.ErrFileQuarantined
+	case attachment.ScanFailed:
+		return nil, attachment.ErrScanFailed
+	}
+	return a, nil
+}
+
+// deleteAttachment removes an attachment whose upload failed, which also
+// returns its size to the quota
+func (uc *useCase) deleteAttachment(ctx context.Context, id string) {
+	if err := uc.attachmentRepo.Delete(ctx, id); err != nil {
+		uc.logger.Error("Failed to delete attachment", "error", err, "file_id", id)
+	}
+}
+
+// deleteContent removes stored content, logging rather than failing since
+// the attachment itself is already gone or was never recorded
+func (uc *useCase) deleteContent(ctx context.Context, id string) {
+	if err := uc.blob.Delete(ctx, id); err != nil {
+		uc.logger.Error("Failed to delete stored file", "error", err, "file_id", id)
+	}
+}
+
+// deleteStoredFile removes the content of an attachment and its thumbnails.
+// Content shared through a blob is left to be purged once it is orphaned.
+func (uc *useCase) deleteStoredFile(ctx context.Context, a *attachment.Attachment) {
+	if !a.HasOwnContent() {
+		return
+	}
+	uc.deleteContent(ctx, a.StorageKey)
+	if a.HasThumbnail() {
+		uc.deleteContent(ctx, a.VariantKey(attachment.VariantThumbnail))
+		uc.deleteContent(ctx, a.VariantKey(attachment.VariantPreview))
+	}
+}
+
+// collectUnattachedFiles removes ready files created before the given time
+// that are not attached to a message, adding them to the report
+func (uc *useCase) collectUnattachedFiles(ctx context.Context, before time.Time, report *GarbageReport) error {
+	afterID := ""
+	for {
+		files, err := uc.attachmentRepo.ListUnattachedBefore(ctx, before, afterID, purgeBatchSize)
+		if err != nil {
+			uc.logger.Error("Failed to list unattached files", "error", err)
+			return err
+		}
+
+		for _, a := range files {
+			afterID = a.ID
+			if !report.DryRun {
+				if err := uc.attachmentRepo.DeleteUnattached(ctx, a.ID); err != nil {
+					if err != attachment.ErrAttachmentNotFound {
+						uc.logger.Error("Failed to delete unattached file", "error", err, "file_id", a.ID)
+					}
+					continue
+				}
+				uc.deleteStoredFile(ctx, a)
+			}
+			report.add(GarbageUnattachedFile, a.ID, a.Size)
+		}
+
+		if len(files) < purgeBatchSize {
+			return nil
+		}
+	}
+}
+
+// purgeOrphanedBlobs removes the content of blobs orphaned since before the
+// given time, calling removed for each blob that was removed, or would be
+// in a dry run
+func (uc *useCase) purgeOrphanedBlobs(ctx context.Context, before time.Time, dryRun bool, removed func(*attachment.Blob)) error {
+	afterKey := ""
+	for {
+		blobs, err := uc.attachmentRepo.ListOrphanedBlobs(ctx, before, afterKey, purgeBatchSize)
+		if err != nil {
+			uc.logger.Error("Failed to list orphaned blobs", "error", err)
+			return err
+		}
+
+		for _, b := range blobs {
+			afterKey = b.Key
+			if !dryRun {
+				err := uc.attachmentRepo.DeleteOrphanedBlob(ctx, b.Key, before, func() error {
+					// The record stays if any content is left, so the next run tries again
+					for _, key := range []string{b.Key, attachment.VariantKey(b.Key, attachment.VariantThumbnail), attachment.VariantKey(b.Key, attachment.VariantPreview)} {
+						if err := uc.blob.Delete(ctx, key); err != nil {
+							return err
+						}
+					}
+					return nil
+				})
+				if err != nil {
+					if err != attachment.ErrBlobNotFound {
+						uc.logger.Error("Failed to delete orphaned blob", "error", err, "key", b.Key)
+					}
+					continue
+				}
+			}
+			removed(b)
+		}
+
+		if len(blobs) < purgeBatchSize {
+			return nil
+		}
+	}
+}
+
+// collectStrayObjects removes stored objects last modified before the given
+// time whose content no blob or file records, adding them to the report.
+// Objects are checked against the database in batches as storage lists them.
+// Only objects under keys that files are stored under are considered, since
+// the storage may be shared with other applications.
+func (uc *useCase) collectStrayObjects(ctx context.Context, before time.Time, report *GarbageReport) error {
+	batch := make([]storage.ObjectInfo, 0, referenceBatchSize)
+	check := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		keys := make([]string, 0, len(batch))
+		for _, object := range batch {
+			keys = append(keys, attachment.BaseKey(object.Key))
+		}
+		referenced, err := uc.attachmentRepo.ListReferencedKeys(ctx, keys)
+		if err != nil {
+			return err
+		}
+		recorded := make(map[string]bool, len(referenced))
+		for _, key := range referenced {
+			recorded[key] = true
+		}
+
+		for _, object := range batch {
+			if recorded[attachment.BaseKey(object.Key)] {
+				continue
+			}
+			if !report.DryRun {
+				if err := uc.blob.Delete(ctx, object.Key); err != nil {
+					uc.logger.Error("Failed to delete stray object", "error", err, "key", object.Key)
+					continue
+				}
+			}
+			report.add(GarbageStrayObject, object.Key, object.Size)
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	err := uc.blob.List(ctx, func(object storage.ObjectInfo) error {
+		report.Scanned++
+		if !attachment.IsStorageKey(object.Key) || !object.ModifiedAt.Before(before) {
+			return nil
+		}
+		batch = append(batch, object)
+		if len(batch) < referenceBatchSize {
+			return nil
+		}
+		return check()
+	})
+	if err == nil {
+		err = check()
+	}
+	if err != nil {
+		uc.logger.Error("Failed to collect stray objects", "error", err)
+		return err
+	}
+	return nil
+}
+
+// optionalRemaining returns the bytes left in a quota, or nil if it is unlimited
+func optionalRemaining(quota, used int64) *int64 {
+	if quota <= 0 {
+		return nil
+	}
+	remaining := remainingQuota(quota, used)
+	return &remaining
+}
+
+func toResumableUploadOutput(a *attachment.Attachment, session *attachment.UploadSession) *ResumableUploadOutput {
+	return &ResumableUploadOutput{
+		File:      ToFileOutput(a),
+		Offset:    session.Offset,
+		ExpiresAt: session.ExpiresAt,
+	}
+}
+
+// chunkReader reads stored chunks one after another, opening each only when
+// it is reached
+type chunkReader struct {
+	ctx     context.Context
+	blob    storage.Blob
+	keys    []string
+	current io.ReadCloser
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.keys) == 0 {
+				return 0, io.EOF
+			}
+			object, err := r.blob.Get(r.ctx, r.keys[0])
+			if err != nil {
+				return 0, fmt.Errorf("failed to read chunk: %w", err)
+			}
+			r.current = object.Content
+			r.keys = r.keys[1:]
+		}
+
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (r *chunkReader) Close() error {
+	if r.current == nil {
+		return nil
+	}
+	return r.current.Close()
+}
+
+// countingWriter counts the bytes written to it
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
\ No newline at end of file
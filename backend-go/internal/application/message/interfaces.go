@@ -2,6 +2,8 @@ package message
 
 import (
 	"context"
+	"fmt"
+	"math"
 	"time"
 )
 
@@ -18,8 +20,13 @@ type UseCase interface {
 type SendMessageInput struct {
 	ChatRoomID string `json:"chat_room_id" validate:"required"`
 	SenderID   string `json:"sender_id" validate:"required"`
-	Content    string `json:"content" validate:"required,min=1"`
+	Content    string `json:"content" validate:"required_without=AttachmentIDs"` // Caption when files are attached
 	Type       string `json:"type" validate:"required,oneof=text image file"`
+	SentByBot  bool   `json:"sent_by_bot"`
+
+	// AttachmentIDs lists files uploaded to the chat room by the sender; the
+	// message type is derived from them
+	AttachmentIDs []string `json:"attachment_ids" validate:"omitempty,min=1,max=10,unique,dive,required"`
 }
 
 // SendMessageOutput represents the output for sending a message
@@ -30,8 +37,28 @@ type SendMessageOutput struct {
 	Content    string    `json:"content"`
 	Type       string    `json:"type"`
 	Status     string    `json:"status"`
+	SentByBot  bool      `json:"sent_by_bot"`
 	CreatedAt  time.Time `json:"created_at"`
 	UpdatedAt  time.Time `json:"updated_at"`
+
+	Attachments []*AttachmentOutput `json:"attachments,omitempty"`
+
+	// NotifyUserIDs lists the members who should be notified about the message
+	NotifyUserIDs []string `json:"-"`
+}
+
+// SlowModeError is returned when a member sends messages faster than the chat room's slow mode allows
+type SlowModeError struct {
+	RetryAfter time.Duration
+}
+
+func (e *SlowModeError) Error() string {
+	return fmt.Sprintf("slow mode is enabled, retry after %d seconds", e.RetryAfterSeconds())
+}
+
+// RetryAfterSeconds returns the wait rounded up to whole seconds
+func (e *SlowModeError) RetryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
 }
 
 // GetMessageInput represents the input for getting a message
@@ -48,8 +75,22 @@ type GetMessageOutput struct {
 	Content    string    `json:"content"`
 	Type       string    `json:"type"`
 	Status     string    `json:"status"`
+	SentByBot  bool      `json:"sent_by_bot"`
 	CreatedAt  time.Time `json:"created_at"`
 	UpdatedAt  time.Time `json:"updated_at"`
+
+	Attachments []*AttachmentOutput `json:"attachments,omitempty"`
+}
+
+// AttachmentOutput represents a file sent with a message
+type AttachmentOutput struct {
+	ID           string `json:"id"`
+	OriginalName string `json:"original_name"`
+	Size         int64  `json:"size"`
+	MimeType     string `json:"mime_type"`
+	Width        int    `json:"width,omitempty"`
+	Height       int    `json:"height,omitempty"`
+	HasThumbnail bool   `json:"has_thumbnail"`
 }
 
 // GetMessagesInput represents the input for getting messages
@@ -3,9 +3,11 @@ package message
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/google/uuid"
 
+	"backend-go/internal/domain/attachment"
 	"backend-go/internal/domain/chat"
 	"backend-go/internal/domain/message"
 	"backend-go/internal/shared/logger"
@@ -13,24 +15,27 @@ import (
 )
 
 type useCase struct {
-	messageRepo message.Repository
-	chatRepo    chat.Repository
-	validator   validation.Validator
-	logger      logger.Logger
+	messageRepo    message.Repository
+	chatRepo       chat.Repository
+	attachmentRepo attachment.Repository
+	validator      validation.Validator
+	logger         logger.Logger
 }
 
 // NewUseCase creates a new message use case
 func NewUseCase(
 	messageRepo message.Repository,
 	chatRepo chat.Repository,
+	attachmentRepo attachment.Repository,
 	validator validation.Validator,
 	logger logger.Logger,
 ) UseCase {
 	return &useCase{
-		messageRepo: messageRepo,
-		chatRepo:    chatRepo,
-		validator:   validator,
-		logger:      logger,
+		messageRepo:    messageRepo,
+		chatRepo:       chatRepo,
+		attachmentRepo: attachmentRepo,
+		validator:      validator,
+		logger:         logger,
 	}
 }
 
@@ -51,13 +56,51 @@ func (uc *useCase) SendMessage(ctx context.Context, input SendMessageInput) (*Se
 		return nil, fmt.Errorf("failed to verify chat room: %w", err)
 	}
 
-	if !chatRoom.IsMember(input.SenderID) {
-		return nil, fmt.Errorf("not a member of this chat room")
+	role, err := uc.chatRepo.GetMemberRole(ctx, chatRoom.ID, input.SenderID)
+	if err != nil {
+		if err == chat.ErrMemberNotFound {
+			return nil, fmt.Errorf("not a member of this chat room")
+		}
+		uc.logger.Error("Failed to get member role", "error", err, "room_id", input.ChatRoomID, "user_id", input.SenderID)
+		return nil, fmt.Errorf("failed to verify chat room: %w", err)
+	}
+
+	// Enforce announcement-only rooms and slow mode
+	if !chatRoom.CanSend(role) {
+		return nil, fmt.Errorf("only chat room admins can send messages in this chat room")
+	}
+
+	// Image and file messages must carry the files they describe
+	messageType := input.Type
+	if len(input.AttachmentIDs) == 0 && messageType != message.TypeText {
+		return nil, fmt.Errorf("%s messages require attachments", messageType)
+	}
+
+	attachments, err := uc.sendableAttachments(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	if len(attachments) > 0 {
+		messageType = attachmentsType(attachments)
+	}
+
+	// Slow mode is checked last, so that a message rejected for another
+	// reason does not count against the member
+	if chatRoom.SlowModeSeconds > 0 && !chat.IsAdminRole(role) {
+		claimed, lastSentAt, err := uc.chatRepo.ClaimSendSlot(ctx, chatRoom.ID, input.SenderID, chatRoom.SlowModeInterval())
+		if err != nil {
+			uc.logger.Error("Failed to check slow mode", "error", err, "room_id", input.ChatRoomID, "user_id", input.SenderID)
+			return nil, fmt.Errorf("failed to send message: %w", err)
+		}
+		if !claimed {
+			return nil, &SlowModeError{RetryAfter: chatRoom.SlowModeWait(role, lastSentAt)}
+		}
 	}
 
 	// Create message
 	messageID := uuid.New().String()
-	msg := message.NewMessage(messageID, input.ChatRoomID, input.SenderID, input.Content, input.Type)
+	msg := message.NewMessage(messageID, input.ChatRoomID, input.SenderID, input.Content, messageType)
+	msg.SentByBot = input.SentByBot
 
 	// Save message
 	if err := uc.messageRepo.Create(ctx, msg); err != nil {
@@ -65,6 +108,20 @@ func (uc *useCase) SendMessage(ctx context.Context, input SendMessageInput) (*Se
 		return nil, fmt.Errorf("failed to send message: %w", err)
 	}
 
+	if len(attachments) > 0 {
+		if err := uc.attachmentRepo.AttachToMessage(ctx, msg.ID, input.AttachmentIDs); err != nil {
+			// Don't leave a message behind without the files it was sent with
+			if deleteErr := uc.messageRepo.Delete(ctx, msg.ID); deleteErr != nil {
+				uc.logger.Error("Failed to delete message", "error", deleteErr, "message_id", msg.ID)
+			}
+			if err == attachment.ErrAlreadyAttached {
+				return nil, err
+			}
+			uc.logger.Error("Failed to attach files to message", "error", err, "message_id", msg.ID)
+			return nil, fmt.Errorf("failed to send message: %w", err)
+		}
+	}
+
 	uc.logger.Info("Message sent successfully", "message_id", messageID, "room_id", input.ChatRoomID, "sender_id", input.SenderID)
 
 	return &SendMessageOutput{
@@ -74,11 +131,125 @@ func (uc *useCase) SendMessage(ctx context.Context, input SendMessageInput) (*Se
 		Content:    msg.Content,
 		Type:       msg.Type,
 		Status:     msg.Status,
+		SentByBot:  msg.SentByBot,
 		CreatedAt:  msg.CreatedAt,
 		UpdatedAt:  msg.UpdatedAt,
+
+		Attachments: attachmentOutputs(attachments),
+
+		NotifyUserIDs: uc.notificationRecipients(ctx, msg),
 	}, nil
 }
 
+// sendableAttachments loads the attachments of a message being sent, in the
+// order they were given, checking each may be sent by the sender
+func (uc *useCase) sendableAttachments(ctx context.Context, input SendMessageInput) ([]*attachment.Attachment, error) {
+	var attachments []*attachment.Attachment
+	for _, id := range input.AttachmentIDs {
+		a, err := uc.attachmentRepo.GetByID(ctx, id)
+		if err != nil {
+			if err == attachment.ErrAttachmentNotFound {
+				return nil, err
+			}
+			uc.logger.Error("Failed to get attachment", "error", err, "attachment_id", id)
+			return nil, fmt.Errorf("failed to verify attachments: %w", err)
+		}
+
+		if err := a.CanAttachTo(input.SenderID, input.ChatRoomID); err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+
+	return attachments, nil
+}
+
+// attachmentsType returns the type of a message sent with the given files
+func attachmentsType(attachments []*attachment.Attachment) string {
+	for _, a := range attachments {
+		if !a.IsImage() {
+			return message.TypeFile
+		}
+	}
+	return message.TypeImage
+}
+
+// messageAttachments loads the attachments of the given messages, keyed by message ID
+func (uc *useCase) messageAttachments(ctx context.Context, messages []*message.Message) (map[string][]*AttachmentOutput, error) {
+	var messageIDs []string
+	for _, msg := range messages {
+		if msg.Type != message.TypeText {
+			messageIDs = append(messageIDs, msg.ID)
+		}
+	}
+	if len(messageIDs) == 0 {
+		return nil, nil
+	}
+
+	attachments, err := uc.attachmentRepo.ListByMessages(ctx, messageIDs)
+	if err != nil {
+		uc.logger.Error("Failed to list message attachments", "error", err)
+		return nil, fmt.Errorf("failed to get attachments: %w", err)
+	}
+
+	result := make(map[string][]*AttachmentOutput)
+	for _, a := range attachments {
+		result[a.MessageID] = append(result[a.MessageID], attachmentOutput(a))
+	}
+
+	return result, nil
+}
+
+func attachmentOutputs(attachments []*attachment.Attachment) []*AttachmentOutput {
+	var result []*AttachmentOutput
+	for _, a := range attachments {
+		result = append(result, attachmentOutput(a))
+	}
+	return result
+}
+
+func attachmentOutput(a *attachment.Attachment) *AttachmentOutput {
+	return &AttachmentOutput{
+		ID:           a.ID,
+		OriginalName: a.OriginalName,
+		Size:         a.Size,
+		MimeType:     a.MimeType,
+		Width:        a.Width,
+		Height:       a.Height,
+		HasThumbnail: a.HasThumbnail(),
+	}
+}
+
+// notificationRecipients returns the members to notify about a message, honouring
+// each member's mute and notification level settings
+func (uc *useCase) notificationRecipients(ctx context.Context, msg *message.Message) []string {
+	mentions := message.Mentions(msg.Content)
+	members, err := uc.chatRepo.ListNotificationRecipients(ctx, msg.ChatRoomID, msg.SenderID, mentions)
+	if err != nil {
+		// The message is already stored; a failed lookup only skips notifications
+		uc.logger.Error("Failed to list notification recipients", "error", err, "room_id", msg.ChatRoomID)
+		return nil
+	}
+
+	mentioned := make(map[string]bool, len(mentions))
+	for _, username := range mentions {
+		mentioned[username] = true
+	}
+
+	var recipients []string
+	for _, member := range members {
+		if member.UserID == msg.SenderID {
+			continue
+		}
+
+		if member.ShouldNotify(mentioned[strings.ToLower(member.Username)]) {
+			recipients = append(recipients, member.UserID)
+		}
+	}
+
+	return recipients
+}
+
 func (uc *useCase) GetMessage(ctx context.Context, input GetMessageInput) (*GetMessageOutput, error) {
 	// Validate input
 	if err := uc.validator.Struct(input); err != nil {
@@ -103,10 +274,20 @@ func (uc *useCase) GetMessage(ctx context.Context, input GetMessageInput) (*GetM
 		return nil, fmt.Errorf("failed to verify access: %w", err)
 	}
 
-	if !chatRoom.IsMember(input.UserID) {
+	isMember, err := uc.chatRepo.IsMember(ctx, chatRoom.ID, input.UserID)
+	if err != nil {
+		uc.logger.Error("Failed to check membership", "error", err, "room_id", chatRoom.ID, "user_id", input.UserID)
+		return nil, fmt.Errorf("failed to verify access: %w", err)
+	}
+	if !isMember {
 		return nil, fmt.Errorf("access denied: not a member of this chat room")
 	}
 
+	attachments, err := uc.messageAttachments(ctx, []*message.Message{msg})
+	if err != nil {
+		return nil, err
+	}
+
 	return &GetMessageOutput{
 		ID:         msg.ID,
 		ChatRoomID: msg.ChatRoomID,
@@ -114,8 +295,11 @@ func (uc *useCase) GetMessage(ctx context.Context, input GetMessageInput) (*GetM
 		Content:    msg.Content,
 		Type:       msg.Type,
 		Status:     msg.Status,
+		SentByBot:  msg.SentByBot,
 		CreatedAt:  msg.CreatedAt,
 		UpdatedAt:  msg.UpdatedAt,
+
+		Attachments: attachments[msg.ID],
 	}, nil
 }
 
@@ -136,7 +320,12 @@ func (uc *useCase) GetMessages(ctx context.Context, input GetMessagesInput) (*Ge
 		return nil, fmt.Errorf("failed to verify access: %w", err)
 	}
 
-	if !chatRoom.IsMember(input.UserID) {
+	isMember, err := uc.chatRepo.IsMember(ctx, chatRoom.ID, input.UserID)
+	if err != nil {
+		uc.logger.Error("Failed to check membership", "error", err, "room_id", chatRoom.ID, "user_id", input.UserID)
+		return nil, fmt.Errorf("failed to verify access: %w", err)
+	}
+	if !isMember {
 		return nil, fmt.Errorf("access denied: not a member of this chat room")
 	}
 
@@ -167,6 +356,11 @@ func (uc *useCase) GetMessages(ctx context.Context, input GetMessagesInput) (*Ge
 		hasMore = offset+len(msgs) < totalCount
 	}
 
+	attachments, err := uc.messageAttachments(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+
 	// Convert to output format
 	var result []*GetMessageOutput
 	for _, msg := range messages {
@@ -177,8 +371,11 @@ func (uc *useCase) GetMessages(ctx context.Context, input GetMessagesInput) (*Ge
 			Content:    msg.Content,
 			Type:       msg.Type,
 			Status:     msg.Status,
+			SentByBot:  msg.SentByBot,
 			CreatedAt:  msg.CreatedAt,
 			UpdatedAt:  msg.UpdatedAt,
+
+			Attachments: attachments[msg.ID],
 		})
 	}
 
@@ -213,7 +410,12 @@ func (uc *useCase) UpdateMessageStatus(ctx context.Context, input UpdateMessageS
 		return fmt.Errorf("failed to verify access: %w", err)
 	}
 
-	if !chatRoom.IsMember(input.UserID) {
+	isMember, err := uc.chatRepo.IsMember(ctx, chatRoom.ID, input.UserID)
+	if err != nil {
+		uc.logger.Error("Failed to check membership", "error", err, "room_id", chatRoom.ID, "user_id", input.UserID)
+		return fmt.Errorf("failed to verify access: %w", err)
+	}
+	if !isMember {
 		return fmt.Errorf("access denied: not a member of this chat room")
 	}
 
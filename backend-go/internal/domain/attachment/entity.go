@@ -0,0 +1,445 @@
+package attachment
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var (
+	ErrAttachmentNotFound = errors.New("attachment not found")
+	ErrNotAuthorized      = errors.New("not authorized")
+	ErrFileTooLarge       = errors.New("file too large")
+	ErrFileTypeNotAllowed = errors.New("file type not allowed")
+	ErrIncompleteUpload   = errors.New("uploaded file does not match its declared size")
+	ErrSessionNotFound    = errors.New("upload session not found")
+	ErrOffsetConflict     = errors.New("upload offset has changed")
+	ErrChunkTooLarge      = errors.New("chunk too large")
+	ErrContentMismatch    = errors.New("file content does not match its type")
+	ErrThumbnailNotFound  = errors.New("thumbnail not found")
+	ErrAlreadyAttached    = errors.New("attachment is already attached to a message")
+	ErrWrongChatRoom      = errors.New("attachment was uploaded to another chat room")
+	ErrUserQuotaExceeded  = errors.New("user storage quota exceeded")
+	ErrRoomQuotaExceeded  = errors.New("chat room storage quota exceeded")
+	ErrBlobNotFound       = errors.New("no stored file has this checksum")
+	ErrScanPending        = errors.New("file is still being scanned for malware")
+	ErrScanFailed         = errors.New("file could not be scanned for malware")
+	ErrFileQuarantined    = errors.New("file was quarantined because malware was found in it")
+)
+
+const (
+	StatusPending = "pending" // Waiting for the client to upload the content directly to storage
+	StatusReady   = "ready"
+)
+
+// Results of scanning uploaded content for malware. Files uploaded while
+// scanning is disabled have no scan status.
+const (
+	ScanPending     = "pending" // Not scanned yet; the file cannot be downloaded until it is
+	ScanClean       = "clean"
+	ScanQuarantined = "quarantined" // Malware was found; the content is kept but never served
+	ScanFailed      = "failed"      // The content can never be scanned, so it is never served
+)
+
+// Rendered versions of an image, stored next to its content
+const (
+	VariantThumbnail = "thumbnail"
+	VariantPreview   = "preview"
+)
+
+// contentTypes lists the file extensions that may be uploaded and the MIME
+// type they are served with. SVG can carry scripts, so like every file it is
+// only ever served as a download and never rendered.
+var contentTypes = map[string]string{
+	// Images
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".svg":  "image/svg+xml",
+
+	// Documents
+	".pdf":  "application/pdf",
+	".doc":  "application/msword",
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xls":  "application/vnd.ms-excel",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".ppt":  "application/vnd.ms-powerpoint",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".txt":  "text/plain",
+	".rtf":  "application/rtf",
+
+	// Archives
+	".zip": "application/zip",
+	".rar": "application/x-rar-compressed",
+	".7z":  "application/x-7z-compressed",
+	".tar": "application/x-tar",
+	".gz":  "application/gzip",
+
+	// Audio
+	".mp3": "audio/mpeg",
+	".wav": "audio/wav",
+	".ogg": "audio/ogg",
+	".m4a": "audio/mp4",
+
+	// Video
+	".mp4":  "video/mp4",
+	".avi":  "video/x-msvideo",
+	".mov":  "video/quicktime",
+	".wmv":  "video/x-ms-wmv",
+	".flv":  "video/x-flv",
+	".webm": "video/webm",
+}
+
+// containerTypes lists the types detected for content in a container format
+// that several file types share
+var containerTypes = map[string][]string{
+	"application/msword":            {"application/x-ole-storage"},
+	"application/vnd.ms-excel":      {"application/x-ole-storage"},
+	"application/vnd.ms-powerpoint": {"application/x-ole-storage"},
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document":   {"application/zip"},
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":         {"application/zip"},
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation": {"application/zip"},
+	"audio/mp4":       {"video/mp4"},
+	"video/mp4":       {"audio/mp4", "video/quicktime"},
+	"video/quicktime": {"video/mp4"},
+}
+
+// Storage usage is accounted per uploader and per chat room
+const (
+	UsageScopeUser = "user"
+	UsageScopeRoom = "room"
+)
+
+// Quota limits the bytes of files a user may upload and a chat room may
+// hold, including uploads in progress. Zero means unlimited.
+type Quota struct {
+	UserBytes int64
+	RoomBytes int64
+}
+
+// Usage is the storage taken by files, including uploads in progress
+type Usage struct {
+	Bytes int64 `json:"bytes"`
+	Files int   `json:"files"`
+}
+
+// RoomUsage is the storage taken by a user's files in one chat room,
+// alongside the storage taken by all files in the room
+type RoomUsage struct {
+	ChatRoomID string `json:"chat_room_id"`
+	Usage
+	RoomBytes int64 `json:"room_bytes"`
+}
+
+// MaxFileNameLength limits the stored original file name
+const MaxFileNameLength = 255
+
+// Attachment represents a file uploaded to a chat room
+type Attachment struct {
+	ID           string     `json:"id"`
+	OwnerID      string     `json:"owner_id"`
+	ChatRoomID   string     `json:"chat_room_id"`
+	OriginalName string     `json:"original_name"`
+	Size         int64      `json:"size"`
+	MimeType     string     `json:"mime_type"`
+	Checksum     string     `json:"checksum"` // Hex SHA-256 of the content; empty while pending
+	Status       string     `json:"status"`
+	Width        int        `json:"width"` // Image dimensions as displayed; zero for other files
+	Height       int        `json:"height"`
+	RenderType   string     `json:"render_type"`           // MIME type of the thumbnail and preview; empty if there are none
+	MessageID    string     `json:"message_id,omitempty"`  // Message the attachment was sent with; empty until it is sent
+	StorageKey   string     `json:"-"`                     // Where the content is stored; the ID until it is ready
+	ScanStatus   string     `json:"scan_status,omitempty"` // Empty if the file was not scanned
+	Threat       string     `json:"threat,omitempty"`      // Name of the malware found in a quarantined file
+	ScanAttempts int        `json:"-"`                     // Failed attempts to scan the content
+	NextScanAt   *time.Time `json:"-"`                     // When the scan is tried again after a failed attempt
+	CreatedAt    time.Time  `json:"created_at"`
+	ReadyAt      time.Time  `json:"-"` // When the content was last verified or scanned; unattached files are kept for a grace period from then
+}
+
+// NewAttachment creates a new attachment instance
+func NewAttachment(id, ownerID, chatRoomID, originalName string, size int64, mimeType, checksum string) *Attachment {
+	now := time.Now()
+	return &Attachment{
+		ID:           id,
+		OwnerID:      ownerID,
+		ChatRoomID:   chatRoomID,
+		OriginalName: originalName,
+		Size:         size,
+		MimeType:     mimeType,
+		Checksum:     checksum,
+		Status:       StatusReady,
+		StorageKey:   id,
+		CreatedAt:    now,
+		ReadyAt:      now,
+	}
+}
+
+// NewPendingAttachment creates an attachment whose content the client will
+// upload directly to storage
+func NewPendingAttachment(id, ownerID, chatRoomID, originalName string, size int64, mimeType string) *Attachment {
+	a := NewAttachment(id, ownerID, chatRoomID, originalName, size, mimeType, "")
+	a.Status = StatusPending
+	return a
+}
+
+// NewSharedAttachment creates an attachment for content that is already
+// stored, so it does not have to be uploaded again
+func NewSharedAttachment(id, ownerID, chatRoomID, originalName string, blob *Blob) *Attachment {
+	a := NewAttachment(id, ownerID, chatRoomID, originalName, blob.Size, blob.MimeType, blob.Checksum)
+	a.StorageKey = blob.Key
+	a.Width, a.Height = blob.Width, blob.Height
+	a.RenderType = blob.RenderType
+	return a
+}
+
+// IsReady checks if the attachment content has been uploaded and verified
+func (a *Attachment) IsReady() bool {
+	return a.Status == StatusReady
+}
+
+// MarkReady records the checksum of uploaded content, which from then on is
+// stored under the key of its checksum
+func (a *Attachment) MarkReady(checksum string) {
+	a.Checksum = checksum
+	a.Status = StatusReady
+	a.StorageKey = ContentKey(checksum)
+	a.ReadyAt = time.Now()
+}
+
+// IsAvailable checks if the attachment content may be served, which it may
+// once it is uploaded and not waiting for or failed a malware scan
+func (a *Attachment) IsAvailable() bool {
+	return a.IsReady() && a.ScanStatus != ScanPending && a.ScanStatus != ScanQuarantined && a.ScanStatus != ScanFailed
+}
+
+// RecordScan records the outcome of a malware scan; threat is the name of
+// the malware found, or empty if the content is clean
+func (a *Attachment) RecordScan(threat string) {
+	a.Threat = threat
+	a.ReadyAt = time.Now()
+	if threat == "" {
+		a.ScanStatus = ScanClean
+	} else {
+		a.ScanStatus = ScanQuarantined
+	}
+}
+
+// RecordScanFailure records that the content can never be scanned, such as
+// content larger than the scanner accepts or no longer stored
+func (a *Attachment) RecordScanFailure() {
+	a.Threat = ""
+	a.ReadyAt = time.Now()
+	a.ScanStatus = ScanFailed
+}
+
+// PostponeScan records a failed attempt to scan the content, which is tried
+// again at the given time
+func (a *Attachment) PostponeScan(next time.Time) {
+	a.ScanAttempts++
+	a.NextScanAt = &next
+}
+
+// HasOwnContent checks if the content is stored under the attachment ID
+// rather than shared with identical files, which is the case for uploads in
+// progress and files uploaded before content was deduplicated
+func (a *Attachment) HasOwnContent() bool {
+	return a.StorageKey == a.ID
+}
+
+// HasThumbnail checks if a thumbnail and preview were rendered for the attachment
+func (a *Attachment) HasThumbnail() bool {
+	return a.RenderType != ""
+}
+
+// VariantKey returns the storage key of a rendered version of the attachment
+func (a *Attachment) VariantKey(variant string) string {
+	return VariantKey(a.StorageKey, variant)
+}
+
+// IsImage checks if the attachment is an image that could be decoded; only
+// those have their dimensions recorded
+func (a *Attachment) IsImage() bool {
+	return a.Width > 0 && a.Height > 0
+}
+
+// CanAttachTo checks if the attachment may be sent by a user with a message
+// in a chat room
+func (a *Attachment) CanAttachTo(userID, chatRoomID string) error {
+	if !a.IsUploadedBy(userID) || !a.IsReady() {
+		return ErrAttachmentNotFound
+	}
+	if a.ScanStatus == ScanPending {
+		return ErrScanPending
+	}
+	if a.ScanStatus == ScanQuarantined {
+		return ErrFileQuarantined
+	}
+	if a.ScanStatus == ScanFailed {
+		return ErrScanFailed
+	}
+	if a.ChatRoomID != chatRoomID {
+		return ErrWrongChatRoom
+	}
+	if a.MessageID != "" {
+		return ErrAlreadyAttached
+	}
+	return nil
+}
+
+// IsUploadedBy checks if the attachment was uploaded by the given user
+func (a *Attachment) IsUploadedBy(userID string) bool {
+	return a.OwnerID == userID
+}
+
+// ContentType returns the MIME type for a file name, and false if files of
+// that type may not be uploaded
+func ContentType(filename string) (string, bool) {
+	contentType, ok := contentTypes[strings.ToLower(filepath.Ext(filename))]
+	return contentType, ok
+}
+
+// MatchesContent checks if content detected as the given type may be stored
+// as a file of mimeType
+func MatchesContent(mimeType, detected string) bool {
+	if detected == mimeType {
+		return true
+	}
+	for _, t := range containerTypes[mimeType] {
+		if t == detected {
+			return true
+		}
+	}
+	return false
+}
+
+// CleanFileName reduces a client-supplied file name to its last path element
+// without control characters, so it is safe to store and display
+func CleanFileName(name string) string {
+	// Clients may send Windows paths, which filepath.Base does not split on Linux
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == unicode.ReplacementChar {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+
+	if len(name) > MaxFileNameLength {
+		// Keep the extension so the type stays recognisable
+		ext := filepath.Ext(name)
+		if len(ext) > 16 {
+			ext = ""
+		}
+		name = strings.ToValidUTF8(name[:MaxFileNameLength-len(ext)], "") + ext
+	}
+	if name == "." || name == ".." {
+		return ""
+	}
+	return name
+}
+
+// Blob is content stored once and shared by every attachment with the same
+// bytes. It is removed some time after the last of them is deleted.
+type Blob struct {
+	Key            string     `json:"key"`
+	Checksum       string     `json:"checksum"`        // Hex SHA-256 of the stored content
+	SourceChecksum string     `json:"source_checksum"` // Hex SHA-256 of the content as uploaded, before image metadata was removed
+	Size           int64      `json:"size"`
+	MimeType       string     `json:"mime_type"`
+	Width          int        `json:"width"`
+	Height         int        `json:"height"`
+	RenderType     string     `json:"render_type"`
+	RefCount       int        `json:"ref_count"`   // Attachments stored in the blob
+	OrphanedAt     *time.Time `json:"orphaned_at"` // When the last attachment was deleted, or the blob was reserved
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// NewBlob creates the blob a ready attachment is stored in. sourceChecksum
+// is the checksum of the content as uploaded.
+func NewBlob(a *Attachment, sourceChecksum string) *Blob {
+	return &Blob{
+		Key:            a.StorageKey,
+		Checksum:       a.Checksum,
+		SourceChecksum: sourceChecksum,
+		Size:           a.Size,
+		MimeType:       a.MimeType,
+		Width:          a.Width,
+		Height:         a.Height,
+		RenderType:     a.RenderType,
+		CreatedAt:      time.Now(),
+	}
+}
+
+// ContentKey returns the storage key of content with the given checksum
+func ContentKey(checksum string) string {
+	return "sha256-" + checksum
+}
+
+// VariantKey returns the storage key of a rendered version of the content
+// stored under key
+func VariantKey(key, variant string) string {
+	return key + "." + variant
+}
+
+// BaseKey returns the storage key of the content a stored object belongs
+// to, which is the key itself unless the object is a rendered version of
+// the content or a chunk of its upload
+func BaseKey(key string) string {
+	if i := strings.IndexByte(key, '.'); i >= 0 {
+		return key[:i]
+	}
+	return key
+}
+
+// storageKeyPattern matches the content key of a checksum or an attachment
+// ID, optionally followed by the suffix of a rendered version or a chunk
+var storageKeyPattern = regexp.MustCompile(`^(sha256-[0-9a-f]{64}|[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})` +
+	`(\.(` + VariantThumbnail + `|` + VariantPreview + `|chunk-[0-9]+-[0-9a-f-]{36}))?$`)
+
+// IsStorageKey checks if key has the form of the keys files are stored
+// under, so objects stored by anything else are never taken for them
+func IsStorageKey(key string) bool {
+	return storageKeyPattern.MatchString(key)
+}
+
+// UploadSession tracks a resumable upload. Each chunk is stored as its own
+// blob and the chunks are joined into the attachment content on completion.
+type UploadSession struct {
+	AttachmentID string    `json:"attachment_id"`
+	Offset       int64     `json:"offset"` // Bytes received so far
+	ChunkKeys    []string  `json:"chunk_keys"`
+	ExpiresAt    time.Time `json:"expires_at"` // Pushed back with every chunk
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// NewUploadSession creates a new upload session instance
+func NewUploadSession(attachmentID string, expiresAt time.Time) *UploadSession {
+	return &UploadSession{
+		AttachmentID: attachmentID,
+		ChunkKeys:    []string{},
+		ExpiresAt:    expiresAt,
+		CreatedAt:    time.Now(),
+	}
+}
+
+// IsExpired checks if the session may no longer receive chunks
+func (s *UploadSession) IsExpired() bool {
+	return !time.Now().Before(s.ExpiresAt)
+}
+
+// ChunkKey returns a storage key for a chunk starting at offset. The random
+// part keeps concurrent writes of the same range from overwriting each other.
+func (s *UploadSession) ChunkKey(offset int64, random string) string {
+	return fmt.Sprintf("%s.chunk-%d-%s", s.AttachmentID, offset, random)
+}
\ No newline at end of file
@@ -0,0 +1,78 @@
+package attachment
+
+import (
+	"context"
+	"time"
+)
+
+// Repository defines the interface for attachment data access
+type Repository interface {
+	// Create stores an attachment unless its size would take its uploader or
+	// chat room over the quota, returning ErrUserQuotaExceeded or
+	// ErrRoomQuotaExceeded
+	Create(ctx context.Context, attachment *Attachment, quota Quota) error
+	GetByID(ctx context.Context, id string) (*Attachment, error)
+	Delete(ctx context.Context, id string) error
+	// MarkReady records the checksum, size, image details, storage key and
+	// scan status of a pending attachment whose content was verified
+	MarkReady(ctx context.Context, attachment *Attachment) error
+	ListPendingBefore(ctx context.Context, before time.Time, limit int) ([]*Attachment, error)
+	// ListUnattachedBefore returns attachments that became ready before the
+	// given time and are not attached to a message, other than those waiting
+	// for a malware scan, in order of their IDs starting after afterID
+	ListUnattachedBefore(ctx context.Context, before time.Time, afterID string, limit int) ([]*Attachment, error)
+	// DeleteUnattached removes an attachment unless it was attached to a
+	// message meanwhile, returning ErrAttachmentNotFound in that case
+	DeleteUnattached(ctx context.Context, id string) error
+	// ListScanPending returns ready attachments waiting for a malware scan
+	// that is due before the given time, those never tried first and then
+	// oldest first
+	ListScanPending(ctx context.Context, before time.Time, limit int) ([]*Attachment, error)
+	// RecordScan stores the scan status and threat of an attachment that was
+	// waiting for a scan, returning ErrAttachmentNotFound if it no longer is
+	RecordScan(ctx context.Context, attachment *Attachment) error
+	// PostponeScan stores the failed scan attempts and next scan time of an
+	// attachment waiting for a scan, returning ErrAttachmentNotFound if it
+	// no longer is
+	PostponeScan(ctx context.Context, attachment *Attachment) error
+	// AttachToMessage binds all of the attachments to a message, or none of
+	// them if any is already bound, returning ErrAlreadyAttached
+	AttachToMessage(ctx context.Context, messageID string, ids []string) error
+	ListByMessages(ctx context.Context, messageIDs []string) ([]*Attachment, error)
+	// GetUsage returns the storage taken by a user's files or a chat room's
+	// files, depending on scope
+	GetUsage(ctx context.Context, scope, ownerID string) (*Usage, error)
+	ListRoomUsage(ctx context.Context, ownerID string) ([]*RoomUsage, error)
+	// ReserveBlob records a blob for content about to be referenced, and keeps
+	// it from being purged for a while even if it is orphaned. Returns the
+	// blob as recorded; unless it is referenced, its content may not have
+	// been stored yet.
+	ReserveBlob(ctx context.Context, blob *Blob) (*Blob, error)
+	// GetBlobByChecksum finds a blob by the checksum of its stored or
+	// uploaded content among the files a user uploaded or can read in their
+	// chat rooms, other than quarantined ones, returning ErrBlobNotFound if
+	// there is none
+	GetBlobByChecksum(ctx context.Context, checksum, userID string) (*Blob, error)
+	// ListOrphanedBlobs returns blobs orphaned since before the given time, in
+	// order of their keys starting after afterKey
+	ListOrphanedBlobs(ctx context.Context, before time.Time, afterKey string, limit int) ([]*Blob, error)
+	// DeleteOrphanedBlob removes a blob that is still orphaned since before
+	// the given time, calling deleteContent before the record goes. Returns
+	// ErrBlobNotFound if the blob was removed, referenced or reserved again
+	// in the meantime.
+	DeleteOrphanedBlob(ctx context.Context, key string, before time.Time, deleteContent func() error) error
+	// ListReferencedKeys returns those of the given storage keys that content
+	// is recorded under, by a blob or by an attachment stored under its own ID
+	ListReferencedKeys(ctx context.Context, keys []string) ([]string, error)
+}
+
+// UploadSessionRepository defines the interface for resumable upload session data access
+type UploadSessionRepository interface {
+	Create(ctx context.Context, session *UploadSession) error
+	GetByAttachmentID(ctx context.Context, attachmentID string) (*UploadSession, error)
+	// AppendChunk records a chunk only if the session is still at fromOffset,
+	// returning ErrOffsetConflict otherwise
+	AppendChunk(ctx context.Context, attachmentID string, fromOffset, toOffset int64, chunkKey string, expiresAt time.Time) error
+	Delete(ctx context.Context, attachmentID string) error
+	ListExpired(ctx context.Context, before time.Time, limit int) ([]*UploadSession, error)
+}
\ No newline at end of file
@@ -1,7 +1,9 @@
 package chat
 
 import (
+	"encoding/base64"
 	"errors"
+	"strings"
 	"time"
 )
 
@@ -10,32 +12,108 @@ var (
 	ErrMemberNotFound   = errors.New("member not found")
 	ErrNotAuthorized    = errors.New("not authorized")
 	ErrAlreadyMember    = errors.New("already a member")
+	ErrUserBanned       = errors.New("user is banned from this chat room")
+	ErrBanNotFound      = errors.New("ban not found")
+	ErrInvalidRole      = errors.New("invalid member role")
+	ErrInvalidCursor    = errors.New("invalid cursor")
 )
 
-// ChatRoom represents a chat room entity
+// RestoreGracePeriod is how long a deleted chat room can still be restored
+// before it is permanently purged
+const RestoreGracePeriod = 7 * 24 * time.Hour
+
+// Notification levels for member settings
+const (
+	NotifyAll      = "all"
+	NotifyMentions = "mentions"
+	NotifyNone     = "none"
+)
+
+// Member roles, in descending order of privilege
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+// MemberCursor marks the last member on a page of members, which are ordered
+// by join time and then user ID. Paging carries on from the cursor even if
+// that member has left the room since.
+type MemberCursor struct {
+	JoinedAt time.Time
+	UserID   string
+}
+
+// Encode returns the cursor in the opaque form handed to clients
+func (c MemberCursor) Encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(c.JoinedAt.UTC().Format(time.RFC3339Nano) + "|" + c.UserID))
+}
+
+// ParseMemberCursor decodes a cursor returned by Encode
+func ParseMemberCursor(cursor string) (*MemberCursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	joinedAt, userID, ok := strings.Cut(string(decoded), "|")
+	if !ok || userID == "" {
+		return nil, ErrInvalidCursor
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, joinedAt)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &MemberCursor{JoinedAt: parsed, UserID: userID}, nil
+}
+
+// ChatRoom represents a chat room entity. Members holds the initial members
+// when creating a room; rooms loaded from the repository leave it empty and
+// report TotalMembers instead (use Repository.ListMembers to page through them).
 type ChatRoom struct {
-	ID          string    `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description,omitempty"`
-	IsPrivate   bool      `json:"is_private"`
-	CreatedBy   string    `json:"created_by"`
-	Members     []string  `json:"members"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Description  string    `json:"description,omitempty"`
+	IsPrivate    bool      `json:"is_private"`
+	CreatedBy    string    `json:"created_by"`
+	Members      []string  `json:"members,omitempty"`
+	TotalMembers int       `json:"member_count"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+
+	// AnnouncementOnly restricts sending messages to room owners and admins
+	AnnouncementOnly bool `json:"announcement_only"`
+	// SlowModeSeconds is the minimum interval between messages from the same member, 0 to disable
+	SlowModeSeconds int `json:"slow_mode_seconds"`
+
+	// DeletedAt is set while the room is soft-deleted and awaiting purge
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+
+	// Settings holds the requesting user's preferences when loaded for a specific user
+	Settings *MemberSettings `json:"settings,omitempty"`
+}
+
+// Member represents a chat room member with basic profile information
+type Member struct {
+	UserID     string     `json:"user_id"`
+	Username   string     `json:"username"`
+	Role       string     `json:"role"`
+	JoinedAt   time.Time  `json:"joined_at"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
 }
 
 // NewChatRoom creates a new chat room instance
 func NewChatRoom(id, name, description, createdBy string, isPrivate bool) *ChatRoom {
 	now := time.Now()
 	return &ChatRoom{
-		ID:          id,
-		Name:        name,
-		Description: description,
-		IsPrivate:   isPrivate,
-		CreatedBy:   createdBy,
-		Members:     []string{createdBy}, // Creator is automatically a member
-		CreatedAt:   now,
-		UpdatedAt:   now,
+		ID:           id,
+		Name:         name,
+		Description:  description,
+		IsPrivate:    isPrivate,
+		CreatedBy:    createdBy,
+		Members:      []string{createdBy}, // Creator is automatically a member
+		TotalMembers: 1,
+		CreatedAt:    now,
+		UpdatedAt:    now,
 	}
 }
 
@@ -49,6 +127,7 @@ func (c *ChatRoom) AddMember(userID string) error {
 	}
 
 	c.Members = append(c.Members, userID)
+	c.TotalMembers = len(c.Members)
 	c.UpdatedAt = time.Now()
 	return nil
 }
@@ -59,6 +138,7 @@ func (c *ChatRoom) RemoveMember(userID string) error {
 		if member == userID {
 			// Remove member from slice
 			c.Members = append(c.Members[:i], c.Members[i+1:]...)
+			c.TotalMembers = len(c.Members)
 			c.UpdatedAt = time.Now()
 			return nil
 		}
@@ -106,4 +186,138 @@ func (c *ChatRoom) Update(name, description string, isPrivate bool) {
 // MemberCount returns the number of members in the chat room
 func (c *ChatRoom) MemberCount() int {
 	return len(c.Members)
+}
+
+// CanSend checks if a member with the given role may send messages to the chat room
+func (c *ChatRoom) CanSend(role string) bool {
+	return !c.AnnouncementOnly || IsAdminRole(role)
+}
+
+// SlowModeInterval returns the minimum time between messages from a member
+func (c *ChatRoom) SlowModeInterval() time.Duration {
+	return time.Duration(c.SlowModeSeconds) * time.Second
+}
+
+// SlowModeWait returns how long a member with the given role must wait before
+// sending another message, given when they last sent one
+func (c *ChatRoom) SlowModeWait(role string, lastSentAt time.Time) time.Duration {
+	if c.SlowModeSeconds <= 0 || IsAdminRole(role) || lastSentAt.IsZero() {
+		return 0
+	}
+
+	wait := time.Until(lastSentAt.Add(c.SlowModeInterval()))
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
+
+// CanRestore checks if a soft-deleted chat room is still within its restore grace period
+func (c *ChatRoom) CanRestore() bool {
+	return c.DeletedAt != nil && time.Since(*c.DeletedAt) < RestoreGracePeriod
+}
+
+// IsValidRole checks if the role is a known member role
+func IsValidRole(role string) bool {
+	return role == RoleOwner || role == RoleAdmin || role == RoleMember
+}
+
+// IsAdminRole checks if the role grants room administration rights
+func IsAdminRole(role string) bool {
+	return role == RoleOwner || role == RoleAdmin
+}
+
+// CanModerate checks if a member with actorRole may kick or ban a member with targetRole.
+// Admins can moderate regular members, and only the owner can moderate admins.
+func CanModerate(actorRole, targetRole string) bool {
+	return roleRank(actorRole) > roleRank(targetRole) && IsAdminRole(actorRole)
+}
+
+func roleRank(role string) int {
+	switch role {
+	case RoleOwner:
+		return 3
+	case RoleAdmin:
+		return 2
+	case RoleMember:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Ban represents a user banned from a chat room
+type Ban struct {
+	ChatRoomID string     `json:"chat_room_id"`
+	UserID     string     `json:"user_id"`
+	BannedBy   string     `json:"banned_by"`
+	Reason     string     `json:"reason,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// NewBan creates a new ban instance. A nil expiresAt means the ban is permanent.
+func NewBan(chatRoomID, userID, bannedBy, reason string, expiresAt *time.Time) *Ban {
+	return &Ban{
+		ChatRoomID: chatRoomID,
+		UserID:     userID,
+		BannedBy:   bannedBy,
+		Reason:     reason,
+		ExpiresAt:  expiresAt,
+		CreatedAt:  time.Now(),
+	}
+}
+
+// IsActive checks if the ban is still in effect
+func (b *Ban) IsActive() bool {
+	if b.ExpiresAt == nil {
+		return true
+	}
+	return time.Now().Before(*b.ExpiresAt)
+}
+
+// MemberSettings represents a member's personal preferences for a chat room
+type MemberSettings struct {
+	ChatRoomID        string     `json:"chat_room_id"`
+	UserID            string     `json:"user_id"`
+	Username          string     `json:"-"`
+	MutedUntil        *time.Time `json:"muted_until,omitempty"`
+	Pinned            bool       `json:"pinned"`
+	Archived          bool       `json:"archived"`
+	NotificationLevel string     `json:"notification_level"`
+}
+
+// NewMemberSettings creates member settings with default values
+func NewMemberSettings(chatRoomID, userID string) *MemberSettings {
+	return &MemberSettings{
+		ChatRoomID:        chatRoomID,
+		UserID:            userID,
+		NotificationLevel: NotifyAll,
+	}
+}
+
+// IsValidNotificationLevel checks if the notification level is known
+func IsValidNotificationLevel(level string) bool {
+	return level == NotifyAll || level == NotifyMentions || level == NotifyNone
+}
+
+// IsMuted checks if the room is currently muted for the member
+func (s *MemberSettings) IsMuted() bool {
+	return s.MutedUntil != nil && time.Now().Before(*s.MutedUntil)
+}
+
+// ShouldNotify checks if the member should be notified about a new message
+func (s *MemberSettings) ShouldNotify(mentioned bool) bool {
+	if s.IsMuted() {
+		return false
+	}
+
+	switch s.NotificationLevel {
+	case NotifyNone:
+		return false
+	case NotifyMentions:
+		return mentioned
+	default:
+		return true
+	}
 }
\ No newline at end of file
@@ -1,15 +1,40 @@
 package chat
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // Repository defines the interface for chat room data access
 type Repository interface {
 	Create(ctx context.Context, chatRoom *ChatRoom) error
 	GetByID(ctx context.Context, id string) (*ChatRoom, error)
-	GetUserChatRooms(ctx context.Context, userID string, limit, offset int) ([]*ChatRoom, int, error)
+	GetUserChatRooms(ctx context.Context, userID string, archived bool, limit, offset int) ([]*ChatRoom, int, error)
 	Update(ctx context.Context, chatRoom *ChatRoom) error
 	Delete(ctx context.Context, id string) error
+	GetDeletedByID(ctx context.Context, id string) (*ChatRoom, error)
+	Restore(ctx context.Context, id string) error
+	PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int64, error)
 	AddMember(ctx context.Context, roomID, userID string) error
 	RemoveMember(ctx context.Context, roomID, userID string) error
 	IsMember(ctx context.Context, roomID, userID string) (bool, error)
+	// ListMembers returns a page of members after the cursor, or the first
+	// page if it is nil, and whether more members follow
+	ListMembers(ctx context.Context, roomID string, after *MemberCursor, limit int) ([]*Member, bool, error)
+	GetMemberRole(ctx context.Context, roomID, userID string) (string, error)
+	UpdateMemberRole(ctx context.Context, roomID, userID, role string) error
+	BanMember(ctx context.Context, ban *Ban) error
+	GetBan(ctx context.Context, roomID, userID string) (*Ban, error)
+	RemoveBan(ctx context.Context, roomID, userID string) error
+	GetMemberSettings(ctx context.Context, roomID, userID string) (*MemberSettings, error)
+	UpdateMemberSettings(ctx context.Context, settings *MemberSettings) error
+	// ClaimSendSlot records that a member sends a message now, unless they
+	// already sent one within interval. If not, it returns false and when
+	// they last sent one. Checking and recording are one statement, so only
+	// one of several concurrent sends gets through.
+	ClaimSendSlot(ctx context.Context, roomID, userID string, interval time.Duration) (bool, time.Time, error)
+	// ListNotificationRecipients returns the settings of members other than
+	// the sender who are not muted and are notified about every message, or
+	// only about mentions and their lowercased username is among mentions
+	ListNotificationRecipients(ctx context.Context, roomID, senderID string, mentions []string) ([]*MemberSettings, error)
 }
\ No newline at end of file
@@ -2,6 +2,7 @@ package message
 
 import (
 	"errors"
+	"strings"
 	"time"
 )
 
@@ -11,18 +12,46 @@ var (
 	ErrInvalidContent  = errors.New("invalid message content")
 )
 
+const (
+	TypeText  = "text"
+	TypeImage = "image" // Every attachment is an image
+	TypeFile  = "file"
+)
+
 // Message represents a message entity
 type Message struct {
 	ID         string    `json:"id"`
 	ChatRoomID string    `json:"chat_room_id"`
 	SenderID   string    `json:"sender_id"`
 	Content    string    `json:"content"`
-	Type       string    `json:"type"`       // text, image, file
-	Status     string    `json:"status"`     // sent, delivered, read
+	Type       string    `json:"type"`        // text, image, file
+	Status     string    `json:"status"`      // sent, delivered, read
+	SentByBot  bool      `json:"sent_by_bot"` // Posted by a bot through an API key
 	CreatedAt  time.Time `json:"created_at"`
 	UpdatedAt  time.Time `json:"updated_at"`
 }
 
+// Mentions returns the usernames mentioned in content, lowercased. A mention
+// is a word starting with @, such as "@bob" in "thanks (@bob)!"; the name
+// ends at whitespace or trailing punctuation, so "@bobby" does not mention bob
+// and an email address mentions no one.
+func Mentions(content string) []string {
+	seen := map[string]bool{}
+	mentions := []string{}
+	for _, word := range strings.Fields(content) {
+		word = strings.TrimLeft(word, "([{\"'")
+		if !strings.HasPrefix(word, "@") {
+			continue
+		}
+		name := strings.ToLower(strings.TrimRight(word[1:], ".,;:!?)]}\"'"))
+		if name != "" && !seen[name] {
+			seen[name] = true
+			mentions = append(mentions, name)
+		}
+	}
+	return mentions
+}
+
 // NewMessage creates a new message instance
 func NewMessage(id, chatRoomID, senderID, content, messageType string) *Message {
 	now := time.Now()
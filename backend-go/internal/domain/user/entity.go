@@ -11,18 +11,69 @@ var (
 	ErrInvalidUsername   = errors.New("invalid username")
 	ErrInvalidPassword   = errors.New("invalid password")
 	ErrUserAlreadyExists = errors.New("user already exists")
+
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+	ErrRefreshTokenReused   = errors.New("refresh token already used")
+
+	ErrSessionNotFound = errors.New("session not found")
+
+	ErrAccountTokenInvalid = errors.New("invalid or expired token")
+
+	ErrTwoFactorNotFound = errors.New("two-factor authentication not set up")
+
+	ErrExternalIdentityNotFound = errors.New("external identity not found")
+
+	ErrBotNotFound    = errors.New("bot not found")
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	ErrAPIKeyInvalid  = errors.New("invalid, expired or revoked API key")
+)
+
+// Account token purposes
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+)
+
+// API key scopes
+const (
+	ScopeRoomsRead     = "rooms:read"
+	ScopeMessagesRead  = "messages:read"
+	ScopeMessagesWrite = "messages:write"
+)
+
+// APIKeyScopes lists every scope an API key may be granted
+var APIKeyScopes = []string{ScopeRoomsRead, ScopeMessagesRead, ScopeMessagesWrite}
+
+// APIKeyTokenPrefix starts every API key, telling them apart from JWTs
+const APIKeyTokenPrefix = "wak_"
+
+// BotEmailDomain is the reserved domain of the placeholder emails bots are given
+const BotEmailDomain = "bots.invalid"
+
+// RecoveryCodeCount is how many recovery codes are issued when two-factor
+// authentication is enabled
+const RecoveryCodeCount = 10
+
+// Account token lifetimes
+const (
+	PasswordResetTTL     = time.Hour
+	EmailVerificationTTL = 24 * time.Hour
 )
 
 // User represents a user entity
 type User struct {
-	ID           string     `json:"id"`
-	Username     string     `json:"username"`
-	Email        string     `json:"email"`
-	PasswordHash string     `json:"-"`
-	IsActive     bool       `json:"is_active"`
-	LastSeenAt   *time.Time `json:"last_seen_at,omitempty"`
-	CreatedAt    time.Time  `json:"created_at"`
-	UpdatedAt    time.Time  `json:"updated_at"`
+	ID              string     `json:"id"`
+	Username        string     `json:"username"`
+	Email           string     `json:"email"`
+	PasswordHash    string     `json:"-"`
+	IsActive        bool       `json:"is_active"`
+	IsAdmin         bool       `json:"is_admin"` // Granted directly in the database
+	IsBot           bool       `json:"is_bot"`
+	OwnerID         *string    `json:"owner_id,omitempty"` // The human who manages the bot
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	LastSeenAt      *time.Time `json:"last_seen_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
 }
 
 // NewUser creates a new user instance
@@ -39,6 +90,20 @@ func NewUser(id, username, email, passwordHash string) *User {
 	}
 }
 
+// NewBot creates a bot user owned by a human. Bots have no password and a
+// placeholder email, so they can only authenticate with API keys.
+func NewBot(id, username, ownerID string) *User {
+	bot := NewUser(id, username, id+"@"+BotEmailDomain, "")
+	bot.IsBot = true
+	bot.OwnerID = &ownerID
+	return bot
+}
+
+// IsOwnedBy checks if the user is a bot managed by the given user
+func (u *User) IsOwnedBy(userID string) bool {
+	return u.IsBot && u.OwnerID != nil && *u.OwnerID == userID
+}
+
 // UpdateLastSeen updates the user's last seen timestamp
 func (u *User) UpdateLastSeen() {
 	now := time.Now()
@@ -58,10 +123,238 @@ func (u *User) Activate() {
 	u.UpdatedAt = time.Now()
 }
 
+// IsEmailVerified checks if the user has confirmed their email address
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// VerifyEmail marks the user's email address as confirmed
+func (u *User) VerifyEmail() {
+	now := time.Now()
+	u.EmailVerifiedAt = &now
+	u.UpdatedAt = now
+}
+
+// ChangePassword replaces the user's password hash
+func (u *User) ChangePassword(passwordHash string) {
+	u.PasswordHash = passwordHash
+	u.UpdatedAt = time.Now()
+}
+
 // IsOnline checks if user is considered online (last seen within 5 minutes)
 func (u *User) IsOnline() bool {
 	if u.LastSeenAt == nil {
 		return false
 	}
 	return time.Since(*u.LastSeenAt) <= 5*time.Minute
+}
+
+// RefreshToken represents a server-side refresh token. Only a hash of the
+// opaque token is stored. Tokens issued by rotating one another share a family,
+// so reuse of an already rotated token can revoke the whole chain.
+type RefreshToken struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	FamilyID   string     `json:"family_id"`
+	TokenHash  string     `json:"-"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	ReplacedBy string     `json:"replaced_by,omitempty"`
+}
+
+// NewRefreshToken creates a new refresh token instance
+func NewRefreshToken(id, userID, familyID, tokenHash string, ttl time.Duration) *RefreshToken {
+	now := time.Now()
+	return &RefreshToken{
+		ID:        id,
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: tokenHash,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+}
+
+// IsExpired checks if the refresh token has expired
+func (t *RefreshToken) IsExpired() bool {
+	return time.Now().After(t.ExpiresAt)
+}
+
+// IsRevoked checks if the refresh token has been rotated or revoked
+func (t *RefreshToken) IsRevoked() bool {
+	return t.RevokedAt != nil
+}
+
+// Session represents a signed-in device. A session starts at login and shares
+// its ID with the refresh token family issued for it, so revoking the session
+// revokes every token the device holds.
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	DeviceName string     `json:"device_name"`
+	UserAgent  string     `json:"user_agent,omitempty"`
+	IPAddress  string     `json:"ip_address,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// NewSession creates a new session instance
+func NewSession(id, userID, deviceName, userAgent, ipAddress string, expiresAt time.Time) *Session {
+	if deviceName == "" {
+		deviceName = "Unknown device"
+	}
+
+	now := time.Now()
+	return &Session{
+		ID:         id,
+		UserID:     userID,
+		DeviceName: deviceName,
+		UserAgent:  userAgent,
+		IPAddress:  ipAddress,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  expiresAt,
+	}
+}
+
+// IsActive checks if the session has neither been revoked nor expired
+func (s *Session) IsActive() bool {
+	return s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+}
+
+// AccountToken represents a single-use token sent to a user by email, such as
+// a password reset or email verification link. Only a hash of it is stored.
+type AccountToken struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"user_id"`
+	Purpose   string     `json:"purpose"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+// NewAccountToken creates a new account token instance
+func NewAccountToken(id, userID, purpose, tokenHash string, ttl time.Duration) *AccountToken {
+	now := time.Now()
+	return &AccountToken{
+		ID:        id,
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: tokenHash,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+}
+
+// TwoFactor holds a user's TOTP enrolment. It only protects logins once the
+// user has confirmed it with a code from their authenticator app.
+type TwoFactor struct {
+	UserID       string     `json:"user_id"`
+	Secret       string     `json:"-"`
+	ConfirmedAt  *time.Time `json:"confirmed_at,omitempty"`
+	LastUsedStep int64      `json:"-"` // Newest accepted TOTP time step, to reject replayed codes
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// NewTwoFactor creates a new unconfirmed TOTP enrolment
+func NewTwoFactor(userID, secret string) *TwoFactor {
+	return &TwoFactor{
+		UserID:    userID,
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	}
+}
+
+// IsEnabled checks if the enrolment has been confirmed
+func (t *TwoFactor) IsEnabled() bool {
+	return t.ConfirmedAt != nil
+}
+
+// ExternalIdentity links an account at an external identity provider, such as
+// a company SSO, to a user
+type ExternalIdentity struct {
+	ID          string     `json:"id"`
+	UserID      string     `json:"user_id"`
+	Issuer      string     `json:"issuer"`
+	Subject     string     `json:"subject"`
+	Email       string     `json:"email,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+}
+
+// NewExternalIdentity creates a new external identity link
+func NewExternalIdentity(id, userID, issuer, subject, email string) *ExternalIdentity {
+	return &ExternalIdentity{
+		ID:        id,
+		UserID:    userID,
+		Issuer:    issuer,
+		Subject:   subject,
+		Email:     email,
+		CreatedAt: time.Now(),
+	}
+}
+
+// APIKey lets a bot authenticate without a JWT. Only a hash of the key is
+// stored; the prefix identifies it in listings. A key may only use its scopes,
+// and only in its rooms if any are listed.
+type APIKey struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	RoomIDs    []string   `json:"room_ids"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// NewAPIKey creates a new API key instance
+func NewAPIKey(id, userID, name, prefix, keyHash string, scopes, roomIDs []string, expiresAt *time.Time) *APIKey {
+	return &APIKey{
+		ID:        id,
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		KeyHash:   keyHash,
+		Scopes:    scopes,
+		RoomIDs:   roomIDs,
+		CreatedAt: time.Now(),
+		ExpiresAt: expiresAt,
+	}
+}
+
+// IsActive checks if the key has neither been revoked nor expired
+func (k *APIKey) IsActive() bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || time.Now().Before(*k.ExpiresAt))
+}
+
+// HasScope checks if the key was granted a scope
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsRoom checks if the key may be used in a room; keys without rooms may
+// be used in every room the bot is a member of
+func (k *APIKey) AllowsRoom(roomID string) bool {
+	if len(k.RoomIDs) == 0 {
+		return true
+	}
+	for _, id := range k.RoomIDs {
+		if id == roomID {
+			return true
+		}
+	}
+	return false
 }
\ No newline at end of file
@@ -1,6 +1,9 @@
 package user
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // Repository defines the interface for user data access
 type Repository interface {
@@ -13,4 +16,79 @@ type Repository interface {
 	UpdateLastSeen(ctx context.Context, id string) error
 	ExistsByEmail(ctx context.Context, email string) (bool, error)
 	ExistsByUsername(ctx context.Context, username string) (bool, error)
+	ListBotsByOwner(ctx context.Context, ownerID string) ([]*User, error)
+}
+
+// RefreshTokenRepository defines the interface for refresh token data access
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *RefreshToken) error
+	GetByHash(ctx context.Context, tokenHash string) (*RefreshToken, error)
+	// Rotate revokes the old token and stores its replacement atomically,
+	// returning ErrRefreshTokenReused if the old token was already revoked
+	Rotate(ctx context.Context, oldID string, newToken *RefreshToken) error
+	RevokeFamily(ctx context.Context, familyID string) error
+	RevokeAllForUser(ctx context.Context, userID string) error
+}
+
+// SessionRepository defines the interface for session data access
+type SessionRepository interface {
+	Create(ctx context.Context, session *Session) error
+	GetByID(ctx context.Context, id string) (*Session, error)
+	ListActiveByUser(ctx context.Context, userID string) ([]*Session, error)
+	// Touch records a use of the session and extends it to the new expiry
+	Touch(ctx context.Context, id string, expiresAt time.Time) error
+	Revoke(ctx context.Context, id string) error
+	// RevokeAllExcept revokes every active session of a user but one,
+	// returning the IDs of the revoked sessions
+	RevokeAllExcept(ctx context.Context, userID, keepID string) ([]string, error)
+}
+
+// AccountTokenRepository defines the interface for account token data access
+type AccountTokenRepository interface {
+	Create(ctx context.Context, token *AccountToken) error
+	// Get returns an unused, unexpired token without using it, or returns
+	// ErrAccountTokenInvalid
+	Get(ctx context.Context, purpose, tokenHash string) (*AccountToken, error)
+	// Consume marks an unused, unexpired token as used and returns it,
+	// or returns ErrAccountTokenInvalid
+	Consume(ctx context.Context, purpose, tokenHash string) (*AccountToken, error)
+	// InvalidateForUser expires every outstanding token of a purpose for a user
+	InvalidateForUser(ctx context.Context, userID, purpose string) error
+}
+
+// TwoFactorRepository defines the interface for TOTP enrolment and recovery code data access
+type TwoFactorRepository interface {
+	Get(ctx context.Context, userID string) (*TwoFactor, error)
+	// Save stores a new unconfirmed enrolment, replacing any unconfirmed one
+	Save(ctx context.Context, twoFactor *TwoFactor) error
+	// Enable confirms the enrolment and replaces the user's recovery codes
+	Enable(ctx context.Context, userID string, recoveryCodeHashes []string) error
+	// UseStep records an accepted TOTP time step, returning false if that
+	// step or a later one was already used
+	UseStep(ctx context.Context, userID string, step int64) (bool, error)
+	// UseRecoveryCode consumes an unused recovery code, returning false if none matched
+	UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error)
+	Delete(ctx context.Context, userID string) error
+}
+
+// ExternalIdentityRepository defines the interface for external identity data access
+type ExternalIdentityRepository interface {
+	Create(ctx context.Context, identity *ExternalIdentity) error
+	GetBySubject(ctx context.Context, issuer, subject string) (*ExternalIdentity, error)
+	// Touch records a login through the identity and the email it currently has
+	Touch(ctx context.Context, id, email string) error
+}
+
+// APIKeyRepository defines the interface for API key data access
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *APIKey) error
+	GetByID(ctx context.Context, id string) (*APIKey, error)
+	GetByHash(ctx context.Context, keyHash string) (*APIKey, error)
+	ListByUser(ctx context.Context, userID string) ([]*APIKey, error)
+	// Touch records a use of the key
+	Touch(ctx context.Context, id string) error
+	// Expire makes a key stop working at the given time, or now if it is earlier
+	Expire(ctx context.Context, id string, at time.Time) error
+	Revoke(ctx context.Context, id string) error
+	RevokeAllForUser(ctx context.Context, userID string) error
 }
\ No newline at end of file
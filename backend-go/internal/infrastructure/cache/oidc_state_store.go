@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"backend-go/internal/shared/oidc"
+)
+
+const oidcStateKeyPrefix = "oidc_state:"
+
+// OIDCStateStore keeps pending single sign-on logins in Redis so the callback
+// can be handled by any replica
+type OIDCStateStore struct {
+	client *redis.Client
+}
+
+// NewOIDCStateStore creates a Redis-backed OIDC login state store
+func NewOIDCStateStore(client *redis.Client) oidc.StateStore {
+	return &OIDCStateStore{
+		client: client,
+	}
+}
+
+// Save stores the login state until it is taken or expires
+func (s *OIDCStateStore) Save(ctx context.Context, state string, login oidc.LoginState, ttl time.Duration) error {
+	data, err := json.Marshal(login)
+	if err != nil {
+		return fmt.Errorf("failed to marshal login state: %w", err)
+	}
+
+	if err := s.client.Set(ctx, oidcStateKeyPrefix+state, data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to store login state: %w", err)
+	}
+
+	return nil
+}
+
+// Take atomically reads and deletes the login state
+func (s *OIDCStateStore) Take(ctx context.Context, state string) (*oidc.LoginState, error) {
+	data, err := s.client.GetDel(ctx, oidcStateKeyPrefix+state).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, oidc.ErrStateNotFound
+		}
+		return nil, fmt.Errorf("failed to get login state: %w", err)
+	}
+
+	var login oidc.LoginState
+	if err := json.Unmarshal(data, &login); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal login state: %w", err)
+	}
+
+	return &login, nil
+}
\ No newline at end of file
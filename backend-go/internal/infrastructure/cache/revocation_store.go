@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"backend-go/internal/shared/jwt"
+)
+
+const revokedTokenKeyPrefix = "revoked_token:"
+
+// RevocationStore keeps revoked token IDs in Redis so every replica sees them
+type RevocationStore struct {
+	client *redis.Client
+}
+
+// NewRevocationStore creates a Redis-backed token revocation store
+func NewRevocationStore(client *redis.Client) jwt.RevocationStore {
+	return &RevocationStore{
+		client: client,
+	}
+}
+
+// Revoke marks a token as revoked; the key expires together with the token
+func (s *RevocationStore) Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		// Already expired, nothing to remember
+		return nil
+	}
+
+	if err := s.client.Set(ctx, revokedTokenKeyPrefix+tokenID, 1, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to store token revocation: %w", err)
+	}
+
+	return nil
+}
+
+// IsRevoked reports whether a token has been revoked
+func (s *RevocationStore) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	count, err := s.client.Exists(ctx, revokedTokenKeyPrefix+tokenID).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+
+	return count > 0, nil
+}
\ No newline at end of file
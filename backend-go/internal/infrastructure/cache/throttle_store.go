@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"backend-go/internal/shared/throttle"
+)
+
+const (
+	failuresKeyPrefix = "throttle_failures:"
+	blockKeyPrefix    = "throttle_block:"
+)
+
+// recordFailureScript increments a failure count, starting its window on the first failure
+var recordFailureScript = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if count == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return count
+`)
+
+// ThrottleStore keeps failed attempt counts and blocks in Redis so limits hold across replicas
+type ThrottleStore struct {
+	client *redis.Client
+}
+
+// NewThrottleStore creates a Redis-backed throttle store
+func NewThrottleStore(client *redis.Client) throttle.Store {
+	return &ThrottleStore{
+		client: client,
+	}
+}
+
+// RecordFailure increments the failure count for a key
+func (s *ThrottleStore) RecordFailure(ctx context.Context, key string, window time.Duration) (int, error) {
+	count, err := recordFailureScript.Run(ctx, s.client, []string{failuresKeyPrefix + key}, window.Milliseconds()).Int()
+	if err != nil {
+		return 0, fmt.Errorf("failed to record failure: %w", err)
+	}
+
+	return count, nil
+}
+
+// Block rejects attempts for a key until the given time; the entry expires with the block
+func (s *ThrottleStore) Block(ctx context.Context, key string, until time.Time) error {
+	ttl := time.Until(until)
+	if ttl <= 0 {
+		return nil
+	}
+
+	if err := s.client.Set(ctx, blockKeyPrefix+key, until.UnixMilli(), ttl).Err(); err != nil {
+		return fmt.Errorf("failed to store block: %w", err)
+	}
+
+	return nil
+}
+
+// BlockedUntil returns when a key is unblocked, or the zero time
+func (s *ThrottleStore) BlockedUntil(ctx context.Context, key string) (time.Time, error) {
+	until, err := s.client.Get(ctx, blockKeyPrefix+key).Int64()
+	if err != nil {
+		if err == redis.Nil {
+			return time.Time{}, nil
+		}
+		return time.Time{}, fmt.Errorf("failed to get block: %w", err)
+	}
+
+	return time.UnixMilli(until), nil
+}
+
+// Reset forgets the failures and block of a key
+func (s *ThrottleStore) Reset(ctx context.Context, key string) error {
+	if err := s.client.Del(ctx, failuresKeyPrefix+key, blockKeyPrefix+key).Err(); err != nil {
+		return fmt.Errorf("failed to reset attempts: %w", err)
+	}
+
+	return nil
+}
\ No newline at end of file
@@ -0,0 +1,117 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"backend-go/internal/domain/user"
+	"backend-go/internal/shared/logger"
+)
+
+type accountTokenRepository struct {
+	db     *pgxpool.Pool
+	logger logger.Logger
+}
+
+func NewAccountTokenRepository(db *pgxpool.Pool, logger logger.Logger) user.AccountTokenRepository {
+	return &accountTokenRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *accountTokenRepository) Create(ctx context.Context, token *user.AccountToken) error {
+	query := `
+		INSERT INTO account_tokens (id, user_id, purpose, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		token.ID,
+		token.UserID,
+		token.Purpose,
+		token.TokenHash,
+		token.ExpiresAt,
+		token.CreatedAt,
+	)
+
+	if err != nil {
+		r.logger.Error("Failed to create account token", "error", err, "user_id", token.UserID, "purpose", token.Purpose)
+		return fmt.Errorf("failed to create account token: %w", err)
+	}
+
+	return nil
+}
+
+func (r *accountTokenRepository) Get(ctx context.Context, purpose, tokenHash string) (*user.AccountToken, error) {
+	query := `
+		SELECT id, user_id, purpose, token_hash, expires_at, created_at, used_at
+		FROM account_tokens
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > NOW()
+	`
+
+	var token user.AccountToken
+	err := r.db.QueryRow(ctx, query, tokenHash, purpose).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Purpose,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.CreatedAt,
+		&token.UsedAt,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, user.ErrAccountTokenInvalid
+		}
+		r.logger.Error("Failed to get account token", "error", err, "purpose", purpose)
+		return nil, fmt.Errorf("failed to get account token: %w", err)
+	}
+
+	return &token, nil
+}
+
+func (r *accountTokenRepository) Consume(ctx context.Context, purpose, tokenHash string) (*user.AccountToken, error) {
+	// Marking the token used in the same statement makes it single-use under concurrency
+	query := `
+		UPDATE account_tokens SET used_at = NOW()
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING id, user_id, purpose, token_hash, expires_at, created_at, used_at
+	`
+
+	var token user.AccountToken
+	err := r.db.QueryRow(ctx, query, tokenHash, purpose).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Purpose,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.CreatedAt,
+		&token.UsedAt,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, user.ErrAccountTokenInvalid
+		}
+		r.logger.Error("Failed to consume account token", "error", err, "purpose", purpose)
+		return nil, fmt.Errorf("failed to consume account token: %w", err)
+	}
+
+	return &token, nil
+}
+
+func (r *accountTokenRepository) InvalidateForUser(ctx context.Context, userID, purpose string) error {
+	query := `UPDATE account_tokens SET expires_at = NOW() WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > NOW()`
+
+	_, err := r.db.Exec(ctx, query, userID, purpose)
+	if err != nil {
+		r.logger.Error("Failed to invalidate account tokens", "error", err, "user_id", userID, "purpose", purpose)
+		return fmt.Errorf("failed to invalidate account tokens: %w", err)
+	}
+
+	return nil
+}
\ No newline at end of file
@@ -0,0 +1,197 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"backend-go/internal/domain/user"
+	"backend-go/internal/shared/logger"
+)
+
+type apiKeyRepository struct {
+	db     *pgxpool.Pool
+	logger logger.Logger
+}
+
+func NewAPIKeyRepository(db *pgxpool.Pool, logger logger.Logger) user.APIKeyRepository {
+	return &apiKeyRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *apiKeyRepository) Create(ctx context.Context, key *user.APIKey) error {
+	query := `
+		INSERT INTO api_keys (id, user_id, name, prefix, key_hash, scopes, room_ids, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		key.ID,
+		key.UserID,
+		key.Name,
+		key.Prefix,
+		key.KeyHash,
+		key.Scopes,
+		key.RoomIDs,
+		key.CreatedAt,
+		key.ExpiresAt,
+	)
+
+	if err != nil {
+		r.logger.Error("Failed to create API key", "error", err, "user_id", key.UserID)
+		return fmt.Errorf("failed to create API key: %w", err)
+	}
+
+	r.logger.Info("API key created", "key_id", key.ID, "user_id", key.UserID)
+	return nil
+}
+
+func (r *apiKeyRepository) GetByID(ctx context.Context, id string) (*user.APIKey, error) {
+	query := `
+		SELECT id, user_id, name, prefix, key_hash, scopes, room_ids, created_at, expires_at, last_used_at, revoked_at
+		FROM api_keys
+		WHERE id = $1
+	`
+
+	key, err := scanAPIKey(r.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, user.ErrAPIKeyNotFound
+		}
+		r.logger.Error("Failed to get API key", "error", err, "key_id", id)
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	return key, nil
+}
+
+func (r *apiKeyRepository) GetByHash(ctx context.Context, keyHash string) (*user.APIKey, error) {
+	query := `
+		SELECT id, user_id, name, prefix, key_hash, scopes, room_ids, created_at, expires_at, last_used_at, revoked_at
+		FROM api_keys
+		WHERE key_hash = $1
+	`
+
+	key, err := scanAPIKey(r.db.QueryRow(ctx, query, keyHash))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, user.ErrAPIKeyNotFound
+		}
+		r.logger.Error("Failed to get API key by hash", "error", err)
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	return key, nil
+}
+
+func (r *apiKeyRepository) ListByUser(ctx context.Context, userID string) ([]*user.APIKey, error) {
+	query := `
+		SELECT id, user_id, name, prefix, key_hash, scopes, room_ids, created_at, expires_at, last_used_at, revoked_at
+		FROM api_keys
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		r.logger.Error("Failed to list API keys", "error", err, "user_id", userID)
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*user.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			r.logger.Error("Failed to scan API key", "error", err)
+			return nil, fmt.Errorf("failed to scan API key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	if err = rows.Err(); err != nil {
+		r.logger.Error("Error iterating API keys", "error", err)
+		return nil, fmt.Errorf("error iterating API keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+func (r *apiKeyRepository) Touch(ctx context.Context, id string) error {
+	query := `UPDATE api_keys SET last_used_at = NOW() WHERE id = $1`
+
+	_, err := r.db.Exec(ctx, query, id)
+	if err != nil {
+		r.logger.Error("Failed to touch API key", "error", err, "key_id", id)
+		return fmt.Errorf("failed to touch API key: %w", err)
+	}
+
+	return nil
+}
+
+func (r *apiKeyRepository) Expire(ctx context.Context, id string, at time.Time) error {
+	query := `
+		UPDATE api_keys SET expires_at = LEAST(COALESCE(expires_at, $2), $2)
+		WHERE id = $1 AND revoked_at IS NULL
+	`
+
+	_, err := r.db.Exec(ctx, query, id, at)
+	if err != nil {
+		r.logger.Error("Failed to expire API key", "error", err, "key_id", id)
+		return fmt.Errorf("failed to expire API key: %w", err)
+	}
+
+	return nil
+}
+
+func (r *apiKeyRepository) Revoke(ctx context.Context, id string) error {
+	query := `UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL`
+
+	_, err := r.db.Exec(ctx, query, id)
+	if err != nil {
+		r.logger.Error("Failed to revoke API key", "error", err, "key_id", id)
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+
+	r.logger.Info("API key revoked", "key_id", id)
+	return nil
+}
+
+func (r *apiKeyRepository) RevokeAllForUser(ctx context.Context, userID string) error {
+	query := `UPDATE api_keys SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`
+
+	_, err := r.db.Exec(ctx, query, userID)
+	if err != nil {
+		r.logger.Error("Failed to revoke API keys", "error", err, "user_id", userID)
+		return fmt.Errorf("failed to revoke API keys: %w", err)
+	}
+
+	r.logger.Info("API keys revoked", "user_id", userID)
+	return nil
+}
+
+// scanAPIKey scans a single API key row
+func scanAPIKey(row pgx.Row) (*user.APIKey, error) {
+	var key user.APIKey
+	err := row.Scan(
+		&key.ID,
+		&key.UserID,
+		&key.Name,
+		&key.Prefix,
+		&key.KeyHash,
+		&key.Scopes,
+		&key.RoomIDs,
+		&key.CreatedAt,
+		&key.ExpiresAt,
+		&key.LastUsedAt,
+		&key.RevokedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
\ No newline at end of file
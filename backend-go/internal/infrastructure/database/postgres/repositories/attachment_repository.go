@@ -0,0 +1,675 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"backend-go/internal/domain/attachment"
+	"backend-go/internal/shared/logger"
+)
+
+type attachmentRepository struct {
+	db     *pgxpool.Pool
+	logger logger.Logger
+}
+
+func NewAttachmentRepository(db *pgxpool.Pool, logger logger.Logger) attachment.Repository {
+	return &attachmentRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *attachmentRepository) Create(ctx context.Context, a *attachment.Attachment, quota attachment.Quota) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", "error", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the usage of the uploader and the room, always in the same order,
+	// so concurrent uploads cannot both fit into the remaining quota
+	ensureQuery := `
+		INSERT INTO storage_usage (scope, owner_id) VALUES ($1, $2), ($3, $4)
+		ON CONFLICT (scope, owner_id) DO NOTHING
+	`
+	if _, err := tx.Exec(ctx, ensureQuery, attachment.UsageScopeRoom, a.ChatRoomID, attachment.UsageScopeUser, a.OwnerID); err != nil {
+		r.logger.Error("Failed to create storage usage", "error", err, "owner_id", a.OwnerID)
+		return fmt.Errorf("failed to create attachment: %w", err)
+	}
+
+	usageQuery := `
+		SELECT scope, bytes FROM storage_usage
+		WHERE (scope = $1 AND owner_id = $2) OR (scope = $3 AND owner_id = $4)
+		ORDER BY scope
+		FOR UPDATE
+	`
+	rows, err := tx.Query(ctx, usageQuery, attachment.UsageScopeRoom, a.ChatRoomID, attachment.UsageScopeUser, a.OwnerID)
+	if err != nil {
+		r.logger.Error("Failed to lock storage usage", "error", err, "owner_id", a.OwnerID)
+		return fmt.Errorf("failed to create attachment: %w", err)
+	}
+	used := make(map[string]int64)
+	for rows.Next() {
+		var scope string
+		var bytes int64
+		if err := rows.Scan(&scope, &bytes); err != nil {
+			rows.Close()
+			r.logger.Error("Failed to scan storage usage", "error", err)
+			return fmt.Errorf("failed to create attachment: %w", err)
+		}
+		used[scope] = bytes
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Failed to iterate storage usage", "error", err)
+		return fmt.Errorf("failed to create attachment: %w", err)
+	}
+
+	if quota.UserBytes > 0 && used[attachment.UsageScopeUser]+a.Size > quota.UserBytes {
+		return attachment.ErrUserQuotaExceeded
+	}
+	if quota.RoomBytes > 0 && used[attachment.UsageScopeRoom]+a.Size > quota.RoomBytes {
+		return attachment.ErrRoomQuotaExceeded
+	}
+
+	// Usage is updated by a trigger on the attachments table
+	query := `
+		INSERT INTO attachments (id, owner_id, chat_room_id, original_name, size, mime_type, checksum, status, width, height, render_type, storage_key, scan_status, threat, created_at, ready_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+	`
+
+	_, err = tx.Exec(ctx, query,
+		a.ID,
+		a.OwnerID,
+		a.ChatRoomID,
+		a.OriginalName,
+		a.Size,
+		a.MimeType,
+		a.Checksum,
+		a.Status,
+		a.Width,
+		a.Height,
+		a.RenderType,
+		a.StorageKey,
+		a.ScanStatus,
+		a.Threat,
+		a.CreatedAt,
+		a.ReadyAt,
+	)
+
+	if err != nil {
+		r.logger.Error("Failed to create attachment", "error", err, "owner_id", a.OwnerID)
+		return fmt.Errorf("failed to create attachment: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		r.logger.Error("Failed to commit transaction", "error", err)
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	r.logger.Info("Attachment created", "attachment_id", a.ID, "room_id", a.ChatRoomID)
+	return nil
+}
+
+func (r *attachmentRepository) GetByID(ctx context.Context, id string) (*attachment.Attachment, error) {
+	query := `
+		SELECT id, owner_id, chat_room_id, original_name, size, mime_type, checksum, status, width, height, render_type, message_id, storage_key, scan_status, threat, scan_attempts, next_scan_at, created_at, ready_at
+		FROM attachments
+		WHERE id = $1
+	`
+
+	a, err := scanAttachment(r.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, attachment.ErrAttachmentNotFound
+		}
+		r.logger.Error("Failed to get attachment", "error", err, "attachment_id", id)
+		return nil, fmt.Errorf("failed to get attachment: %w", err)
+	}
+
+	return a, nil
+}
+
+func (r *attachmentRepository) Delete(ctx context.Context, id string) error {
+	query := `DELETE FROM attachments WHERE id = $1`
+
+	result, err := r.db.Exec(ctx, query, id)
+	if err != nil {
+		r.logger.Error("Failed to delete attachment", "error", err, "attachment_id", id)
+		return fmt.Errorf("failed to delete attachment: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return attachment.ErrAttachmentNotFound
+	}
+
+	r.logger.Info("Attachment deleted", "attachment_id", id)
+	return nil
+}
+
+func (r *attachmentRepository) MarkReady(ctx context.Context, a *attachment.Attachment) error {
+	// The blob reference is counted by a trigger on the attachments table
+	query := `
+		UPDATE attachments SET size = $2, checksum = $3, width = $4, height = $5, render_type = $6, storage_key = $7, scan_status = $8, status = $9, ready_at = $10
+		WHERE id = $1 AND status = $11
+	`
+
+	result, err := r.db.Exec(ctx, query,
+		a.ID,
+		a.Size,
+		a.Checksum,
+		a.Width,
+		a.Height,
+		a.RenderType,
+		a.StorageKey,
+		a.ScanStatus,
+		attachment.StatusReady,
+		a.ReadyAt,
+		attachment.StatusPending,
+	)
+	if err != nil {
+		r.logger.Error("Failed to mark attachment ready", "error", err, "attachment_id", a.ID)
+		return fmt.Errorf("failed to mark attachment ready: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return attachment.ErrAttachmentNotFound
+	}
+
+	return nil
+}
+
+func (r *attachmentRepository) ListPendingBefore(ctx context.Context, before time.Time, limit int) ([]*attachment.Attachment, error) {
+	query := `
+		SELECT id, owner_id, chat_room_id, original_name, size, mime_type, checksum, status, width, height, render_type, message_id, storage_key, scan_status, threat, scan_attempts, next_scan_at, created_at, ready_at
+		FROM attachments
+		WHERE status = $1 AND created_at < $2
+			AND NOT EXISTS (SELECT 1 FROM upload_sessions us WHERE us.attachment_id = attachments.id)
+		ORDER BY created_at
+		LIMIT $3
+	`
+
+	rows, err := r.db.Query(ctx, query, attachment.StatusPending, before, limit)
+	if err != nil {
+		r.logger.Error("Failed to list pending attachments", "error", err)
+		return nil, fmt.Errorf("failed to list pending attachments: %w", err)
+	}
+	defer rows.Close()
+
+	var attachments []*attachment.Attachment
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			r.logger.Error("Failed to scan attachment", "error", err)
+			return nil, fmt.Errorf("failed to scan attachment: %w", err)
+		}
+		attachments = append(attachments, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Failed to iterate attachments", "error", err)
+		return nil, fmt.Errorf("failed to iterate attachments: %w", err)
+	}
+
+	return attachments, nil
+}
+
+func (r *attachmentRepository) ListUnattachedBefore(ctx context.Context, before time.Time, afterID string, limit int) ([]*attachment.Attachment, error) {
+	query := `
+		SELECT id, owner_id, chat_room_id, original_name, size, mime_type, checksum, status, width, height, render_type, message_id, storage_key, scan_status, threat, scan_attempts, next_scan_at, created_at, ready_at
+		FROM attachments
+		WHERE status = $1 AND message_id IS NULL AND scan_status <> $2 AND ready_at < $3 AND id > $4
+		ORDER BY id
+		LIMIT $5
+	`
+
+	rows, err := r.db.Query(ctx, query, attachment.StatusReady, attachment.ScanPending, before, afterID, limit)
+	if err != nil {
+		r.logger.Error("Failed to list unattached attachments", "error", err)
+		return nil, fmt.Errorf("failed to list unattached attachments: %w", err)
+	}
+	defer rows.Close()
+
+	var attachments []*attachment.Attachment
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			r.logger.Error("Failed to scan attachment", "error", err)
+			return nil, fmt.Errorf("failed to scan attachment: %w", err)
+		}
+		attachments = append(attachments, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Failed to iterate attachments", "error", err)
+		return nil, fmt.Errorf("failed to iterate attachments: %w", err)
+	}
+
+	return attachments, nil
+}
+
+func (r *attachmentRepository) DeleteUnattached(ctx context.Context, id string) error {
+	query := `DELETE FROM attachments WHERE id = $1 AND message_id IS NULL`
+
+	result, err := r.db.Exec(ctx, query, id)
+	if err != nil {
+		r.logger.Error("Failed to delete attachment", "error", err, "attachment_id", id)
+		return fmt.Errorf("failed to delete attachment: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return attachment.ErrAttachmentNotFound
+	}
+
+	r.logger.Info("Attachment deleted", "attachment_id", id)
+	return nil
+}
+
+func (r *attachmentRepository) ListScanPending(ctx context.Context, before time.Time, limit int) ([]*attachment.Attachment, error) {
+	query := `
+		SELECT id, owner_id, chat_room_id, original_name, size, mime_type, checksum, status, width, height, render_type, message_id, storage_key, scan_status, threat, scan_attempts, next_scan_at, created_at, ready_at
+		FROM attachments
+		WHERE scan_status = $1 AND status = $2 AND (next_scan_at IS NULL OR next_scan_at < $3)
+		ORDER BY next_scan_at NULLS FIRST, created_at
+		LIMIT $4
+	`
+
+	rows, err := r.db.Query(ctx, query, attachment.ScanPending, attachment.StatusReady, before, limit)
+	if err != nil {
+		r.logger.Error("Failed to list attachments pending scan", "error", err)
+		return nil, fmt.Errorf("failed to list attachments pending scan: %w", err)
+	}
+	defer rows.Close()
+
+	var attachments []*attachment.Attachment
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			r.logger.Error("Failed to scan attachment", "error", err)
+			return nil, fmt.Errorf("failed to scan attachment: %w", err)
+		}
+		attachments = append(attachments, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Failed to iterate attachments", "error", err)
+		return nil, fmt.Errorf("failed to iterate attachments: %w", err)
+	}
+
+	return attachments, nil
+}
+
+func (r *attachmentRepository) RecordScan(ctx context.Context, a *attachment.Attachment) error {
+	query := `UPDATE attachments SET scan_status = $2, threat = $3, ready_at = $4 WHERE id = $1 AND scan_status = $5`
+
+	result, err := r.db.Exec(ctx, query, a.ID, a.ScanStatus, a.Threat, a.ReadyAt, attachment.ScanPending)
+	if err != nil {
+		r.logger.Error("Failed to record scan", "error", err, "attachment_id", a.ID)
+		return fmt.Errorf("failed to record scan: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return attachment.ErrAttachmentNotFound
+	}
+
+	return nil
+}
+
+func (r *attachmentRepository) PostponeScan(ctx context.Context, a *attachment.Attachment) error {
+	query := `UPDATE attachments SET scan_attempts = $2, next_scan_at = $3 WHERE id = $1 AND scan_status = $4`
+
+	result, err := r.db.Exec(ctx, query, a.ID, a.ScanAttempts, a.NextScanAt, attachment.ScanPending)
+	if err != nil {
+		r.logger.Error("Failed to postpone scan", "error", err, "attachment_id", a.ID)
+		return fmt.Errorf("failed to postpone scan: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return attachment.ErrAttachmentNotFound
+	}
+
+	return nil
+}
+
+func (r *attachmentRepository) AttachToMessage(ctx context.Context, messageID string, ids []string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", "error", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		UPDATE attachments SET message_id = $1
+		WHERE id = ANY($2) AND message_id IS NULL AND status = $3
+	`
+
+	result, err := tx.Exec(ctx, query, messageID, ids, attachment.StatusReady)
+	if err != nil {
+		r.logger.Error("Failed to attach attachments", "error", err, "message_id", messageID)
+		return fmt.Errorf("failed to attach attachments: %w", err)
+	}
+
+	// Another message claimed one of the attachments first
+	if result.RowsAffected() != int64(len(ids)) {
+		return attachment.ErrAlreadyAttached
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		r.logger.Error("Failed to commit transaction", "error", err)
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (r *attachmentRepository) ListByMessages(ctx context.Context, messageIDs []string) ([]*attachment.Attachment, error) {
+	query := `
+		SELECT id, owner_id, chat_room_id, original_name, size, mime_type, checksum, status, width, height, render_type, message_id, storage_key, scan_status, threat, scan_attempts, next_scan_at, created_at, ready_at
+		FROM attachments
+		WHERE message_id = ANY($1)
+		ORDER BY created_at, id
+	`
+
+	rows, err := r.db.Query(ctx, query, messageIDs)
+	if err != nil {
+		r.logger.Error("Failed to list message attachments", "error", err)
+		return nil, fmt.Errorf("failed to list message attachments: %w", err)
+	}
+	defer rows.Close()
+
+	var attachments []*attachment.Attachment
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			r.logger.Error("Failed to scan attachment", "error", err)
+			return nil, fmt.Errorf("failed to scan attachment: %w", err)
+		}
+		attachments = append(attachments, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Failed to iterate attachments", "error", err)
+		return nil, fmt.Errorf("failed to iterate attachments: %w", err)
+	}
+
+	return attachments, nil
+}
+
+func (r *attachmentRepository) GetUsage(ctx context.Context, scope, ownerID string) (*attachment.Usage, error) {
+	query := `SELECT bytes, files FROM storage_usage WHERE scope = $1 AND owner_id = $2`
+
+	var usage attachment.Usage
+	err := r.db.QueryRow(ctx, query, scope, ownerID).Scan(&usage.Bytes, &usage.Files)
+	if err != nil {
+		// Nothing has been uploaded yet
+		if err == pgx.ErrNoRows {
+			return &usage, nil
+		}
+		r.logger.Error("Failed to get storage usage", "error", err, "scope", scope, "owner_id", ownerID)
+		return nil, fmt.Errorf("failed to get storage usage: %w", err)
+	}
+
+	return &usage, nil
+}
+
+func (r *attachmentRepository) ListRoomUsage(ctx context.Context, ownerID string) ([]*attachment.RoomUsage, error) {
+	query := `
+		SELECT a.chat_room_id, SUM(a.size), COUNT(*), COALESCE(su.bytes, 0)
+		FROM attachments a
+		LEFT JOIN storage_usage su ON su.scope = $2 AND su.owner_id = a.chat_room_id
+		WHERE a.owner_id = $1
+		GROUP BY a.chat_room_id, su.bytes
+		ORDER BY SUM(a.size) DESC, a.chat_room_id
+	`
+
+	rows, err := r.db.Query(ctx, query, ownerID, attachment.UsageScopeRoom)
+	if err != nil {
+		r.logger.Error("Failed to list room storage usage", "error", err, "owner_id", ownerID)
+		return nil, fmt.Errorf("failed to list room storage usage: %w", err)
+	}
+	defer rows.Close()
+
+	var usage []*attachment.RoomUsage
+	for rows.Next() {
+		var u attachment.RoomUsage
+		if err := rows.Scan(&u.ChatRoomID, &u.Bytes, &u.Files, &u.RoomBytes); err != nil {
+			r.logger.Error("Failed to scan room storage usage", "error", err)
+			return nil, fmt.Errorf("failed to scan room storage usage: %w", err)
+		}
+		usage = append(usage, &u)
+	}
+
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Failed to iterate room storage usage", "error", err)
+		return nil, fmt.Errorf("failed to iterate room storage usage: %w", err)
+	}
+
+	return usage, nil
+}
+
+func (r *attachmentRepository) ReserveBlob(ctx context.Context, b *attachment.Blob) (*attachment.Blob, error) {
+	// Updating an existing blob waits for a purge that holds it, after which
+	// the blob is inserted afresh. An unreferenced blob counts as orphaned
+	// from now on, so it is not purged before the reference is recorded.
+	query := `
+		INSERT INTO blobs (key, checksum, source_checksum, size, mime_type, width, height, render_type, orphaned_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, CURRENT_TIMESTAMP, $9)
+		ON CONFLICT (key) DO UPDATE
+			SET orphaned_at = CASE WHEN blobs.ref_count = 0 THEN CURRENT_TIMESTAMP ELSE NULL END
+		RETURNING key, checksum, source_checksum, size, mime_type, width, height, render_type, ref_count, orphaned_at, created_at
+	`
+
+	stored, err := scanBlob(r.db.QueryRow(ctx, query,
+		b.Key,
+		b.Checksum,
+		b.SourceChecksum,
+		b.Size,
+		b.MimeType,
+		b.Width,
+		b.Height,
+		b.RenderType,
+		b.CreatedAt,
+	))
+	if err != nil {
+		r.logger.Error("Failed to reserve blob", "error", err, "key", b.Key)
+		return nil, fmt.Errorf("failed to reserve blob: %w", err)
+	}
+
+	return stored, nil
+}
+
+func (r *attachmentRepository) GetBlobByChecksum(ctx context.Context, checksum, userID string) (*attachment.Blob, error) {
+	// Only content the user already has access to is found, so the checksum
+	// of a file cannot be used to learn whether someone else uploaded it
+	query := `
+		SELECT b.key, b.checksum, b.source_checksum, b.size, b.mime_type, b.width, b.height, b.render_type, b.ref_count, b.orphaned_at, b.created_at
+		FROM blobs b
+		WHERE (b.checksum = $1 OR b.source_checksum = $1) AND b.ref_count > 0
+			AND EXISTS (
+				SELECT 1 FROM attachments a
+				WHERE a.storage_key = b.key AND a.status = $3 AND a.scan_status <> $4
+					AND (a.owner_id = $2 OR EXISTS (
+						SELECT 1 FROM chat_room_members crm
+						JOIN chat_rooms cr ON cr.id = crm.chat_room_id
+						WHERE crm.chat_room_id = a.chat_room_id AND crm.user_id = $2 AND cr.deleted_at IS NULL
+					))
+			)
+		ORDER BY b.checksum = $1 DESC, b.created_at
+		LIMIT 1
+	`
+
+	b, err := scanBlob(r.db.QueryRow(ctx, query, checksum, userID, attachment.StatusReady, attachment.ScanQuarantined))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, attachment.ErrBlobNotFound
+		}
+		r.logger.Error("Failed to get blob", "error", err, "checksum", checksum)
+		return nil, fmt.Errorf("failed to get blob: %w", err)
+	}
+
+	return b, nil
+}
+
+func (r *attachmentRepository) ListOrphanedBlobs(ctx context.Context, before time.Time, afterKey string, limit int) ([]*attachment.Blob, error) {
+	query := `
+		SELECT key, checksum, source_checksum, size, mime_type, width, height, render_type, ref_count, orphaned_at, created_at
+		FROM blobs
+		WHERE ref_count = 0 AND orphaned_at < $1 AND key > $2
+		ORDER BY key
+		LIMIT $3
+	`
+
+	rows, err := r.db.Query(ctx, query, before, afterKey, limit)
+	if err != nil {
+		r.logger.Error("Failed to list orphaned blobs", "error", err)
+		return nil, fmt.Errorf("failed to list orphaned blobs: %w", err)
+	}
+	defer rows.Close()
+
+	var blobs []*attachment.Blob
+	for rows.Next() {
+		b, err := scanBlob(rows)
+		if err != nil {
+			r.logger.Error("Failed to scan blob", "error", err)
+			return nil, fmt.Errorf("failed to scan blob: %w", err)
+		}
+		blobs = append(blobs, b)
+	}
+
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Failed to iterate blobs", "error", err)
+		return nil, fmt.Errorf("failed to iterate blobs: %w", err)
+	}
+
+	return blobs, nil
+}
+
+func (r *attachmentRepository) DeleteOrphanedBlob(ctx context.Context, key string, before time.Time, deleteContent func() error) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", "error", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Hold the blob while its content is deleted, so an identical upload
+	// reserving it meanwhile waits and then stores the content again
+	lockQuery := `SELECT key FROM blobs WHERE key = $1 AND ref_count = 0 AND orphaned_at < $2 FOR UPDATE`
+	if err := tx.QueryRow(ctx, lockQuery, key, before).Scan(&key); err != nil {
+		if err == pgx.ErrNoRows {
+			return attachment.ErrBlobNotFound
+		}
+		r.logger.Error("Failed to lock blob", "error", err, "key", key)
+		return fmt.Errorf("failed to delete blob: %w", err)
+	}
+
+	if err := deleteContent(); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM blobs WHERE key = $1`, key); err != nil {
+		r.logger.Error("Failed to delete blob", "error", err, "key", key)
+		return fmt.Errorf("failed to delete blob: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		r.logger.Error("Failed to commit transaction", "error", err)
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	r.logger.Info("Blob deleted", "key", key)
+	return nil
+}
+
+func (r *attachmentRepository) ListReferencedKeys(ctx context.Context, keys []string) ([]string, error) {
+	// Uploads in progress are stored under their own ID until they are ready
+	query := `
+		SELECT key FROM blobs WHERE key = ANY($1)
+		UNION
+		SELECT storage_key FROM attachments WHERE storage_key = ANY($1)
+	`
+
+	rows, err := r.db.Query(ctx, query, keys)
+	if err != nil {
+		r.logger.Error("Failed to list referenced storage keys", "error", err)
+		return nil, fmt.Errorf("failed to list referenced storage keys: %w", err)
+	}
+	defer rows.Close()
+
+	var referenced []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			r.logger.Error("Failed to scan storage key", "error", err)
+			return nil, fmt.Errorf("failed to scan storage key: %w", err)
+		}
+		referenced = append(referenced, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Failed to iterate storage keys", "error", err)
+		return nil, fmt.Errorf("failed to iterate storage keys: %w", err)
+	}
+
+	return referenced, nil
+}
+
+func scanBlob(row pgx.Row) (*attachment.Blob, error) {
+	var b attachment.Blob
+	err := row.Scan(
+		&b.Key,
+		&b.Checksum,
+		&b.SourceChecksum,
+		&b.Size,
+		&b.MimeType,
+		&b.Width,
+		&b.Height,
+		&b.RenderType,
+		&b.RefCount,
+		&b.OrphanedAt,
+		&b.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func scanAttachment(row pgx.Row) (*attachment.Attachment, error) {
+	var a attachment.Attachment
+	var messageID *string
+	err := row.Scan(
+		&a.ID,
+		&a.OwnerID,
+		&a.ChatRoomID,
+		&a.OriginalName,
+		&a.Size,
+		&a.MimeType,
+		&a.Checksum,
+		&a.Status,
+		&a.Width,
+		&a.Height,
+		&a.RenderType,
+		&messageID,
+		&a.StorageKey,
+		&a.ScanStatus,
+		&a.Threat,
+		&a.ScanAttempts,
+		&a.NextScanAt,
+		&a.CreatedAt,
+		&a.ReadyAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if messageID != nil {
+		a.MessageID = *messageID
+	}
+	return &a, nil
+}
\ No newline at end of file
@@ -54,7 +54,7 @@ func (r *chatRepository) Create(ctx context.Context, chatRoom *chat.ChatRoom) er
 
 	// Add members to chat room
 	if len(chatRoom.Members) > 0 {
-		err = r.addMembersToRoom(ctx, tx, chatRoom.ID, chatRoom.Members)
+		err = r.addMembersToRoom(ctx, tx, chatRoom.ID, chatRoom.CreatedBy, chatRoom.Members)
 		if err != nil {
 			return err
 		}
@@ -71,9 +71,11 @@ func (r *chatRepository) Create(ctx context.Context, chatRoom *chat.ChatRoom) er
 
 func (r *chatRepository) GetByID(ctx context.Context, id string) (*chat.ChatRoom, error) {
 	query := `
-		SELECT id, name, description, is_private, created_by, created_at, updated_at
-		FROM chat_rooms
-		WHERE id = $1
+		SELECT cr.id, cr.name, cr.description, cr.is_private, cr.created_by,
+			(SELECT COUNT(*) FROM chat_room_members crm WHERE crm.chat_room_id = cr.id),
+			cr.created_at, cr.updated_at, cr.announcement_only, cr.slow_mode_seconds
+		FROM chat_rooms cr
+		WHERE cr.id = $1 AND cr.deleted_at IS NULL
 	`
 
 	var chatRoom chat.ChatRoom
@@ -85,8 +87,11 @@ func (r *chatRepository) GetByID(ctx context.Context, id string) (*chat.ChatRoom
 		&description,
 		&chatRoom.IsPrivate,
 		&chatRoom.CreatedBy,
+		&chatRoom.TotalMembers,
 		&chatRoom.CreatedAt,
 		&chatRoom.UpdatedAt,
+		&chatRoom.AnnouncementOnly,
+		&chatRoom.SlowModeSeconds,
 	)
 
 	if err != nil {
@@ -101,43 +106,39 @@ func (r *chatRepository) GetByID(ctx context.Context, id string) (*chat.ChatRoom
 		chatRoom.Description = *description
 	}
 
-	// Get members
-	members, err := r.getChatRoomMembers(ctx, id)
-	if err != nil {
-		return nil, err
-	}
-	chatRoom.Members = members
-
 	return &chatRoom, nil
 }
 
-func (r *chatRepository) GetUserChatRooms(ctx context.Context, userID string, limit, offset int) ([]*chat.ChatRoom, int, error) {
+func (r *chatRepository) GetUserChatRooms(ctx context.Context, userID string, archived bool, limit, offset int) ([]*chat.ChatRoom, int, error) {
 	// Get total count
 	countQuery := `
-		SELECT COUNT(DISTINCT cr.id)
+		SELECT COUNT(*)
 		FROM chat_rooms cr
 		JOIN chat_room_members crm ON cr.id = crm.chat_room_id
-		WHERE crm.user_id = $1
+		WHERE crm.user_id = $1 AND crm.archived = $2 AND cr.deleted_at IS NULL
 	`
 
 	var total int
-	err := r.db.QueryRow(ctx, countQuery, userID).Scan(&total)
+	err := r.db.QueryRow(ctx, countQuery, userID, archived).Scan(&total)
 	if err != nil {
 		r.logger.Error("Failed to get user chat rooms count", "error", err, "user_id", userID)
 		return nil, 0, fmt.Errorf("failed to get user chat rooms count: %w", err)
 	}
 
-	// Get chat rooms
+	// Get chat rooms, pinned rooms first
 	query := `
-		SELECT DISTINCT cr.id, cr.name, cr.description, cr.is_private, cr.created_by, cr.created_at, cr.updated_at
+		SELECT cr.id, cr.name, cr.description, cr.is_private, cr.created_by,
+			(SELECT COUNT(*) FROM chat_room_members m WHERE m.chat_room_id = cr.id),
+			cr.created_at, cr.updated_at, cr.announcement_only, cr.slow_mode_seconds,
+			crm.muted_until, crm.pinned, crm.archived, crm.notification_level
 		FROM chat_rooms cr
 		JOIN chat_room_members crm ON cr.id = crm.chat_room_id
-		WHERE crm.user_id = $1
-		ORDER BY cr.updated_at DESC
-		LIMIT $2 OFFSET $3
+		WHERE crm.user_id = $1 AND crm.archived = $2 AND cr.deleted_at IS NULL
+		ORDER BY crm.pinned DESC, cr.updated_at DESC
+		LIMIT $3 OFFSET $4
 	`
 
-	rows, err := r.db.Query(ctx, query, userID, limit, offset)
+	rows, err := r.db.Query(ctx, query, userID, archived, limit, offset)
 	if err != nil {
 		r.logger.Error("Failed to get user chat rooms", "error", err, "user_id", userID)
 		return nil, 0, fmt.Errorf("failed to get user chat rooms: %w", err)
@@ -148,6 +149,7 @@ func (r *chatRepository) GetUserChatRooms(ctx context.Context, userID string, li
 	for rows.Next() {
 		var chatRoom chat.ChatRoom
 		var description *string
+		settings := chat.MemberSettings{UserID: userID}
 
 		err := rows.Scan(
 			&chatRoom.ID,
@@ -155,8 +157,15 @@ func (r *chatRepository) GetUserChatRooms(ctx context.Context, userID string, li
 			&description,
 			&chatRoom.IsPrivate,
 			&chatRoom.CreatedBy,
+			&chatRoom.TotalMembers,
 			&chatRoom.CreatedAt,
 			&chatRoom.UpdatedAt,
+			&chatRoom.AnnouncementOnly,
+			&chatRoom.SlowModeSeconds,
+			&settings.MutedUntil,
+			&settings.Pinned,
+			&settings.Archived,
+			&settings.NotificationLevel,
 		)
 
 		if err != nil {
@@ -168,12 +177,8 @@ func (r *chatRepository) GetUserChatRooms(ctx context.Context, userID string, li
 			chatRoom.Description = *description
 		}
 
-		// Get members for each chat room
-		members, err := r.getChatRoomMembers(ctx, chatRoom.ID)
-		if err != nil {
-			return nil, 0, err
-		}
-		chatRoom.Members = members
+		settings.ChatRoomID = chatRoom.ID
+		chatRoom.Settings = &settings
 
 		chatRooms = append(chatRooms, &chatRoom)
 	}
@@ -189,8 +194,9 @@ func (r *chatRepository) GetUserChatRooms(ctx context.Context, userID string, li
 func (r *chatRepository) Update(ctx context.Context, chatRoom *chat.ChatRoom) error {
 	query := `
 		UPDATE chat_rooms
-		SET name = $2, description = $3, is_private = $4, updated_at = $5
-		WHERE id = $1
+		SET name = $2, description = $3, is_private = $4, updated_at = $5,
+			announcement_only = $6, slow_mode_seconds = $7
+		WHERE id = $1 AND deleted_at IS NULL
 	`
 
 	chatRoom.UpdatedAt = time.Now()
@@ -201,6 +207,8 @@ func (r *chatRepository) Update(ctx context.Context, chatRoom *chat.ChatRoom) er
 		chatRoom.Description,
 		chatRoom.IsPrivate,
 		chatRoom.UpdatedAt,
+		chatRoom.AnnouncementOnly,
+		chatRoom.SlowModeSeconds,
 	)
 
 	if err != nil {
@@ -216,39 +224,94 @@ func (r *chatRepository) Update(ctx context.Context, chatRoom *chat.ChatRoom) er
 	return nil
 }
 
+// Delete soft-deletes a chat room; it can be restored until PurgeDeleted removes it
 func (r *chatRepository) Delete(ctx context.Context, id string) error {
-	tx, err := r.db.Begin(ctx)
+	query := `UPDATE chat_rooms SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`
+
+	result, err := r.db.Exec(ctx, query, id)
 	if err != nil {
-		r.logger.Error("Failed to begin transaction", "error", err)
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		r.logger.Error("Failed to delete chat room", "error", err, "room_id", id)
+		return fmt.Errorf("failed to delete chat room: %w", err)
 	}
-	defer tx.Rollback(ctx)
 
-	// Delete chat room members first
-	_, err = tx.Exec(ctx, "DELETE FROM chat_room_members WHERE chat_room_id = $1", id)
+	if result.RowsAffected() == 0 {
+		return chat.ErrChatRoomNotFound
+	}
+
+	r.logger.Info("Chat room deleted successfully", "room_id", id)
+	return nil
+}
+
+func (r *chatRepository) GetDeletedByID(ctx context.Context, id string) (*chat.ChatRoom, error) {
+	query := `
+		SELECT cr.id, cr.name, cr.description, cr.is_private, cr.created_by,
+			(SELECT COUNT(*) FROM chat_room_members crm WHERE crm.chat_room_id = cr.id),
+			cr.created_at, cr.updated_at, cr.announcement_only, cr.slow_mode_seconds, cr.deleted_at
+		FROM chat_rooms cr
+		WHERE cr.id = $1 AND cr.deleted_at IS NOT NULL
+	`
+
+	var chatRoom chat.ChatRoom
+	var description *string
+
+	err := r.db.QueryRow(ctx, query, id).Scan(
+		&chatRoom.ID,
+		&chatRoom.Name,
+		&description,
+		&chatRoom.IsPrivate,
+		&chatRoom.CreatedBy,
+		&chatRoom.TotalMembers,
+		&chatRoom.CreatedAt,
+		&chatRoom.UpdatedAt,
+		&chatRoom.AnnouncementOnly,
+		&chatRoom.SlowModeSeconds,
+		&chatRoom.DeletedAt,
+	)
+
 	if err != nil {
-		r.logger.Error("Failed to delete chat room members", "error", err, "room_id", id)
-		return fmt.Errorf("failed to delete chat room members: %w", err)
+		if err == pgx.ErrNoRows {
+			return nil, chat.ErrChatRoomNotFound
+		}
+		r.logger.Error("Failed to get deleted chat room by ID", "error", err, "room_id", id)
+		return nil, fmt.Errorf("failed to get deleted chat room by ID: %w", err)
 	}
 
-	// Delete chat room
-	result, err := tx.Exec(ctx, "DELETE FROM chat_rooms WHERE id = $1", id)
+	if description != nil {
+		chatRoom.Description = *description
+	}
+
+	return &chatRoom, nil
+}
+
+func (r *chatRepository) Restore(ctx context.Context, id string) error {
+	query := `UPDATE chat_rooms SET deleted_at = NULL, updated_at = NOW() WHERE id = $1 AND deleted_at IS NOT NULL`
+
+	result, err := r.db.Exec(ctx, query, id)
 	if err != nil {
-		r.logger.Error("Failed to delete chat room", "error", err, "room_id", id)
-		return fmt.Errorf("failed to delete chat room: %w", err)
+		r.logger.Error("Failed to restore chat room", "error", err, "room_id", id)
+		return fmt.Errorf("failed to restore chat room: %w", err)
 	}
 
 	if result.RowsAffected() == 0 {
 		return chat.ErrChatRoomNotFound
 	}
 
-	if err = tx.Commit(ctx); err != nil {
-		r.logger.Error("Failed to commit transaction", "error", err, "room_id", id)
-		return fmt.Errorf("failed to commit transaction: %w", err)
+	r.logger.Info("Chat room restored successfully", "room_id", id)
+	return nil
+}
+
+// PurgeDeleted permanently removes chat rooms soft-deleted before the given time.
+// Members, bans and messages are removed by their ON DELETE CASCADE constraints.
+func (r *chatRepository) PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	query := `DELETE FROM chat_rooms WHERE deleted_at IS NOT NULL AND deleted_at < $1`
+
+	result, err := r.db.Exec(ctx, query, deletedBefore)
+	if err != nil {
+		r.logger.Error("Failed to purge deleted chat rooms", "error", err)
+		return 0, fmt.Errorf("failed to purge deleted chat rooms: %w", err)
 	}
 
-	r.logger.Info("Chat room deleted successfully", "room_id", id)
-	return nil
+	return result.RowsAffected(), nil
 }
 
 func (r *chatRepository) AddMember(ctx context.Context, roomID, userID string) error {
@@ -286,7 +349,13 @@ func (r *chatRepository) RemoveMember(ctx context.Context, roomID, userID string
 }
 
 func (r *chatRepository) IsMember(ctx context.Context, roomID, userID string) (bool, error) {
-	query := `SELECT EXISTS(SELECT 1 FROM chat_room_members WHERE chat_room_id = $1 AND user_id = $2)`
+	query := `
+		SELECT EXISTS(
+			SELECT 1 FROM chat_room_members crm
+			JOIN chat_rooms cr ON cr.id = crm.chat_room_id
+			WHERE crm.chat_room_id = $1 AND crm.user_id = $2 AND cr.deleted_at IS NULL
+		)
+	`
 
 	var exists bool
 	err := r.db.QueryRow(ctx, query, roomID, userID).Scan(&exists)
@@ -298,54 +367,369 @@ func (r *chatRepository) IsMember(ctx context.Context, roomID, userID string) (b
 	return exists, nil
 }
 
-func (r *chatRepository) getChatRoomMembers(ctx context.Context, roomID string) ([]string, error) {
+func (r *chatRepository) GetMemberRole(ctx context.Context, roomID, userID string) (string, error) {
+	query := `
+		SELECT crm.role FROM chat_room_members crm
+		JOIN chat_rooms cr ON cr.id = crm.chat_room_id
+		WHERE crm.chat_room_id = $1 AND crm.user_id = $2 AND cr.deleted_at IS NULL
+	`
+
+	var role string
+	err := r.db.QueryRow(ctx, query, roomID, userID).Scan(&role)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return "", chat.ErrMemberNotFound
+		}
+		r.logger.Error("Failed to get member role", "error", err, "room_id", roomID, "user_id", userID)
+		return "", fmt.Errorf("failed to get member role: %w", err)
+	}
+
+	return role, nil
+}
+
+func (r *chatRepository) UpdateMemberRole(ctx context.Context, roomID, userID, role string) error {
+	query := `UPDATE chat_room_members SET role = $3 WHERE chat_room_id = $1 AND user_id = $2`
+
+	result, err := r.db.Exec(ctx, query, roomID, userID, role)
+	if err != nil {
+		r.logger.Error("Failed to update member role", "error", err, "room_id", roomID, "user_id", userID)
+		return fmt.Errorf("failed to update member role: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return chat.ErrMemberNotFound
+	}
+
+	r.logger.Info("Member role updated successfully", "room_id", roomID, "user_id", userID, "role", role)
+	return nil
+}
+
+func (r *chatRepository) BanMember(ctx context.Context, ban *chat.Ban) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", "error", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Record the ban, replacing any previous ban for the same user
+	query := `
+		INSERT INTO chat_room_bans (chat_room_id, user_id, banned_by, reason, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (chat_room_id, user_id)
+		DO UPDATE SET banned_by = EXCLUDED.banned_by, reason = EXCLUDED.reason,
+			expires_at = EXCLUDED.expires_at, created_at = EXCLUDED.created_at
+	`
+
+	_, err = tx.Exec(ctx, query,
+		ban.ChatRoomID,
+		ban.UserID,
+		ban.BannedBy,
+		ban.Reason,
+		ban.ExpiresAt,
+		ban.CreatedAt,
+	)
+	if err != nil {
+		r.logger.Error("Failed to ban member", "error", err, "room_id", ban.ChatRoomID, "user_id", ban.UserID)
+		return fmt.Errorf("failed to ban member: %w", err)
+	}
+
+	// Banned users lose their membership
+	_, err = tx.Exec(ctx, "DELETE FROM chat_room_members WHERE chat_room_id = $1 AND user_id = $2", ban.ChatRoomID, ban.UserID)
+	if err != nil {
+		r.logger.Error("Failed to remove banned member", "error", err, "room_id", ban.ChatRoomID, "user_id", ban.UserID)
+		return fmt.Errorf("failed to remove banned member: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		r.logger.Error("Failed to commit transaction", "error", err, "room_id", ban.ChatRoomID)
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	r.logger.Info("Member banned successfully", "room_id", ban.ChatRoomID, "user_id", ban.UserID)
+	return nil
+}
+
+func (r *chatRepository) GetBan(ctx context.Context, roomID, userID string) (*chat.Ban, error) {
+	query := `
+		SELECT chat_room_id, user_id, banned_by, reason, expires_at, created_at
+		FROM chat_room_bans
+		WHERE chat_room_id = $1 AND user_id = $2
+	`
+
+	var ban chat.Ban
+	var reason *string
+
+	err := r.db.QueryRow(ctx, query, roomID, userID).Scan(
+		&ban.ChatRoomID,
+		&ban.UserID,
+		&ban.BannedBy,
+		&reason,
+		&ban.ExpiresAt,
+		&ban.CreatedAt,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, chat.ErrBanNotFound
+		}
+		r.logger.Error("Failed to get ban", "error", err, "room_id", roomID, "user_id", userID)
+		return nil, fmt.Errorf("failed to get ban: %w", err)
+	}
+
+	if reason != nil {
+		ban.Reason = *reason
+	}
+
+	return &ban, nil
+}
+
+func (r *chatRepository) RemoveBan(ctx context.Context, roomID, userID string) error {
+	query := `DELETE FROM chat_room_bans WHERE chat_room_id = $1 AND user_id = $2`
+
+	result, err := r.db.Exec(ctx, query, roomID, userID)
+	if err != nil {
+		r.logger.Error("Failed to remove ban", "error", err, "room_id", roomID, "user_id", userID)
+		return fmt.Errorf("failed to remove ban: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return chat.ErrBanNotFound
+	}
+
+	r.logger.Info("Ban removed successfully", "room_id", roomID, "user_id", userID)
+	return nil
+}
+
+func (r *chatRepository) GetMemberSettings(ctx context.Context, roomID, userID string) (*chat.MemberSettings, error) {
+	query := `
+		SELECT crm.chat_room_id, crm.user_id, u.username, crm.muted_until, crm.pinned, crm.archived, crm.notification_level
+		FROM chat_room_members crm
+		JOIN users u ON u.id = crm.user_id
+		WHERE crm.chat_room_id = $1 AND crm.user_id = $2
+	`
+
+	var settings chat.MemberSettings
+	err := r.db.QueryRow(ctx, query, roomID, userID).Scan(
+		&settings.ChatRoomID,
+		&settings.UserID,
+		&settings.Username,
+		&settings.MutedUntil,
+		&settings.Pinned,
+		&settings.Archived,
+		&settings.NotificationLevel,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, chat.ErrMemberNotFound
+		}
+		r.logger.Error("Failed to get member settings", "error", err, "room_id", roomID, "user_id", userID)
+		return nil, fmt.Errorf("failed to get member settings: %w", err)
+	}
+
+	return &settings, nil
+}
+
+func (r *chatRepository) UpdateMemberSettings(ctx context.Context, settings *chat.MemberSettings) error {
+	query := `
+		UPDATE chat_room_members
+		SET muted_until = $3, pinned = $4, archived = $5, notification_level = $6
+		WHERE chat_room_id = $1 AND user_id = $2
+	`
+
+	result, err := r.db.Exec(ctx, query,
+		settings.ChatRoomID,
+		settings.UserID,
+		settings.MutedUntil,
+		settings.Pinned,
+		settings.Archived,
+		settings.NotificationLevel,
+	)
+
+	if err != nil {
+		r.logger.Error("Failed to update member settings", "error", err, "room_id", settings.ChatRoomID, "user_id", settings.UserID)
+		return fmt.Errorf("failed to update member settings: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return chat.ErrMemberNotFound
+	}
+
+	r.logger.Info("Member settings updated successfully", "room_id", settings.ChatRoomID, "user_id", settings.UserID)
+	return nil
+}
+
+func (r *chatRepository) ClaimSendSlot(ctx context.Context, roomID, userID string, interval time.Duration) (bool, time.Time, error) {
+	// Concurrent updates of the row wait for each other and re-check the condition
+	query := `
+		UPDATE chat_room_members
+		SET last_sent_at = NOW()
+		WHERE chat_room_id = $1 AND user_id = $2
+		  AND (last_sent_at IS NULL OR last_sent_at <= NOW() - $3 * INTERVAL '1 second')
+	`
+
+	result, err := r.db.Exec(ctx, query, roomID, userID, interval.Seconds())
+	if err != nil {
+		r.logger.Error("Failed to claim send slot", "error", err, "room_id", roomID, "user_id", userID)
+		return false, time.Time{}, fmt.Errorf("failed to claim send slot: %w", err)
+	}
+	if result.RowsAffected() > 0 {
+		return true, time.Time{}, nil
+	}
+
+	var lastSentAt *time.Time
+	query = `SELECT last_sent_at FROM chat_room_members WHERE chat_room_id = $1 AND user_id = $2`
+	if err := r.db.QueryRow(ctx, query, roomID, userID).Scan(&lastSentAt); err != nil {
+		if err == pgx.ErrNoRows {
+			return false, time.Time{}, chat.ErrMemberNotFound
+		}
+		r.logger.Error("Failed to get last message time", "error", err, "room_id", roomID, "user_id", userID)
+		return false, time.Time{}, fmt.Errorf("failed to get last message time: %w", err)
+	}
+	if lastSentAt == nil {
+		return false, time.Time{}, nil
+	}
+	return false, *lastSentAt, nil
+}
+
+func (r *chatRepository) ListNotificationRecipients(ctx context.Context, roomID, senderID string, mentions []string) ([]*chat.MemberSettings, error) {
+	// Only members who will be notified are loaded, not the whole room
 	query := `
-		SELECT user_id
-		FROM chat_room_members
-		WHERE chat_room_id = $1
-		ORDER BY joined_at ASC
+		SELECT crm.chat_room_id, crm.user_id, u.username, crm.muted_until, crm.pinned, crm.archived, crm.notification_level
+		FROM chat_room_members crm
+		JOIN users u ON u.id = crm.user_id
+		WHERE crm.chat_room_id = $1 AND crm.user_id <> $2
+		  AND (crm.muted_until IS NULL OR crm.muted_until <= NOW())
+		  AND (crm.notification_level = $3 OR (crm.notification_level = $4 AND LOWER(u.username) = ANY($5)))
 	`
 
-	rows, err := r.db.Query(ctx, query, roomID)
+	if mentions == nil {
+		mentions = []string{}
+	}
+	rows, err := r.db.Query(ctx, query, roomID, senderID, chat.NotifyAll, chat.NotifyMentions, mentions)
+	if err != nil {
+		r.logger.Error("Failed to list member settings", "error", err, "room_id", roomID)
+		return nil, fmt.Errorf("failed to list member settings: %w", err)
+	}
+	defer rows.Close()
+
+	var settingsList []*chat.MemberSettings
+	for rows.Next() {
+		var settings chat.MemberSettings
+		err := rows.Scan(
+			&settings.ChatRoomID,
+			&settings.UserID,
+			&settings.Username,
+			&settings.MutedUntil,
+			&settings.Pinned,
+			&settings.Archived,
+			&settings.NotificationLevel,
+		)
+
+		if err != nil {
+			r.logger.Error("Failed to scan member settings", "error", err, "room_id", roomID)
+			return nil, fmt.Errorf("failed to scan member settings: %w", err)
+		}
+
+		settingsList = append(settingsList, &settings)
+	}
+
+	if err = rows.Err(); err != nil {
+		r.logger.Error("Failed to iterate member settings", "error", err, "room_id", roomID)
+		return nil, fmt.Errorf("failed to iterate member settings: %w", err)
+	}
+
+	return settingsList, nil
+}
+
+func (r *chatRepository) ListMembers(ctx context.Context, roomID string, after *chat.MemberCursor, limit int) ([]*chat.Member, bool, error) {
+	// Members are ordered by join time; the cursor holds the join time and user ID of the last member on the previous page
+	var query string
+	var args []interface{}
+
+	if after != nil {
+		query = `
+			SELECT crm.user_id, u.username, crm.role, crm.joined_at, u.last_seen_at
+			FROM chat_room_members crm
+			JOIN users u ON u.id = crm.user_id
+			WHERE crm.chat_room_id = $1 AND (crm.joined_at, crm.user_id) > ($2, $3)
+			ORDER BY crm.joined_at ASC, crm.user_id ASC
+			LIMIT $4
+		`
+		args = []interface{}{roomID, after.JoinedAt, after.UserID, limit + 1} // +1 to check if there are more
+	} else {
+		query = `
+			SELECT crm.user_id, u.username, crm.role, crm.joined_at, u.last_seen_at
+			FROM chat_room_members crm
+			JOIN users u ON u.id = crm.user_id
+			WHERE crm.chat_room_id = $1
+			ORDER BY crm.joined_at ASC, crm.user_id ASC
+			LIMIT $2
+		`
+		args = []interface{}{roomID, limit + 1} // +1 to check if there are more
+	}
+
+	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
-		r.logger.Error("Failed to get chat room members", "error", err, "room_id", roomID)
-		return nil, fmt.Errorf("failed to get chat room members: %w", err)
+		r.logger.Error("Failed to list chat room members", "error", err, "room_id", roomID)
+		return nil, false, fmt.Errorf("failed to list chat room members: %w", err)
 	}
 	defer rows.Close()
 
-	var members []string
+	var members []*chat.Member
 	for rows.Next() {
-		var userID string
-		if err := rows.Scan(&userID); err != nil {
+		var member chat.Member
+		err := rows.Scan(
+			&member.UserID,
+			&member.Username,
+			&member.Role,
+			&member.JoinedAt,
+			&member.LastSeenAt,
+		)
+
+		if err != nil {
 			r.logger.Error("Failed to scan member", "error", err, "room_id", roomID)
-			return nil, fmt.Errorf("failed to scan member: %w", err)
+			return nil, false, fmt.Errorf("failed to scan member: %w", err)
 		}
-		members = append(members, userID)
+
+		members = append(members, &member)
 	}
 
 	if err = rows.Err(); err != nil {
 		r.logger.Error("Failed to iterate members", "error", err, "room_id", roomID)
-		return nil, fmt.Errorf("failed to iterate members: %w", err)
+		return nil, false, fmt.Errorf("failed to iterate members: %w", err)
 	}
 
-	return members, nil
+	// Check if there are more members
+	hasMore := len(members) > limit
+	if hasMore {
+		members = members[:limit] // Remove the extra member
+	}
+
+	return members, hasMore, nil
 }
 
-func (r *chatRepository) addMembersToRoom(ctx context.Context, tx pgx.Tx, roomID string, members []string) error {
+func (r *chatRepository) addMembersToRoom(ctx context.Context, tx pgx.Tx, roomID, createdBy string, members []string) error {
 	if len(members) == 0 {
 		return nil
 	}
 
 	// Build bulk insert query
 	query := `
-		INSERT INTO chat_room_members (chat_room_id, user_id, joined_at)
-		VALUES ($1, $2, $3)
+		INSERT INTO chat_room_members (chat_room_id, user_id, role, joined_at)
+		VALUES ($1, $2, $3, $4)
 		ON CONFLICT (chat_room_id, user_id) DO NOTHING
 	`
 
 	now := time.Now()
 	for _, member := range members {
-		_, err := tx.Exec(ctx, query, roomID, member, now)
+		role := chat.RoleMember
+		if member == createdBy {
+			role = chat.RoleOwner
+		}
+
+		_, err := tx.Exec(ctx, query, roomID, member, role, now)
 		if err != nil {
 			r.logger.Error("Failed to add member to chat room", "error", err, "room_id", roomID, "member", member)
 			return fmt.Errorf("failed to add member to chat room: %w", err)
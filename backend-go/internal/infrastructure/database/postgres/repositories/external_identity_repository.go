@@ -0,0 +1,94 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"backend-go/internal/domain/user"
+	"backend-go/internal/shared/logger"
+)
+
+type externalIdentityRepository struct {
+	db     *pgxpool.Pool
+	logger logger.Logger
+}
+
+func NewExternalIdentityRepository(db *pgxpool.Pool, logger logger.Logger) user.ExternalIdentityRepository {
+	return &externalIdentityRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *externalIdentityRepository) Create(ctx context.Context, identity *user.ExternalIdentity) error {
+	query := `
+		INSERT INTO user_identities (id, user_id, issuer, subject, email, created_at, last_login_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		identity.ID,
+		identity.UserID,
+		identity.Issuer,
+		identity.Subject,
+		identity.Email,
+		identity.CreatedAt,
+		identity.LastLoginAt,
+	)
+
+	if err != nil {
+		r.logger.Error("Failed to create external identity", "error", err, "user_id", identity.UserID)
+		return fmt.Errorf("failed to create external identity: %w", err)
+	}
+
+	return nil
+}
+
+func (r *externalIdentityRepository) GetBySubject(ctx context.Context, issuer, subject string) (*user.ExternalIdentity, error) {
+	query := `
+		SELECT id, user_id, issuer, subject, email, created_at, last_login_at
+		FROM user_identities
+		WHERE issuer = $1 AND subject = $2
+	`
+
+	var identity user.ExternalIdentity
+	var email *string
+
+	err := r.db.QueryRow(ctx, query, issuer, subject).Scan(
+		&identity.ID,
+		&identity.UserID,
+		&identity.Issuer,
+		&identity.Subject,
+		&email,
+		&identity.CreatedAt,
+		&identity.LastLoginAt,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, user.ErrExternalIdentityNotFound
+		}
+		r.logger.Error("Failed to get external identity", "error", err, "issuer", issuer)
+		return nil, fmt.Errorf("failed to get external identity: %w", err)
+	}
+
+	if email != nil {
+		identity.Email = *email
+	}
+
+	return &identity, nil
+}
+
+func (r *externalIdentityRepository) Touch(ctx context.Context, id, email string) error {
+	query := `UPDATE user_identities SET email = $2, last_login_at = NOW() WHERE id = $1`
+
+	_, err := r.db.Exec(ctx, query, id, email)
+	if err != nil {
+		r.logger.Error("Failed to update external identity", "error", err, "identity_id", id)
+		return fmt.Errorf("failed to update external identity: %w", err)
+	}
+
+	return nil
+}
\ No newline at end of file
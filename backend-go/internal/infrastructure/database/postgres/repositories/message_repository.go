@@ -25,8 +25,8 @@ func NewMessageRepository(db *pgxpool.Pool, logger logger.Logger) message.Reposi
 
 func (r *messageRepository) Create(ctx context.Context, msg *message.Message) error {
 	query := `
-		INSERT INTO messages (id, chat_room_id, sender_id, content, type, status, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		INSERT INTO messages (id, chat_room_id, sender_id, content, type, status, sent_by_bot, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 	`
 
 	_, err := r.db.Exec(ctx, query,
@@ -36,6 +36,7 @@ func (r *messageRepository) Create(ctx context.Context, msg *message.Message) er
 		msg.Content,
 		msg.Type,
 		msg.Status,
+		msg.SentByBot,
 		msg.CreatedAt,
 		msg.UpdatedAt,
 	)
@@ -51,7 +52,7 @@ func (r *messageRepository) Create(ctx context.Context, msg *message.Message) er
 
 func (r *messageRepository) GetByID(ctx context.Context, id string) (*message.Message, error) {
 	query := `
-		SELECT id, chat_room_id, sender_id, content, type, status, created_at, updated_at
+		SELECT id, chat_room_id, sender_id, content, type, status, sent_by_bot, created_at, updated_at
 		FROM messages
 		WHERE id = $1
 	`
@@ -64,6 +65,7 @@ func (r *messageRepository) GetByID(ctx context.Context, id string) (*message.Me
 		&msg.Content,
 		&msg.Type,
 		&msg.Status,
+		&msg.SentByBot,
 		&msg.CreatedAt,
 		&msg.UpdatedAt,
 	)
@@ -91,7 +93,7 @@ func (r *messageRepository) GetByChatRoom(ctx context.Context, chatRoomID string
 
 	// Get messages (ordered by created_at DESC for latest first)
 	query := `
-		SELECT id, chat_room_id, sender_id, content, type, status, created_at, updated_at
+		SELECT id, chat_room_id, sender_id, content, type, status, sent_by_bot, created_at, updated_at
 		FROM messages
 		WHERE chat_room_id = $1
 		ORDER BY created_at DESC
@@ -115,6 +117,7 @@ func (r *messageRepository) GetByChatRoom(ctx context.Context, chatRoomID string
 			&msg.Content,
 			&msg.Type,
 			&msg.Status,
+			&msg.SentByBot,
 			&msg.CreatedAt,
 			&msg.UpdatedAt,
 		)
@@ -156,7 +159,7 @@ func (r *messageRepository) GetByChatRoomWithCursor(ctx context.Context, chatRoo
 
 	if before != "" {
 		query = `
-			SELECT id, chat_room_id, sender_id, content, type, status, created_at, updated_at
+			SELECT id, chat_room_id, sender_id, content, type, status, sent_by_bot, created_at, updated_at
 			FROM messages
 			WHERE chat_room_id = $1 AND created_at < $2
 			ORDER BY created_at DESC
@@ -165,7 +168,7 @@ func (r *messageRepository) GetByChatRoomWithCursor(ctx context.Context, chatRoo
 		args = []interface{}{chatRoomID, beforeTime, limit + 1} // +1 to check if there are more
 	} else {
 		query = `
-			SELECT id, chat_room_id, sender_id, content, type, status, created_at, updated_at
+			SELECT id, chat_room_id, sender_id, content, type, status, sent_by_bot, created_at, updated_at
 			FROM messages
 			WHERE chat_room_id = $1
 			ORDER BY created_at DESC
@@ -191,6 +194,7 @@ func (r *messageRepository) GetByChatRoomWithCursor(ctx context.Context, chatRoo
 			&msg.Content,
 			&msg.Type,
 			&msg.Status,
+			&msg.SentByBot,
 			&msg.CreatedAt,
 			&msg.UpdatedAt,
 		)
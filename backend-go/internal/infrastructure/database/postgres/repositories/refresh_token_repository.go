@@ -0,0 +1,154 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"backend-go/internal/domain/user"
+	"backend-go/internal/shared/logger"
+)
+
+type refreshTokenRepository struct {
+	db     *pgxpool.Pool
+	logger logger.Logger
+}
+
+func NewRefreshTokenRepository(db *pgxpool.Pool, logger logger.Logger) user.RefreshTokenRepository {
+	return &refreshTokenRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *refreshTokenRepository) Create(ctx context.Context, token *user.RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (id, user_id, family_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		token.ID,
+		token.UserID,
+		token.FamilyID,
+		token.TokenHash,
+		token.ExpiresAt,
+		token.CreatedAt,
+	)
+
+	if err != nil {
+		r.logger.Error("Failed to create refresh token", "error", err, "user_id", token.UserID)
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	return nil
+}
+
+func (r *refreshTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*user.RefreshToken, error) {
+	query := `
+		SELECT id, user_id, family_id, token_hash, expires_at, created_at, revoked_at, replaced_by
+		FROM refresh_tokens
+		WHERE token_hash = $1
+	`
+
+	var token user.RefreshToken
+	var replacedBy *string
+
+	err := r.db.QueryRow(ctx, query, tokenHash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.FamilyID,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.CreatedAt,
+		&token.RevokedAt,
+		&replacedBy,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, user.ErrRefreshTokenNotFound
+		}
+		r.logger.Error("Failed to get refresh token", "error", err)
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	if replacedBy != nil {
+		token.ReplacedBy = *replacedBy
+	}
+
+	return &token, nil
+}
+
+func (r *refreshTokenRepository) Rotate(ctx context.Context, oldID string, newToken *user.RefreshToken) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", "error", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Only one request can win the rotation of a given token
+	result, err := tx.Exec(ctx,
+		"UPDATE refresh_tokens SET revoked_at = NOW(), replaced_by = $2 WHERE id = $1 AND revoked_at IS NULL",
+		oldID, newToken.ID,
+	)
+	if err != nil {
+		r.logger.Error("Failed to revoke refresh token", "error", err, "token_id", oldID)
+		return fmt.Errorf("failed to revoke refresh token: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return user.ErrRefreshTokenReused
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO refresh_tokens (id, user_id, family_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`,
+		newToken.ID,
+		newToken.UserID,
+		newToken.FamilyID,
+		newToken.TokenHash,
+		newToken.ExpiresAt,
+		newToken.CreatedAt,
+	)
+	if err != nil {
+		r.logger.Error("Failed to create refresh token", "error", err, "user_id", newToken.UserID)
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		r.logger.Error("Failed to commit transaction", "error", err, "token_id", oldID)
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (r *refreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL`
+
+	_, err := r.db.Exec(ctx, query, familyID)
+	if err != nil {
+		r.logger.Error("Failed to revoke refresh token family", "error", err, "family_id", familyID)
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+
+	r.logger.Info("Refresh token family revoked", "family_id", familyID)
+	return nil
+}
+
+func (r *refreshTokenRepository) RevokeAllForUser(ctx context.Context, userID string) error {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`
+
+	_, err := r.db.Exec(ctx, query, userID)
+	if err != nil {
+		r.logger.Error("Failed to revoke refresh tokens", "error", err, "user_id", userID)
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	r.logger.Info("Refresh tokens revoked", "user_id", userID)
+	return nil
+}
\ No newline at end of file
@@ -0,0 +1,181 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"backend-go/internal/domain/user"
+	"backend-go/internal/shared/logger"
+)
+
+type sessionRepository struct {
+	db     *pgxpool.Pool
+	logger logger.Logger
+}
+
+func NewSessionRepository(db *pgxpool.Pool, logger logger.Logger) user.SessionRepository {
+	return &sessionRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *sessionRepository) Create(ctx context.Context, session *user.Session) error {
+	query := `
+		INSERT INTO sessions (id, user_id, device_name, user_agent, ip_address, created_at, last_used_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		session.ID,
+		session.UserID,
+		session.DeviceName,
+		session.UserAgent,
+		session.IPAddress,
+		session.CreatedAt,
+		session.LastUsedAt,
+		session.ExpiresAt,
+	)
+
+	if err != nil {
+		r.logger.Error("Failed to create session", "error", err, "user_id", session.UserID)
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return nil
+}
+
+func (r *sessionRepository) GetByID(ctx context.Context, id string) (*user.Session, error) {
+	query := `
+		SELECT id, user_id, device_name, COALESCE(user_agent, ''), COALESCE(ip_address, ''),
+		       created_at, last_used_at, expires_at, revoked_at
+		FROM sessions
+		WHERE id = $1
+	`
+
+	session, err := scanSession(r.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, user.ErrSessionNotFound
+		}
+		r.logger.Error("Failed to get session", "error", err, "session_id", id)
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	return session, nil
+}
+
+func (r *sessionRepository) ListActiveByUser(ctx context.Context, userID string) ([]*user.Session, error) {
+	query := `
+		SELECT id, user_id, device_name, COALESCE(user_agent, ''), COALESCE(ip_address, ''),
+		       created_at, last_used_at, expires_at, revoked_at
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY last_used_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		r.logger.Error("Failed to list sessions", "error", err, "user_id", userID)
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []*user.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			r.logger.Error("Failed to scan session", "error", err)
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+
+	if err = rows.Err(); err != nil {
+		r.logger.Error("Error iterating sessions", "error", err)
+		return nil, fmt.Errorf("error iterating sessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
+func (r *sessionRepository) Touch(ctx context.Context, id string, expiresAt time.Time) error {
+	query := `UPDATE sessions SET last_used_at = NOW(), expires_at = $2 WHERE id = $1 AND revoked_at IS NULL`
+
+	_, err := r.db.Exec(ctx, query, id, expiresAt)
+	if err != nil {
+		r.logger.Error("Failed to touch session", "error", err, "session_id", id)
+		return fmt.Errorf("failed to touch session: %w", err)
+	}
+
+	return nil
+}
+
+func (r *sessionRepository) Revoke(ctx context.Context, id string) error {
+	query := `UPDATE sessions SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL`
+
+	_, err := r.db.Exec(ctx, query, id)
+	if err != nil {
+		r.logger.Error("Failed to revoke session", "error", err, "session_id", id)
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	r.logger.Info("Session revoked", "session_id", id)
+	return nil
+}
+
+func (r *sessionRepository) RevokeAllExcept(ctx context.Context, userID, keepID string) ([]string, error) {
+	query := `
+		UPDATE sessions SET revoked_at = NOW()
+		WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL
+		RETURNING id
+	`
+
+	rows, err := r.db.Query(ctx, query, userID, keepID)
+	if err != nil {
+		r.logger.Error("Failed to revoke sessions", "error", err, "user_id", userID)
+		return nil, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			r.logger.Error("Failed to scan session ID", "error", err)
+			return nil, fmt.Errorf("failed to scan session ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		r.logger.Error("Error iterating revoked sessions", "error", err)
+		return nil, fmt.Errorf("error iterating revoked sessions: %w", err)
+	}
+
+	r.logger.Info("Sessions revoked", "user_id", userID, "count", len(ids))
+	return ids, nil
+}
+
+// scanSession scans a single session row
+func scanSession(row pgx.Row) (*user.Session, error) {
+	var session user.Session
+	err := row.Scan(
+		&session.ID,
+		&session.UserID,
+		&session.DeviceName,
+		&session.UserAgent,
+		&session.IPAddress,
+		&session.CreatedAt,
+		&session.LastUsedAt,
+		&session.ExpiresAt,
+		&session.RevokedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
\ No newline at end of file
@@ -0,0 +1,94 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"backend-go/internal/shared/jwt"
+	"backend-go/internal/shared/logger"
+)
+
+type signingKeyRepository struct {
+	db     *pgxpool.Pool
+	logger logger.Logger
+}
+
+// NewSigningKeyRepository creates a key store that shares JWT signing keys between replicas
+func NewSigningKeyRepository(db *pgxpool.Pool, logger logger.Logger) jwt.KeyStore {
+	return &signingKeyRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *signingKeyRepository) List(ctx context.Context) ([]*jwt.SigningKey, error) {
+	query := `
+		SELECT id, algorithm, private_key, active_from, created_at
+		FROM jwt_signing_keys
+		ORDER BY active_from
+	`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		r.logger.Error("Failed to list signing keys", "error", err)
+		return nil, fmt.Errorf("failed to list signing keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*jwt.SigningKey
+	for rows.Next() {
+		var key jwt.SigningKey
+		var privateKey string
+
+		if err := rows.Scan(&key.ID, &key.Algorithm, &privateKey, &key.ActiveFrom, &key.CreatedAt); err != nil {
+			r.logger.Error("Failed to scan signing key", "error", err)
+			return nil, fmt.Errorf("failed to scan signing key: %w", err)
+		}
+
+		key.PrivateKey, err = jwt.ParsePrivateKey([]byte(privateKey))
+		if err != nil {
+			r.logger.Error("Failed to parse signing key", "error", err, "kid", key.ID)
+			return nil, fmt.Errorf("failed to parse signing key %s: %w", key.ID, err)
+		}
+
+		keys = append(keys, &key)
+	}
+
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Failed to iterate signing keys", "error", err)
+		return nil, fmt.Errorf("failed to iterate signing keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+func (r *signingKeyRepository) Add(ctx context.Context, key *jwt.SigningKey) error {
+	privateKey, err := jwt.MarshalPrivateKey(key.PrivateKey)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO jwt_signing_keys (id, algorithm, private_key, active_from, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	_, err = r.db.Exec(ctx, query, key.ID, key.Algorithm, string(privateKey), key.ActiveFrom, key.CreatedAt)
+	if err != nil {
+		r.logger.Error("Failed to create signing key", "error", err, "kid", key.ID)
+		return fmt.Errorf("failed to create signing key: %w", err)
+	}
+
+	return nil
+}
+
+func (r *signingKeyRepository) Delete(ctx context.Context, id string) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM jwt_signing_keys WHERE id = $1`, id)
+	if err != nil {
+		r.logger.Error("Failed to delete signing key", "error", err, "kid", id)
+		return fmt.Errorf("failed to delete signing key: %w", err)
+	}
+
+	return nil
+}
\ No newline at end of file
@@ -0,0 +1,172 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"backend-go/internal/domain/user"
+	"backend-go/internal/shared/logger"
+)
+
+type twoFactorRepository struct {
+	db     *pgxpool.Pool
+	logger logger.Logger
+}
+
+func NewTwoFactorRepository(db *pgxpool.Pool, logger logger.Logger) user.TwoFactorRepository {
+	return &twoFactorRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *twoFactorRepository) Get(ctx context.Context, userID string) (*user.TwoFactor, error) {
+	query := `
+		SELECT user_id, secret, confirmed_at, last_used_step, created_at
+		FROM user_two_factor
+		WHERE user_id = $1
+	`
+
+	var twoFactor user.TwoFactor
+	err := r.db.QueryRow(ctx, query, userID).Scan(
+		&twoFactor.UserID,
+		&twoFactor.Secret,
+		&twoFactor.ConfirmedAt,
+		&twoFactor.LastUsedStep,
+		&twoFactor.CreatedAt,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, user.ErrTwoFactorNotFound
+		}
+		r.logger.Error("Failed to get two-factor enrolment", "error", err, "user_id", userID)
+		return nil, fmt.Errorf("failed to get two-factor enrolment: %w", err)
+	}
+
+	return &twoFactor, nil
+}
+
+func (r *twoFactorRepository) Save(ctx context.Context, twoFactor *user.TwoFactor) error {
+	// A confirmed enrolment is never overwritten here; it has to be deleted first
+	query := `
+		INSERT INTO user_two_factor (user_id, secret, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, created_at = EXCLUDED.created_at, last_used_step = 0
+		WHERE user_two_factor.confirmed_at IS NULL
+	`
+
+	_, err := r.db.Exec(ctx, query, twoFactor.UserID, twoFactor.Secret, twoFactor.CreatedAt)
+	if err != nil {
+		r.logger.Error("Failed to save two-factor enrolment", "error", err, "user_id", twoFactor.UserID)
+		return fmt.Errorf("failed to save two-factor enrolment: %w", err)
+	}
+
+	return nil
+}
+
+func (r *twoFactorRepository) Enable(ctx context.Context, userID string, recoveryCodeHashes []string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", "error", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, `UPDATE user_two_factor SET confirmed_at = NOW() WHERE user_id = $1`, userID)
+	if err != nil {
+		r.logger.Error("Failed to confirm two-factor enrolment", "error", err, "user_id", userID)
+		return fmt.Errorf("failed to confirm two-factor enrolment: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return user.ErrTwoFactorNotFound
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM two_factor_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		r.logger.Error("Failed to delete recovery codes", "error", err, "user_id", userID)
+		return fmt.Errorf("failed to replace recovery codes: %w", err)
+	}
+
+	for _, codeHash := range recoveryCodeHashes {
+		_, err := tx.Exec(ctx,
+			`INSERT INTO two_factor_recovery_codes (id, user_id, code_hash) VALUES ($1, $2, $3)`,
+			uuid.New().String(), userID, codeHash,
+		)
+		if err != nil {
+			r.logger.Error("Failed to store recovery code", "error", err, "user_id", userID)
+			return fmt.Errorf("failed to replace recovery codes: %w", err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		r.logger.Error("Failed to commit transaction", "error", err, "user_id", userID)
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	r.logger.Info("Two-factor authentication enabled", "user_id", userID)
+	return nil
+}
+
+func (r *twoFactorRepository) UseStep(ctx context.Context, userID string, step int64) (bool, error) {
+	// The conditional update makes concurrent uses of the same code race safely
+	query := `UPDATE user_two_factor SET last_used_step = $2 WHERE user_id = $1 AND last_used_step < $2`
+
+	result, err := r.db.Exec(ctx, query, userID, step)
+	if err != nil {
+		r.logger.Error("Failed to record TOTP step", "error", err, "user_id", userID)
+		return false, fmt.Errorf("failed to record TOTP step: %w", err)
+	}
+
+	return result.RowsAffected() > 0, nil
+}
+
+func (r *twoFactorRepository) UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error) {
+	query := `
+		UPDATE two_factor_recovery_codes SET used_at = NOW()
+		WHERE id = (
+			SELECT id FROM two_factor_recovery_codes
+			WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+			LIMIT 1
+			FOR UPDATE
+		)
+	`
+
+	result, err := r.db.Exec(ctx, query, userID, codeHash)
+	if err != nil {
+		r.logger.Error("Failed to use recovery code", "error", err, "user_id", userID)
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+
+	return result.RowsAffected() > 0, nil
+}
+
+func (r *twoFactorRepository) Delete(ctx context.Context, userID string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", "error", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM two_factor_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		r.logger.Error("Failed to delete recovery codes", "error", err, "user_id", userID)
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM user_two_factor WHERE user_id = $1`, userID); err != nil {
+		r.logger.Error("Failed to delete two-factor enrolment", "error", err, "user_id", userID)
+		return fmt.Errorf("failed to delete two-factor enrolment: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		r.logger.Error("Failed to commit transaction", "error", err, "user_id", userID)
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	r.logger.Info("Two-factor authentication disabled", "user_id", userID)
+	return nil
+}
\ No newline at end of file
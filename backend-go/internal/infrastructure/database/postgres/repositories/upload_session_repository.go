@@ -0,0 +1,146 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"backend-go/internal/domain/attachment"
+	"backend-go/internal/shared/logger"
+)
+
+type uploadSessionRepository struct {
+	db     *pgxpool.Pool
+	logger logger.Logger
+}
+
+func NewUploadSessionRepository(db *pgxpool.Pool, logger logger.Logger) attachment.UploadSessionRepository {
+	return &uploadSessionRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *uploadSessionRepository) Create(ctx context.Context, session *attachment.UploadSession) error {
+	query := `
+		INSERT INTO upload_sessions (attachment_id, upload_offset, chunk_keys, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		session.AttachmentID,
+		session.Offset,
+		session.ChunkKeys,
+		session.ExpiresAt,
+		session.CreatedAt,
+	)
+
+	if err != nil {
+		r.logger.Error("Failed to create upload session", "error", err, "attachment_id", session.AttachmentID)
+		return fmt.Errorf("failed to create upload session: %w", err)
+	}
+
+	return nil
+}
+
+func (r *uploadSessionRepository) GetByAttachmentID(ctx context.Context, attachmentID string) (*attachment.UploadSession, error) {
+	query := `
+		SELECT attachment_id, upload_offset, chunk_keys, expires_at, created_at
+		FROM upload_sessions
+		WHERE attachment_id = $1
+	`
+
+	session, err := scanUploadSession(r.db.QueryRow(ctx, query, attachmentID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, attachment.ErrSessionNotFound
+		}
+		r.logger.Error("Failed to get upload session", "error", err, "attachment_id", attachmentID)
+		return nil, fmt.Errorf("failed to get upload session: %w", err)
+	}
+
+	return session, nil
+}
+
+func (r *uploadSessionRepository) AppendChunk(ctx context.Context, attachmentID string, fromOffset, toOffset int64, chunkKey string, expiresAt time.Time) error {
+	query := `
+		UPDATE upload_sessions
+		SET upload_offset = $3, chunk_keys = array_append(chunk_keys, $4), expires_at = $5
+		WHERE attachment_id = $1 AND upload_offset = $2
+	`
+
+	result, err := r.db.Exec(ctx, query, attachmentID, fromOffset, toOffset, chunkKey, expiresAt)
+	if err != nil {
+		r.logger.Error("Failed to append upload chunk", "error", err, "attachment_id", attachmentID)
+		return fmt.Errorf("failed to append upload chunk: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return attachment.ErrOffsetConflict
+	}
+
+	return nil
+}
+
+func (r *uploadSessionRepository) Delete(ctx context.Context, attachmentID string) error {
+	query := `DELETE FROM upload_sessions WHERE attachment_id = $1`
+
+	_, err := r.db.Exec(ctx, query, attachmentID)
+	if err != nil {
+		r.logger.Error("Failed to delete upload session", "error", err, "attachment_id", attachmentID)
+		return fmt.Errorf("failed to delete upload session: %w", err)
+	}
+
+	return nil
+}
+
+func (r *uploadSessionRepository) ListExpired(ctx context.Context, before time.Time, limit int) ([]*attachment.UploadSession, error) {
+	query := `
+		SELECT attachment_id, upload_offset, chunk_keys, expires_at, created_at
+		FROM upload_sessions
+		WHERE expires_at < $1
+		ORDER BY expires_at
+		LIMIT $2
+	`
+
+	rows, err := r.db.Query(ctx, query, before, limit)
+	if err != nil {
+		r.logger.Error("Failed to list expired upload sessions", "error", err)
+		return nil, fmt.Errorf("failed to list expired upload sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []*attachment.UploadSession
+	for rows.Next() {
+		session, err := scanUploadSession(rows)
+		if err != nil {
+			r.logger.Error("Failed to scan upload session", "error", err)
+			return nil, fmt.Errorf("failed to scan upload session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Failed to iterate upload sessions", "error", err)
+		return nil, fmt.Errorf("failed to iterate upload sessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
+func scanUploadSession(row pgx.Row) (*attachment.UploadSession, error) {
+	var session attachment.UploadSession
+	err := row.Scan(
+		&session.AttachmentID,
+		&session.Offset,
+		&session.ChunkKeys,
+		&session.ExpiresAt,
+		&session.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
\ No newline at end of file
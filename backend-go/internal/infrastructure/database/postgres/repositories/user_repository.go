@@ -25,8 +25,8 @@ func NewUserRepository(db *pgxpool.Pool, logger logger.Logger) user.Repository {
 
 func (r *userRepository) Create(ctx context.Context, u *user.User) error {
 	query := `
-		INSERT INTO users (id, username, email, password_hash, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO users (id, username, email, password_hash, email_verified_at, is_bot, owner_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 	`
 
 	_, err := r.db.Exec(ctx, query,
@@ -34,6 +34,9 @@ func (r *userRepository) Create(ctx context.Context, u *user.User) error {
 		u.Username,
 		u.Email,
 		u.PasswordHash,
+		u.EmailVerifiedAt,
+		u.IsBot,
+		u.OwnerID,
 		u.CreatedAt,
 		u.UpdatedAt,
 	)
@@ -49,7 +52,7 @@ func (r *userRepository) Create(ctx context.Context, u *user.User) error {
 
 func (r *userRepository) GetByID(ctx context.Context, id string) (*user.User, error) {
 	query := `
-		SELECT id, username, email, password_hash, is_active, last_seen_at, created_at, updated_at
+		SELECT id, username, email, password_hash, is_active, is_admin, is_bot, owner_id, email_verified_at, last_seen_at, created_at, updated_at
 		FROM users
 		WHERE id = $1
 	`
@@ -63,6 +66,10 @@ func (r *userRepository) GetByID(ctx context.Context, id string) (*user.User, er
 		&u.Email,
 		&u.PasswordHash,
 		&u.IsActive,
+		&u.IsAdmin,
+		&u.IsBot,
+		&u.OwnerID,
+		&u.EmailVerifiedAt,
 		&lastSeenAt,
 		&u.CreatedAt,
 		&u.UpdatedAt,
@@ -82,7 +89,7 @@ func (r *userRepository) GetByID(ctx context.Context, id string) (*user.User, er
 
 func (r *userRepository) GetByEmail(ctx context.Context, email string) (*user.User, error) {
 	query := `
-		SELECT id, username, email, password_hash, is_active, last_seen_at, created_at, updated_at
+		SELECT id, username, email, password_hash, is_active, is_admin, is_bot, owner_id, email_verified_at, last_seen_at, created_at, updated_at
 		FROM users
 		WHERE email = $1
 	`
@@ -96,6 +103,10 @@ func (r *userRepository) GetByEmail(ctx context.Context, email string) (*user.Us
 		&u.Email,
 		&u.PasswordHash,
 		&u.IsActive,
+		&u.IsAdmin,
+		&u.IsBot,
+		&u.OwnerID,
+		&u.EmailVerifiedAt,
 		&lastSeenAt,
 		&u.CreatedAt,
 		&u.UpdatedAt,
@@ -115,7 +126,7 @@ func (r *userRepository) GetByEmail(ctx context.Context, email string) (*user.Us
 
 func (r *userRepository) GetByUsername(ctx context.Context, username string) (*user.User, error) {
 	query := `
-		SELECT id, username, email, password_hash, is_active, last_seen_at, created_at, updated_at
+		SELECT id, username, email, password_hash, is_active, is_admin, is_bot, owner_id, email_verified_at, last_seen_at, created_at, updated_at
 		FROM users
 		WHERE username = $1
 	`
@@ -129,6 +140,10 @@ func (r *userRepository) GetByUsername(ctx context.Context, username string) (*u
 		&u.Email,
 		&u.PasswordHash,
 		&u.IsActive,
+		&u.IsAdmin,
+		&u.IsBot,
+		&u.OwnerID,
+		&u.EmailVerifiedAt,
 		&lastSeenAt,
 		&u.CreatedAt,
 		&u.UpdatedAt,
@@ -149,7 +164,8 @@ func (r *userRepository) GetByUsername(ctx context.Context, username string) (*u
 func (r *userRepository) Update(ctx context.Context, u *user.User) error {
 	query := `
 		UPDATE users
-		SET username = $2, email = $3, password_hash = $4, is_active = $5, last_seen_at = $6, updated_at = $7
+		SET username = $2, email = $3, password_hash = $4, is_active = $5, last_seen_at = $6, updated_at = $7,
+		    email_verified_at = $8
 		WHERE id = $1
 	`
 
@@ -163,6 +179,7 @@ func (r *userRepository) Update(ctx context.Context, u *user.User) error {
 		u.IsActive,
 		u.LastSeenAt,
 		u.UpdatedAt,
+		u.EmailVerifiedAt,
 	)
 
 	if err != nil {
@@ -240,4 +257,51 @@ func (r *userRepository) ExistsByUsername(ctx context.Context, username string)
 	}
 
 	return exists, nil
+}
+
+func (r *userRepository) ListBotsByOwner(ctx context.Context, ownerID string) ([]*user.User, error) {
+	query := `
+		SELECT id, username, email, password_hash, is_active, is_admin, is_bot, owner_id, email_verified_at, last_seen_at, created_at, updated_at
+		FROM users
+		WHERE owner_id = $1 AND is_bot
+		ORDER BY created_at
+	`
+
+	rows, err := r.db.Query(ctx, query, ownerID)
+	if err != nil {
+		r.logger.Error("Failed to list bots", "error", err, "owner_id", ownerID)
+		return nil, fmt.Errorf("failed to list bots: %w", err)
+	}
+	defer rows.Close()
+
+	var bots []*user.User
+	for rows.Next() {
+		var u user.User
+		err := rows.Scan(
+			&u.ID,
+			&u.Username,
+			&u.Email,
+			&u.PasswordHash,
+			&u.IsActive,
+			&u.IsAdmin,
+			&u.IsBot,
+			&u.OwnerID,
+			&u.EmailVerifiedAt,
+			&u.LastSeenAt,
+			&u.CreatedAt,
+			&u.UpdatedAt,
+		)
+		if err != nil {
+			r.logger.Error("Failed to scan bot", "error", err)
+			return nil, fmt.Errorf("failed to scan bot: %w", err)
+		}
+		bots = append(bots, &u)
+	}
+
+	if err = rows.Err(); err != nil {
+		r.logger.Error("Error iterating bots", "error", err)
+		return nil, fmt.Errorf("error iterating bots: %w", err)
+	}
+
+	return bots, nil
 }
\ No newline at end of file
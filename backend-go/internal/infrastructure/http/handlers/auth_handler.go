@@ -1,47 +1,121 @@
 package handlers
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"backend-go/internal/application/auth"
 	"backend-go/internal/shared/logger"
+	"backend-go/internal/shared/throttle"
 )
 
+// SessionHub interface for closing the WebSocket connections of a session
+type SessionHub interface {
+	CloseSession(sessionID string)
+}
+
 type AuthHandler struct {
 	authUseCase auth.UseCase
+	wsHub       SessionHub
 	logger      logger.Logger
 }
 
-func NewAuthHandler(authUseCase auth.UseCase, logger logger.Logger) *AuthHandler {
+func NewAuthHandler(authUseCase auth.UseCase, wsHub SessionHub, logger logger.Logger) *AuthHandler {
 	return &AuthHandler{
 		authUseCase: authUseCase,
+		wsHub:       wsHub,
 		logger:      logger,
 	}
 }
 
 type RegisterRequest struct {
-	Username string `json:"username" binding:"required,min=3,max=50"`
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
+	Username   string `json:"username" binding:"required,min=3,max=50"`
+	Email      string `json:"email" binding:"required,email"`
+	Password   string `json:"password" binding:"required"`
+	DeviceName string `json:"device_name,omitempty" binding:"omitempty,max=100"`
 }
 
 type LoginRequest struct {
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required"`
+	Email      string `json:"email" binding:"required,email"`
+	Password   string `json:"password" binding:"required"`
+	DeviceName string `json:"device_name,omitempty" binding:"omitempty,max=100"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type VerifyTwoFactorRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+	DeviceName     string `json:"device_name,omitempty" binding:"omitempty,max=100"`
+}
+
+type OIDCCallbackRequest struct {
+	Code       string `json:"code" binding:"required"`
+	State      string `json:"state" binding:"required"`
+	DeviceName string `json:"device_name,omitempty" binding:"omitempty,max=100"`
+}
+
+type UnlockLoginRequest struct {
+	Email     string `json:"email,omitempty" binding:"omitempty,email"`
+	IPAddress string `json:"ip_address,omitempty" binding:"omitempty,ip"`
 }
 
 type AuthResponse struct {
-	Token     string    `json:"token"`
-	User      UserInfo  `json:"user"`
-	ExpiresAt time.Time `json:"expires_at"`
+	SessionID        string    `json:"session_id"`
+	Token            string    `json:"token"`
+	User             UserInfo  `json:"user"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
 }
 
 type UserInfo struct {
-	ID       string `json:"id"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
+	ID            string `json:"id"`
+	Username      string `json:"username"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+type SessionResponse struct {
+	ID         string `json:"id"`
+	DeviceName string `json:"device_name"`
+	UserAgent  string `json:"user_agent,omitempty"`
+	IPAddress  string `json:"ip_address,omitempty"`
+	Current    bool   `json:"current"`
+	CreatedAt  string `json:"created_at"`
+	LastUsedAt string `json:"last_used_at"`
 }
 
 // Register handles user registration
@@ -57,21 +131,29 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		Username: req.Username,
 		Email:    req.Email,
 		Password: req.Password,
+		Device:   deviceInfo(c, req.DeviceName),
 	})
 
 	if err != nil {
 		h.logger.Error("Registration failed", "error", err, "email", req.Email)
+		if respondBlocked(c, err) {
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	response := AuthResponse{
-		Token:     result.Token,
-		ExpiresAt: result.ExpiresAt,
+		SessionID:        result.SessionID,
+		Token:            result.Token,
+		ExpiresAt:        result.ExpiresAt,
+		RefreshToken:     result.RefreshToken,
+		RefreshExpiresAt: result.RefreshExpiresAt,
 		User: UserInfo{
-			ID:       result.User.ID,
-			Username: result.User.Username,
-			Email:    result.User.Email,
+			ID:            result.User.ID,
+			Username:      result.User.Username,
+			Email:         result.User.Email,
+			EmailVerified: result.User.EmailVerified,
 		},
 	}
 
@@ -91,21 +173,39 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	result, err := h.authUseCase.Login(c.Request.Context(), auth.LoginInput{
 		Email:    req.Email,
 		Password: req.Password,
+		Device:   deviceInfo(c, req.DeviceName),
 	})
 
 	if err != nil {
 		h.logger.Error("Login failed", "error", err, "email", req.Email)
+		if respondBlocked(c, err) {
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
+	// The client must complete the login with POST /auth/2fa/verify
+	if result.TwoFactorRequired {
+		c.JSON(http.StatusOK, gin.H{
+			"two_factor_required":  true,
+			"challenge_token":      result.ChallengeToken,
+			"challenge_expires_at": result.ChallengeExpiresAt,
+		})
+		return
+	}
+
 	response := AuthResponse{
-		Token:     result.Token,
-		ExpiresAt: result.ExpiresAt,
+		SessionID:        result.SessionID,
+		Token:            result.Token,
+		ExpiresAt:        result.ExpiresAt,
+		RefreshToken:     result.RefreshToken,
+		RefreshExpiresAt: result.RefreshExpiresAt,
 		User: UserInfo{
-			ID:       result.User.ID,
-			Username: result.User.Username,
-			Email:    result.User.Email,
+			ID:            result.User.ID,
+			Username:      result.User.Username,
+			Email:         result.User.Email,
+			EmailVerified: result.User.EmailVerified,
 		},
 	}
 
@@ -126,47 +226,538 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 		token = token[7:]
 	}
 
-	err := h.authUseCase.Logout(c.Request.Context(), token)
+	// The refresh token is optional; without it only the access token is revoked
+	var req LogoutRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			h.logger.Error("Invalid logout request", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+			return
+		}
+	}
+
+	err := h.authUseCase.Logout(c.Request.Context(), auth.LogoutInput{
+		AccessToken:  token,
+		RefreshToken: req.RefreshToken,
+	})
 	if err != nil {
 		h.logger.Error("Logout failed", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Logout failed"})
 		return
 	}
 
+	if sessionID := c.GetString("session_id"); sessionID != "" {
+		h.wsHub.CloseSession(sessionID)
+	}
+
 	h.logger.Info("User logged out successfully")
 	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
 }
 
 // RefreshToken handles token refresh
 func (h *AuthHandler) RefreshToken(c *gin.Context) {
-	token := c.GetHeader("Authorization")
-	if token == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No token provided"})
+	var req RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("Invalid refresh token request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
 		return
 	}
 
-	// Remove "Bearer " prefix
-	if len(token) > 7 && token[:7] == "Bearer " {
-		token = token[7:]
-	}
-
-	result, err := h.authUseCase.RefreshToken(c.Request.Context(), token)
+	result, err := h.authUseCase.RefreshToken(c.Request.Context(), req.RefreshToken)
 	if err != nil {
 		h.logger.Error("Token refresh failed", "error", err)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
 		return
 	}
 
 	response := AuthResponse{
-		Token:     result.Token,
-		ExpiresAt: result.ExpiresAt,
+		SessionID:        result.SessionID,
+		Token:            result.Token,
+		ExpiresAt:        result.ExpiresAt,
+		RefreshToken:     result.RefreshToken,
+		RefreshExpiresAt: result.RefreshExpiresAt,
 		User: UserInfo{
-			ID:       result.User.ID,
-			Username: result.User.Username,
-			Email:    result.User.Email,
+			ID:            result.User.ID,
+			Username:      result.User.Username,
+			Email:         result.User.Email,
+			EmailVerified: result.User.EmailVerified,
 		},
 	}
 
 	h.logger.Info("Token refreshed successfully", "user_id", result.User.ID)
 	c.JSON(http.StatusOK, response)
+}
+
+// ListSessions lists the devices the current user is signed in on
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("user_id")
+	if !exists {
+		h.logger.Error("User ID not found in context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	result, err := h.authUseCase.ListSessions(c.Request.Context(), auth.ListSessionsInput{
+		UserID:           userID.(string),
+		CurrentSessionID: c.GetString("session_id"),
+	})
+	if err != nil {
+		h.logger.Error("Failed to list sessions", "error", err, "user_id", userID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list sessions"})
+		return
+	}
+
+	sessions := make([]SessionResponse, 0, len(result.Sessions))
+	for _, s := range result.Sessions {
+		sessions = append(sessions, SessionResponse{
+			ID:         s.ID,
+			DeviceName: s.DeviceName,
+			UserAgent:  s.UserAgent,
+			IPAddress:  s.IPAddress,
+			Current:    s.Current,
+			CreatedAt:  s.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+			LastUsedAt: s.LastUsedAt.Format("2006-01-02T15:04:05Z07:00"),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// RevokeSession signs out a single session and closes its WebSocket connections
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("user_id")
+	if !exists {
+		h.logger.Error("User ID not found in context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	sessionID := c.Param("id")
+	err := h.authUseCase.RevokeSession(c.Request.Context(), auth.RevokeSessionInput{
+		UserID:    userID.(string),
+		SessionID: sessionID,
+	})
+	if err != nil {
+		h.logger.Error("Failed to revoke session", "error", err, "user_id", userID, "session_id", sessionID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.wsHub.CloseSession(sessionID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// RevokeOtherSessions signs out every session except the current one
+func (h *AuthHandler) RevokeOtherSessions(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("user_id")
+	if !exists {
+		h.logger.Error("User ID not found in context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// Tokens issued before sessions existed cannot tell which session to keep
+	sessionID := c.GetString("session_id")
+	if sessionID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Token is not bound to a session; log in again"})
+		return
+	}
+
+	result, err := h.authUseCase.RevokeOtherSessions(c.Request.Context(), auth.RevokeOtherSessionsInput{
+		UserID:           userID.(string),
+		CurrentSessionID: sessionID,
+	})
+	if err != nil {
+		h.logger.Error("Failed to revoke other sessions", "error", err, "user_id", userID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	for _, revokedID := range result.RevokedSessionIDs {
+		h.wsHub.CloseSession(revokedID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Other sessions revoked successfully",
+		"revoked_count": len(result.RevokedSessionIDs),
+	})
+}
+
+// ForgotPassword emails a password reset link. The response is the same
+// whether or not the email belongs to an account.
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("Invalid forgot password request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	err := h.authUseCase.RequestPasswordReset(c.Request.Context(), auth.RequestPasswordResetInput{
+		Email: req.Email,
+	})
+	if err != nil {
+		h.logger.Error("Password reset request failed", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send password reset email"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the email is registered, a password reset link has been sent"})
+}
+
+// ResetPassword sets a new password using a reset token and signs out every session
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("Invalid reset password request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	result, err := h.authUseCase.ResetPassword(c.Request.Context(), auth.ResetPasswordInput{
+		Token:       req.Token,
+		NewPassword: req.NewPassword,
+	})
+	if err != nil {
+		h.logger.Error("Password reset failed", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, sessionID := range result.RevokedSessionIDs {
+		h.wsHub.CloseSession(sessionID)
+	}
+
+	h.logger.Info("Password reset successfully", "user_id", result.UserID)
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully; please log in again"})
+}
+
+// ChangePassword sets a new password after checking the current one and signs
+// out every other session
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("user_id")
+	if !exists {
+		h.logger.Error("User ID not found in context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("Invalid change password request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	result, err := h.authUseCase.ChangePassword(c.Request.Context(), auth.ChangePasswordInput{
+		UserID:           userID.(string),
+		CurrentSessionID: c.GetString("session_id"),
+		CurrentPassword:  req.CurrentPassword,
+		NewPassword:      req.NewPassword,
+	})
+	if err != nil {
+		h.logger.Error("Password change failed", "error", err, "user_id", userID)
+		if respondBlocked(c, err) {
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, revokedID := range result.RevokedSessionIDs {
+		h.wsHub.CloseSession(revokedID)
+	}
+
+	h.logger.Info("Password changed successfully", "user_id", userID)
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Password changed successfully",
+		"revoked_count": len(result.RevokedSessionIDs),
+	})
+}
+
+// VerifyEmail confirms the user's email address using a verification token
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("Invalid verify email request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	err := h.authUseCase.VerifyEmail(c.Request.Context(), auth.VerifyEmailInput{
+		Token: req.Token,
+	})
+	if err != nil {
+		h.logger.Error("Email verification failed", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+// ResendVerification sends a new verification email to the current user
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("user_id")
+	if !exists {
+		h.logger.Error("User ID not found in context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	err := h.authUseCase.SendEmailVerification(c.Request.Context(), auth.SendEmailVerificationInput{
+		UserID: userID.(string),
+	})
+	if err != nil {
+		h.logger.Error("Failed to resend verification email", "error", err, "user_id", userID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Verification email sent"})
+}
+
+// SetupTwoFactor starts two-factor enrolment and returns the TOTP secret
+func (h *AuthHandler) SetupTwoFactor(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("user_id")
+	if !exists {
+		h.logger.Error("User ID not found in context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	result, err := h.authUseCase.SetupTwoFactor(c.Request.Context(), auth.SetupTwoFactorInput{
+		UserID: userID.(string),
+	})
+	if err != nil {
+		h.logger.Error("Failed to set up two-factor authentication", "error", err, "user_id", userID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ConfirmTwoFactor enables two-factor authentication once the user proves
+// their authenticator works, returning one-time recovery codes
+func (h *AuthHandler) ConfirmTwoFactor(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("user_id")
+	if !exists {
+		h.logger.Error("User ID not found in context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("Invalid confirm two-factor request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	result, err := h.authUseCase.ConfirmTwoFactor(c.Request.Context(), auth.ConfirmTwoFactorInput{
+		UserID: userID.(string),
+		Code:   req.Code,
+	})
+	if err != nil {
+		h.logger.Error("Failed to confirm two-factor authentication", "error", err, "user_id", userID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.logger.Info("Two-factor authentication enabled", "user_id", userID)
+	c.JSON(http.StatusOK, result)
+}
+
+// DisableTwoFactor turns two-factor authentication off after checking a current code
+func (h *AuthHandler) DisableTwoFactor(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("user_id")
+	if !exists {
+		h.logger.Error("User ID not found in context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("Invalid disable two-factor request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	err := h.authUseCase.DisableTwoFactor(c.Request.Context(), auth.DisableTwoFactorInput{
+		UserID: userID.(string),
+		Code:   req.Code,
+	})
+	if err != nil {
+		h.logger.Error("Failed to disable two-factor authentication", "error", err, "user_id", userID)
+		if respondBlocked(c, err) {
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.logger.Info("Two-factor authentication disabled", "user_id", userID)
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// VerifyTwoFactor completes a login by exchanging a challenge token and a
+// TOTP or recovery code for a full session
+func (h *AuthHandler) VerifyTwoFactor(c *gin.Context) {
+	var req VerifyTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("Invalid verify two-factor request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	result, err := h.authUseCase.VerifyTwoFactorLogin(c.Request.Context(), auth.VerifyTwoFactorLoginInput{
+		ChallengeToken: req.ChallengeToken,
+		Code:           req.Code,
+		Device:         deviceInfo(c, req.DeviceName),
+	})
+	if err != nil {
+		h.logger.Error("Two-factor login failed", "error", err)
+		if respondBlocked(c, err) {
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired two-factor code"})
+		return
+	}
+
+	response := AuthResponse{
+		SessionID:        result.SessionID,
+		Token:            result.Token,
+		ExpiresAt:        result.ExpiresAt,
+		RefreshToken:     result.RefreshToken,
+		RefreshExpiresAt: result.RefreshExpiresAt,
+		User: UserInfo{
+			ID:            result.User.ID,
+			Username:      result.User.Username,
+			Email:         result.User.Email,
+			EmailVerified: result.User.EmailVerified,
+		},
+	}
+
+	h.logger.Info("User logged in successfully", "user_id", result.User.ID)
+	c.JSON(http.StatusOK, response)
+}
+
+// StartOIDCLogin returns the identity provider URL to send the user to for single sign-on
+func (h *AuthHandler) StartOIDCLogin(c *gin.Context) {
+	result, err := h.authUseCase.StartOIDCLogin(c.Request.Context(), auth.StartOIDCLoginInput{
+		DeviceName: c.Query("device_name"),
+	})
+	if err != nil {
+		h.logger.Error("Failed to start OIDC login", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// CompleteOIDCLogin exchanges the authorization code the identity provider
+// redirected back with for a session
+func (h *AuthHandler) CompleteOIDCLogin(c *gin.Context) {
+	var req OIDCCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("Invalid OIDC callback request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	result, err := h.authUseCase.CompleteOIDCLogin(c.Request.Context(), auth.CompleteOIDCLoginInput{
+		Code:   req.Code,
+		State:  req.State,
+		Device: deviceInfo(c, req.DeviceName),
+	})
+	if err != nil {
+		h.logger.Error("OIDC login failed", "error", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := AuthResponse{
+		SessionID:        result.SessionID,
+		Token:            result.Token,
+		ExpiresAt:        result.ExpiresAt,
+		RefreshToken:     result.RefreshToken,
+		RefreshExpiresAt: result.RefreshExpiresAt,
+		User: UserInfo{
+			ID:            result.User.ID,
+			Username:      result.User.Username,
+			Email:         result.User.Email,
+			EmailVerified: result.User.EmailVerified,
+		},
+	}
+
+	h.logger.Info("User logged in successfully", "user_id", result.User.ID)
+	c.JSON(http.StatusOK, response)
+}
+
+// UnlockLogin lets an admin lift the lockout of an account or a client IP
+func (h *AuthHandler) UnlockLogin(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("user_id")
+	if !exists {
+		h.logger.Error("User ID not found in context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req UnlockLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("Invalid unlock login request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	err := h.authUseCase.UnlockLogin(c.Request.Context(), auth.UnlockLoginInput{
+		AdminID:   userID.(string),
+		Email:     req.Email,
+		IPAddress: req.IPAddress,
+	})
+	if err != nil {
+		h.logger.Error("Failed to unlock login", "error", err, "user_id", userID)
+		if errors.Is(err, auth.ErrAdminRequired) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Login unlocked"})
+}
+
+// respondBlocked answers 429 with a Retry-After header when err means the
+// client is throttled, reporting whether it did
+func respondBlocked(c *gin.Context, err error) bool {
+	var blocked *throttle.BlockedError
+	if !errors.As(err, &blocked) {
+		return false
+	}
+
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(blocked.RetryAfter.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": blocked.Error()})
+	return true
+}
+
+// deviceInfo describes the client making the request
+func deviceInfo(c *gin.Context, deviceName string) auth.DeviceInfo {
+	return auth.DeviceInfo{
+		DeviceName: deviceName,
+		UserAgent:  c.Request.UserAgent(),
+		IPAddress:  c.ClientIP(),
+	}
 }
\ No newline at end of file
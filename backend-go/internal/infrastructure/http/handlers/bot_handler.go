@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"backend-go/internal/application/bot"
+	"backend-go/internal/domain/user"
+	"backend-go/internal/shared/logger"
+)
+
+type BotHandler struct {
+	botUseCase bot.UseCase
+	logger     logger.Logger
+}
+
+func NewBotHandler(botUseCase bot.UseCase, logger logger.Logger) *BotHandler {
+	return &BotHandler{
+		botUseCase: botUseCase,
+		logger:     logger,
+	}
+}
+
+type CreateBotRequest struct {
+	Username string `json:"username" binding:"required,min=3,max=50"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"`
+	RoomIDs       []string `json:"room_ids,omitempty"`
+	ExpiresInDays int      `json:"expires_in_days,omitempty"`
+}
+
+type RotateAPIKeyRequest struct {
+	GracePeriodSeconds int `json:"grace_period_seconds,omitempty"`
+}
+
+// CreateBot creates a bot owned by the current user
+func (h *BotHandler) CreateBot(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		h.logger.Error("User ID not found in context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req CreateBotRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("Invalid create bot request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	result, err := h.botUseCase.CreateBot(c.Request.Context(), bot.CreateBotInput{
+		OwnerID:  userID.(string),
+		Username: req.Username,
+	})
+	if err != nil {
+		h.logger.Error("Failed to create bot", "error", err, "user_id", userID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.logger.Info("Bot created successfully", "bot_id", result.ID, "user_id", userID)
+	c.JSON(http.StatusCreated, result)
+}
+
+// ListBots lists the current user's bots
+func (h *BotHandler) ListBots(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		h.logger.Error("User ID not found in context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	result, err := h.botUseCase.ListBots(c.Request.Context(), bot.ListBotsInput{
+		OwnerID: userID.(string),
+	})
+	if err != nil {
+		h.logger.Error("Failed to list bots", "error", err, "user_id", userID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list bots"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// DeleteBot deactivates a bot and revokes all of its API keys
+func (h *BotHandler) DeleteBot(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		h.logger.Error("User ID not found in context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	err := h.botUseCase.DeleteBot(c.Request.Context(), bot.DeleteBotInput{
+		OwnerID: userID.(string),
+		BotID:   c.Param("id"),
+	})
+	if err != nil {
+		h.logger.Error("Failed to delete bot", "error", err, "bot_id", c.Param("id"), "user_id", userID)
+		h.respondError(c, err)
+		return
+	}
+
+	h.logger.Info("Bot deleted successfully", "bot_id", c.Param("id"), "user_id", userID)
+	c.JSON(http.StatusOK, gin.H{"message": "Bot deleted successfully"})
+}
+
+// CreateAPIKey issues an API key to a bot. The key is only returned once.
+func (h *BotHandler) CreateAPIKey(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		h.logger.Error("User ID not found in context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("Invalid create API key request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	result, err := h.botUseCase.CreateAPIKey(c.Request.Context(), bot.CreateAPIKeyInput{
+		OwnerID:       userID.(string),
+		BotID:         c.Param("id"),
+		Name:          req.Name,
+		Scopes:        req.Scopes,
+		RoomIDs:       req.RoomIDs,
+		ExpiresInDays: req.ExpiresInDays,
+	})
+	if err != nil {
+		h.logger.Error("Failed to create API key", "error", err, "bot_id", c.Param("id"), "user_id", userID)
+		h.respondError(c, err)
+		return
+	}
+
+	h.logger.Info("API key created successfully", "bot_id", c.Param("id"), "key_id", result.APIKey.ID, "user_id", userID)
+	c.JSON(http.StatusCreated, result)
+}
+
+// ListAPIKeys lists a bot's API keys without their secrets
+func (h *BotHandler) ListAPIKeys(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		h.logger.Error("User ID not found in context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	result, err := h.botUseCase.ListAPIKeys(c.Request.Context(), bot.ListAPIKeysInput{
+		OwnerID: userID.(string),
+		BotID:   c.Param("id"),
+	})
+	if err != nil {
+		h.logger.Error("Failed to list API keys", "error", err, "bot_id", c.Param("id"), "user_id", userID)
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// RotateAPIKey replaces an API key with a new one with the same grants
+func (h *BotHandler) RotateAPIKey(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		h.logger.Error("User ID not found in context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// The body is optional; without it the old key stops working immediately
+	var req RotateAPIKeyRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			h.logger.Error("Invalid rotate API key request", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+			return
+		}
+	}
+
+	result, err := h.botUseCase.RotateAPIKey(c.Request.Context(), bot.RotateAPIKeyInput{
+		OwnerID:            userID.(string),
+		BotID:              c.Param("id"),
+		KeyID:              c.Param("key_id"),
+		GracePeriodSeconds: req.GracePeriodSeconds,
+	})
+	if err != nil {
+		h.logger.Error("Failed to rotate API key", "error", err, "key_id", c.Param("key_id"), "user_id", userID)
+		h.respondError(c, err)
+		return
+	}
+
+	h.logger.Info("API key rotated successfully", "old_key_id", c.Param("key_id"), "new_key_id", result.APIKey.ID, "user_id", userID)
+	c.JSON(http.StatusCreated, result)
+}
+
+// RevokeAPIKey makes an API key stop working immediately
+func (h *BotHandler) RevokeAPIKey(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		h.logger.Error("User ID not found in context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	err := h.botUseCase.RevokeAPIKey(c.Request.Context(), bot.RevokeAPIKeyInput{
+		OwnerID: userID.(string),
+		BotID:   c.Param("id"),
+		KeyID:   c.Param("key_id"),
+	})
+	if err != nil {
+		h.logger.Error("Failed to revoke API key", "error", err, "key_id", c.Param("key_id"), "user_id", userID)
+		h.respondError(c, err)
+		return
+	}
+
+	h.logger.Info("API key revoked successfully", "key_id", c.Param("key_id"), "user_id", userID)
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked successfully"})
+}
+
+// respondError maps bot use case errors to responses
+func (h *BotHandler) respondError(c *gin.Context, err error) {
+	if errors.Is(err, user.ErrBotNotFound) || errors.Is(err, user.ErrAPIKeyNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
\ No newline at end of file
@@ -3,20 +3,32 @@ package handlers
 import (
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"backend-go/internal/application/chat"
+	"backend-go/internal/infrastructure/websocket"
 	"backend-go/internal/shared/logger"
 )
 
 type ChatHandler struct {
 	chatUseCase chat.UseCase
+	wsHub       ChatRoomHub
 	logger      logger.Logger
 }
 
-func NewChatHandler(chatUseCase chat.UseCase, logger logger.Logger) *ChatHandler {
+// ChatRoomHub interface for WebSocket room membership operations
+type ChatRoomHub interface {
+	WebSocketHub
+	LeaveRoom(userID, roomID string)
+	CloseRoom(roomID string)
+	IsUserOnline(userID string) bool
+}
+
+func NewChatHandler(chatUseCase chat.UseCase, wsHub ChatRoomHub, logger logger.Logger) *ChatHandler {
 	return &ChatHandler{
 		chatUseCase: chatUseCase,
+		wsHub:       wsHub,
 		logger:      logger,
 	}
 }
@@ -32,15 +44,65 @@ type JoinChatRoomRequest struct {
 	UserID string `json:"user_id" binding:"required"`
 }
 
+type UpdateChatRoomRequest struct {
+	Name             string `json:"name,omitempty" binding:"omitempty,min=1,max=100"`
+	Description      string `json:"description,omitempty" binding:"max=500"`
+	IsPrivate        *bool  `json:"is_private,omitempty"`
+	AnnouncementOnly *bool  `json:"announcement_only,omitempty"`
+	SlowModeSeconds  *int   `json:"slow_mode_seconds,omitempty" binding:"omitempty,min=0,max=21600"`
+}
+
+type AddMembersRequest struct {
+	UserIDs []string `json:"user_ids" binding:"required,min=1,max=100"`
+}
+
+type BanMemberRequest struct {
+	UserID    string     `json:"user_id" binding:"required"`
+	Reason    string     `json:"reason,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type UpdateMemberRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=admin member"`
+}
+
+type UpdateMemberSettingsRequest struct {
+	MutedUntil        *time.Time `json:"muted_until,omitempty"`
+	Pinned            bool       `json:"pinned"`
+	Archived          bool       `json:"archived"`
+	NotificationLevel string     `json:"notification_level" binding:"required,oneof=all mentions none"`
+}
+
 type ChatRoomResponse struct {
-	ID          string   `json:"id"`
-	Name        string   `json:"name"`
-	Description string   `json:"description,omitempty"`
-	IsPrivate   bool     `json:"is_private"`
-	CreatedBy   string   `json:"created_by"`
-	Members     []string `json:"members"`
-	CreatedAt   string   `json:"created_at"`
-	UpdatedAt   string   `json:"updated_at"`
+	ID               string                  `json:"id"`
+	Name             string                  `json:"name"`
+	Description      string                  `json:"description,omitempty"`
+	IsPrivate        bool                    `json:"is_private"`
+	CreatedBy        string                  `json:"created_by"`
+	Members          []string                `json:"members,omitempty"`
+	MemberCount      int                     `json:"member_count"`
+	AnnouncementOnly bool                    `json:"announcement_only"`
+	SlowModeSeconds  int                     `json:"slow_mode_seconds"`
+	Settings         *MemberSettingsResponse `json:"settings,omitempty"`
+	CreatedAt        string                  `json:"created_at"`
+	UpdatedAt        string                  `json:"updated_at"`
+}
+
+type MemberSettingsResponse struct {
+	Muted             bool    `json:"muted"`
+	MutedUntil        *string `json:"muted_until,omitempty"`
+	Pinned            bool    `json:"pinned"`
+	Archived          bool    `json:"archived"`
+	NotificationLevel string  `json:"notification_level"`
+}
+
+type MemberResponse struct {
+	UserID     string  `json:"user_id"`
+	Username   string  `json:"username"`
+	Role       string  `json:"role"`
+	Online     bool    `json:"online"`
+	JoinedAt   string  `json:"joined_at"`
+	LastSeenAt *string `json:"last_seen_at,omitempty"`
 }
 
 // CreateChatRoom handles chat room creation
@@ -81,6 +143,7 @@ func (h *ChatHandler) CreateChatRoom(c *gin.Context) {
 		IsPrivate:   result.IsPrivate,
 		CreatedBy:   result.CreatedBy,
 		Members:     result.Members,
+		MemberCount: result.MemberCount,
 		CreatedAt:   result.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
 		UpdatedAt:   result.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
 	}
@@ -112,10 +175,13 @@ func (h *ChatHandler) GetChatRooms(c *gin.Context) {
 		}
 	}
 
+	archived, _ := strconv.ParseBool(c.Query("archived"))
+
 	result, err := h.chatUseCase.GetUserChatRooms(c.Request.Context(), chat.GetUserChatRoomsInput{
-		UserID: userID.(string),
-		Page:   page,
-		Limit:  limit,
+		UserID:   userID.(string),
+		Archived: archived,
+		Page:     page,
+		Limit:    limit,
 	})
 
 	if err != nil {
@@ -127,14 +193,17 @@ func (h *ChatHandler) GetChatRooms(c *gin.Context) {
 	var chatRooms []ChatRoomResponse
 	for _, room := range result.ChatRooms {
 		chatRooms = append(chatRooms, ChatRoomResponse{
-			ID:          room.ID,
-			Name:        room.Name,
-			Description: room.Description,
-			IsPrivate:   room.IsPrivate,
-			CreatedBy:   room.CreatedBy,
-			Members:     room.Members,
-			CreatedAt:   room.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-			UpdatedAt:   room.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+			ID:               room.ID,
+			Name:             room.Name,
+			Description:      room.Description,
+			IsPrivate:        room.IsPrivate,
+			CreatedBy:        room.CreatedBy,
+			MemberCount:      room.MemberCount,
+			AnnouncementOnly: room.AnnouncementOnly,
+			SlowModeSeconds:  room.SlowModeSeconds,
+			Settings:         toMemberSettingsResponse(room.Settings),
+			CreatedAt:        room.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+			UpdatedAt:        room.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
 		})
 	}
 
@@ -179,14 +248,17 @@ func (h *ChatHandler) GetChatRoom(c *gin.Context) {
 	}
 
 	response := ChatRoomResponse{
-		ID:          result.ID,
-		Name:        result.Name,
-		Description: result.Description,
-		IsPrivate:   result.IsPrivate,
-		CreatedBy:   result.CreatedBy,
-		Members:     result.Members,
-		CreatedAt:   result.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-		UpdatedAt:   result.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		ID:               result.ID,
+		Name:             result.Name,
+		Description:      result.Description,
+		IsPrivate:        result.IsPrivate,
+		CreatedBy:        result.CreatedBy,
+		MemberCount:      result.MemberCount,
+		AnnouncementOnly: result.AnnouncementOnly,
+		SlowModeSeconds:  result.SlowModeSeconds,
+		Settings:         toMemberSettingsResponse(result.Settings),
+		CreatedAt:        result.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt:        result.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
 	}
 
 	h.logger.Info("Chat room retrieved successfully", "room_id", roomID, "user_id", userID)
@@ -219,6 +291,8 @@ func (h *ChatHandler) JoinChatRoom(c *gin.Context) {
 		return
 	}
 
+	h.notifyMembersAdded(roomID, []string{userID.(string)}, userID.(string))
+
 	h.logger.Info("User joined chat room successfully", "room_id", roomID, "user_id", userID)
 	c.JSON(http.StatusOK, gin.H{"message": "Joined chat room successfully"})
 }
@@ -249,6 +323,570 @@ func (h *ChatHandler) LeaveChatRoom(c *gin.Context) {
 		return
 	}
 
+	h.notifyMemberRemoved(roomID, userID.(string), userID.(string), "left")
+
 	h.logger.Info("User left chat room successfully", "room_id", roomID, "user_id", userID)
 	c.JSON(http.StatusOK, gin.H{"message": "Left chat room successfully"})
+}
+
+// GetMembers handles listing chat room members
+func (h *ChatHandler) GetMembers(c *gin.Context) {
+	roomID := c.Param("id")
+	if roomID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Room ID is required"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		h.logger.Error("User ID not found in context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// Parse pagination parameters
+	limit := 50
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
+			limit = parsed
+		}
+	}
+
+	// Parse after parameter for cursor-based pagination
+	after := c.Query("after")
+
+	result, err := h.chatUseCase.ListMembers(c.Request.Context(), chat.ListMembersInput{
+		RoomID: roomID,
+		UserID: userID.(string),
+		After:  after,
+		Limit:  limit,
+	})
+
+	if err != nil {
+		h.logger.Error("Failed to get members", "error", err, "room_id", roomID, "user_id", userID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	members := make([]MemberResponse, 0, len(result.Members))
+	for _, member := range result.Members {
+		response := MemberResponse{
+			UserID:   member.UserID,
+			Username: member.Username,
+			Role:     member.Role,
+			JoinedAt: member.JoinedAt.Format("2006-01-02T15:04:05Z07:00"),
+		}
+		if member.LastSeenAt != nil {
+			lastSeenAt := member.LastSeenAt.Format("2006-01-02T15:04:05Z07:00")
+			response.LastSeenAt = &lastSeenAt
+		}
+		if h.wsHub != nil {
+			response.Online = h.wsHub.IsUserOnline(member.UserID)
+		}
+		members = append(members, response)
+	}
+
+	response := gin.H{
+		"members": members,
+		"pagination": gin.H{
+			"limit":       limit,
+			"next_cursor": result.NextCursor,
+			"has_more":    result.HasMore,
+		},
+	}
+
+	h.logger.Info("Members retrieved successfully", "room_id", roomID, "user_id", userID, "count", len(members))
+	c.JSON(http.StatusOK, response)
+}
+
+// AddMembers handles adding members to a chat room
+func (h *ChatHandler) AddMembers(c *gin.Context) {
+	roomID := c.Param("id")
+	if roomID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Room ID is required"})
+		return
+	}
+
+	var req AddMembersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("Invalid add members request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		h.logger.Error("User ID not found in context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	result, err := h.chatUseCase.AddMembers(c.Request.Context(), chat.AddMembersInput{
+		RoomID:    roomID,
+		UserID:    userID.(string),
+		MemberIDs: req.UserIDs,
+	})
+
+	if err != nil {
+		h.logger.Error("Failed to add members", "error", err, "room_id", roomID, "user_id", userID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.notifyMembersAdded(roomID, result.Added, userID.(string))
+
+	h.logger.Info("Members added successfully", "room_id", roomID, "user_id", userID, "count", len(result.Added))
+	c.JSON(http.StatusOK, result)
+}
+
+// KickMember handles removing a member from a chat room
+func (h *ChatHandler) KickMember(c *gin.Context) {
+	roomID := c.Param("id")
+	memberID := c.Param("user_id")
+	if roomID == "" || memberID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Room ID and user ID are required"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		h.logger.Error("User ID not found in context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	err := h.chatUseCase.KickMember(c.Request.Context(), chat.KickMemberInput{
+		RoomID:   roomID,
+		UserID:   userID.(string),
+		MemberID: memberID,
+	})
+
+	if err != nil {
+		h.logger.Error("Failed to kick member", "error", err, "room_id", roomID, "member_id", memberID, "user_id", userID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.notifyMemberRemoved(roomID, memberID, userID.(string), "kicked")
+
+	h.logger.Info("Member kicked successfully", "room_id", roomID, "member_id", memberID, "user_id", userID)
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+}
+
+// BanMember handles banning a user from a chat room
+func (h *ChatHandler) BanMember(c *gin.Context) {
+	roomID := c.Param("id")
+	if roomID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Room ID is required"})
+		return
+	}
+
+	var req BanMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("Invalid ban member request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		h.logger.Error("User ID not found in context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	err := h.chatUseCase.BanMember(c.Request.Context(), chat.BanMemberInput{
+		RoomID:    roomID,
+		UserID:    userID.(string),
+		MemberID:  req.UserID,
+		Reason:    req.Reason,
+		ExpiresAt: req.ExpiresAt,
+	})
+
+	if err != nil {
+		h.logger.Error("Failed to ban member", "error", err, "room_id", roomID, "member_id", req.UserID, "user_id", userID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.notifyMemberRemoved(roomID, req.UserID, userID.(string), "banned")
+
+	h.logger.Info("Member banned successfully", "room_id", roomID, "member_id", req.UserID, "user_id", userID)
+	c.JSON(http.StatusOK, gin.H{"message": "Member banned successfully"})
+}
+
+// UnbanMember handles lifting a chat room ban
+func (h *ChatHandler) UnbanMember(c *gin.Context) {
+	roomID := c.Param("id")
+	memberID := c.Param("user_id")
+	if roomID == "" || memberID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Room ID and user ID are required"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		h.logger.Error("User ID not found in context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	err := h.chatUseCase.UnbanMember(c.Request.Context(), chat.UnbanMemberInput{
+		RoomID:   roomID,
+		UserID:   userID.(string),
+		MemberID: memberID,
+	})
+
+	if err != nil {
+		h.logger.Error("Failed to unban member", "error", err, "room_id", roomID, "member_id", memberID, "user_id", userID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.logger.Info("Member unbanned successfully", "room_id", roomID, "member_id", memberID, "user_id", userID)
+	c.JSON(http.StatusOK, gin.H{"message": "Member unbanned successfully"})
+}
+
+// UpdateMemberRole handles promoting or demoting a chat room member
+func (h *ChatHandler) UpdateMemberRole(c *gin.Context) {
+	roomID := c.Param("id")
+	memberID := c.Param("user_id")
+	if roomID == "" || memberID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Room ID and user ID are required"})
+		return
+	}
+
+	var req UpdateMemberRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("Invalid update member role request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		h.logger.Error("User ID not found in context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	err := h.chatUseCase.UpdateMemberRole(c.Request.Context(), chat.UpdateMemberRoleInput{
+		RoomID:   roomID,
+		UserID:   userID.(string),
+		MemberID: memberID,
+		Role:     req.Role,
+	})
+
+	if err != nil {
+		h.logger.Error("Failed to update member role", "error", err, "room_id", roomID, "member_id", memberID, "user_id", userID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.logger.Info("Member role updated successfully", "room_id", roomID, "member_id", memberID, "role", req.Role)
+	c.JSON(http.StatusOK, gin.H{"message": "Member role updated successfully"})
+}
+
+// UpdateChatRoom handles updating a chat room's details
+func (h *ChatHandler) UpdateChatRoom(c *gin.Context) {
+	roomID := c.Param("id")
+	if roomID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Room ID is required"})
+		return
+	}
+
+	var req UpdateChatRoomRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("Invalid update chat room request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		h.logger.Error("User ID not found in context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	result, err := h.chatUseCase.UpdateChatRoom(c.Request.Context(), chat.UpdateChatRoomInput{
+		RoomID:           roomID,
+		UserID:           userID.(string),
+		Name:             req.Name,
+		Description:      req.Description,
+		IsPrivate:        req.IsPrivate,
+		AnnouncementOnly: req.AnnouncementOnly,
+		SlowModeSeconds:  req.SlowModeSeconds,
+	})
+
+	if err != nil {
+		h.logger.Error("Failed to update chat room", "error", err, "room_id", roomID, "user_id", userID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := ChatRoomResponse{
+		ID:               result.ID,
+		Name:             result.Name,
+		Description:      result.Description,
+		IsPrivate:        result.IsPrivate,
+		CreatedBy:        result.CreatedBy,
+		MemberCount:      result.MemberCount,
+		AnnouncementOnly: result.AnnouncementOnly,
+		SlowModeSeconds:  result.SlowModeSeconds,
+		CreatedAt:        result.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt:        result.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+
+	if h.wsHub != nil {
+		h.wsHub.BroadcastToRoom(roomID, map[string]interface{}{
+			"type":       "room_updated",
+			"room_id":    roomID,
+			"updated_by": userID,
+			"room":       response,
+			"timestamp":  response.UpdatedAt,
+		})
+	}
+
+	h.logger.Info("Chat room updated successfully", "room_id", roomID, "user_id", userID)
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteChatRoom handles deleting a chat room
+func (h *ChatHandler) DeleteChatRoom(c *gin.Context) {
+	roomID := c.Param("id")
+	if roomID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Room ID is required"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		h.logger.Error("User ID not found in context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	result, err := h.chatUseCase.DeleteChatRoom(c.Request.Context(), chat.DeleteChatRoomInput{
+		RoomID: roomID,
+		UserID: userID.(string),
+	})
+
+	if err != nil {
+		h.logger.Error("Failed to delete chat room", "error", err, "room_id", roomID, "user_id", userID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	restorableUntil := result.RestorableUntil.Format("2006-01-02T15:04:05Z07:00")
+
+	// Members lose their live subscription; the room can no longer be joined until restored
+	if h.wsHub != nil {
+		h.wsHub.BroadcastToRoom(roomID, map[string]interface{}{
+			"type":             "room_deleted",
+			"room_id":          roomID,
+			"deleted_by":       userID,
+			"restorable_until": restorableUntil,
+			"timestamp":        result.DeletedAt.Format("2006-01-02T15:04:05Z07:00"),
+		})
+		h.wsHub.CloseRoom(roomID)
+	}
+
+	h.logger.Info("Chat room deleted successfully", "room_id", roomID, "user_id", userID)
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "Chat room deleted successfully",
+		"restorable_until": restorableUntil,
+	})
+}
+
+// RestoreChatRoom handles restoring a deleted chat room within its grace period
+func (h *ChatHandler) RestoreChatRoom(c *gin.Context) {
+	roomID := c.Param("id")
+	if roomID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Room ID is required"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		h.logger.Error("User ID not found in context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	result, err := h.chatUseCase.RestoreChatRoom(c.Request.Context(), chat.RestoreChatRoomInput{
+		RoomID: roomID,
+		UserID: userID.(string),
+	})
+
+	if err != nil {
+		h.logger.Error("Failed to restore chat room", "error", err, "room_id", roomID, "user_id", userID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := ChatRoomResponse{
+		ID:               result.ID,
+		Name:             result.Name,
+		Description:      result.Description,
+		IsPrivate:        result.IsPrivate,
+		CreatedBy:        result.CreatedBy,
+		MemberCount:      result.MemberCount,
+		AnnouncementOnly: result.AnnouncementOnly,
+		SlowModeSeconds:  result.SlowModeSeconds,
+		CreatedAt:        result.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt:        result.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+
+	h.logger.Info("Chat room restored successfully", "room_id", roomID, "user_id", userID)
+	c.JSON(http.StatusOK, response)
+}
+
+// GetSettings handles getting the current user's settings for a chat room
+func (h *ChatHandler) GetSettings(c *gin.Context) {
+	roomID := c.Param("id")
+	if roomID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Room ID is required"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		h.logger.Error("User ID not found in context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	result, err := h.chatUseCase.GetMemberSettings(c.Request.Context(), chat.GetMemberSettingsInput{
+		RoomID: roomID,
+		UserID: userID.(string),
+	})
+
+	if err != nil {
+		h.logger.Error("Failed to get member settings", "error", err, "room_id", roomID, "user_id", userID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, toMemberSettingsResponse(result))
+}
+
+// UpdateSettings handles replacing the current user's settings for a chat room
+func (h *ChatHandler) UpdateSettings(c *gin.Context) {
+	roomID := c.Param("id")
+	if roomID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Room ID is required"})
+		return
+	}
+
+	var req UpdateMemberSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("Invalid update member settings request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		h.logger.Error("User ID not found in context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	result, err := h.chatUseCase.UpdateMemberSettings(c.Request.Context(), chat.UpdateMemberSettingsInput{
+		RoomID:            roomID,
+		UserID:            userID.(string),
+		MutedUntil:        req.MutedUntil,
+		Pinned:            req.Pinned,
+		Archived:          req.Archived,
+		NotificationLevel: req.NotificationLevel,
+	})
+
+	if err != nil {
+		h.logger.Error("Failed to update member settings", "error", err, "room_id", roomID, "user_id", userID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.logger.Info("Member settings updated successfully", "room_id", roomID, "user_id", userID)
+	c.JSON(http.StatusOK, toMemberSettingsResponse(result))
+}
+
+// toMemberSettingsResponse converts member settings output to its response form
+func toMemberSettingsResponse(settings *chat.MemberSettingsOutput) *MemberSettingsResponse {
+	if settings == nil {
+		return nil
+	}
+
+	response := &MemberSettingsResponse{
+		Muted:             settings.Muted,
+		Pinned:            settings.Pinned,
+		Archived:          settings.Archived,
+		NotificationLevel: settings.NotificationLevel,
+	}
+	if settings.MutedUntil != nil {
+		mutedUntil := settings.MutedUntil.Format("2006-01-02T15:04:05Z07:00")
+		response.MutedUntil = &mutedUntil
+	}
+
+	return response
+}
+
+// notifyMembersAdded broadcasts a member_added event to the room and to each new member
+func (h *ChatHandler) notifyMembersAdded(roomID string, memberIDs []string, addedBy string) {
+	if h.wsHub == nil || len(memberIDs) == 0 {
+		return
+	}
+
+	now := time.Now()
+	h.wsHub.BroadcastToRoom(roomID, map[string]interface{}{
+		"type":      "member_added",
+		"room_id":   roomID,
+		"user_ids":  memberIDs,
+		"added_by":  addedBy,
+		"timestamp": now.Format("2006-01-02T15:04:05Z07:00"),
+	})
+
+	// New members are not subscribed to the room yet, so tell them directly
+	for _, memberID := range memberIDs {
+		h.wsHub.SendToUser(memberID, websocket.Message{
+			Type:   "member_added",
+			RoomID: roomID,
+			Data: map[string]interface{}{
+				"user_ids": memberIDs,
+				"added_by": addedBy,
+			},
+			Timestamp: now,
+		})
+	}
+}
+
+// notifyMemberRemoved drops the member's live room subscription and broadcasts a member_removed event
+func (h *ChatHandler) notifyMemberRemoved(roomID, memberID, removedBy, reason string) {
+	if h.wsHub == nil {
+		return
+	}
+
+	now := time.Now()
+	h.wsHub.LeaveRoom(memberID, roomID)
+	h.wsHub.BroadcastToRoom(roomID, map[string]interface{}{
+		"type":       "member_removed",
+		"room_id":    roomID,
+		"user_id":    memberID,
+		"removed_by": removedBy,
+		"reason":     reason,
+		"timestamp":  now.Format("2006-01-02T15:04:05Z07:00"),
+	})
+
+	// The removed member is no longer subscribed, so tell them directly
+	h.wsHub.SendToUser(memberID, websocket.Message{
+		Type:   "member_removed",
+		RoomID: roomID,
+		Data: map[string]interface{}{
+			"user_id":    memberID,
+			"removed_by": removedBy,
+			"reason":     reason,
+		},
+		Timestamp: now,
+	})
 }
\ No newline at end of file
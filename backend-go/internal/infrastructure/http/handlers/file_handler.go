@@ -1,42 +1,69 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
-	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
+	"backend-go/internal/application/file"
+	"backend-go/internal/domain/attachment"
+	"backend-go/internal/domain/chat"
 	"backend-go/internal/infrastructure/http/middleware"
 	"backend-go/internal/shared/logger"
+	"backend-go/internal/shared/storage"
 )
 
+// multipartOverhead allows for the form fields and boundaries around an
+// upload of the maximum size
+const multipartOverhead = 64 << 10
+
+// Resumable uploads follow the core of the tus protocol: the client creates an
+// upload, sends chunks with PATCH at the offset reported by HEAD and finishes
+// it once every byte is stored
+const (
+	uploadOffsetHeader  = "Upload-Offset"
+	uploadLengthHeader  = "Upload-Length"
+	uploadExpiresHeader = "Upload-Expires"
+	chunkContentType    = "application/offset+octet-stream"
+)
+
+// CreateUploadRequest represents the request for starting a direct upload
+type CreateUploadRequest struct {
+	RoomID   string `json:"room_id" binding:"required"`
+	Filename string `json:"filename" binding:"required"`
+	Size     int64  `json:"size" binding:"min=0"`
+}
+
+// UploadByChecksumRequest represents the request for creating a file from
+// content that is already stored
+type UploadByChecksumRequest struct {
+	RoomID   string `json:"room_id" binding:"required"`
+	Filename string `json:"filename" binding:"required"`
+	Size     int64  `json:"size" binding:"min=0"`
+	SHA256   string `json:"sha256" binding:"required"`
+}
+
 // FileHandler handles file upload and download requests
 type FileHandler struct {
-	uploadPath string
-	maxSize    int64
-	logger     *logger.Logger
+	fileUseCase file.UseCase
+	settings    file.UploadSettings
+	logger      logger.Logger
 }
 
 // NewFileHandler creates a new file handler
-func NewFileHandler(uploadPath string, maxSize int64, logger *logger.Logger) *FileHandler {
-	// Create upload directory if it doesn't exist
-	if err := os.MkdirAll(uploadPath, 0755); err != nil {
-		logger.WithError(err).Error("Failed to create upload directory")
-	}
-
+func NewFileHandler(fileUseCase file.UseCase, settings file.UploadSettings, logger logger.Logger) *FileHandler {
 	return &FileHandler{
-		uploadPath: uploadPath,
-		maxSize:    maxSize,
-		logger:     logger,
+		fileUseCase: fileUseCase,
+		settings:    settings,
+		logger:      logger,
 	}
 }
 
-// UploadFile handles file upload
+// UploadFile handles uploading a file to a chat room
 func (h *FileHandler) UploadFile(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
@@ -46,166 +73,279 @@ func (h *FileHandler) UploadFile(c *gin.Context) {
 		return
 	}
 
-	// Parse multipart form
-	if err := c.Request.ParseMultipartForm(h.maxSize); err != nil {
+	// Stop reading oversized bodies early; the use case enforces the exact limit
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.settings.MaxSize+multipartOverhead)
+
+	upload, header, err := c.Request.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			h.respondError(c, attachment.ErrFileTooLarge, h.settings.MaxSize)
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "file too large or invalid form",
+			"error": "no file provided",
 		})
 		return
 	}
+	defer upload.Close()
 
-	file, header, err := c.Request.FormFile("file")
-	if err != nil {
+	roomID := c.PostForm("room_id")
+	if roomID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "no file provided",
+			"error": "room ID is required",
 		})
 		return
 	}
-	defer file.Close()
 
-	// Validate file size
-	if header.Size > h.maxSize {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": fmt.Sprintf("file size exceeds maximum allowed size of %d bytes", h.maxSize),
+	result, err := h.fileUseCase.UploadFile(c.Request.Context(), file.UploadFileInput{
+		UserID:     userID,
+		ChatRoomID: roomID,
+		Filename:   header.Filename,
+		Size:       header.Size,
+		Content:    upload,
+	})
+	if err != nil {
+		h.logger.Error("Failed to upload file", "error", err, "room_id", roomID, "user_id", userID)
+		h.respondError(c, err, h.settings.MaxSize)
+		return
+	}
+
+	c.JSON(http.StatusCreated, result)
+}
+
+// CreateUpload handles starting an upload straight to storage with a pre-signed URL
+func (h *FileHandler) CreateUpload(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "user not authenticated",
 		})
 		return
 	}
 
-	// Validate file type
-	if !h.isAllowedFileType(header.Filename) {
+	var req CreateUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "file type not allowed",
+			"error": "invalid request data",
 		})
 		return
 	}
 
-	// Generate unique filename
-	fileID := uuid.New().String()
-	ext := filepath.Ext(header.Filename)
-	filename := fileID + ext
-	filePath := filepath.Join(h.uploadPath, filename)
-
-	// Create destination file
-	dst, err := os.Create(filePath)
+	result, err := h.fileUseCase.CreateUpload(c.Request.Context(), file.CreateUploadInput{
+		UserID:     userID,
+		ChatRoomID: req.RoomID,
+		Filename:   req.Filename,
+		Size:       req.Size,
+	})
 	if err != nil {
-		h.logger.WithError(err).Error("Failed to create destination file")
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to save file",
-		})
+		h.logger.Error("Failed to create upload", "error", err, "room_id", req.RoomID, "user_id", userID)
+		h.respondError(c, err, h.settings.MaxSize)
 		return
 	}
-	defer dst.Close()
 
-	// Copy file content
-	if _, err := io.Copy(dst, file); err != nil {
-		h.logger.WithError(err).Error("Failed to copy file content")
-		os.Remove(filePath) // Clean up on error
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to save file",
+	c.JSON(http.StatusCreated, result)
+}
+
+// CompleteUpload handles finishing an upload made with a pre-signed URL
+func (h *FileHandler) CompleteUpload(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "user not authenticated",
 		})
 		return
 	}
 
-	h.logger.LogUserAction(userID, "upload_file", map[string]interface{}{
-		"file_id":       fileID,
-		"filename":      header.Filename,
-		"size":          header.Size,
-		"content_type":  header.Header.Get("Content-Type"),
+	result, err := h.fileUseCase.CompleteUpload(c.Request.Context(), file.CompleteUploadInput{
+		FileID: c.Param("id"),
+		UserID: userID,
 	})
+	if err != nil {
+		h.logger.Error("Failed to complete upload", "error", err, "file_id", c.Param("id"), "user_id", userID)
+		h.respondError(c, err, h.settings.MaxSize)
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"file_id":      fileID,
-		"filename":     header.Filename,
-		"size":         header.Size,
-		"content_type": header.Header.Get("Content-Type"),
-		"upload_url":   fmt.Sprintf("/api/v1/files/%s", fileID),
-		"uploaded_at":  time.Now(),
-	})
+	c.JSON(http.StatusOK, result)
 }
 
-// DownloadFile handles file download
-func (h *FileHandler) DownloadFile(c *gin.Context) {
-	fileID := c.Param("id")
-	if fileID == "" {
+// UploadByChecksum handles creating a file from stored content with the same
+// SHA-256, so the client can skip uploading it; 404 means it must upload
+func (h *FileHandler) UploadByChecksum(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "user not authenticated",
+		})
+		return
+	}
+
+	var req UploadByChecksumRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "file ID is required",
+			"error": "invalid request data",
 		})
 		return
 	}
 
-	// Find file with any extension
-	files, err := filepath.Glob(filepath.Join(h.uploadPath, fileID+".*"))
-	if err != nil || len(files) == 0 {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "file not found",
+	result, err := h.fileUseCase.UploadByChecksum(c.Request.Context(), file.UploadByChecksumInput{
+		UserID:     userID,
+		ChatRoomID: req.RoomID,
+		Filename:   req.Filename,
+		Size:       req.Size,
+		Checksum:   req.SHA256,
+	})
+	if err != nil {
+		if err != attachment.ErrBlobNotFound {
+			h.logger.Error("Failed to upload file by checksum", "error", err, "room_id", req.RoomID, "user_id", userID)
+		}
+		h.respondError(c, err, max(h.settings.MaxSize, h.settings.ResumableMaxSize))
+		return
+	}
+
+	c.JSON(http.StatusCreated, result)
+}
+
+// CreateResumableUpload handles starting an upload that is sent in chunks
+func (h *FileHandler) CreateResumableUpload(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "user not authenticated",
 		})
 		return
 	}
 
-	filePath := files[0]
-	filename := filepath.Base(filePath)
+	var req CreateUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid request data",
+		})
+		return
+	}
 
-	// Get file info
-	fileInfo, err := os.Stat(filePath)
+	result, err := h.fileUseCase.CreateResumableUpload(c.Request.Context(), file.CreateUploadInput{
+		UserID:     userID,
+		ChatRoomID: req.RoomID,
+		Filename:   req.Filename,
+		Size:       req.Size,
+	})
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "file not found",
-		})
+		h.logger.Error("Failed to create resumable upload", "error", err, "room_id", req.RoomID, "user_id", userID)
+		h.respondError(c, err, h.settings.ResumableMaxSize)
+		return
+	}
+
+	c.Header("Location", c.Request.URL.Path+"/"+result.File.ID)
+	setUploadHeaders(c, result)
+	c.JSON(http.StatusCreated, result)
+}
+
+// GetResumableUpload handles reporting how much of a resumable upload is stored
+func (h *FileHandler) GetResumableUpload(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.Status(http.StatusUnauthorized)
 		return
 	}
 
-	// Set headers
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
-	c.Header("Content-Type", h.getContentType(filename))
-	c.Header("Content-Length", fmt.Sprintf("%d", fileInfo.Size()))
+	result, err := h.fileUseCase.GetResumableUpload(c.Request.Context(), file.ResumableUploadInput{
+		FileID: c.Param("id"),
+		UserID: userID,
+	})
+	if err != nil {
+		h.logger.Error("Failed to get resumable upload", "error", err, "file_id", c.Param("id"), "user_id", userID)
+		// HEAD responses carry no body, so only the status is meaningful
+		h.respondError(c, err, h.settings.ResumableMaxSize)
+		return
+	}
 
-	// Serve file
-	c.File(filePath)
+	// Progress changes with every chunk
+	c.Header("Cache-Control", "no-store")
+	setUploadHeaders(c, result)
+	c.Status(http.StatusOK)
 }
 
-// GetFileInfo handles getting file information
-func (h *FileHandler) GetFileInfo(c *gin.Context) {
-	fileID := c.Param("id")
-	if fileID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "file ID is required",
+// WriteChunk handles appending a chunk to a resumable upload
+func (h *FileHandler) WriteChunk(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "user not authenticated",
 		})
 		return
 	}
 
-	// Find file with any extension
-	files, err := filepath.Glob(filepath.Join(h.uploadPath, fileID+".*"))
-	if err != nil || len(files) == 0 {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "file not found",
+	if c.ContentType() != chunkContentType {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"error": "content type must be " + chunkContentType,
 		})
 		return
 	}
+	offset, err := strconv.ParseInt(c.GetHeader(uploadOffsetHeader), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid upload offset",
+		})
+		return
+	}
+	// The chunk is stored with its exact size, so it must be known up front
+	if c.Request.ContentLength < 0 {
+		c.JSON(http.StatusLengthRequired, gin.H{
+			"error": "content length is required",
+		})
+		return
+	}
+	if c.Request.ContentLength > h.settings.ChunkMaxSize {
+		h.respondError(c, attachment.ErrChunkTooLarge, h.settings.ResumableMaxSize)
+		return
+	}
 
-	filePath := files[0]
-	filename := filepath.Base(filePath)
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.settings.ChunkMaxSize)
 
-	// Get file info
-	fileInfo, err := os.Stat(filePath)
+	result, err := h.fileUseCase.WriteChunk(c.Request.Context(), file.WriteChunkInput{
+		FileID:  c.Param("id"),
+		UserID:  userID,
+		Offset:  offset,
+		Size:    c.Request.ContentLength,
+		Content: c.Request.Body,
+	})
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "file not found",
+		h.logger.Error("Failed to write upload chunk", "error", err, "file_id", c.Param("id"), "user_id", userID, "offset", offset)
+		h.respondError(c, err, h.settings.ResumableMaxSize)
+		return
+	}
+
+	setUploadHeaders(c, result)
+	c.Status(http.StatusNoContent)
+}
+
+// CompleteResumableUpload handles finishing a resumable upload once every chunk is stored
+func (h *FileHandler) CompleteResumableUpload(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "user not authenticated",
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"file_id":      fileID,
-		"filename":     filename,
-		"size":         fileInfo.Size(),
-		"content_type": h.getContentType(filename),
-		"modified_at":  fileInfo.ModTime(),
-		"download_url": fmt.Sprintf("/api/v1/files/%s", fileID),
+	result, err := h.fileUseCase.CompleteResumableUpload(c.Request.Context(), file.ResumableUploadInput{
+		FileID: c.Param("id"),
+		UserID: userID,
 	})
+	if err != nil {
+		h.logger.Error("Failed to complete resumable upload", "error", err, "file_id", c.Param("id"), "user_id", userID)
+		h.respondError(c, err, h.settings.ResumableMaxSize)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
 }
 
-// DeleteFile handles file deletion
-func (h *FileHandler) DeleteFile(c *gin.Context) {
+// DownloadFile handles downloading a file's content
+func (h *FileHandler) DownloadFile(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{
@@ -214,141 +354,247 @@ func (h *FileHandler) DeleteFile(c *gin.Context) {
 		return
 	}
 
-	fileID := c.Param("id")
-	if fileID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "file ID is required",
+	result, err := h.fileUseCase.OpenFile(c.Request.Context(), file.GetFileInput{
+		FileID: c.Param("id"),
+		UserID: userID,
+	})
+	if err != nil {
+		h.logger.Error("Failed to open file", "error", err, "file_id", c.Param("id"), "user_id", userID)
+		h.respondError(c, err, h.settings.MaxSize)
+		return
+	}
+
+	// Always download rather than render, and never run scripts the file may
+	// contain; pre-signed links carry the disposition themselves
+	if result.URL == "" {
+		c.Header("Content-Disposition", storage.ContentDisposition(result.File.OriginalName))
+		c.Header("Content-Security-Policy", "default-src 'none'; sandbox")
+	}
+	h.sendContent(c, result, result.File.MimeType, contentETag(result.File.Checksum, ""))
+}
+
+// DownloadThumbnail handles downloading the thumbnail of an image
+func (h *FileHandler) DownloadThumbnail(c *gin.Context) {
+	h.downloadVariant(c, attachment.VariantThumbnail)
+}
+
+// DownloadPreview handles downloading the preview of an image
+func (h *FileHandler) DownloadPreview(c *gin.Context) {
+	h.downloadVariant(c, attachment.VariantPreview)
+}
+
+// downloadVariant sends a rendered version of an image, which is safe to show inline
+func (h *FileHandler) downloadVariant(c *gin.Context, variant string) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "user not authenticated",
 		})
 		return
 	}
 
-	// Find file with any extension
-	files, err := filepath.Glob(filepath.Join(h.uploadPath, fileID+".*"))
-	if err != nil || len(files) == 0 {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "file not found",
+	result, err := h.fileUseCase.OpenThumbnail(c.Request.Context(), file.OpenThumbnailInput{
+		FileID:  c.Param("id"),
+		UserID:  userID,
+		Variant: variant,
+	})
+	if err != nil {
+		h.logger.Error("Failed to open thumbnail", "error", err, "file_id", c.Param("id"), "user_id", userID, "variant", variant)
+		h.respondError(c, err, h.settings.MaxSize)
+		return
+	}
+
+	h.sendContent(c, result, result.File.RenderType, contentETag(result.File.Checksum, variant))
+}
+
+// sendContent redirects to a pre-signed download link or sends opened
+// content, answering range and conditional requests. The content behind a
+// file never changes, so clients may cache it for good.
+func (h *FileHandler) sendContent(c *gin.Context, result *file.OpenFileOutput, contentType, etag string) {
+	if result.Content != nil {
+		defer result.Content.Close()
+	}
+
+	// Clients that kept the content need not be redirected again
+	c.Header("ETag", etag)
+	if notModified(c.Request, etag, result.File.CreatedAt) {
+		c.Header("Cache-Control", file.ContentCacheControl)
+		c.Header("Last-Modified", result.File.CreatedAt.UTC().Format(http.TimeFormat))
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	// Pre-signed links expire, so the redirect itself must not be cached
+	if result.URL != "" {
+		c.Header("Cache-Control", "no-store")
+		c.Redirect(http.StatusFound, result.URL)
+		return
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Cache-Control", file.ContentCacheControl)
+	http.ServeContent(c.Writer, c.Request, "", result.File.CreatedAt, result.Content)
+}
+
+// GetFileInfo handles getting file information
+func (h *FileHandler) GetFileInfo(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "user not authenticated",
 		})
 		return
 	}
 
-	filePath := files[0]
+	result, err := h.fileUseCase.GetFile(c.Request.Context(), file.GetFileInput{
+		FileID: c.Param("id"),
+		UserID: userID,
+	})
+	if err != nil {
+		h.logger.Error("Failed to get file", "error", err, "file_id", c.Param("id"), "user_id", userID)
+		h.respondError(c, err, h.settings.MaxSize)
+		return
+	}
 
-	// Delete file
-	if err := os.Remove(filePath); err != nil {
-		h.logger.WithError(err).Error("Failed to delete file")
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to delete file",
+	c.JSON(http.StatusOK, result)
+}
+
+// DeleteFile handles file deletion
+func (h *FileHandler) DeleteFile(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "user not authenticated",
 		})
 		return
 	}
 
-	h.logger.LogUserAction(userID, "delete_file", map[string]interface{}{
-		"file_id": fileID,
+	err := h.fileUseCase.DeleteFile(c.Request.Context(), file.DeleteFileInput{
+		FileID: c.Param("id"),
+		UserID: userID,
 	})
+	if err != nil {
+		h.logger.Error("Failed to delete file", "error", err, "file_id", c.Param("id"), "user_id", userID)
+		h.respondError(c, err, h.settings.MaxSize)
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "file deleted successfully",
 	})
 }
 
-// isAllowedFileType checks if the file type is allowed
-func (h *FileHandler) isAllowedFileType(filename string) bool {
-	ext := strings.ToLower(filepath.Ext(filename))
-	
-	allowedTypes := map[string]bool{
-		// Images
-		".jpg":  true,
-		".jpeg": true,
-		".png":  true,
-		".gif":  true,
-		".webp": true,
-		".svg":  true,
-		
-		// Documents
-		".pdf":  true,
-		".doc":  true,
-		".docx": true,
-		".xls":  true,
-		".xlsx": true,
-		".ppt":  true,
-		".pptx": true,
-		".txt":  true,
-		".rtf":  true,
-		
-		// Archives
-		".zip":  true,
-		".rar":  true,
-		".7z":   true,
-		".tar":  true,
-		".gz":   true,
-		
-		// Audio
-		".mp3":  true,
-		".wav":  true,
-		".ogg":  true,
-		".m4a":  true,
-		
-		// Video
-		".mp4":  true,
-		".avi":  true,
-		".mov":  true,
-		".wmv":  true,
-		".flv":  true,
-		".webm": true,
-	}
-
-	return allowedTypes[ext]
+// GetStorageUsage handles reading the storage taken by the user's files and their quota
+func (h *FileHandler) GetStorageUsage(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "user not authenticated",
+		})
+		return
+	}
+
+	result, err := h.fileUseCase.GetStorageUsage(c.Request.Context(), file.GetStorageUsageInput{
+		UserID: userID,
+	})
+	if err != nil {
+		h.logger.Error("Failed to get storage usage", "error", err, "user_id", userID)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to get storage usage",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// respondError maps file use case errors to HTTP responses; maxSize is the
+// file size limit that applied to the request
+func (h *FileHandler) respondError(c *gin.Context, err error, maxSize int64) {
+	var offsetErr *file.OffsetMismatchError
+	var sizeErr *file.FileTooLargeError
+	var quotaErr *file.QuotaExceededError
+	switch {
+	case errors.As(err, &offsetErr):
+		c.Header(uploadOffsetHeader, strconv.FormatInt(offsetErr.Offset, 10))
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, attachment.ErrAttachmentNotFound), errors.Is(err, attachment.ErrSessionNotFound),
+		errors.Is(err, attachment.ErrThumbnailNotFound), errors.Is(err, attachment.ErrBlobNotFound),
+		errors.Is(err, chat.ErrChatRoomNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, attachment.ErrNotAuthorized), errors.Is(err, attachment.ErrFileQuarantined),
+		errors.Is(err, attachment.ErrScanFailed):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, attachment.ErrScanPending):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, storage.ErrPresignNotSupported):
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "direct uploads are not supported by the configured storage"})
+	case errors.As(err, &sizeErr):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":    err.Error(),
+			"max_size": sizeErr.MaxSize,
+		})
+	case errors.As(err, &quotaErr):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":     err.Error(),
+			"scope":     quotaErr.Scope,
+			"quota":     quotaErr.Quota,
+			"used":      quotaErr.Used,
+			"remaining": quotaErr.Remaining(),
+		})
+	case errors.Is(err, attachment.ErrUserQuotaExceeded), errors.Is(err, attachment.ErrRoomQuotaExceeded):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, attachment.ErrFileTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": fmt.Sprintf("file size exceeds maximum allowed size of %d bytes", maxSize),
+		})
+	case errors.Is(err, attachment.ErrContentMismatch):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+	case errors.Is(err, attachment.ErrChunkTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": fmt.Sprintf("chunk exceeds maximum allowed size of %d bytes or the declared upload length", h.settings.ChunkMaxSize),
+		})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
+
+// contentETag returns a strong entity tag for stored content, derived from
+// its checksum and the rendered variant, if any
+func contentETag(checksum, variant string) string {
+	if variant != "" {
+		return `"` + checksum + "-" + variant + `"`
+	}
+	return `"` + checksum + `"`
+}
+
+// notModified checks if a GET or HEAD request is conditional on validators
+// that still match, as http.ServeContent does. If-None-Match takes
+// precedence over If-Modified-Since.
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			// Weak comparison, as for any GET
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return err == nil && !modified.Truncate(time.Second).After(since)
 }
 
-// getContentType returns the content type based on file extension
-func (h *FileHandler) getContentType(filename string) string {
-	ext := strings.ToLower(filepath.Ext(filename))
-	
-	contentTypes := map[string]string{
-		// Images
-		".jpg":  "image/jpeg",
-		".jpeg": "image/jpeg",
-		".png":  "image/png",
-		".gif":  "image/gif",
-		".webp": "image/webp",
-		".svg":  "image/svg+xml",
-		
-		// Documents
-		".pdf":  "application/pdf",
-		".doc":  "application/msword",
-		".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
-		".xls":  "application/vnd.ms-excel",
-		".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
-		".ppt":  "application/vnd.ms-powerpoint",
-		".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
-		".txt":  "text/plain",
-		".rtf":  "application/rtf",
-		
-		// Archives
-		".zip":  "application/zip",
-		".rar":  "application/x-rar-compressed",
-		".7z":   "application/x-7z-compressed",
-		".tar":  "application/x-tar",
-		".gz":   "application/gzip",
-		
-		// Audio
-		".mp3":  "audio/mpeg",
-		".wav":  "audio/wav",
-		".ogg":  "audio/ogg",
-		".m4a":  "audio/mp4",
-		
-		// Video
-		".mp4":  "video/mp4",
-		".avi":  "video/x-msvideo",
-		".mov":  "video/quicktime",
-		".wmv":  "video/x-ms-wmv",
-		".flv":  "video/x-flv",
-		".webm": "video/webm",
-	}
-
-	if contentType, exists := contentTypes[ext]; exists {
-		return contentType
-	}
-
-	return "application/octet-stream"
+// setUploadHeaders reports the progress of a resumable upload in tus headers
+func setUploadHeaders(c *gin.Context, upload *file.ResumableUploadOutput) {
+	c.Header(uploadOffsetHeader, strconv.FormatInt(upload.Offset, 10))
+	c.Header(uploadLengthHeader, strconv.FormatInt(upload.File.Size, 10))
+	c.Header(uploadExpiresHeader, upload.ExpiresAt.UTC().Format(http.TimeFormat))
 }
\ No newline at end of file
@@ -1,11 +1,14 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"backend-go/internal/application/message"
+	"backend-go/internal/infrastructure/websocket"
 	"backend-go/internal/shared/logger"
 )
 
@@ -18,6 +21,7 @@ type MessageHandler struct {
 // WebSocketHub interface for WebSocket operations
 type WebSocketHub interface {
 	BroadcastToRoom(roomID string, message interface{})
+	SendToUser(userID string, message websocket.Message)
 }
 
 func NewMessageHandler(messageUseCase message.UseCase, wsHub WebSocketHub, logger logger.Logger) *MessageHandler {
@@ -29,8 +33,9 @@ func NewMessageHandler(messageUseCase message.UseCase, wsHub WebSocketHub, logge
 }
 
 type SendMessageRequest struct {
-	Content string `json:"content" binding:"required,min=1"`
-	Type    string `json:"type,omitempty"` // text, image, file, etc.
+	Content       string   `json:"content"`        // Optional caption when files are attached
+	Type          string   `json:"type,omitempty"` // text, image, file, etc.
+	AttachmentIDs []string `json:"attachment_ids,omitempty"`
 }
 
 type MessageResponse struct {
@@ -40,13 +45,51 @@ type MessageResponse struct {
 	Content   string `json:"content"`
 	Type      string `json:"type"`
 	Status    string `json:"status"`
+	SentByBot bool   `json:"sent_by_bot"`
 	CreatedAt string `json:"created_at"`
 	UpdatedAt string `json:"updated_at"`
+
+	Attachments []AttachmentResponse `json:"attachments,omitempty"`
+}
+
+// AttachmentResponse describes a file sent with a message and where to fetch it
+type AttachmentResponse struct {
+	ID           string `json:"id"`
+	OriginalName string `json:"original_name"`
+	Size         int64  `json:"size"`
+	MimeType     string `json:"mime_type"`
+	Width        int    `json:"width,omitempty"`
+	Height       int    `json:"height,omitempty"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url,omitempty"`
+	PreviewURL   string `json:"preview_url,omitempty"`
+}
+
+// attachmentResponses links message attachments to the file download endpoints
+func attachmentResponses(attachments []*message.AttachmentOutput) []AttachmentResponse {
+	var result []AttachmentResponse
+	for _, a := range attachments {
+		response := AttachmentResponse{
+			ID:           a.ID,
+			OriginalName: a.OriginalName,
+			Size:         a.Size,
+			MimeType:     a.MimeType,
+			Width:        a.Width,
+			Height:       a.Height,
+			URL:          "/api/v1/files/" + a.ID + "/download",
+		}
+		if a.HasThumbnail {
+			response.ThumbnailURL = "/api/v1/files/" + a.ID + "/thumbnail"
+			response.PreviewURL = "/api/v1/files/" + a.ID + "/preview"
+		}
+		result = append(result, response)
+	}
+	return result
 }
 
 // SendMessage handles sending a message to a chat room
 func (h *MessageHandler) SendMessage(c *gin.Context) {
-	roomID := c.Param("room_id")
+	roomID := c.Param("id")
 	if roomID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Room ID is required"})
 		return
@@ -73,14 +116,27 @@ func (h *MessageHandler) SendMessage(c *gin.Context) {
 	}
 
 	result, err := h.messageUseCase.SendMessage(c.Request.Context(), message.SendMessageInput{
-		ChatRoomID: roomID,
-		SenderID:   userID.(string),
-		Content:    req.Content,
-		Type:       messageType,
+		ChatRoomID:    roomID,
+		SenderID:      userID.(string),
+		Content:       req.Content,
+		Type:          messageType,
+		SentByBot:     c.GetBool("is_bot"),
+		AttachmentIDs: req.AttachmentIDs,
 	})
 
 	if err != nil {
 		h.logger.Error("Failed to send message", "error", err, "room_id", roomID, "user_id", userID)
+
+		var slowModeErr *message.SlowModeError
+		if errors.As(err, &slowModeErr) {
+			c.Header("Retry-After", strconv.Itoa(slowModeErr.RetryAfterSeconds()))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":       err.Error(),
+				"retry_after": slowModeErr.RetryAfterSeconds(),
+			})
+			return
+		}
+
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -92,32 +148,81 @@ func (h *MessageHandler) SendMessage(c *gin.Context) {
 		Content:    result.Content,
 		Type:       result.Type,
 		Status:     result.Status,
+		SentByBot:  result.SentByBot,
 		CreatedAt:  result.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
 		UpdatedAt:  result.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
-	}
 
-	// Broadcast message to WebSocket clients in the room
-	if h.wsHub != nil {
-		wsMessage := map[string]interface{}{
-			"type":        "new_message",
-			"message_id":  result.ID,
-			"room_id":     result.ChatRoomID,
-			"sender_id":   result.SenderID,
-			"content":     result.Content,
-			"message_type": result.Type,
-			"status":      result.Status,
-			"created_at":  result.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-		}
-		h.wsHub.BroadcastToRoom(roomID, wsMessage)
+		Attachments: attachmentResponses(result.Attachments),
 	}
 
+	h.publishMessage(result)
+
 	h.logger.Info("Message sent successfully", "message_id", result.ID, "room_id", roomID, "user_id", userID)
 	c.JSON(http.StatusCreated, response)
 }
 
+// SendSocketMessage sends a text message received over a WebSocket connection,
+// applying the same checks and delivery as SendMessage
+func (h *MessageHandler) SendSocketMessage(ctx context.Context, roomID, userID, content string) error {
+	result, err := h.messageUseCase.SendMessage(ctx, message.SendMessageInput{
+		ChatRoomID: roomID,
+		SenderID:   userID,
+		Content:    content,
+		Type:       "text",
+	})
+	if err != nil {
+		h.logger.Error("Failed to send socket message", "error", err, "room_id", roomID, "user_id", userID)
+		return err
+	}
+
+	h.publishMessage(result)
+
+	h.logger.Info("Message sent successfully", "message_id", result.ID, "room_id", roomID, "user_id", userID)
+	return nil
+}
+
+// publishMessage broadcasts a new message to the room and notifies members
+func (h *MessageHandler) publishMessage(result *message.SendMessageOutput) {
+	if h.wsHub == nil {
+		return
+	}
+
+	wsMessage := map[string]interface{}{
+		"type":         "new_message",
+		"message_id":   result.ID,
+		"room_id":      result.ChatRoomID,
+		"sender_id":    result.SenderID,
+		"content":      result.Content,
+		"message_type": result.Type,
+		"status":       result.Status,
+		"sent_by_bot":  result.SentByBot,
+		"created_at":   result.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+	if len(result.Attachments) > 0 {
+		wsMessage["attachments"] = attachmentResponses(result.Attachments)
+	}
+	h.wsHub.BroadcastToRoom(result.ChatRoomID, wsMessage)
+
+	// Notify members according to their mute and notification settings
+	for _, recipientID := range result.NotifyUserIDs {
+		h.wsHub.SendToUser(recipientID, websocket.Message{
+			Type:     "notification",
+			RoomID:   result.ChatRoomID,
+			SenderID: result.SenderID,
+			Content:  result.Content,
+			Data: map[string]interface{}{
+				"message_id":       result.ID,
+				"message_type":     result.Type,
+				"attachment_count": len(result.Attachments),
+			},
+			Timestamp: result.CreatedAt,
+		})
+	}
+}
+
 // GetMessages handles getting messages from a chat room
 func (h *MessageHandler) GetMessages(c *gin.Context) {
-	roomID := c.Param("room_id")
+	roomID := c.Param("id")
 	if roomID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Room ID is required"})
 		return
@@ -170,8 +275,11 @@ func (h *MessageHandler) GetMessages(c *gin.Context) {
 			Content:    msg.Content,
 			Type:       msg.Type,
 			Status:     msg.Status,
+			SentByBot:  msg.SentByBot,
 			CreatedAt:  msg.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
 			UpdatedAt:  msg.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+
+			Attachments: attachmentResponses(msg.Attachments),
 		})
 	}
 
@@ -223,8 +331,11 @@ func (h *MessageHandler) GetMessage(c *gin.Context) {
 		Content:    result.Content,
 		Type:       result.Type,
 		Status:     result.Status,
+		SentByBot:  result.SentByBot,
 		CreatedAt:  result.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
 		UpdatedAt:  result.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+
+		Attachments: attachmentResponses(result.Attachments),
 	}
 
 	h.logger.Info("Message retrieved successfully", "message_id", messageID, "user_id", userID)
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"backend-go/internal/domain/user"
+)
+
+// APIKeyGrant is the bot an API key authenticates, and what the key allows
+type APIKeyGrant struct {
+	UserID  string
+	KeyID   string
+	Scopes  []string
+	RoomIDs []string // Empty allows every room the bot is a member of
+}
+
+// APIKeyAuthenticator resolves an API key presented instead of a JWT,
+// returning user.ErrAPIKeyInvalid for unknown or inactive keys
+type APIKeyAuthenticator func(ctx context.Context, key string) (*APIKeyGrant, error)
+
+// apiKeyRoutes lists the only routes API keys may be used on, and the scope
+// each needs. Every route listed takes the chat room ID as its :id parameter.
+var apiKeyRoutes = map[string]string{
+	"GET /api/v1/chatrooms/:id":           user.ScopeRoomsRead,
+	"GET /api/v1/chatrooms/:id/messages":  user.ScopeMessagesRead,
+	"POST /api/v1/chatrooms/:id/messages": user.ScopeMessagesWrite,
+}
+
+// authenticateAPIKey checks an API key and its scope and rooms for the route
+func authenticateAPIKey(c *gin.Context, apiKeys APIKeyAuthenticator, key string) {
+	scope, allowed := apiKeyRoutes[c.Request.Method+" "+c.FullPath()]
+	if apiKeys == nil || !allowed {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "API keys cannot be used for this endpoint",
+		})
+		c.Abort()
+		return
+	}
+
+	grant, err := apiKeys(c.Request.Context(), key)
+	if err != nil {
+		if errors.Is(err, user.ErrAPIKeyInvalid) {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "invalid, expired or revoked API key",
+			})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "failed to authenticate API key",
+			})
+		}
+		c.Abort()
+		return
+	}
+
+	if !containsString(grant.Scopes, scope) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "API key lacks the " + scope + " scope",
+		})
+		c.Abort()
+		return
+	}
+
+	if len(grant.RoomIDs) > 0 && !containsString(grant.RoomIDs, c.Param("id")) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "API key is not allowed in this chat room",
+		})
+		c.Abort()
+		return
+	}
+
+	// Set bot identity in context
+	c.Set("user_id", grant.UserID)
+	c.Set("api_key_id", grant.KeyID)
+	c.Set("is_bot", true)
+
+	c.Next()
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
\ No newline at end of file
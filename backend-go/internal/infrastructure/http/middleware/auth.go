@@ -5,11 +5,13 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"backend-go/internal/domain/user"
 	"backend-go/internal/shared/jwt"
 )
 
-// Auth middleware validates JWT tokens
-func Auth(jwtService jwt.Service) gin.HandlerFunc {
+// Auth middleware validates JWT tokens, and bot API keys on the routes that
+// allow them when apiKeys is set
+func Auth(jwtService jwt.Service, apiKeys APIKeyAuthenticator) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get token from Authorization header
 		authHeader := c.GetHeader("Authorization")
@@ -33,6 +35,11 @@ func Auth(jwtService jwt.Service) gin.HandlerFunc {
 		// Extract token
 		token := authHeader[7:] // Remove "Bearer " prefix
 
+		if strings.HasPrefix(token, user.APIKeyTokenPrefix) {
+			authenticateAPIKey(c, apiKeys, token)
+			return
+		}
+
 		// Validate token
 		claims, err := jwtService.ValidateToken(token)
 		if err != nil {
@@ -55,6 +62,9 @@ func Auth(jwtService jwt.Service) gin.HandlerFunc {
 		// Set user ID in context
 		c.Set("user_id", userID)
 		c.Set("token", token)
+		if sessionID, ok := claims["sid"].(string); ok && sessionID != "" {
+			c.Set("session_id", sessionID)
+		}
 
 		c.Next()
 	}
@@ -90,6 +100,9 @@ func OptionalAuth(jwtService jwt.Service) gin.HandlerFunc {
 		// Set user ID in context if token is valid
 		c.Set("user_id", userID)
 		c.Set("token", token)
+		if sessionID, ok := claims["sid"].(string); ok && sessionID != "" {
+			c.Set("session_id", sessionID)
+		}
 
 		c.Next()
 	}
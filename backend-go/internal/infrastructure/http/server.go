@@ -1,14 +1,22 @@
 package http
 
 import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	
 	"backend-go/internal/application/auth"
+	"backend-go/internal/application/bot"
 	"backend-go/internal/application/chat"
+	"backend-go/internal/application/file"
 	"backend-go/internal/application/message"
+	"backend-go/internal/infrastructure/cache"
 	"backend-go/internal/infrastructure/database/postgres"
 	"backend-go/internal/infrastructure/database/redis"
 	"backend-go/internal/infrastructure/database/postgres/repositories"
@@ -18,6 +26,12 @@ import (
 	"backend-go/internal/shared/config"
 	"backend-go/internal/shared/jwt"
 	"backend-go/internal/shared/logger"
+	"backend-go/internal/shared/mail"
+	"backend-go/internal/shared/oidc"
+	"backend-go/internal/shared/password"
+	"backend-go/internal/shared/scan"
+	"backend-go/internal/shared/storage"
+	"backend-go/internal/shared/throttle"
 	"backend-go/internal/shared/validation"
 )
 
@@ -29,6 +43,14 @@ type Server struct {
 	db          *postgres.DB
 	redisClient *redis.Client
 	wsHub       *websocket.Hub
+	jwtService  jwt.Service
+	keyRing     *jwt.KeyRing
+	apiKeys     middleware.APIKeyAuthenticator
+
+	// ctx is cancelled by Close to stop background work, which background tracks
+	ctx        context.Context
+	cancel     context.CancelFunc
+	background sync.WaitGroup
 }
 
 // New creates a new HTTP server
@@ -47,20 +69,60 @@ func New(
 	// Create router
 	router := gin.New()
 
+	// A single JWT service shares one revocation store across all validators
+	revocationStore := cache.NewRevocationStore(redisClient.Client)
+	tokenDuration := time.Duration(cfg.JWT.ExpireHours) * time.Hour
+	jwtService := jwt.NewService(cfg.JWT.Secret, tokenDuration, revocationStore)
+
+	// Asymmetric signing keys are shared through the database and rotated in the background
+	var keyRing *jwt.KeyRing
+	if cfg.JWT.Algorithm != jwt.AlgorithmHS256 {
+		var err error
+		keyRing, err = jwt.NewKeyRing(repositories.NewSigningKeyRepository(db.Pool, *logger), jwt.KeyRingConfig{
+			Algorithm:        cfg.JWT.Algorithm,
+			RotationInterval: cfg.JWT.KeyRotationInterval,
+			Prepublish:       cfg.JWT.KeyPrepublish,
+			Retention:        tokenDuration,
+		}, *logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load JWT signing keys: %w", err)
+		}
+		go keyRing.Run()
+
+		jwtService = jwt.NewKeyRingService(keyRing, cfg.JWT.Secret, tokenDuration, revocationStore)
+	}
+
+	// Re-check tokens of open sockets so revocation also ends live connections
+	wsHub.SetTokenValidator(func(token string) error {
+		_, err := jwtService.ValidateToken(token)
+		if errors.Is(err, jwt.ErrRevocationUnavailable) {
+			return fmt.Errorf("%w: %w", websocket.ErrTokenCheckUnavailable, err)
+		}
+		return err
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
 	server := &Server{
+		ctx:         ctx,
+		cancel:      cancel,
 		config:      cfg,
 		logger:      logger,
 		router:      router,
 		db:          db,
 		redisClient: redisClient,
 		wsHub:       wsHub,
+		jwtService:  jwtService,
+		keyRing:     keyRing,
 	}
 
 	// Setup middleware
 	server.setupMiddleware()
 
 	// Setup routes
-	server.setupRoutes()
+	if err := server.setupRoutes(); err != nil {
+		server.Close()
+		return nil, err
+	}
 
 	return server, nil
 }
@@ -70,6 +132,37 @@ func (s *Server) Handler() http.Handler {
 	return s.router
 }
 
+// Close stops background work started by the server, cancelling runs in
+// progress and waiting for them to return
+func (s *Server) Close() {
+	if s.keyRing != nil {
+		s.keyRing.Stop()
+	}
+	s.cancel()
+	s.background.Wait()
+}
+
+// runPeriodically calls run every interval until the server is closed. run
+// is given a context that is cancelled when the server closes.
+func (s *Server) runPeriodically(interval time.Duration, run func(ctx context.Context)) {
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+				run(s.ctx)
+			}
+		}
+	}()
+}
+
 // setupMiddleware configures middleware
 func (s *Server) setupMiddleware() {
 	// Recovery middleware
@@ -81,7 +174,7 @@ func (s *Server) setupMiddleware() {
 	// CORS middleware (simple CORS for now)
 	s.router.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
 		
 		if c.Request.Method == "OPTIONS" {
@@ -94,19 +187,75 @@ func (s *Server) setupMiddleware() {
 }
 
 // setupRoutes configures routes
-func (s *Server) setupRoutes() {
+func (s *Server) setupRoutes() error {
 	// Health check
 	s.router.GET("/health", s.healthCheck)
 
+	// Runtime and background job metrics, such as garbage collection totals
+	if s.config.Metrics.Enabled {
+		s.router.GET(s.config.Metrics.Path, gin.WrapH(expvar.Handler()))
+	}
+
 	// WebSocket endpoint
 	s.router.GET("/ws", s.websocketHandler)
 
+	// Public keys for verifying access tokens
+	s.router.GET("/.well-known/jwks.json", s.jwks)
+
 	// API routes
 	api := s.router.Group("/api/v1")
 	{
+		// First, since it provides the API key check the other routes authenticate with
+		s.setupBotRoutes(api)
 		s.setupAuthRoutes(api)
 		s.setupChatRoutes(api)
-		// s.setupMessageRoutes(api) // TODO: fix message routes panic
+		s.setupMessageRoutes(api)
+		if err := s.setupFileRoutes(api); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setupBotRoutes configures bot and API key management routes
+func (s *Server) setupBotRoutes(api *gin.RouterGroup) {
+	// Create dependencies
+	userRepo := repositories.NewUserRepository(s.db.Pool, *s.logger)
+	apiKeyRepo := repositories.NewAPIKeyRepository(s.db.Pool, *s.logger)
+	validator := validation.New()
+
+	// Create use case
+	botUseCase := bot.NewUseCase(userRepo, apiKeyRepo, validator, *s.logger)
+
+	// Bots may use their API keys instead of a JWT
+	s.apiKeys = func(ctx context.Context, key string) (*middleware.APIKeyGrant, error) {
+		principal, err := botUseCase.AuthenticateAPIKey(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		return &middleware.APIKeyGrant{
+			UserID:  principal.UserID,
+			KeyID:   principal.KeyID,
+			Scopes:  principal.Scopes,
+			RoomIDs: principal.RoomIDs,
+		}, nil
+	}
+
+	// Create handler
+	botHandler := handlers.NewBotHandler(botUseCase, *s.logger)
+
+	// Bot routes
+	botGroup := api.Group("/bots")
+	botGroup.Use(middleware.Auth(s.jwtService, s.apiKeys))
+	{
+		botGroup.GET("", botHandler.ListBots)
+		botGroup.POST("", botHandler.CreateBot)
+		botGroup.DELETE("/:id", botHandler.DeleteBot)
+		botGroup.GET("/:id/keys", botHandler.ListAPIKeys)
+		botGroup.POST("/:id/keys", botHandler.CreateAPIKey)
+		botGroup.POST("/:id/keys/:key_id/rotate", botHandler.RotateAPIKey)
+		botGroup.DELETE("/:id/keys/:key_id", botHandler.RevokeAPIKey)
 	}
 }
 
@@ -114,14 +263,24 @@ func (s *Server) setupRoutes() {
 func (s *Server) setupAuthRoutes(api *gin.RouterGroup) {
 	// Create dependencies
 	userRepo := repositories.NewUserRepository(s.db.Pool, *s.logger)
-	jwtService := jwt.NewService(s.config.JWT.Secret, time.Duration(s.config.JWT.ExpireHours)*time.Hour)
+	refreshTokenRepo := repositories.NewRefreshTokenRepository(s.db.Pool, *s.logger)
+	sessionRepo := repositories.NewSessionRepository(s.db.Pool, *s.logger)
+	accountTokenRepo := repositories.NewAccountTokenRepository(s.db.Pool, *s.logger)
+	twoFactorRepo := repositories.NewTwoFactorRepository(s.db.Pool, *s.logger)
+	identityRepo := repositories.NewExternalIdentityRepository(s.db.Pool, *s.logger)
+	mailer := s.newMailSender()
 	validator := validation.New()
 
 	// Create use case
-	authUseCase := auth.NewUseCase(userRepo, jwtService, validator, *s.logger)
+	refreshTokenTTL := time.Duration(s.config.JWT.RefreshExpireHours) * time.Hour
+	authUseCase := auth.NewUseCase(
+		userRepo, refreshTokenRepo, sessionRepo, accountTokenRepo, twoFactorRepo, identityRepo,
+		s.jwtService, mailer, validator, *s.logger, refreshTokenTTL, s.config.Mail.AppURL, s.oidcSettings(),
+		s.loginProtection(), s.passwordSettings(),
+	)
 
 	// Create handler
-	authHandler := handlers.NewAuthHandler(authUseCase, *s.logger)
+	authHandler := handlers.NewAuthHandler(authUseCase, s.wsHub, *s.logger)
 
 	// Auth routes
 	authGroup := api.Group("/auth")
@@ -129,8 +288,112 @@ func (s *Server) setupAuthRoutes(api *gin.RouterGroup) {
 		authGroup.POST("/register", authHandler.Register)
 		authGroup.POST("/login", authHandler.Login)
 		authGroup.POST("/refresh", authHandler.RefreshToken)
-		authGroup.POST("/logout", middleware.Auth(jwtService), authHandler.Logout)
+		authGroup.POST("/logout", middleware.Auth(s.jwtService, s.apiKeys), authHandler.Logout)
+		authGroup.GET("/sessions", middleware.Auth(s.jwtService, s.apiKeys), authHandler.ListSessions)
+		authGroup.DELETE("/sessions", middleware.Auth(s.jwtService, s.apiKeys), authHandler.RevokeOtherSessions)
+		authGroup.DELETE("/sessions/:id", middleware.Auth(s.jwtService, s.apiKeys), authHandler.RevokeSession)
+		authGroup.POST("/password/forgot", authHandler.ForgotPassword)
+		authGroup.POST("/password/reset", authHandler.ResetPassword)
+		authGroup.POST("/password/change", middleware.Auth(s.jwtService, s.apiKeys), authHandler.ChangePassword)
+		authGroup.POST("/email/verify", authHandler.VerifyEmail)
+		authGroup.POST("/email/verification", middleware.Auth(s.jwtService, s.apiKeys), authHandler.ResendVerification)
+		authGroup.POST("/2fa/setup", middleware.Auth(s.jwtService, s.apiKeys), authHandler.SetupTwoFactor)
+		authGroup.POST("/2fa/confirm", middleware.Auth(s.jwtService, s.apiKeys), authHandler.ConfirmTwoFactor)
+		authGroup.POST("/2fa/disable", middleware.Auth(s.jwtService, s.apiKeys), authHandler.DisableTwoFactor)
+		authGroup.POST("/2fa/verify", authHandler.VerifyTwoFactor)
+		authGroup.GET("/oidc/authorize", authHandler.StartOIDCLogin)
+		authGroup.POST("/oidc/callback", authHandler.CompleteOIDCLogin)
+		authGroup.POST("/admin/unlock", middleware.Auth(s.jwtService, s.apiKeys), authHandler.UnlockLogin)
+	}
+}
+
+// newMailSender creates the configured outgoing mail sender
+func (s *Server) newMailSender() mail.Sender {
+	cfg := s.config.Mail
+	if cfg.Driver == "smtp" {
+		return mail.NewSMTPSender(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.From)
+	}
+	return mail.NewLogSender(*s.logger, cfg.OutputDir)
+}
+
+// oidcSettings configures single sign-on when an identity provider is set up
+func (s *Server) oidcSettings() auth.OIDCSettings {
+	cfg := s.config.OIDC
+	settings := auth.OIDCSettings{
+		AllowedDomains: cfg.AllowedDomains,
+		AutoProvision:  cfg.AutoProvision,
+	}
+
+	if cfg.Enabled {
+		settings.Provider = oidc.NewProvider(oidc.Config{
+			IssuerURL:    cfg.IssuerURL,
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       cfg.Scopes,
+		}, nil)
+		settings.States = cache.NewOIDCStateStore(s.redisClient.Client)
 	}
+
+	return settings
+}
+
+// loginProtection tracks failed logins in Redis so limits hold across replicas
+func (s *Server) loginProtection() auth.LoginProtection {
+	cfg := s.config.Login
+	store := cache.NewThrottleStore(s.redisClient.Client)
+
+	return auth.LoginProtection{
+		Accounts: throttle.NewLimiter(store, throttle.Policy{
+			Window:           cfg.Window,
+			FreeAttempts:     cfg.FreeAttempts,
+			BaseDelay:        cfg.BaseDelay,
+			MaxDelay:         cfg.MaxDelay,
+			LockoutThreshold: cfg.AccountLockout,
+			LockoutDuration:  cfg.LockoutDuration,
+		}, "account:"),
+		// Many users can share an IP, so it is only ever locked out, never delayed
+		IPs: throttle.NewLimiter(store, throttle.Policy{
+			Window:           cfg.Window,
+			FreeAttempts:     cfg.IPLockout,
+			LockoutThreshold: cfg.IPLockout,
+			LockoutDuration:  cfg.LockoutDuration,
+		}, "ip:"),
+	}
+}
+
+// passwordSettings configures the password policy and hashing
+func (s *Server) passwordSettings() auth.PasswordSettings {
+	cfg := s.config.Password
+	settings := auth.PasswordSettings{
+		Policy: password.Policy{
+			MinLength:     cfg.MinLength,
+			MaxLength:     password.MaxBcryptLength,
+			RequireUpper:  cfg.RequireUpper,
+			RequireLower:  cfg.RequireLower,
+			RequireDigit:  cfg.RequireDigit,
+			RequireSymbol: cfg.RequireSymbol,
+		},
+		Hasher: password.NewHasher(cfg.BcryptCost),
+	}
+
+	if cfg.BreachedDir != "" {
+		settings.Breached = password.NewRangeFileChecker(cfg.BreachedDir)
+	}
+
+	return settings
+}
+
+// jwks publishes the public keys tokens are signed with so other services can
+// verify them. The set is empty when tokens are signed with a shared secret.
+func (s *Server) jwks(c *gin.Context) {
+	set := jwt.JWKS{Keys: []jwt.JWK{}}
+	if s.keyRing != nil {
+		set = s.keyRing.JWKS()
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, set)
 }
 
 // healthCheck handles health check requests
@@ -167,24 +430,47 @@ func (s *Server) setupChatRoutes(api *gin.RouterGroup) {
 	// Create dependencies
 	userRepo := repositories.NewUserRepository(s.db.Pool, *s.logger)
 	chatRepo := repositories.NewChatRepository(s.db.Pool, *s.logger)
-	jwtService := jwt.NewService(s.config.JWT.Secret, time.Duration(s.config.JWT.ExpireHours)*time.Hour)
 	validator := validation.New()
 
 	// Create use case
 	chatUseCase := chat.NewUseCase(chatRepo, userRepo, validator, *s.logger)
 
+	// Only room members may subscribe to a room over WebSocket
+	s.wsHub.SetRoomAuthorizer(chatRepo.IsMember)
+
+	// Permanently remove deleted chat rooms once their restore grace period has passed
+	s.runPeriodically(time.Hour, func(ctx context.Context) { s.purgeDeletedChatRooms(ctx, chatUseCase) })
+
 	// Create handler
-	chatHandler := handlers.NewChatHandler(chatUseCase, *s.logger)
+	chatHandler := handlers.NewChatHandler(chatUseCase, s.wsHub, *s.logger)
 
 	// Chat routes
 	chatGroup := api.Group("/chatrooms")
-	chatGroup.Use(middleware.Auth(jwtService))
+	chatGroup.Use(middleware.Auth(s.jwtService, s.apiKeys))
 	{
 		chatGroup.GET("", chatHandler.GetChatRooms)
 		chatGroup.POST("", chatHandler.CreateChatRoom)
 		chatGroup.GET("/:id", chatHandler.GetChatRoom)
+		chatGroup.PATCH("/:id", chatHandler.UpdateChatRoom)
+		chatGroup.DELETE("/:id", chatHandler.DeleteChatRoom)
+		chatGroup.POST("/:id/restore", chatHandler.RestoreChatRoom)
 		chatGroup.POST("/:id/join", chatHandler.JoinChatRoom)
 		chatGroup.POST("/:id/leave", chatHandler.LeaveChatRoom)
+		chatGroup.GET("/:id/settings", chatHandler.GetSettings)
+		chatGroup.PUT("/:id/settings", chatHandler.UpdateSettings)
+		chatGroup.GET("/:id/members", chatHandler.GetMembers)
+		chatGroup.POST("/:id/members", chatHandler.AddMembers)
+		chatGroup.DELETE("/:id/members/:user_id", chatHandler.KickMember)
+		chatGroup.PUT("/:id/members/:user_id/role", chatHandler.UpdateMemberRole)
+		chatGroup.POST("/:id/bans", chatHandler.BanMember)
+		chatGroup.DELETE("/:id/bans/:user_id", chatHandler.UnbanMember)
+	}
+}
+
+// purgeDeletedChatRooms purges soft-deleted chat rooms
+func (s *Server) purgeDeletedChatRooms(ctx context.Context, chatUseCase chat.UseCase) {
+	if _, err := chatUseCase.PurgeDeletedChatRooms(ctx); err != nil {
+		s.logger.Error("Failed to purge deleted chat rooms", "error", err)
 	}
 }
 
@@ -193,18 +479,22 @@ func (s *Server) setupMessageRoutes(api *gin.RouterGroup) {
 	// Create dependencies
 	chatRepo := repositories.NewChatRepository(s.db.Pool, *s.logger)
 	messageRepo := repositories.NewMessageRepository(s.db.Pool, *s.logger)
-	jwtService := jwt.NewService(s.config.JWT.Secret, time.Duration(s.config.JWT.ExpireHours)*time.Hour)
+	attachmentRepo := repositories.NewAttachmentRepository(s.db.Pool, *s.logger)
 	validator := validation.New()
 
 	// Create use case
-	messageUseCase := message.NewUseCase(messageRepo, chatRepo, validator, *s.logger)
+	messageUseCase := message.NewUseCase(messageRepo, chatRepo, attachmentRepo, validator, *s.logger)
 
 	// Create handler
 	messageHandler := handlers.NewMessageHandler(messageUseCase, s.wsHub, *s.logger)
 
-	// Message routes under chat rooms
-	chatGroup := api.Group("/chatrooms/:room_id")
-	chatGroup.Use(middleware.Auth(jwtService))
+	// Route chat messages sent over WebSocket through the same checks as the HTTP API
+	s.wsHub.SetMessageSender(messageHandler.SendSocketMessage)
+
+	// Message routes under chat rooms; the wildcard must be named as in the
+	// chat routes, since gin panics on differently named wildcards at one position
+	chatGroup := api.Group("/chatrooms/:id")
+	chatGroup.Use(middleware.Auth(s.jwtService, s.apiKeys))
 	{
 		chatGroup.GET("/messages", messageHandler.GetMessages)
 		chatGroup.POST("/messages", messageHandler.SendMessage)
@@ -212,7 +502,7 @@ func (s *Server) setupMessageRoutes(api *gin.RouterGroup) {
 
 	// Individual message operations
 	messageGroup := api.Group("/messages")
-	messageGroup.Use(middleware.Auth(jwtService))
+	messageGroup.Use(middleware.Auth(s.jwtService, s.apiKeys))
 	{
 		messageGroup.GET("/:id", messageHandler.GetMessage)
 		messageGroup.PUT("/:id/status", messageHandler.UpdateMessageStatus)
@@ -220,6 +510,160 @@ func (s *Server) setupMessageRoutes(api *gin.RouterGroup) {
 	}
 }
 
+// setupFileRoutes configures file upload routes
+func (s *Server) setupFileRoutes(api *gin.RouterGroup) error {
+	// Create use case
+	fileUseCase, settings, err := NewFileUseCase(s.config, s.db, s.logger)
+	if err != nil {
+		return err
+	}
+
+	// Remove uploads that were started but never completed, and content no file references
+	s.runPeriodically(time.Hour, func(ctx context.Context) { s.purgeStaleUploads(ctx, fileUseCase) })
+
+	// Release uploads once they are scanned for malware, or quarantine them
+	if s.config.Upload.ClamAVAddress != "" {
+		s.runPeriodically(s.config.Upload.ScanInterval, func(ctx context.Context) { s.scanPendingFiles(ctx, fileUseCase) })
+	}
+
+	// Remove files never sent with a message and content left behind by deleted messages and rooms
+	if s.config.Upload.GCInterval > 0 {
+		s.runPeriodically(s.config.Upload.GCInterval, func(ctx context.Context) { s.collectGarbage(ctx, fileUseCase) })
+	}
+
+	// Create handler
+	fileHandler := handlers.NewFileHandler(fileUseCase, settings, *s.logger)
+
+	// File routes; access follows membership of the room a file was posted in
+	fileGroup := api.Group("/files")
+	fileGroup.Use(middleware.Auth(s.jwtService, s.apiKeys))
+	{
+		fileGroup.POST("", fileHandler.UploadFile)
+		fileGroup.POST("/uploads", fileHandler.CreateUpload)
+		fileGroup.POST("/by-checksum", fileHandler.UploadByChecksum)
+		fileGroup.POST("/:id/complete", fileHandler.CompleteUpload)
+		fileGroup.POST("/resumable", fileHandler.CreateResumableUpload)
+		fileGroup.HEAD("/resumable/:id", fileHandler.GetResumableUpload)
+		fileGroup.PATCH("/resumable/:id", fileHandler.WriteChunk)
+		fileGroup.POST("/resumable/:id/complete", fileHandler.CompleteResumableUpload)
+		fileGroup.DELETE("/resumable/:id", fileHandler.DeleteFile)
+		fileGroup.GET("/:id", fileHandler.GetFileInfo)
+		fileGroup.GET("/:id/download", fileHandler.DownloadFile)
+		fileGroup.HEAD("/:id/download", fileHandler.DownloadFile)
+		fileGroup.GET("/:id/thumbnail", fileHandler.DownloadThumbnail)
+		fileGroup.HEAD("/:id/thumbnail", fileHandler.DownloadThumbnail)
+		fileGroup.GET("/:id/preview", fileHandler.DownloadPreview)
+		fileGroup.HEAD("/:id/preview", fileHandler.DownloadPreview)
+		fileGroup.DELETE("/:id", fileHandler.DeleteFile)
+	}
+
+	meGroup := api.Group("/me")
+	meGroup.Use(middleware.Auth(s.jwtService, s.apiKeys))
+	{
+		meGroup.GET("/storage", fileHandler.GetStorageUsage)
+	}
+
+	return nil
+}
+
+// NewFileUseCase creates the file use case with the configured storage and
+// malware scanner, for the server and for maintenance commands such as
+// garbage collection
+func NewFileUseCase(cfg *config.Config, db *postgres.DB, logger *logger.Logger) (file.UseCase, file.UploadSettings, error) {
+	// Create dependencies
+	attachmentRepo := repositories.NewAttachmentRepository(db.Pool, *logger)
+	sessionRepo := repositories.NewUploadSessionRepository(db.Pool, *logger)
+	chatRepo := repositories.NewChatRepository(db.Pool, *logger)
+	blob, err := newBlobStorage(cfg)
+	if err != nil {
+		return nil, file.UploadSettings{}, err
+	}
+	validator := validation.New()
+	typeMaxSizes, err := cfg.Upload.TypeLimits()
+	if err != nil {
+		return nil, file.UploadSettings{}, err
+	}
+
+	settings := file.UploadSettings{
+		MaxSize:          cfg.Upload.MaxSize,
+		URLExpiry:        cfg.Upload.URLExpiry,
+		ResumableMaxSize: cfg.Upload.ResumableMaxSize,
+		ChunkMaxSize:     cfg.Upload.ChunkMaxSize,
+		ResumableExpiry:  cfg.Upload.ResumableExpiry,
+		ProxyDownloads:   cfg.Upload.ProxyDownloads,
+		TypeMaxSizes:     typeMaxSizes,
+		UserQuota:        cfg.Upload.UserQuota,
+		RoomQuota:        cfg.Upload.RoomQuota,
+		GarbageGrace:     cfg.Upload.GCGrace,
+	}
+	fileUseCase := file.NewUseCase(attachmentRepo, sessionRepo, chatRepo, blob, newScanner(cfg), validator, *logger, settings)
+	return fileUseCase, settings, nil
+}
+
+// newBlobStorage creates the configured storage for uploaded file content
+func newBlobStorage(cfg *config.Config) (storage.Blob, error) {
+	if cfg.Upload.Driver == "s3" {
+		return storage.NewS3Blob(storage.S3Config{
+			Endpoint:        cfg.S3.Endpoint,
+			Region:          cfg.S3.Region,
+			Bucket:          cfg.S3.Bucket,
+			AccessKeyID:     cfg.S3.AccessKeyID,
+			SecretAccessKey: cfg.S3.SecretAccessKey,
+			PathStyle:       cfg.S3.PathStyle,
+		}, nil)
+	}
+	return storage.NewLocalBlob(cfg.Upload.Dir)
+}
+
+// newScanner creates the malware scanner for uploads, or nil if uploads are
+// not scanned
+func newScanner(cfg *config.Config) scan.Scanner {
+	if cfg.Upload.ClamAVAddress == "" {
+		return nil
+	}
+	return scan.NewClamAVScanner(cfg.Upload.ClamAVAddress, cfg.Upload.ScanTimeout)
+}
+
+// scanPendingFiles scans files waiting for a malware scan and tells their
+// uploaders whether the file is now available, quarantined or could not be
+// scanned
+func (s *Server) scanPendingFiles(ctx context.Context, fileUseCase file.UseCase) {
+	scanned, err := fileUseCase.ScanPendingFiles(ctx)
+	if err != nil {
+		s.logger.Error("Failed to scan pending files", "error", err)
+		return
+	}
+
+	for _, f := range scanned {
+		s.wsHub.SendToUser(f.OwnerID, websocket.Message{
+			Type:   "file_scanned",
+			RoomID: f.ChatRoomID,
+			Data: map[string]interface{}{
+				"file_id":     f.ID,
+				"scan_status": f.ScanStatus,
+				"threat":      f.Threat,
+			},
+			Timestamp: time.Now(),
+		})
+	}
+}
+
+// purgeStaleUploads removes abandoned and expired uploads and content no
+// file references any more
+func (s *Server) purgeStaleUploads(ctx context.Context, fileUseCase file.UseCase) {
+	if _, err := fileUseCase.PurgeStaleUploads(ctx); err != nil {
+		s.logger.Error("Failed to purge stale uploads", "error", err)
+	}
+}
+
+// collectGarbage removes files never sent with a message and stored content
+// nothing references
+func (s *Server) collectGarbage(ctx context.Context, fileUseCase file.UseCase) {
+	if _, err := fileUseCase.CollectGarbage(ctx, file.CollectGarbageInput{}); err != nil {
+		s.logger.Error("Failed to collect garbage", "error", err)
+	}
+}
+
 // websocketHandler handles WebSocket connections
 func (s *Server) websocketHandler(c *gin.Context) {
 	// Get JWT token from query parameter or header
@@ -237,8 +681,7 @@ func (s *Server) websocketHandler(c *gin.Context) {
 	}
 
 	// Validate token
-	jwtService := jwt.NewService(s.config.JWT.Secret, time.Duration(s.config.JWT.ExpireHours)*time.Hour)
-	claims, err := jwtService.ValidateToken(token)
+	claims, err := s.jwtService.ValidateToken(token)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
 		return
@@ -249,7 +692,8 @@ func (s *Server) websocketHandler(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token claims"})
 		return
 	}
+	sessionID, _ := claims["sid"].(string)
 
 	// Handle WebSocket connection
-	s.wsHub.HandleConnection(c.Writer, c.Request, userID)
+	s.wsHub.HandleConnection(c.Writer, c.Request, userID, sessionID, token)
 }
\ No newline at end of file
@@ -2,6 +2,7 @@ package websocket
 
 import (
 	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -94,6 +95,11 @@ func (c *Client) handleMessage(msg Message) {
 	switch msg.Type {
 	case "join_room":
 		if msg.RoomID != "" {
+			if !c.hub.CanJoinRoom(c.userID, msg.RoomID) {
+				c.sendError(msg.RoomID, "not a member of this chat room", nil)
+				return
+			}
+
 			c.hub.JoinRoom(c.userID, msg.RoomID)
 			
 			// Send confirmation
@@ -132,8 +138,14 @@ func (c *Client) handleMessage(msg Message) {
 
 	case "message":
 		if msg.RoomID != "" && c.roomID == msg.RoomID {
-			// Broadcast message to room
-			c.hub.BroadcastToRoom(msg.RoomID, msg)
+			if err := c.hub.SendMessage(msg); err != nil {
+				var data interface{}
+				var retryErr retryAfterError
+				if errors.As(err, &retryErr) {
+					data = map[string]interface{}{"retry_after": retryErr.RetryAfterSeconds()}
+				}
+				c.sendError(msg.RoomID, err.Error(), data)
+			}
 		}
 
 	case "typing":
@@ -165,4 +177,29 @@ func (c *Client) handleMessage(msg Message) {
 	default:
 		c.hub.logger.Warn("Unknown message type", "type", msg.Type, "user_id", c.userID)
 	}
+}
+
+// sendError sends an error event to the client
+func (c *Client) sendError(roomID, reason string, data interface{}) {
+	response := Message{
+		Type:      "error",
+		RoomID:    roomID,
+		Content:   reason,
+		Data:      data,
+		Timestamp: time.Now(),
+	}
+	if data, err := json.Marshal(response); err == nil {
+		select {
+		case c.send <- data:
+		default:
+			close(c.send)
+		}
+	}
+}
+
+// disconnect closes the connection with the given close code; the read pump
+// then unregisters the client from the hub
+func (c *Client) disconnect(code int, reason string) {
+	c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(writeWait))
+	c.conn.Close()
 }
\ No newline at end of file
@@ -1,7 +1,9 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"sync"
 	"time"
@@ -30,6 +32,15 @@ type Hub struct {
 	// User to client mapping
 	userClients map[string]*Client
 
+	// Checks whether a user may subscribe to a room
+	roomAuthorizer RoomAuthorizer
+
+	// Persists and delivers chat messages sent over the socket
+	messageSender MessageSender
+
+	// Re-validates the access tokens of open connections
+	tokenValidator TokenValidator
+
 	logger logger.Logger
 	mu     sync.RWMutex
 }
@@ -49,6 +60,32 @@ type Client struct {
 
 	// Current room ID
 	roomID string
+
+	// Session the connection belongs to
+	sessionID string
+
+	// Access token the connection was opened with
+	token string
+}
+
+// RoomAuthorizer reports whether a user is allowed to join a room
+type RoomAuthorizer func(ctx context.Context, roomID, userID string) (bool, error)
+
+// MessageSender sends a chat message on behalf of a user, taking care of
+// permission checks, persistence and delivery to the room
+type MessageSender func(ctx context.Context, roomID, userID, content string) error
+
+// TokenValidator returns an error when an access token is no longer valid,
+// or ErrTokenCheckUnavailable when its validity cannot be determined
+type TokenValidator func(token string) error
+
+// ErrTokenCheckUnavailable tells the hub to keep a connection whose token
+// could not be checked, such as during a revocation store outage
+var ErrTokenCheckUnavailable = errors.New("token check unavailable")
+
+// retryAfterError is implemented by send errors that tell the client when to retry
+type retryAfterError interface {
+	RetryAfterSeconds() int
 }
 
 // Message represents a WebSocket message
@@ -73,6 +110,9 @@ const (
 
 	// Maximum message size allowed from peer
 	maxMessageSize = 512
+
+	// How often the tokens of open connections are re-validated
+	tokenCheckPeriod = 30 * time.Second
 )
 
 var upgrader = websocket.Upgrader{
@@ -97,10 +137,77 @@ func NewHub(logger logger.Logger) *Hub {
 	}
 }
 
+// SetRoomAuthorizer sets the check used before a client joins a room
+func (h *Hub) SetRoomAuthorizer(authorizer RoomAuthorizer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.roomAuthorizer = authorizer
+}
+
+// CanJoinRoom checks if a user is allowed to join a room
+func (h *Hub) CanJoinRoom(userID, roomID string) bool {
+	h.mu.RLock()
+	authorizer := h.roomAuthorizer
+	h.mu.RUnlock()
+
+	if authorizer == nil {
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	allowed, err := authorizer(ctx, roomID, userID)
+	if err != nil {
+		h.logger.Error("Failed to authorize room join", "error", err, "user_id", userID, "room_id", roomID)
+		return false
+	}
+	return allowed
+}
+
+// SetMessageSender sets the handler for chat messages sent over the socket
+func (h *Hub) SetMessageSender(sender MessageSender) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.messageSender = sender
+}
+
+// SendMessage sends a chat message from a user to a room. Without a message
+// sender configured the message is broadcast to the room as-is.
+func (h *Hub) SendMessage(msg Message) error {
+	h.mu.RLock()
+	sender := h.messageSender
+	h.mu.RUnlock()
+
+	if sender == nil {
+		h.BroadcastToRoom(msg.RoomID, msg)
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return sender(ctx, msg.RoomID, msg.SenderID, msg.Content)
+}
+
+// SetTokenValidator sets the check used to drop connections whose token was revoked or expired
+func (h *Hub) SetTokenValidator(validator TokenValidator) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.tokenValidator = validator
+}
+
 // Run starts the hub
 func (h *Hub) Run() {
+	tokenCheck := time.NewTicker(tokenCheckPeriod)
+	defer tokenCheck.Stop()
+
 	for {
 		select {
+		case <-tokenCheck.C:
+			// Validation may hit the revocation store, so keep it off the hub loop
+			go h.closeRevokedConnections()
+
 		case client := <-h.register:
 			h.mu.Lock()
 			h.clients[client] = true
@@ -164,7 +271,7 @@ func (h *Hub) Run() {
 }
 
 // HandleConnection handles WebSocket connections
-func (h *Hub) HandleConnection(w http.ResponseWriter, r *http.Request, userID string) {
+func (h *Hub) HandleConnection(w http.ResponseWriter, r *http.Request, userID, sessionID, token string) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		h.logger.Error("Failed to upgrade connection", "error", err)
@@ -172,10 +279,12 @@ func (h *Hub) HandleConnection(w http.ResponseWriter, r *http.Request, userID st
 	}
 
 	client := &Client{
-		hub:    h,
-		conn:   conn,
-		send:   make(chan []byte, 256),
-		userID: userID,
+		hub:       h,
+		conn:      conn,
+		send:      make(chan []byte, 256),
+		userID:    userID,
+		sessionID: sessionID,
+		token:     token,
 	}
 
 	client.hub.register <- client
@@ -185,6 +294,58 @@ func (h *Hub) HandleConnection(w http.ResponseWriter, r *http.Request, userID st
 	go client.readPump()
 }
 
+// closeRevokedConnections disconnects clients whose access token is no longer valid
+func (h *Hub) closeRevokedConnections() {
+	h.mu.RLock()
+	validator := h.tokenValidator
+	clients := make([]*Client, 0, len(h.clients))
+	for client := range h.clients {
+		clients = append(clients, client)
+	}
+	h.mu.RUnlock()
+
+	if validator == nil {
+		return
+	}
+
+	for _, client := range clients {
+		err := validator(client.token)
+		if errors.Is(err, ErrTokenCheckUnavailable) {
+			// The token is checked again at the next sweep
+			h.logger.Error("Failed to check connection token", "error", err, "user_id", client.userID)
+			continue
+		}
+		if err != nil {
+			h.logger.Info("Closing connection with invalid token", "user_id", client.userID, "reason", err.Error())
+			client.disconnect(websocket.ClosePolicyViolation, "token revoked or expired")
+		}
+	}
+}
+
+// CloseSession disconnects every client opened with the given session
+func (h *Hub) CloseSession(sessionID string) {
+	if sessionID == "" {
+		return
+	}
+
+	h.mu.RLock()
+	var clients []*Client
+	for client := range h.clients {
+		if client.sessionID == sessionID {
+			clients = append(clients, client)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, client := range clients {
+		client.disconnect(websocket.ClosePolicyViolation, "session revoked")
+	}
+
+	if len(clients) > 0 {
+		h.logger.Info("Closed session connections", "session_id", sessionID, "count", len(clients))
+	}
+}
+
 // JoinRoom adds a client to a room
 func (h *Hub) JoinRoom(userID, roomID string) {
 	h.mu.Lock()
@@ -230,6 +391,24 @@ func (h *Hub) LeaveRoom(userID, roomID string) {
 	}
 }
 
+// CloseRoom unsubscribes every client from a room
+func (h *Hub) CloseRoom(roomID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	room, exists := h.rooms[roomID]
+	if !exists {
+		return
+	}
+
+	for client := range room {
+		client.roomID = ""
+	}
+	delete(h.rooms, roomID)
+
+	h.logger.Info("Room closed", "room_id", roomID, "clients", len(room))
+}
+
 // BroadcastToRoom sends a message to all clients in a room
 func (h *Hub) BroadcastToRoom(roomID string, message interface{}) {
 	h.mu.RLock()
@@ -291,6 +470,14 @@ func (h *Hub) SendToUser(userID string, message Message) {
 	h.logger.Info("Message sent to user", "user_id", userID)
 }
 
+// IsUserOnline checks if a user has an active connection
+func (h *Hub) IsUserOnline(userID string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	_, exists := h.userClients[userID]
+	return exists
+}
+
 // GetConnectionCount returns the number of active connections
 func (h *Hub) GetConnectionCount() int {
 	h.mu.RLock()
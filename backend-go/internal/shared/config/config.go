@@ -2,6 +2,9 @@ package config
 
 import (
 	"fmt"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
@@ -16,6 +19,13 @@ type Config struct {
 	WebSocket WebSocketConfig `mapstructure:"websocket"`
 	Log       LogConfig       `mapstructure:"log"`
 	CORS      CORSConfig      `mapstructure:"cors"`
+	Mail      MailConfig      `mapstructure:"mail"`
+	OIDC      OIDCConfig      `mapstructure:"oidc"`
+	Login     LoginConfig     `mapstructure:"login"`
+	Password  PasswordConfig  `mapstructure:"password"`
+	Upload    UploadConfig    `mapstructure:"upload"`
+	S3        S3Config        `mapstructure:"s3"`
+	Metrics   MetricsConfig   `mapstructure:"metrics"`
 }
 
 // ServerConfig holds server configuration
@@ -50,9 +60,12 @@ type RedisConfig struct {
 
 // JWTConfig holds JWT configuration
 type JWTConfig struct {
-	Secret               string        `mapstructure:"secret"`
-	ExpireHours          int           `mapstructure:"expire_hours"`
-	RefreshExpireHours   int           `mapstructure:"refresh_expire_hours"`
+	Secret              string        `mapstructure:"secret"`    // HS256 signing secret; with RS256/EdDSA it only verifies older tokens
+	Algorithm           string        `mapstructure:"algorithm"` // "HS256", "RS256" or "EdDSA"
+	ExpireHours         int           `mapstructure:"expire_hours"`
+	RefreshExpireHours  int           `mapstructure:"refresh_expire_hours"`
+	KeyRotationInterval time.Duration `mapstructure:"key_rotation_interval"`
+	KeyPrepublish       time.Duration `mapstructure:"key_prepublish"` // How long new keys are published before they sign
 }
 
 // WebSocketConfig holds WebSocket configuration
@@ -65,6 +78,103 @@ type WebSocketConfig struct {
 	WriteWait       time.Duration `mapstructure:"write_wait"`
 }
 
+// MailConfig holds outgoing email configuration
+type MailConfig struct {
+	Driver       string `mapstructure:"driver"` // "smtp" or "log"
+	From         string `mapstructure:"from"`
+	SMTPHost     string `mapstructure:"smtp_host"`
+	SMTPPort     int    `mapstructure:"smtp_port"`
+	SMTPUsername string `mapstructure:"smtp_username"`
+	SMTPPassword string `mapstructure:"smtp_password"`
+	OutputDir    string `mapstructure:"output_dir"` // Where the log driver also writes messages, if set
+	AppURL       string `mapstructure:"app_url"`    // Base URL of the client app used in email links
+}
+
+// OIDCConfig holds single sign-on configuration
+type OIDCConfig struct {
+	Enabled        bool     `mapstructure:"enabled"`
+	IssuerURL      string   `mapstructure:"issuer_url"`
+	ClientID       string   `mapstructure:"client_id"`
+	ClientSecret   string   `mapstructure:"client_secret"`
+	RedirectURL    string   `mapstructure:"redirect_url"` // Client app page that receives the authorization code
+	Scopes         []string `mapstructure:"scopes"`
+	AllowedDomains []string `mapstructure:"allowed_domains"` // Email domains allowed to sign in; empty allows any
+	AutoProvision  bool     `mapstructure:"auto_provision"`  // Create accounts for unknown users on first login
+}
+
+// LoginConfig holds brute-force protection for logins
+type LoginConfig struct {
+	Window          time.Duration `mapstructure:"window"`        // How long failed attempts are remembered
+	FreeAttempts    int           `mapstructure:"free_attempts"` // Failures per account before delays start
+	BaseDelay       time.Duration `mapstructure:"base_delay"`    // First delay, doubling with each further failure
+	MaxDelay        time.Duration `mapstructure:"max_delay"`
+	AccountLockout  int           `mapstructure:"account_lockout"` // Failures that lock an account; zero disables
+	IPLockout       int           `mapstructure:"ip_lockout"`      // Failures that lock a client IP; zero disables
+	LockoutDuration time.Duration `mapstructure:"lockout_duration"`
+}
+
+// PasswordConfig holds the password policy and hashing configuration
+type PasswordConfig struct {
+	MinLength     int    `mapstructure:"min_length"`
+	RequireUpper  bool   `mapstructure:"require_upper"`
+	RequireLower  bool   `mapstructure:"require_lower"`
+	RequireDigit  bool   `mapstructure:"require_digit"`
+	RequireSymbol bool   `mapstructure:"require_symbol"`
+	BreachedDir   string `mapstructure:"breached_dir"` // Offline Pwned Passwords range files; empty disables the check
+	BcryptCost    int    `mapstructure:"bcrypt_cost"`  // Hashes at other costs are upgraded at login
+}
+
+// UploadConfig holds file upload configuration
+type UploadConfig struct {
+	Driver           string        `mapstructure:"driver"`             // "local" or "s3"
+	Dir              string        `mapstructure:"dir"`                // Where the local driver stores file content
+	MaxSize          int64         `mapstructure:"max_size"`           // Largest accepted upload in bytes
+	URLExpiry        time.Duration `mapstructure:"url_expiry"`         // Lifetime of pre-signed upload and download URLs
+	ResumableMaxSize int64         `mapstructure:"resumable_max_size"` // Largest file accepted through resumable uploads
+	ChunkMaxSize     int64         `mapstructure:"chunk_max_size"`     // Largest chunk of a resumable upload
+	ResumableExpiry  time.Duration `mapstructure:"resumable_expiry"`   // Idle time after which partial uploads are discarded
+	ProxyDownloads   bool          `mapstructure:"proxy_downloads"`    // Stream downloads through the API instead of redirecting to pre-signed URLs
+	TypeMaxSizes     []string      `mapstructure:"type_max_sizes"`     // "type=bytes" entries lowering the limit for a MIME type or top-level type
+	UserQuota        int64         `mapstructure:"user_quota"`         // Bytes each user may store; 0 for unlimited
+	RoomQuota        int64         `mapstructure:"room_quota"`         // Bytes each chat room may hold; 0 for unlimited
+	ClamAVAddress    string        `mapstructure:"clamav_address"`     // clamd "host:port" or "unix:///path" to scan uploads with; empty disables scanning
+	ScanTimeout      time.Duration `mapstructure:"scan_timeout"`       // Longest a single file may take to scan
+	ScanInterval     time.Duration `mapstructure:"scan_interval"`      // How often files waiting to be scanned are picked up
+	GCInterval       time.Duration `mapstructure:"gc_interval"`        // How often unsent files and unreferenced content are collected; 0 disables the janitor
+	GCGrace          time.Duration `mapstructure:"gc_grace"`           // How long unsent files and unreferenced content are kept
+}
+
+// TypeLimits parses TypeMaxSizes into size limits keyed by MIME type or
+// top-level type, such as "video=104857600" or "application/pdf=10485760"
+func (c UploadConfig) TypeLimits() (map[string]int64, error) {
+	limits := make(map[string]int64, len(c.TypeMaxSizes))
+	for _, entry := range c.TypeMaxSizes {
+		contentType, size, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		limit, err := strconv.ParseInt(strings.TrimSpace(size), 10, 64)
+		if !ok || contentType == "" || err != nil || limit <= 0 {
+			return nil, fmt.Errorf("invalid upload type max size %q, expected type=bytes", entry)
+		}
+		limits[strings.ToLower(strings.TrimSpace(contentType))] = limit
+	}
+	return limits, nil
+}
+
+// S3Config holds the S3-compatible storage used by the s3 upload driver
+type S3Config struct {
+	Endpoint        string `mapstructure:"endpoint"`
+	Region          string `mapstructure:"region"`
+	Bucket          string `mapstructure:"bucket"`
+	AccessKeyID     string `mapstructure:"access_key_id"`
+	SecretAccessKey string `mapstructure:"secret_access_key"`
+	PathStyle       bool   `mapstructure:"path_style"` // Needed by MinIO and most other S3-compatible stores
+}
+
+// MetricsConfig holds the endpoint publishing runtime and job metrics
+type MetricsConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Path    string `mapstructure:"path"` // Served as expvar JSON
+}
+
 // LogConfig holds logging configuration
 type LogConfig struct {
 	Level  string `mapstructure:"level"`
@@ -151,9 +261,12 @@ func setDefaults() {
 	viper.SetDefault("redis.pool_size", 10)
 
 	// JWT defaults
-	viper.SetDefault("jwt.secret", "your-secret-key")
+	viper.SetDefault("jwt.secret", "")
+	viper.SetDefault("jwt.algorithm", "HS256")
 	viper.SetDefault("jwt.expire_hours", 24)
 	viper.SetDefault("jwt.refresh_expire_hours", 168)
+	viper.SetDefault("jwt.key_rotation_interval", "720h")
+	viper.SetDefault("jwt.key_prepublish", "1h")
 
 	// WebSocket defaults
 	viper.SetDefault("websocket.read_buffer_size", 1024)
@@ -163,6 +276,57 @@ func setDefaults() {
 	viper.SetDefault("websocket.pong_wait", "60s")
 	viper.SetDefault("websocket.write_wait", "10s")
 
+	// Mail defaults
+	viper.SetDefault("mail.driver", "log")
+	viper.SetDefault("mail.from", "no-reply@localhost")
+	viper.SetDefault("mail.smtp_port", 587)
+	viper.SetDefault("mail.app_url", "http://localhost:3000")
+
+	// OIDC defaults
+	viper.SetDefault("oidc.enabled", false)
+	viper.SetDefault("oidc.scopes", []string{"openid", "email", "profile"})
+	viper.SetDefault("oidc.auto_provision", true)
+
+	// Login protection defaults
+	viper.SetDefault("login.window", "15m")
+	viper.SetDefault("login.free_attempts", 3)
+	viper.SetDefault("login.base_delay", "1s")
+	viper.SetDefault("login.max_delay", "30s")
+	viper.SetDefault("login.account_lockout", 10)
+	viper.SetDefault("login.ip_lockout", 100)
+	viper.SetDefault("login.lockout_duration", "15m")
+
+	// Password defaults
+	viper.SetDefault("password.min_length", 8)
+	viper.SetDefault("password.require_upper", true)
+	viper.SetDefault("password.require_lower", true)
+	viper.SetDefault("password.require_digit", true)
+	viper.SetDefault("password.require_symbol", false)
+	viper.SetDefault("password.bcrypt_cost", 12)
+
+	// Upload defaults
+	viper.SetDefault("upload.driver", "local")
+	viper.SetDefault("upload.dir", "./uploads")
+	viper.SetDefault("upload.max_size", 25<<20)
+	viper.SetDefault("upload.url_expiry", "15m")
+	viper.SetDefault("upload.resumable_max_size", 2<<30)
+	viper.SetDefault("upload.chunk_max_size", 16<<20)
+	viper.SetDefault("upload.resumable_expiry", "24h")
+	viper.SetDefault("upload.proxy_downloads", false)
+	viper.SetDefault("upload.type_max_sizes", []string{})
+	viper.SetDefault("upload.user_quota", 5<<30)
+	viper.SetDefault("upload.room_quota", 50<<30)
+	viper.SetDefault("upload.clamav_address", "")
+	viper.SetDefault("upload.scan_timeout", "2m")
+	viper.SetDefault("upload.scan_interval", "5s")
+	viper.SetDefault("upload.gc_interval", "24h")
+	viper.SetDefault("upload.gc_grace", "24h")
+	viper.SetDefault("s3.region", "us-east-1")
+
+	// Metrics defaults
+	viper.SetDefault("metrics.enabled", false)
+	viper.SetDefault("metrics.path", "/metrics")
+
 	// Log defaults
 	viper.SetDefault("log.level", "info")
 	viper.SetDefault("log.format", "json")
@@ -199,6 +363,9 @@ func setDefaults() {
 	viper.BindEnv("jwt.secret", "JWT_SECRET")
 	viper.BindEnv("jwt.expire_hours", "JWT_EXPIRE_HOURS")
 	viper.BindEnv("jwt.refresh_expire_hours", "JWT_REFRESH_EXPIRE_HOURS")
+	viper.BindEnv("jwt.algorithm", "JWT_ALGORITHM")
+	viper.BindEnv("jwt.key_rotation_interval", "JWT_KEY_ROTATION_INTERVAL")
+	viper.BindEnv("jwt.key_prepublish", "JWT_KEY_PREPUBLISH")
 
 	viper.BindEnv("websocket.read_buffer_size", "WS_READ_BUFFER_SIZE")
 	viper.BindEnv("websocket.write_buffer_size", "WS_WRITE_BUFFER_SIZE")
@@ -207,6 +374,67 @@ func setDefaults() {
 	viper.BindEnv("websocket.pong_wait", "WS_PONG_WAIT")
 	viper.BindEnv("websocket.write_wait", "WS_WRITE_WAIT")
 
+	viper.BindEnv("mail.driver", "MAIL_DRIVER")
+	viper.BindEnv("mail.from", "MAIL_FROM")
+	viper.BindEnv("mail.smtp_host", "SMTP_HOST")
+	viper.BindEnv("mail.smtp_port", "SMTP_PORT")
+	viper.BindEnv("mail.smtp_username", "SMTP_USERNAME")
+	viper.BindEnv("mail.smtp_password", "SMTP_PASSWORD")
+	viper.BindEnv("mail.output_dir", "MAIL_OUTPUT_DIR")
+	viper.BindEnv("mail.app_url", "APP_URL")
+
+	viper.BindEnv("oidc.enabled", "OIDC_ENABLED")
+	viper.BindEnv("oidc.issuer_url", "OIDC_ISSUER_URL")
+	viper.BindEnv("oidc.client_id", "OIDC_CLIENT_ID")
+	viper.BindEnv("oidc.client_secret", "OIDC_CLIENT_SECRET")
+	viper.BindEnv("oidc.redirect_url", "OIDC_REDIRECT_URL")
+	viper.BindEnv("oidc.scopes", "OIDC_SCOPES")
+	viper.BindEnv("oidc.allowed_domains", "OIDC_ALLOWED_DOMAINS")
+	viper.BindEnv("oidc.auto_provision", "OIDC_AUTO_PROVISION")
+
+	viper.BindEnv("login.window", "LOGIN_WINDOW")
+	viper.BindEnv("login.free_attempts", "LOGIN_FREE_ATTEMPTS")
+	viper.BindEnv("login.base_delay", "LOGIN_BASE_DELAY")
+	viper.BindEnv("login.max_delay", "LOGIN_MAX_DELAY")
+	viper.BindEnv("login.account_lockout", "LOGIN_ACCOUNT_LOCKOUT")
+	viper.BindEnv("login.ip_lockout", "LOGIN_IP_LOCKOUT")
+	viper.BindEnv("login.lockout_duration", "LOGIN_LOCKOUT_DURATION")
+
+	viper.BindEnv("password.min_length", "PASSWORD_MIN_LENGTH")
+	viper.BindEnv("password.require_upper", "PASSWORD_REQUIRE_UPPER")
+	viper.BindEnv("password.require_lower", "PASSWORD_REQUIRE_LOWER")
+	viper.BindEnv("password.require_digit", "PASSWORD_REQUIRE_DIGIT")
+	viper.BindEnv("password.require_symbol", "PASSWORD_REQUIRE_SYMBOL")
+	viper.BindEnv("password.breached_dir", "PASSWORD_BREACHED_DIR")
+	viper.BindEnv("password.bcrypt_cost", "PASSWORD_BCRYPT_COST")
+
+	viper.BindEnv("upload.driver", "UPLOAD_DRIVER")
+	viper.BindEnv("upload.dir", "UPLOAD_DIR")
+	viper.BindEnv("upload.max_size", "UPLOAD_MAX_SIZE")
+	viper.BindEnv("upload.url_expiry", "UPLOAD_URL_EXPIRY")
+	viper.BindEnv("upload.resumable_max_size", "UPLOAD_RESUMABLE_MAX_SIZE")
+	viper.BindEnv("upload.chunk_max_size", "UPLOAD_CHUNK_MAX_SIZE")
+	viper.BindEnv("upload.resumable_expiry", "UPLOAD_RESUMABLE_EXPIRY")
+	viper.BindEnv("upload.proxy_downloads", "UPLOAD_PROXY_DOWNLOADS")
+	viper.BindEnv("upload.type_max_sizes", "UPLOAD_TYPE_MAX_SIZES")
+	viper.BindEnv("upload.user_quota", "UPLOAD_USER_QUOTA")
+	viper.BindEnv("upload.room_quota", "UPLOAD_ROOM_QUOTA")
+	viper.BindEnv("upload.clamav_address", "UPLOAD_CLAMAV_ADDRESS")
+	viper.BindEnv("upload.scan_timeout", "UPLOAD_SCAN_TIMEOUT")
+	viper.BindEnv("upload.scan_interval", "UPLOAD_SCAN_INTERVAL")
+	viper.BindEnv("upload.gc_interval", "UPLOAD_GC_INTERVAL")
+	viper.BindEnv("upload.gc_grace", "UPLOAD_GC_GRACE")
+
+	viper.BindEnv("s3.endpoint", "S3_ENDPOINT")
+	viper.BindEnv("s3.region", "S3_REGION")
+	viper.BindEnv("s3.bucket", "S3_BUCKET")
+	viper.BindEnv("s3.access_key_id", "S3_ACCESS_KEY_ID")
+	viper.BindEnv("s3.secret_access_key", "S3_SECRET_ACCESS_KEY")
+	viper.BindEnv("s3.path_style", "S3_PATH_STYLE")
+
+	viper.BindEnv("metrics.enabled", "METRICS_ENABLED")
+	viper.BindEnv("metrics.path", "METRICS_PATH")
+
 	viper.BindEnv("log.level", "LOG_LEVEL")
 	viper.BindEnv("log.format", "LOG_FORMAT")
 	viper.BindEnv("log.output", "LOG_OUTPUT")
@@ -226,8 +454,105 @@ func validate(config *Config) error {
 		return fmt.Errorf("database name is required")
 	}
 
-	if config.JWT.Secret == "" || config.JWT.Secret == "your-secret-key" {
-		return fmt.Errorf("JWT secret must be set and not be the default value")
+	switch config.JWT.Algorithm {
+	case "HS256":
+		if config.JWT.Secret == "" || config.JWT.Secret == "your-secret-key" {
+			return fmt.Errorf("JWT secret must be set and not be the default value")
+		}
+	case "RS256", "EdDSA":
+		// The secret is optional here, but the example value must never verify tokens
+		if config.JWT.Secret == "your-secret-key" {
+			return fmt.Errorf("JWT secret must not be the default value")
+		}
+		if config.JWT.KeyRotationInterval <= config.JWT.KeyPrepublish {
+			return fmt.Errorf("JWT key rotation interval must be longer than the key prepublish period")
+		}
+	default:
+		return fmt.Errorf("unsupported JWT algorithm: %s", config.JWT.Algorithm)
+	}
+
+	if config.JWT.RefreshExpireHours <= config.JWT.ExpireHours {
+		return fmt.Errorf("JWT refresh token lifetime must be longer than the access token lifetime")
+	}
+
+	switch config.Mail.Driver {
+	case "log":
+	case "smtp":
+		if config.Mail.SMTPHost == "" {
+			return fmt.Errorf("SMTP host is required for the smtp mail driver")
+		}
+	default:
+		return fmt.Errorf("unsupported mail driver: %s", config.Mail.Driver)
+	}
+
+	if config.OIDC.Enabled {
+		if config.OIDC.IssuerURL == "" || config.OIDC.ClientID == "" || config.OIDC.RedirectURL == "" {
+			return fmt.Errorf("OIDC issuer URL, client ID and redirect URL are required when OIDC is enabled")
+		}
+	}
+
+	if config.Login.Window <= 0 {
+		return fmt.Errorf("login window must be positive")
+	}
+	if config.Login.AccountLockout > 0 && config.Login.LockoutDuration <= 0 {
+		return fmt.Errorf("login lockout duration must be positive when lockout is enabled")
+	}
+
+	if config.Password.MinLength < 1 || config.Password.MinLength > 72 {
+		return fmt.Errorf("password minimum length must be between 1 and 72")
+	}
+	if config.Password.BcryptCost < 10 || config.Password.BcryptCost > 31 {
+		return fmt.Errorf("bcrypt cost must be between 10 and 31")
+	}
+	if config.Password.BreachedDir != "" {
+		if info, err := os.Stat(config.Password.BreachedDir); err != nil || !info.IsDir() {
+			return fmt.Errorf("breached password directory not found: %s", config.Password.BreachedDir)
+		}
+	}
+
+	switch config.Upload.Driver {
+	case "local":
+		if config.Upload.Dir == "" {
+			return fmt.Errorf("upload directory is required for the local upload driver")
+		}
+	case "s3":
+		if config.S3.Endpoint == "" || config.S3.Bucket == "" || config.S3.Region == "" {
+			return fmt.Errorf("S3 endpoint, bucket and region are required for the s3 upload driver")
+		}
+		if config.S3.AccessKeyID == "" || config.S3.SecretAccessKey == "" {
+			return fmt.Errorf("S3 access key ID and secret access key are required for the s3 upload driver")
+		}
+	default:
+		return fmt.Errorf("unsupported upload driver: %s", config.Upload.Driver)
+	}
+	if config.Upload.MaxSize <= 0 {
+		return fmt.Errorf("upload max size must be positive")
+	}
+	// S3 rejects pre-signed URLs valid for longer than seven days
+	if config.Upload.URLExpiry < time.Minute || config.Upload.URLExpiry > 7*24*time.Hour {
+		return fmt.Errorf("upload URL expiry must be between 1m and 168h")
+	}
+	if config.Upload.ResumableMaxSize <= 0 || config.Upload.ChunkMaxSize <= 0 {
+		return fmt.Errorf("resumable upload max size and chunk max size must be positive")
+	}
+	if config.Upload.ResumableExpiry < time.Minute {
+		return fmt.Errorf("resumable upload expiry must be at least 1m")
+	}
+	if _, err := config.Upload.TypeLimits(); err != nil {
+		return err
+	}
+	if config.Upload.UserQuota < 0 || config.Upload.RoomQuota < 0 {
+		return fmt.Errorf("upload quotas must not be negative")
+	}
+	if config.Upload.ClamAVAddress != "" && (config.Upload.ScanTimeout < time.Second || config.Upload.ScanInterval < time.Second) {
+		return fmt.Errorf("upload scan timeout and interval must be at least 1s")
+	}
+	if config.Upload.GCInterval < 0 || config.Upload.GCGrace < time.Hour {
+		return fmt.Errorf("upload gc interval must not be negative and gc grace must be at least 1h")
+	}
+
+	if config.Metrics.Enabled && !strings.HasPrefix(config.Metrics.Path, "/") {
+		return fmt.Errorf("metrics path must start with /")
 	}
 
 	return nil
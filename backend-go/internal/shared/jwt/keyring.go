@@ -0,0 +1,283 @@
+package jwt
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"backend-go/internal/shared/logger"
+)
+
+const (
+	// keyCheckInterval is how often the key ring reloads keys and rotates when due
+	keyCheckInterval = time.Minute
+
+	// keyReloadInterval limits reloads triggered by tokens with an unknown key ID
+	keyReloadInterval = 10 * time.Second
+
+	// keyStoreTimeout bounds each call to the key store
+	keyStoreTimeout = 5 * time.Second
+)
+
+// KeyRingConfig controls key generation and rotation
+type KeyRingConfig struct {
+	Algorithm        string        // RS256 or EdDSA
+	RotationInterval time.Duration // How long each key signs before the next one takes over
+	Prepublish       time.Duration // How long a new key is published before it signs
+	Retention        time.Duration // How long a replaced key still verifies; the longest token lifetime
+}
+
+// KeyRing holds the signing keys shared through a key store. The newest
+// active key signs; every stored key verifies until its tokens have expired.
+type KeyRing struct {
+	store  KeyStore
+	config KeyRingConfig
+	logger logger.Logger
+
+	mu       sync.RWMutex
+	keys     []*SigningKey // Ordered by ActiveFrom
+	loadedAt time.Time
+
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewKeyRing creates a key ring and loads its keys, generating the first key
+// if the store is empty
+func NewKeyRing(store KeyStore, config KeyRingConfig, logger logger.Logger) (*KeyRing, error) {
+	if config.Algorithm != AlgorithmRS256 && config.Algorithm != AlgorithmEdDSA {
+		return nil, fmt.Errorf("unsupported signing algorithm: %s", config.Algorithm)
+	}
+	if config.RotationInterval <= config.Prepublish {
+		return nil, fmt.Errorf("key rotation interval must be longer than the prepublish period")
+	}
+
+	ring := &KeyRing{
+		store:  store,
+		config: config,
+		logger: logger,
+		done:   make(chan struct{}),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), keyStoreTimeout)
+	defer cancel()
+
+	if err := ring.Maintain(ctx); err != nil {
+		return nil, err
+	}
+
+	return ring, nil
+}
+
+// Run maintains the key ring until Stop is called
+func (r *KeyRing) Run() {
+	ticker := time.NewTicker(keyCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), keyStoreTimeout)
+			if err := r.Maintain(ctx); err != nil {
+				r.logger.Error("Failed to maintain signing keys", "error", err)
+			}
+			cancel()
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// Stop ends Run
+func (r *KeyRing) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.done)
+	})
+}
+
+// Maintain loads the keys from the store, then adds the next key when
+// rotation is due and deletes keys whose tokens have all expired
+func (r *KeyRing) Maintain(ctx context.Context) error {
+	keys, err := r.list(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	switch {
+	case len(keys) == 0:
+		// Nothing to hand over from, so the first key signs immediately
+		if _, err := r.addKey(ctx, now); err != nil {
+			return err
+		}
+	case keys[len(keys)-1].Algorithm != r.config.Algorithm:
+		// Switch algorithms right away; old keys still verify their tokens
+		if _, err := r.addKey(ctx, now); err != nil {
+			return err
+		}
+	default:
+		newest := keys[len(keys)-1]
+		nextActiveFrom := newest.ActiveFrom.Add(r.config.RotationInterval)
+		if !now.Before(nextActiveFrom.Add(-r.config.Prepublish)) {
+			if nextActiveFrom.Before(now.Add(r.config.Prepublish)) {
+				nextActiveFrom = now.Add(r.config.Prepublish)
+			}
+			if _, err := r.addKey(ctx, nextActiveFrom); err != nil {
+				return err
+			}
+		}
+	}
+
+	keys, err = r.list(ctx)
+	if err != nil {
+		return err
+	}
+
+	// A key stopped signing when the next one became active
+	for i := 0; i < len(keys)-1; i++ {
+		replacedAt := keys[i+1].ActiveFrom
+		if replacedAt.After(now) || now.Sub(replacedAt) < r.config.Retention {
+			continue
+		}
+		if err := r.store.Delete(ctx, keys[i].ID); err != nil {
+			return fmt.Errorf("failed to delete signing key: %w", err)
+		}
+		r.logger.Info("Signing key retired", "kid", keys[i].ID)
+	}
+
+	return r.reload(ctx)
+}
+
+// Rotate publishes a new key that starts signing after the prepublish period
+func (r *KeyRing) Rotate(ctx context.Context) (*SigningKey, error) {
+	key, err := r.addKey(ctx, time.Now().Add(r.config.Prepublish))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.reload(ctx); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// JWKS returns the public keys of every key that may have signed a valid token
+// or is about to start signing
+func (r *KeyRing) JWKS() JWKS {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	set := JWKS{Keys: make([]JWK, 0, len(r.keys))}
+	for _, key := range r.keys {
+		jwk, err := key.PublicJWK()
+		if err != nil {
+			r.logger.Error("Failed to encode signing key", "error", err, "kid", key.ID)
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
+
+// signingKey returns the newest key that is already active
+func (r *KeyRing) signingKey() (*SigningKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	for i := len(r.keys) - 1; i >= 0; i-- {
+		if !r.keys[i].ActiveFrom.After(now) {
+			return r.keys[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("no active signing key")
+}
+
+// verificationKey finds a key by ID, reloading the store when another
+// replica may have added it
+func (r *KeyRing) verificationKey(kid string) (*SigningKey, error) {
+	if key := r.lookup(kid); key != nil {
+		return key, nil
+	}
+
+	r.mu.RLock()
+	recentlyLoaded := time.Since(r.loadedAt) < keyReloadInterval
+	r.mu.RUnlock()
+
+	if !recentlyLoaded {
+		ctx, cancel := context.WithTimeout(context.Background(), keyStoreTimeout)
+		defer cancel()
+
+		if err := r.reload(ctx); err != nil {
+			return nil, err
+		}
+		if key := r.lookup(kid); key != nil {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (r *KeyRing) lookup(kid string) *SigningKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, key := range r.keys {
+		if key.ID == kid {
+			return key
+		}
+	}
+	return nil
+}
+
+func (r *KeyRing) addKey(ctx context.Context, activeFrom time.Time) (*SigningKey, error) {
+	key, err := GenerateSigningKey(r.config.Algorithm, activeFrom)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.store.Add(ctx, key); err != nil {
+		return nil, fmt.Errorf("failed to store signing key: %w", err)
+	}
+
+	r.logger.Info("Signing key created", "kid", key.ID, "algorithm", key.Algorithm, "active_from", key.ActiveFrom)
+	return key, nil
+}
+
+func (r *KeyRing) reload(ctx context.Context) error {
+	keys, err := r.list(ctx)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.keys = keys
+	r.loadedAt = time.Now()
+	r.mu.Unlock()
+
+	return nil
+}
+
+// list returns the stored keys ordered by when they start signing
+func (r *KeyRing) list(ctx context.Context) ([]*SigningKey, error) {
+	keys, err := r.store.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load signing keys: %w", err)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].ActiveFrom.Equal(keys[j].ActiveFrom) {
+			return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+		}
+		return keys[i].ActiveFrom.Before(keys[j].ActiveFrom)
+	})
+
+	return keys, nil
+}
\ No newline at end of file
@@ -0,0 +1,184 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Supported signing algorithms
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// Size of generated RSA keys in bits
+const rsaKeySize = 2048
+
+// SigningKey is an asymmetric key pair that tokens are signed with. A key is
+// published as soon as it is created but only signs from ActiveFrom on, so
+// verifiers that cache the key set have picked it up by then.
+type SigningKey struct {
+	ID         string
+	Algorithm  string
+	PrivateKey crypto.Signer
+	ActiveFrom time.Time
+	CreatedAt  time.Time
+}
+
+// KeyStore persists signing keys so every replica signs and verifies with the same set
+type KeyStore interface {
+	List(ctx context.Context) ([]*SigningKey, error)
+	Add(ctx context.Context, key *SigningKey) error
+	Delete(ctx context.Context, id string) error
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517)
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set as served from /.well-known/jwks.json
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// GenerateSigningKey creates a new key pair for an asymmetric algorithm
+func GenerateSigningKey(algorithm string, activeFrom time.Time) (*SigningKey, error) {
+	var privateKey crypto.Signer
+
+	switch algorithm {
+	case AlgorithmRS256:
+		key, err := rsa.GenerateKey(rand.Reader, rsaKeySize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate RSA key: %w", err)
+		}
+		privateKey = key
+	case AlgorithmEdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate Ed25519 key: %w", err)
+		}
+		privateKey = key
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm: %s", algorithm)
+	}
+
+	return &SigningKey{
+		ID:         uuid.New().String(),
+		Algorithm:  algorithm,
+		PrivateKey: privateKey,
+		ActiveFrom: activeFrom,
+		CreatedAt:  time.Now(),
+	}, nil
+}
+
+// MarshalPrivateKey encodes a private key as PKCS #8 PEM for storage
+func MarshalPrivateKey(key crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal private key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// ParsePrivateKey decodes a PKCS #8 PEM private key
+func ParsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("invalid private key PEM")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	return signer, nil
+}
+
+// PublicJWK returns the public half of the key in JWK format
+func (k *SigningKey) PublicJWK() (JWK, error) {
+	jwk := JWK{
+		KeyID:     k.ID,
+		Use:       "sig",
+		Algorithm: k.Algorithm,
+	}
+
+	switch public := k.PrivateKey.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	default:
+		return JWK{}, fmt.Errorf("unsupported public key type %T", public)
+	}
+
+	return jwk, nil
+}
+
+type memoryKeyStore struct {
+	mu   sync.Mutex
+	keys map[string]*SigningKey
+}
+
+// NewMemoryKeyStore creates a process-local key store for tests and single-instance development
+func NewMemoryKeyStore() KeyStore {
+	return &memoryKeyStore{
+		keys: make(map[string]*SigningKey),
+	}
+}
+
+func (s *memoryKeyStore) List(ctx context.Context) ([]*SigningKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]*SigningKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (s *memoryKeyStore) Add(ctx context.Context, key *SigningKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[key.ID] = key
+	return nil
+}
+
+func (s *memoryKeyStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.keys, id)
+	return nil
+}
\ No newline at end of file
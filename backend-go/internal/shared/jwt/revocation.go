@@ -0,0 +1,57 @@
+package jwt
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RevocationStore records revoked token IDs until the tokens would have expired anyway
+type RevocationStore interface {
+	Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, tokenID string) (bool, error)
+}
+
+type memoryRevocationStore struct {
+	mu      sync.Mutex
+	revoked map[string]time.Time
+}
+
+// NewMemoryRevocationStore creates a process-local revocation store for tests and single-instance development
+func NewMemoryRevocationStore() RevocationStore {
+	return &memoryRevocationStore{
+		revoked: make(map[string]time.Time),
+	}
+}
+
+func (s *memoryRevocationStore) Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, exp := range s.revoked {
+		if !exp.After(now) {
+			delete(s.revoked, id)
+		}
+	}
+
+	if expiresAt.After(now) {
+		s.revoked[tokenID] = expiresAt
+	}
+	return nil
+}
+
+func (s *memoryRevocationStore) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exp, ok := s.revoked[tokenID]
+	if !ok {
+		return false, nil
+	}
+	if !exp.After(time.Now()) {
+		delete(s.revoked, tokenID)
+		return false, nil
+	}
+	return true, nil
+}
\ No newline at end of file
@@ -1,48 +1,91 @@
 package jwt
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
+const (
+	// revocationCheckTimeout bounds how long a validation waits on the revocation store
+	revocationCheckTimeout = 2 * time.Second
+
+	// ChallengeTokenDuration is how long a two-factor login challenge stays valid
+	ChallengeTokenDuration = 5 * time.Minute
+
+	// purposeTwoFactorChallenge marks tokens that only prove the password step of a login
+	purposeTwoFactorChallenge = "2fa_challenge"
+)
+
+// ErrRevocationUnavailable is returned when the revocation store cannot tell
+// whether a token was revoked
+var ErrRevocationUnavailable = errors.New("revocation store unavailable")
+
 // Service defines the interface for JWT operations
 type Service interface {
-	GenerateToken(userID, email string) (string, time.Time, error)
+	GenerateToken(userID, email, sessionID string) (string, time.Time, error)
 	ValidateToken(tokenString string) (map[string]interface{}, error)
 	BlacklistToken(tokenString string) error
+	// RevokeSession rejects every access token issued for a session
+	RevokeSession(sessionID string) error
+	// GenerateChallengeToken issues a short-lived token proving a correct
+	// password, to be exchanged for a session once the second factor checks out
+	GenerateChallengeToken(userID string) (string, time.Time, error)
+	ValidateChallengeToken(tokenString string) (string, error)
 }
 
 type service struct {
-	secretKey     []byte
+	secretKey     []byte   // Signs HS256 tokens, or only verifies them when keys is set
+	keys          *KeyRing // Signs with asymmetric keys when set
 	tokenDuration time.Duration
-	blacklist     map[string]bool // In production, use Redis or database
+	revocations   RevocationStore
 }
 
 // Claims represents the JWT claims
 type Claims struct {
-	UserID string `json:"user_id"`
-	Email  string `json:"email"`
+	UserID    string `json:"user_id"`
+	Email     string `json:"email"`
+	SessionID string `json:"sid,omitempty"`
+	Purpose   string `json:"purpose,omitempty"` // Empty for access tokens
 	jwt.RegisteredClaims
 }
 
-// NewService creates a new JWT service
-func NewService(secretKey string, tokenDuration time.Duration) Service {
+// NewService creates a new JWT service backed by the given revocation store
+func NewService(secretKey string, tokenDuration time.Duration, revocations RevocationStore) Service {
 	return &service{
 		secretKey:     []byte(secretKey),
 		tokenDuration: tokenDuration,
-		blacklist:     make(map[string]bool),
+		revocations:   revocations,
+	}
+}
+
+// NewKeyRingService creates a JWT service that signs with the key ring's
+// asymmetric keys, so other services can verify tokens from the published key
+// set. A non-empty legacySecret keeps HS256 tokens signed with it valid, so
+// switching from a shared secret does not sign everyone out; drop it once
+// those tokens have expired.
+func NewKeyRingService(keys *KeyRing, legacySecret string, tokenDuration time.Duration, revocations RevocationStore) Service {
+	return &service{
+		secretKey:     []byte(legacySecret),
+		keys:          keys,
+		tokenDuration: tokenDuration,
+		revocations:   revocations,
 	}
 }
 
-func (s *service) GenerateToken(userID, email string) (string, time.Time, error) {
+func (s *service) GenerateToken(userID, email, sessionID string) (string, time.Time, error) {
 	expiresAt := time.Now().Add(s.tokenDuration)
 
 	claims := Claims{
-		UserID: userID,
-		Email:  email,
+		UserID:    userID,
+		Email:     email,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
 			ExpiresAt: jwt.NewNumericDate(expiresAt),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
@@ -51,43 +94,33 @@ func (s *service) GenerateToken(userID, email string) (string, time.Time, error)
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(s.secretKey)
+	tokenString, err := s.sign(claims)
 	if err != nil {
-		return "", time.Time{}, fmt.Errorf("failed to sign token: %w", err)
+		return "", time.Time{}, err
 	}
 
 	return tokenString, expiresAt, nil
 }
 
 func (s *service) ValidateToken(tokenString string) (map[string]interface{}, error) {
-	// Check if token is blacklisted
-	if s.blacklist[tokenString] {
-		return nil, fmt.Errorf("token is blacklisted")
-	}
-
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return s.secretKey, nil
-	})
-
+	claims, err := s.parseToken(tokenString)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse token: %w", err)
+		return nil, err
 	}
 
-	if !token.Valid {
-		return nil, fmt.Errorf("invalid token")
+	// Challenge tokens must never be accepted as access tokens
+	if claims.Purpose != "" {
+		return nil, fmt.Errorf("token cannot be used for authentication")
 	}
 
-	claims, ok := token.Claims.(*Claims)
-	if !ok {
-		return nil, fmt.Errorf("invalid token claims")
+	if err := s.checkRevoked(claims); err != nil {
+		return nil, err
 	}
 
 	// Convert claims to map
 	claimsMap := map[string]interface{}{
+		"jti":     claims.ID,
+		"sid":     claims.SessionID,
 		"user_id": claims.UserID,
 		"email":   claims.Email,
 		"exp":     claims.ExpiresAt.Time,
@@ -100,8 +133,187 @@ func (s *service) ValidateToken(tokenString string) (map[string]interface{}, err
 	return claimsMap, nil
 }
 
+func (s *service) GenerateChallengeToken(userID string) (string, time.Time, error) {
+	expiresAt := time.Now().Add(ChallengeTokenDuration)
+
+	claims := Claims{
+		UserID:  userID,
+		Purpose: purposeTwoFactorChallenge,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+			Issuer:    "whatsapp-chat-backend",
+			Subject:   userID,
+		},
+	}
+
+	tokenString, err := s.sign(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return tokenString, expiresAt, nil
+}
+
+func (s *service) ValidateChallengeToken(tokenString string) (string, error) {
+	claims, err := s.parseToken(tokenString)
+	if err != nil {
+		return "", err
+	}
+
+	if claims.Purpose != purposeTwoFactorChallenge {
+		return "", fmt.Errorf("not a challenge token")
+	}
+
+	if err := s.checkRevoked(claims); err != nil {
+		return "", err
+	}
+
+	return claims.UserID, nil
+}
+
 func (s *service) BlacklistToken(tokenString string) error {
-	// In production, this should be stored in Redis with expiration
-	s.blacklist[tokenString] = true
+	claims, err := s.parseToken(tokenString)
+	if err != nil {
+		return err
+	}
+
+	if claims.ID == "" {
+		return fmt.Errorf("token has no ID")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), revocationCheckTimeout)
+	defer cancel()
+
+	// The entry only needs to outlive the token itself
+	if err := s.revocations.Revoke(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) RevokeSession(sessionID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), revocationCheckTimeout)
+	defer cancel()
+
+	// Access tokens issued for the session are all expired one token lifetime from now
+	if err := s.revocations.Revoke(ctx, sessionRevocationID(sessionID), time.Now().Add(s.tokenDuration)); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	return nil
+}
+
+// checkRevoked fails closed: a token is only accepted when the store confirms
+// neither it nor its session has been revoked
+func (s *service) checkRevoked(claims *Claims) error {
+	// Tokens issued before revocation support carry no ID and cannot be revoked
+	if claims.ID == "" {
+		return fmt.Errorf("token has no ID")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), revocationCheckTimeout)
+	defer cancel()
+
+	revoked, err := s.revocations.IsRevoked(ctx, claims.ID)
+	if err != nil {
+		return fmt.Errorf("failed to check token revocation: %w: %w", ErrRevocationUnavailable, err)
+	}
+	if revoked {
+		return fmt.Errorf("token is blacklisted")
+	}
+
+	if claims.SessionID != "" {
+		revoked, err = s.revocations.IsRevoked(ctx, sessionRevocationID(claims.SessionID))
+		if err != nil {
+			return fmt.Errorf("failed to check session revocation: %w: %w", ErrRevocationUnavailable, err)
+		}
+		if revoked {
+			return fmt.Errorf("session is revoked")
+		}
+	}
+
 	return nil
+}
+
+// sessionRevocationID keeps session entries apart from token IDs in the revocation store
+func sessionRevocationID(sessionID string) string {
+	return "session:" + sessionID
+}
+
+// sign signs claims with the current key ring key, or the secret without a key ring
+func (s *service) sign(claims Claims) (string, error) {
+	if s.keys == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		tokenString, err := token.SignedString(s.secretKey)
+		if err != nil {
+			return "", fmt.Errorf("failed to sign token: %w", err)
+		}
+		return tokenString, nil
+	}
+
+	key, err := s.keys.signingKey()
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.Algorithm), claims)
+	token.Header["kid"] = key.ID
+
+	tokenString, err := token.SignedString(key.PrivateKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+
+	return tokenString, nil
+}
+
+// verificationKey picks the key a token's signature is checked against
+func (s *service) verificationKey(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if len(s.secretKey) > 0 {
+			return s.secretKey, nil
+		}
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodEd25519:
+		if s.keys != nil {
+			kid, _ := token.Header["kid"].(string)
+			key, err := s.keys.verificationKey(kid)
+			if err != nil {
+				return nil, err
+			}
+			// The key decides the algorithm, never the token header alone
+			if key.Algorithm != token.Method.Alg() {
+				return nil, fmt.Errorf("signing method %s does not match key %q", token.Method.Alg(), kid)
+			}
+			return key.PrivateKey.Public(), nil
+		}
+	}
+
+	return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+}
+
+// parseToken verifies the signature and standard claims of a token
+func (s *service) parseToken(tokenString string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, s.verificationKey,
+		jwt.WithValidMethods([]string{AlgorithmHS256, AlgorithmRS256, AlgorithmEdDSA}),
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse token: %w", err)
+	}
+
+	if !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	claims, ok := token.Claims.(*Claims)
+	if !ok {
+		return nil, fmt.Errorf("invalid token claims")
+	}
+
+	return claims, nil
 }
\ No newline at end of file
@@ -0,0 +1,50 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"backend-go/internal/shared/logger"
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+type logSender struct {
+	logger logger.Logger
+	dir    string
+}
+
+// NewLogSender creates a sender for development and tests that logs messages
+// instead of delivering them. When dir is set each message is also written
+// there as a file so links in it can be followed.
+func NewLogSender(logger logger.Logger, dir string) Sender {
+	return &logSender{
+		logger: logger,
+		dir:    dir,
+	}
+}
+
+func (s *logSender) Send(ctx context.Context, msg Message) error {
+	s.logger.Info("Email not delivered (log mail driver)", "to", msg.To, "subject", msg.Subject)
+
+	if s.dir == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create mail output directory: %w", err)
+	}
+
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), unsafeFileChars.ReplaceAllString(msg.To, "_"))
+	content := fmt.Sprintf("To: %s\nSubject: %s\n\n%s\n", sanitizeHeader(msg.To), sanitizeHeader(msg.Subject), msg.Body)
+
+	if err := os.WriteFile(filepath.Join(s.dir, name), []byte(content), 0600); err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+
+	return nil
+}
\ No newline at end of file
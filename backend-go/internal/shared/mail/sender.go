@@ -0,0 +1,23 @@
+package mail
+
+import (
+	"context"
+	"strings"
+)
+
+// Message represents a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender defines the interface for delivering email
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// sanitizeHeader strips line breaks so header values cannot inject extra headers
+func sanitizeHeader(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
\ No newline at end of file
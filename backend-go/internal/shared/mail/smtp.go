@@ -0,0 +1,60 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+type smtpSender struct {
+	addr     string
+	host     string
+	username string
+	password string
+	from     string
+}
+
+// NewSMTPSender creates a sender that delivers email through an SMTP server
+func NewSMTPSender(host string, port int, username, password, from string) Sender {
+	return &smtpSender{
+		addr:     fmt.Sprintf("%s:%d", host, port),
+		host:     host,
+		username: username,
+		password: password,
+		from:     from,
+	}
+}
+
+func (s *smtpSender) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if s.username != "" {
+		auth = smtp.PlainAuth("", s.username, s.password, s.host)
+	}
+
+	to := sanitizeHeader(msg.To)
+	if err := smtp.SendMail(s.addr, auth, s.from, []string{to}, s.build(to, msg)); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return nil
+}
+
+// build renders the message with its headers
+func (s *smtpSender) build(to string, msg Message) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + sanitizeHeader(s.from) + "\r\n")
+	b.WriteString("To: " + to + "\r\n")
+	b.WriteString("Subject: " + sanitizeHeader(msg.Subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
\ No newline at end of file
@@ -0,0 +1,129 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+
+	// Register GIF decoding for image.Decode
+	_ "image/gif"
+)
+
+var (
+	ErrInvalidImage     = errors.New("invalid image")
+	ErrUnsupportedImage = errors.New("unsupported image type")
+)
+
+const (
+	// ThumbnailSize and PreviewSize are the longest side of the rendered
+	// thumbnail and preview in pixels
+	ThumbnailSize = 320
+	PreviewSize   = 1280
+
+	// MaxPixels limits the images decoded for rendering, since decoding needs
+	// memory in proportion to the pixel count
+	MaxPixels = 50_000_000
+
+	jpegQuality = 82
+)
+
+// Image is an uploaded image with its metadata removed
+type Image struct {
+	Data   []byte // The image without metadata
+	Width  int    // Width as displayed, after applying the orientation
+	Height int
+
+	// Thumbnail and Preview are nil when the image cannot be rendered
+	Thumbnail []byte
+	Preview   []byte
+	// RenderType is the MIME type of the thumbnail and preview: JPEG for
+	// opaque images, PNG for images with transparency
+	RenderType string
+}
+
+// IsImage checks if ProcessImage handles content of the given MIME type
+func IsImage(mimeType string) bool {
+	switch mimeType {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+		return true
+	}
+	return false
+}
+
+// ProcessImage strips metadata such as EXIF and GPS data from an image, reads
+// its dimensions and renders a thumbnail and a preview. WebP images are only
+// stripped, since the standard library cannot decode them.
+func ProcessImage(data []byte, mimeType string) (*Image, error) {
+	orientation := 1
+	var err error
+	switch mimeType {
+	case "image/jpeg":
+		data, orientation, err = stripJPEG(data)
+	case "image/png":
+		data, orientation, err = stripPNG(data)
+	case "image/gif":
+		// GIF has no standard place for EXIF or location data
+	case "image/webp":
+		if data, err = stripWebP(data); err != nil {
+			return nil, err
+		}
+		width, height, err := webpSize(data)
+		if err != nil {
+			return nil, err
+		}
+		return &Image{Data: data, Width: width, Height: height}, nil
+	default:
+		return nil, ErrUnsupportedImage
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || config.Width <= 0 || config.Height <= 0 {
+		return nil, ErrInvalidImage
+	}
+	result := &Image{Data: data, Width: config.Width, Height: config.Height}
+	if orientation >= 5 {
+		result.Width, result.Height = result.Height, result.Width
+	}
+	if config.Width*config.Height > MaxPixels {
+		return result, nil
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	preview := orient(fit(decoded, PreviewSize), orientation)
+	thumbnail := fit(preview, ThumbnailSize)
+
+	result.RenderType = "image/jpeg"
+	if !preview.Opaque() {
+		result.RenderType = "image/png"
+	}
+	if result.Preview, err = encode(preview, result.RenderType); err != nil {
+		return nil, err
+	}
+	if result.Thumbnail, err = encode(thumbnail, result.RenderType); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// encode writes a rendered image as JPEG or PNG
+func encode(img image.Image, mimeType string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if mimeType == "image/png" {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
\ No newline at end of file
@@ -0,0 +1,282 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+)
+
+// Metadata is removed without re-encoding the image, so quality is kept.
+// Only the EXIF orientation survives, since viewers need it to show the
+// image the right way up.
+
+const orientationTag = 0x0112
+
+var (
+	exifHeader = []byte("Exif\x00\x00")
+	pngHeader  = []byte("\x89PNG\r\n\x1a\n")
+	iccHeader  = []byte("ICC_PROFILE\x00")
+	jfifHeader = []byte("JFIF\x00")
+)
+
+// pngMetadataChunks lists the PNG chunks that carry text, EXIF and time stamps
+var pngMetadataChunks = map[string]bool{
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"eXIf": true,
+	"tIME": true,
+}
+
+// stripJPEG removes EXIF, XMP, IPTC and comment segments from a JPEG image
+// and returns it with its EXIF orientation
+func stripJPEG(data []byte) ([]byte, int, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, 0, ErrInvalidImage
+	}
+
+	orientation := 1
+	var segments [][]byte
+	i := 2
+	for {
+		// Markers may be padded with any number of 0xFF bytes
+		for i < len(data) && data[i] == 0xFF && i+1 < len(data) && data[i+1] == 0xFF {
+			i++
+		}
+		if i+4 > len(data) || data[i] != 0xFF {
+			return nil, 0, ErrInvalidImage
+		}
+		marker := data[i+1]
+		if marker == 0xDA {
+			// Start of scan; the compressed data and everything after it is kept
+			segments = append(segments, data[i:])
+			break
+		}
+
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return nil, 0, ErrInvalidImage
+		}
+		segment := data[i : i+2+length]
+		payload := segment[4:]
+		i += 2 + length
+
+		switch {
+		case marker == 0xE1 && bytes.HasPrefix(payload, exifHeader):
+			if o := exifOrientation(payload[len(exifHeader):]); o > 1 {
+				orientation = o
+			}
+			continue
+		case marker == 0xE0 && !bytes.HasPrefix(payload, jfifHeader):
+			// JFIF extensions hold embedded thumbnails
+			continue
+		case marker == 0xE2 && bytes.HasPrefix(payload, iccHeader):
+			// Colour profiles change how the image looks
+		case marker == 0xEE:
+			// Adobe segments describe the colour transform
+		case marker >= 0xE1 && marker <= 0xEF, marker == 0xFE:
+			continue
+		}
+		segments = append(segments, segment)
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, 0xFF, 0xD8)
+	// A JFIF segment must stay first, so the orientation follows it
+	if len(segments) > 0 && segments[0][1] == 0xE0 {
+		out = append(out, segments[0]...)
+		segments = segments[1:]
+	}
+	if orientation > 1 {
+		exif := append(append([]byte(nil), exifHeader...), orientationExif(orientation)...)
+		out = append(out, 0xFF, 0xE1, byte((len(exif)+2)>>8), byte(len(exif)+2))
+		out = append(out, exif...)
+	}
+	for _, segment := range segments {
+		out = append(out, segment...)
+	}
+	return out, orientation, nil
+}
+
+// stripPNG removes text, EXIF and time chunks from a PNG image and returns it
+// with its EXIF orientation
+func stripPNG(data []byte) ([]byte, int, error) {
+	chunks, err := pngChunks(data)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	orientation := 1
+	for _, chunk := range chunks {
+		if string(chunk[4:8]) == "eXIf" {
+			if o := exifOrientation(chunk[8 : len(chunk)-4]); o > 1 {
+				orientation = o
+			}
+		}
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, pngHeader...)
+	for _, chunk := range chunks {
+		chunkType := string(chunk[4:8])
+		if pngMetadataChunks[chunkType] {
+			continue
+		}
+		out = append(out, chunk...)
+		// The orientation must come before the image data
+		if chunkType == "IHDR" && orientation > 1 {
+			out = appendPNGChunk(out, "eXIf", orientationExif(orientation))
+		}
+	}
+	return out, orientation, nil
+}
+
+// pngChunks splits a PNG image into its chunks, each with length and CRC
+func pngChunks(data []byte) ([][]byte, error) {
+	if !bytes.HasPrefix(data, pngHeader) {
+		return nil, ErrInvalidImage
+	}
+
+	var chunks [][]byte
+	for i := len(pngHeader); i < len(data); {
+		if i+12 > len(data) {
+			return nil, ErrInvalidImage
+		}
+		end := i + 12 + int(binary.BigEndian.Uint32(data[i:]))
+		if end < i+12 || end > len(data) {
+			return nil, ErrInvalidImage
+		}
+		chunks = append(chunks, data[i:end])
+		i = end
+	}
+	if len(chunks) == 0 || string(chunks[0][4:8]) != "IHDR" {
+		return nil, ErrInvalidImage
+	}
+	return chunks, nil
+}
+
+// stripWebP removes EXIF and XMP chunks from a WebP image
+func stripWebP(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, ErrInvalidImage
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:12]...)
+	for i := 12; i < len(data); {
+		if i+8 > len(data) {
+			return nil, ErrInvalidImage
+		}
+		end := i + 8 + int(binary.LittleEndian.Uint32(data[i+4:]))
+		if end < i+8 || end > len(data) {
+			return nil, ErrInvalidImage
+		}
+		// Chunks are padded to an even size
+		if (end-i)%2 == 1 && end < len(data) {
+			end++
+		}
+		fourCC := string(data[i : i+4])
+		chunk := data[i:end]
+		i = end
+
+		switch fourCC {
+		case "EXIF", "XMP ":
+			continue
+		case "VP8X":
+			// Clear the flags announcing EXIF and XMP chunks
+			chunk = append([]byte(nil), chunk...)
+			if len(chunk) > 8 {
+				chunk[8] &^= 0x0C
+			}
+		}
+		out = append(out, chunk...)
+	}
+
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out, nil
+}
+
+// webpSize reads the canvas size of a WebP image from its first chunk
+func webpSize(data []byte) (int, int, error) {
+	if len(data) < 30 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return 0, 0, ErrInvalidImage
+	}
+	chunk := data[20:]
+	switch string(data[12:16]) {
+	case "VP8X":
+		width := int(chunk[4]) | int(chunk[5])<<8 | int(chunk[6])<<16
+		height := int(chunk[7]) | int(chunk[8])<<8 | int(chunk[9])<<16
+		return width + 1, height + 1, nil
+	case "VP8L":
+		if chunk[0] != 0x2F {
+			return 0, 0, ErrInvalidImage
+		}
+		bits := binary.LittleEndian.Uint32(chunk[1:])
+		return int(bits&0x3FFF) + 1, int(bits>>14&0x3FFF) + 1, nil
+	case "VP8 ":
+		if chunk[3] != 0x9D || chunk[4] != 0x01 || chunk[5] != 0x2A {
+			return 0, 0, ErrInvalidImage
+		}
+		return int(binary.LittleEndian.Uint16(chunk[6:]) & 0x3FFF), int(binary.LittleEndian.Uint16(chunk[8:]) & 0x3FFF), nil
+	}
+	return 0, 0, ErrInvalidImage
+}
+
+// exifOrientation reads the orientation tag from TIFF-structured EXIF data,
+// returning 0 if there is none
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 0
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for n := 0; n < count; n++ {
+		entry := ifd + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		if order.Uint16(tiff[entry:]) == orientationTag {
+			if o := int(order.Uint16(tiff[entry+8:])); o >= 1 && o <= 8 {
+				return o
+			}
+			return 0
+		}
+	}
+	return 0
+}
+
+// orientationExif builds TIFF-structured EXIF data holding only an orientation
+func orientationExif(orientation int) []byte {
+	tiff := []byte{'M', 'M', 0, 42, 0, 0, 0, 8, 0, 1}
+	entry := make([]byte, 12)
+	binary.BigEndian.PutUint16(entry[0:], orientationTag)
+	binary.BigEndian.PutUint16(entry[2:], 3) // SHORT
+	binary.BigEndian.PutUint32(entry[4:], 1)
+	binary.BigEndian.PutUint16(entry[8:], uint16(orientation))
+	tiff = append(tiff, entry...)
+	return append(tiff, 0, 0, 0, 0) // No further IFD
+}
+
+// appendPNGChunk appends a chunk with its CRC
+func appendPNGChunk(out []byte, chunkType string, data []byte) []byte {
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(data)))
+	out = append(out, length[:]...)
+	start := len(out)
+	out = append(out, chunkType...)
+	out = append(out, data...)
+	return binary.BigEndian.AppendUint32(out, crc32.ChecksumIEEE(out[start:]))
+}
\ No newline at end of file
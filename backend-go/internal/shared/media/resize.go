@@ -0,0 +1,110 @@
+package media
+
+import "image"
+
+// fit scales an image down so neither side exceeds maxSide, averaging the
+// source pixels that make up each target pixel. Smaller images keep their size.
+func fit(src image.Image, maxSide int) *image.RGBA {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	dw, dh := w, h
+	if w > maxSide || h > maxSide {
+		if w >= h {
+			dw, dh = maxSide, max(1, h*maxSide/w)
+		} else {
+			dw, dh = max(1, w*maxSide/h), maxSide
+		}
+	}
+
+	at := pixelReader(src)
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*h/dh, max((y+1)*h/dh, y*h/dh+1)
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*w/dw, max((x+1)*w/dw, x*w/dw+1)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := at(bounds.Min.X+sx, bounds.Min.Y+sy)
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+
+			i := dst.PixOffset(x, y)
+			dst.Pix[i+0] = uint8(r / n >> 8)
+			dst.Pix[i+1] = uint8(g / n >> 8)
+			dst.Pix[i+2] = uint8(b / n >> 8)
+			dst.Pix[i+3] = uint8(a / n >> 8)
+		}
+	}
+	return dst
+}
+
+// pixelReader returns a function reading premultiplied 16-bit colour values,
+// avoiding the allocation of image.Image.At for common image types
+func pixelReader(src image.Image) func(x, y int) (uint32, uint32, uint32, uint32) {
+	switch img := src.(type) {
+	case *image.RGBA:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			return img.RGBAAt(x, y).RGBA()
+		}
+	case *image.NRGBA:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			return img.NRGBAAt(x, y).RGBA()
+		}
+	case *image.YCbCr:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			return img.YCbCrAt(x, y).RGBA()
+		}
+	case *image.Gray:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			return img.GrayAt(x, y).RGBA()
+		}
+	case *image.Paletted:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			return img.Palette[img.ColorIndexAt(x, y)].RGBA()
+		}
+	}
+	return func(x, y int) (uint32, uint32, uint32, uint32) {
+		return src.At(x, y).RGBA()
+	}
+}
+
+// orient turns an image the way its EXIF orientation says it should be shown
+func orient(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // Mirrored
+				sx, sy = w-1-x, y
+			case 3: // Upside down
+				sx, sy = w-1-x, h-1-y
+			case 4: // Upside down and mirrored
+				sx, sy = x, h-1-y
+			case 5: // Transposed
+				sx, sy = y, x
+			case 6: // Turned 90 degrees clockwise
+				sx, sy = y, h-1-x
+			case 7: // Transversed
+				sx, sy = w-1-y, h-1-x
+			case 8: // Turned 90 degrees counter-clockwise
+				sx, sy = w-1-y, x
+			}
+			dst.SetRGBA(x, y, src.RGBAAt(sx, sy))
+		}
+	}
+	return dst
+}
\ No newline at end of file
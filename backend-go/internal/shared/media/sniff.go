@@ -0,0 +1,126 @@
+package media
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+)
+
+// SniffLen is how many leading bytes DetectContentType looks at
+const SniffLen = 512
+
+// OctetStream is reported for content of no recognised type
+const OctetStream = "application/octet-stream"
+
+// OLEStorage is reported for legacy Office documents, which share one
+// container format
+const OLEStorage = "application/x-ole-storage"
+
+// signatures lists magic numbers that http.DetectContentType does not know
+var signatures = []struct {
+	offset   int
+	magic    []byte
+	mimeType string
+}{
+	{0, []byte("\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1"), OLEStorage},
+	{0, []byte("Rar!\x1A\x07"), "application/x-rar-compressed"},
+	{0, []byte("7z\xBC\xAF\x27\x1C"), "application/x-7z-compressed"},
+	{0, []byte(`{\rtf`), "application/rtf"},
+	{0, []byte("FLV\x01"), "video/x-flv"},
+	{0, []byte("\x30\x26\xB2\x75\x8E\x66\xCF\x11\xA6\xD9\x00\xAA\x00\x62\xCE\x6C"), "video/x-ms-wmv"},
+	{0, []byte("\x1F\x8B\x08"), "application/gzip"},
+	{257, []byte("ustar"), "application/x-tar"},
+}
+
+// detectedAliases maps types reported by http.DetectContentType to the names
+// used for uploads
+var detectedAliases = map[string]string{
+	"audio/wave":         "audio/wav",
+	"application/ogg":    "audio/ogg",
+	"video/avi":          "video/x-msvideo",
+	"application/x-gzip": "application/gzip",
+}
+
+// DetectContentType determines the MIME type of content from its first
+// SniffLen bytes, without parameters. It returns OctetStream if the type is
+// not recognised.
+func DetectContentType(head []byte) string {
+	if len(head) > SniffLen {
+		head = head[:SniffLen]
+	}
+
+	for _, s := range signatures {
+		if len(head) >= s.offset+len(s.magic) && bytes.Equal(head[s.offset:s.offset+len(s.magic)], s.magic) {
+			return s.mimeType
+		}
+	}
+	if mimeType, ok := detectISOMedia(head); ok {
+		return mimeType
+	}
+	if isMP3(head) {
+		return "audio/mpeg"
+	}
+	if isSVG(head) {
+		return "image/svg+xml"
+	}
+
+	mimeType, _, err := mime.ParseMediaType(http.DetectContentType(head))
+	if err != nil {
+		return OctetStream
+	}
+	if alias, ok := detectedAliases[mimeType]; ok {
+		return alias
+	}
+	return mimeType
+}
+
+// detectISOMedia recognises MP4, M4A and QuickTime files by their ftyp box
+func detectISOMedia(head []byte) (string, bool) {
+	if len(head) < 12 || string(head[4:8]) != "ftyp" {
+		return "", false
+	}
+	switch string(head[8:12]) {
+	case "qt  ":
+		return "video/quicktime", true
+	case "M4A ", "M4B ", "M4P ":
+		return "audio/mp4", true
+	default:
+		return "video/mp4", true
+	}
+}
+
+// isMP3 recognises MP3 files by an ID3 tag or an MPEG audio frame header
+func isMP3(head []byte) bool {
+	if bytes.HasPrefix(head, []byte("ID3")) {
+		return true
+	}
+	// Frame sync, then a valid version, layer III and a valid bitrate
+	return len(head) >= 3 && head[0] == 0xFF && head[1]&0xE0 == 0xE0 &&
+		head[1]&0x18 != 0x08 && head[1]&0x06 == 0x02 && head[2]&0xF0 != 0xF0
+}
+
+// isSVG recognises SVG documents by their root element, which may be
+// preceded by an XML declaration, comments and a doctype
+func isSVG(head []byte) bool {
+	text := bytes.TrimPrefix(head, []byte("\xEF\xBB\xBF"))
+	for {
+		text = bytes.TrimLeft(text, " \t\r\n")
+		var end []byte
+		switch {
+		case bytes.HasPrefix(text, []byte("<?")):
+			end = []byte("?>")
+		case bytes.HasPrefix(text, []byte("<!--")):
+			end = []byte("-->")
+		case bytes.HasPrefix(text, []byte("<!")):
+			end = []byte(">")
+		default:
+			return len(text) > 4 && bytes.EqualFold(text[:4], []byte("<svg")) &&
+				bytes.IndexByte([]byte(" \t\r\n>/"), text[4]) >= 0
+		}
+		i := bytes.Index(text, end)
+		if i < 0 {
+			return false
+		}
+		text = text[i+len(end):]
+	}
+}
\ No newline at end of file
@@ -0,0 +1,32 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+)
+
+// NewCodeVerifier returns a random PKCE code verifier (RFC 7636)
+func NewCodeVerifier() (string, error) {
+	return randomString(32)
+}
+
+// NewState returns a random value for the state or nonce parameters
+func NewState() (string, error) {
+	return randomString(24)
+}
+
+// CodeChallenge derives the S256 code challenge sent with the authorization request
+func CodeChallenge(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func randomString(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate random value: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
\ No newline at end of file
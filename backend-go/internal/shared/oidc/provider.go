@@ -0,0 +1,335 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// Clock skew tolerated when checking ID token timestamps
+	clockSkew = time.Minute
+
+	// Minimum time between key set refreshes triggered by unknown key IDs
+	keyRefreshInterval = time.Minute
+)
+
+// Config describes the client registration at an OpenID Connect provider
+type Config struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Identity holds the verified ID token claims used to sign a user in
+type Identity struct {
+	Issuer            string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
+// Provider is an OpenID Connect relying party using the authorization code
+// flow with PKCE. Provider metadata and signing keys are fetched on first use.
+type Provider struct {
+	config Config
+	client *http.Client
+
+	mu            sync.Mutex
+	metadata      *metadata
+	keys          map[string]*rsa.PublicKey
+	keysFetchedAt time.Time
+}
+
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce             string      `json:"nonce"`
+	Email             string      `json:"email"`
+	EmailVerified     interface{} `json:"email_verified"` // Some providers send "true" as a string
+	Name              string      `json:"name"`
+	PreferredUsername string      `json:"preferred_username"`
+}
+
+// NewProvider creates a provider client; a nil HTTP client uses a default with a timeout
+func NewProvider(config Config, client *http.Client) *Provider {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &Provider{
+		config: config,
+		client: client,
+	}
+}
+
+// AuthCodeURL returns the provider URL the user is sent to for signing in
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	meta, err := p.getMetadata(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.config.ClientID)
+	params.Set("redirect_uri", p.config.RedirectURL)
+	params.Set("scope", strings.Join(p.scopes(), " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", CodeChallenge(codeVerifier))
+	params.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return meta.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the identity from the
+// verified ID token
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Identity, error) {
+	meta, err := p.getMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+	form.Set("client_id", p.config.ClientID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode token response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		if body.Error != "" {
+			return nil, fmt.Errorf("token request rejected: %s %s", body.Error, body.ErrorDescription)
+		}
+		return nil, fmt.Errorf("token request failed with status %d", resp.StatusCode)
+	}
+
+	if body.IDToken == "" {
+		return nil, fmt.Errorf("token response has no id_token")
+	}
+
+	return p.verifyIDToken(ctx, body.IDToken, nonce)
+}
+
+// verifyIDToken checks the signature, issuer, audience, expiry and nonce of an ID token
+func (p *Provider) verifyIDToken(ctx context.Context, rawToken, nonce string) (*Identity, error) {
+	meta, err := p.getMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &idTokenClaims{}
+	_, err = jwt.ParseWithClaims(rawToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.getKey(ctx, meta, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256"}),
+		jwt.WithIssuer(meta.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(clockSkew),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id token: %w", err)
+	}
+
+	if claims.Nonce != nonce {
+		return nil, fmt.Errorf("invalid id token: nonce mismatch")
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("invalid id token: missing subject")
+	}
+
+	return &Identity{
+		Issuer:            claims.Issuer,
+		Subject:           claims.Subject,
+		Email:             claims.Email,
+		EmailVerified:     claims.EmailVerified == true || claims.EmailVerified == "true",
+		Name:              claims.Name,
+		PreferredUsername: claims.PreferredUsername,
+	}, nil
+}
+
+// getMetadata returns the provider metadata, fetching it on first use
+func (p *Provider) getMetadata(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	wellKnown := strings.TrimSuffix(p.config.IssuerURL, "/") + "/.well-known/openid-configuration"
+
+	var meta metadata
+	if err := p.getJSON(ctx, wellKnown, &meta); err != nil {
+		return nil, fmt.Errorf("failed to discover provider: %w", err)
+	}
+
+	// The issuer must match exactly so tokens from another issuer are never accepted
+	if meta.Issuer != p.config.IssuerURL {
+		return nil, fmt.Errorf("provider issuer %q does not match configured issuer %q", meta.Issuer, p.config.IssuerURL)
+	}
+
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, fmt.Errorf("provider metadata is incomplete")
+	}
+
+	p.metadata = &meta
+	return p.metadata, nil
+}
+
+// getKey returns the signing key with the given ID, refreshing the key set
+// when the provider has rotated keys
+func (p *Provider) getKey(ctx context.Context, meta *metadata, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+
+	if p.keys != nil && time.Since(p.keysFetchedAt) < keyRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := p.getJSON(ctx, meta.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("failed to fetch signing keys: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			continue
+		}
+
+		exponent := 0
+		for _, b := range e {
+			exponent = exponent<<8 | int(b)
+		}
+
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exponent}
+	}
+
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookupKey finds a cached key; tokens without a key ID match a provider's only key
+func (p *Provider) lookupKey(kid string) (*rsa.PublicKey, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+// scopes returns the configured scopes, always including openid
+func (p *Provider) scopes() []string {
+	scopes := []string{"openid"}
+	for _, scope := range p.config.Scopes {
+		if scope != "openid" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
\ No newline at end of file
@@ -0,0 +1,79 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// StateTTL is how long a user has to complete sign-in at the provider
+const StateTTL = 10 * time.Minute
+
+// ErrStateNotFound is returned for unknown, expired or already used login state
+var ErrStateNotFound = errors.New("unknown or expired login state")
+
+// LoginState is what is remembered between sending the user to the provider
+// and handling the callback
+type LoginState struct {
+	CodeVerifier string `json:"code_verifier"`
+	Nonce        string `json:"nonce"`
+	DeviceName   string `json:"device_name,omitempty"`
+}
+
+// StateStore keeps pending logins keyed by their state parameter
+type StateStore interface {
+	Save(ctx context.Context, state string, login LoginState, ttl time.Duration) error
+	// Take returns and removes the login state so each callback is handled once
+	Take(ctx context.Context, state string) (*LoginState, error)
+}
+
+type memoryEntry struct {
+	login     LoginState
+	expiresAt time.Time
+}
+
+type memoryStateStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+}
+
+// NewMemoryStateStore creates a process-local state store for tests and single-instance development
+func NewMemoryStateStore() StateStore {
+	return &memoryStateStore{
+		entries: make(map[string]memoryEntry),
+	}
+}
+
+func (s *memoryStateStore) Save(ctx context.Context, state string, login LoginState, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for key, entry := range s.entries {
+		if !entry.expiresAt.After(now) {
+			delete(s.entries, key)
+		}
+	}
+
+	s.entries[state] = memoryEntry{login: login, expiresAt: now.Add(ttl)}
+	return nil
+}
+
+func (s *memoryStateStore) Take(ctx context.Context, state string) (*LoginState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[state]
+	if !ok {
+		return nil, ErrStateNotFound
+	}
+	delete(s.entries, state)
+
+	if !entry.expiresAt.After(time.Now()) {
+		return nil, ErrStateNotFound
+	}
+
+	login := entry.login
+	return &login, nil
+}
\ No newline at end of file
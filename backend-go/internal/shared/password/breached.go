@@ -0,0 +1,64 @@
+package password
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrBreached is returned for passwords found in a list of breached passwords
+var ErrBreached = errors.New("password has appeared in a data breach; please choose another")
+
+// BreachChecker reports whether a password is known from data breaches
+type BreachChecker interface {
+	IsBreached(ctx context.Context, password string) (bool, error)
+}
+
+type rangeFileChecker struct {
+	dir string
+}
+
+// NewRangeFileChecker checks passwords against an offline copy of a breached
+// password list in the k-anonymity layout of Pwned Passwords: the file named
+// after the first five hex digits of a password's SHA-1 hash, such as
+// "5BAA6.txt", holds "SUFFIX:COUNT" lines for the remaining 35. Only that one
+// file is read per check. Missing files are treated as empty, so a partial
+// copy of the list still works.
+func NewRangeFileChecker(dir string) BreachChecker {
+	return &rangeFileChecker{dir: dir}
+}
+
+func (c *rangeFileChecker) IsBreached(ctx context.Context, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	file, err := os.Open(filepath.Join(c.dir, prefix+".txt"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to open breached password range: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		entry, _, _ := strings.Cut(line, ":")
+		if strings.EqualFold(entry, suffix) {
+			return true, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("failed to read breached password range: %w", err)
+	}
+
+	return false, nil
+}
\ No newline at end of file
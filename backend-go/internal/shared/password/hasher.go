@@ -0,0 +1,57 @@
+package password
+
+import (
+	"fmt"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Hasher hashes passwords with bcrypt at a configurable cost
+type Hasher struct {
+	cost int
+
+	dummyOnce sync.Once
+	dummyHash []byte
+}
+
+// NewHasher creates a hasher; a zero cost uses bcrypt's default
+func NewHasher(cost int) *Hasher {
+	if cost == 0 {
+		cost = bcrypt.DefaultCost
+	}
+	return &Hasher{cost: cost}
+}
+
+// Hash returns the bcrypt hash of a password
+func (h *Hasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// Compare returns nil if the password matches the hash
+func (h *Hasher) Compare(hash, password string) error {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+}
+
+// CompareDummy takes as long as comparing against a real hash, for when there
+// is no user, so response times don't reveal which accounts exist
+func (h *Hasher) CompareDummy(password string) {
+	h.dummyOnce.Do(func() {
+		h.dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not-a-real-password"), h.cost)
+	})
+	bcrypt.CompareHashAndPassword(h.dummyHash, []byte(password))
+}
+
+// NeedsRehash reports whether a hash was made at a different cost than the
+// hasher's, so it should be replaced the next time the password is known
+func (h *Hasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return false
+	}
+	return cost != h.cost
+}
\ No newline at end of file
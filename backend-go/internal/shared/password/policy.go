@@ -0,0 +1,91 @@
+package password
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxBcryptLength is the longest password bcrypt can hash, in bytes
+const MaxBcryptLength = 72
+
+// Policy describes the passwords users may choose
+type Policy struct {
+	MinLength     int // In characters
+	MaxLength     int // In bytes; at most MaxBcryptLength
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+}
+
+// PolicyError lists every rule a password breaks, so users can fix them all at once
+type PolicyError struct {
+	Problems []string
+}
+
+func (e *PolicyError) Error() string {
+	return "password " + strings.Join(e.Problems, ", ")
+}
+
+// Validate checks a password against the policy. Passwords containing any of
+// the personal values, such as the username, are rejected as easy to guess.
+func (p Policy) Validate(password string, personal ...string) error {
+	var problems []string
+
+	if utf8.RuneCountInString(password) < p.MinLength {
+		problems = append(problems, fmt.Sprintf("must be at least %d characters", p.MinLength))
+	}
+
+	maxLength := p.MaxLength
+	if maxLength <= 0 || maxLength > MaxBcryptLength {
+		maxLength = MaxBcryptLength
+	}
+	if len(password) > maxLength {
+		problems = append(problems, fmt.Sprintf("must be at most %d bytes", maxLength))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+
+	if p.RequireUpper && !hasUpper {
+		problems = append(problems, "must contain an uppercase letter")
+	}
+	if p.RequireLower && !hasLower {
+		problems = append(problems, "must contain a lowercase letter")
+	}
+	if p.RequireDigit && !hasDigit {
+		problems = append(problems, "must contain a digit")
+	}
+	if p.RequireSymbol && !hasSymbol {
+		problems = append(problems, "must contain a symbol")
+	}
+
+	lowered := strings.ToLower(password)
+	for _, value := range personal {
+		// Very short values would match too many unrelated passwords
+		value = strings.ToLower(strings.TrimSpace(value))
+		if utf8.RuneCountInString(value) >= 3 && strings.Contains(lowered, value) {
+			problems = append(problems, "must not contain your username or email")
+			break
+		}
+	}
+
+	if len(problems) > 0 {
+		return &PolicyError{Problems: problems}
+	}
+
+	return nil
+}
\ No newline at end of file
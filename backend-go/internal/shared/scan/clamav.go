@@ -0,0 +1,127 @@
+package scan
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// chunkSize is how much content is sent to clamd per INSTREAM chunk
+const chunkSize = 64 << 10
+
+// ErrScanFailed is returned when clamd cannot scan the content, for
+// example because it exceeds the daemon's StreamMaxLength
+var ErrScanFailed = errors.New("clamd could not scan the content")
+
+type clamAVScanner struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// NewClamAVScanner creates a scanner that streams content to a clamd daemon
+// with the INSTREAM command. address is a host:port, optionally prefixed
+// with "tcp://", or the path of a Unix socket prefixed with "unix://".
+// timeout bounds each scan, including the transfer of the content.
+func NewClamAVScanner(address string, timeout time.Duration) Scanner {
+	network := "tcp"
+	if path, ok := strings.CutPrefix(address, "unix://"); ok {
+		network, address = "unix", path
+	} else {
+		address = strings.TrimPrefix(address, "tcp://")
+	}
+
+	return &clamAVScanner{
+		network: network,
+		address: address,
+		timeout: timeout,
+	}
+}
+
+func (s *clamAVScanner) Scan(ctx context.Context, content io.Reader) (string, error) {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, s.network, s.address)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to clamd: %w", err)
+	}
+	defer conn.Close()
+
+	// Unblock reads and writes once the context ends
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	// clamd stops reading and replies as soon as it rejects a stream, so a
+	// failed write is reported by the reply if there is one
+	sendErr := s.send(conn, content)
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", fmt.Errorf("failed to scan content: %w", ctxErr)
+		}
+		if sendErr != nil {
+			return "", sendErr
+		}
+		return "", fmt.Errorf("failed to read clamd reply: %w", err)
+	}
+
+	return parseReply(strings.TrimSuffix(reply, "\x00"))
+}
+
+// send streams content as length-prefixed chunks, ending with an empty one
+func (s *clamAVScanner) send(conn net.Conn, content io.Reader) error {
+	if _, err := io.WriteString(conn, "zINSTREAM\x00"); err != nil {
+		return fmt.Errorf("failed to send clamd command: %w", err)
+	}
+
+	buf := make([]byte, 4+chunkSize)
+	for {
+		n, readErr := io.ReadFull(content, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, err := conn.Write(buf[:4+n]); err != nil {
+				return fmt.Errorf("failed to send content to clamd: %w", err)
+			}
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			return fmt.Errorf("failed to read content: %w", readErr)
+		}
+	}
+
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return fmt.Errorf("failed to send content to clamd: %w", err)
+	}
+	return nil
+}
+
+// parseReply reads the verdict from a reply such as "stream: OK" or
+// "stream: Eicar-Test-Signature FOUND"
+func parseReply(reply string) (string, error) {
+	result := strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case result == "OK":
+		return "", nil
+	case strings.HasSuffix(result, " FOUND"):
+		return strings.TrimSuffix(result, " FOUND"), nil
+	case strings.HasSuffix(result, " ERROR"):
+		return "", fmt.Errorf("%w: %s", ErrScanFailed, strings.TrimSuffix(result, " ERROR"))
+	default:
+		return "", fmt.Errorf("unexpected clamd reply %q", reply)
+	}
+}
\ No newline at end of file
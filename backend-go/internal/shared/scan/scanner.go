@@ -0,0 +1,13 @@
+package scan
+
+import (
+	"context"
+	"io"
+)
+
+// Scanner defines the interface for checking file content for malware
+type Scanner interface {
+	// Scan reads content to the end and returns the name of the malware
+	// found in it, or an empty string if it is clean
+	Scan(ctx context.Context, content io.Reader) (string, error)
+}
\ No newline at end of file
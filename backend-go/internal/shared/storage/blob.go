@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+var (
+	ErrNotFound            = errors.New("blob not found")
+	ErrPresignNotSupported = errors.New("pre-signed URLs are not supported by this storage")
+)
+
+// MaxPresignExpiry is the longest lifetime S3 accepts for a pre-signed URL
+const MaxPresignExpiry = 7 * 24 * time.Hour
+
+// Blob defines the interface for storing opaque content under string keys
+type Blob interface {
+	// Put stores exactly size bytes read from content
+	Put(ctx context.Context, key string, content io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (*Object, error)
+	Delete(ctx context.Context, key string) error
+	// Copy stores the content of srcKey under dstKey as well. Returns
+	// ErrNotFound if srcKey does not exist.
+	Copy(ctx context.Context, srcKey, dstKey string) error
+	// List calls fn for every stored object, in no particular order, and
+	// stops at the first error fn returns. fn may delete objects.
+	List(ctx context.Context, fn func(ObjectInfo) error) error
+
+	// PresignPut returns a URL clients can PUT content to directly, sending
+	// the given Content-Type. Returns ErrPresignNotSupported if the storage
+	// cannot be reached by clients.
+	PresignPut(ctx context.Context, key, contentType string, expires time.Duration) (string, error)
+	// PresignGet returns a URL clients can download content from directly.
+	// Returns ErrPresignNotSupported if the storage cannot be reached by clients.
+	PresignGet(ctx context.Context, key string, expires time.Duration, options GetOptions) (string, error)
+}
+
+// Object is stored content opened for reading. The caller must close
+// Content. Seeking is cheap, so parts of large content can be read without
+// reading what comes before them.
+type Object struct {
+	Content io.ReadSeekCloser
+	Size    int64
+}
+
+// ObjectInfo describes a stored object without opening it
+type ObjectInfo struct {
+	Key        string
+	Size       int64
+	ModifiedAt time.Time
+}
+
+// GetOptions overrides response headers of pre-signed downloads
+type GetOptions struct {
+	ContentType        string
+	ContentDisposition string
+	CacheControl       string
+}
\ No newline at end of file
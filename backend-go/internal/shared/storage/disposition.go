@@ -0,0 +1,43 @@
+package storage
+
+import "strings"
+
+// ContentDisposition builds an attachment Content-Disposition header for a
+// file name. The quoted filename is an ASCII-only fallback with anything that
+// could break out of the quotes replaced; the exact name goes in filename*
+// (RFC 6266) percent-encoded, which leaves nothing to inject.
+func ContentDisposition(name string) string {
+	fallback := strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' || r == '%' {
+			return '_'
+		}
+		return r
+	}, name)
+	if fallback == "" {
+		fallback = "download"
+	}
+
+	value := `attachment; filename="` + fallback + `"`
+	if fallback != name && name != "" {
+		value += "; filename*=UTF-8''" + encodeRFC5987(name)
+	}
+	return value
+}
+
+// encodeRFC5987 percent-encodes every byte that is not an RFC 5987 attr-char
+func encodeRFC5987(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' ||
+			strings.IndexByte("!#$&+-.^_`|~", ch) >= 0 {
+			b.WriteByte(ch)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[ch>>4])
+		b.WriteByte(hex[ch&0x0f])
+	}
+	return b.String()
+}
\ No newline at end of file
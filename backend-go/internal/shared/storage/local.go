@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type localBlob struct {
+	dir string
+}
+
+// NewLocalBlob stores content as files in dir, creating it if needed. Files
+// are only reachable through the API, so it cannot pre-sign URLs.
+func NewLocalBlob(dir string) (Blob, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create upload directory: %w", err)
+	}
+	return &localBlob{dir: dir}, nil
+}
+
+func (b *localBlob) Put(ctx context.Context, key string, content io.Reader, size int64, contentType string) error {
+	path, err := b.path(key)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a failed upload never leaves a partial file behind
+	tmp, err := os.CreateTemp(b.dir, ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, io.LimitReader(content, size))
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if written != size {
+		return fmt.Errorf("failed to write file: got %d of %d bytes", written, size)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to save file: %w", err)
+	}
+	return nil
+}
+
+func (b *localBlob) Get(ctx context.Context, key string) (*Object, error) {
+	path, err := b.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	return &Object{Content: f, Size: info.Size()}, nil
+}
+
+func (b *localBlob) Delete(ctx context.Context, key string) error {
+	path, err := b.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	return nil
+}
+
+func (b *localBlob) Copy(ctx context.Context, srcKey, dstKey string) error {
+	object, err := b.Get(ctx, srcKey)
+	if err != nil {
+		return err
+	}
+	defer object.Content.Close()
+
+	return b.Put(ctx, dstKey, object.Content, object.Size, "")
+}
+
+func (b *localBlob) List(ctx context.Context, fn func(ObjectInfo) error) error {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return fmt.Errorf("failed to list files: %w", err)
+	}
+
+	for _, entry := range entries {
+		// Temporary files of writes in progress are not objects
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return fmt.Errorf("failed to list files: %w", err)
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(ObjectInfo{Key: entry.Name(), Size: info.Size(), ModifiedAt: info.ModTime()}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *localBlob) PresignPut(ctx context.Context, key, contentType string, expires time.Duration) (string, error) {
+	return "", ErrPresignNotSupported
+}
+
+func (b *localBlob) PresignGet(ctx context.Context, key string, expires time.Duration, options GetOptions) (string, error) {
+	return "", ErrPresignNotSupported
+}
+
+// path maps a key to a file directly inside the storage directory
+func (b *localBlob) path(key string) (string, error) {
+	if key == "" || strings.ContainsAny(key, `/\`) || strings.HasPrefix(key, ".") {
+		return "", fmt.Errorf("invalid storage key: %q", key)
+	}
+	return filepath.Join(b.dir, key), nil
+}
\ No newline at end of file
@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+type memoryBlob struct {
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+}
+
+type memoryObject struct {
+	data       []byte
+	modifiedAt time.Time
+}
+
+// NewMemoryBlob creates a Blob kept in process memory, for tests and
+// single-instance development setups
+func NewMemoryBlob() Blob {
+	return &memoryBlob{objects: make(map[string]memoryObject)}
+}
+
+func (b *memoryBlob) Put(ctx context.Context, key string, content io.Reader, size int64, contentType string) error {
+	data, err := io.ReadAll(io.LimitReader(content, size))
+	if err != nil {
+		return fmt.Errorf("failed to read content: %w", err)
+	}
+	if int64(len(data)) != size {
+		return fmt.Errorf("failed to read content: got %d of %d bytes", len(data), size)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.objects[key] = memoryObject{data: data, modifiedAt: time.Now()}
+	return nil
+}
+
+func (b *memoryBlob) Get(ctx context.Context, key string) (*Object, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	object, ok := b.objects[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &Object{Content: readSeekNopCloser{bytes.NewReader(object.data)}, Size: int64(len(object.data))}, nil
+}
+
+func (b *memoryBlob) Delete(ctx context.Context, key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.objects, key)
+	return nil
+}
+
+func (b *memoryBlob) Copy(ctx context.Context, srcKey, dstKey string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	object, ok := b.objects[srcKey]
+	if !ok {
+		return ErrNotFound
+	}
+	// Stored content is never modified in place, so the copy can share it
+	b.objects[dstKey] = memoryObject{data: object.data, modifiedAt: time.Now()}
+	return nil
+}
+
+func (b *memoryBlob) List(ctx context.Context, fn func(ObjectInfo) error) error {
+	// Work on a snapshot so fn can modify the storage
+	b.mu.RLock()
+	infos := make([]ObjectInfo, 0, len(b.objects))
+	for key, object := range b.objects {
+		infos = append(infos, ObjectInfo{Key: key, Size: int64(len(object.data)), ModifiedAt: object.modifiedAt})
+	}
+	b.mu.RUnlock()
+
+	for _, info := range infos {
+		if err := fn(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *memoryBlob) PresignPut(ctx context.Context, key, contentType string, expires time.Duration) (string, error) {
+	return "", ErrPresignNotSupported
+}
+
+func (b *memoryBlob) PresignGet(ctx context.Context, key string, expires time.Duration, options GetOptions) (string, error) {
+	return "", ErrPresignNotSupported
+}
+
+// readSeekNopCloser adds a no-op Close to a bytes.Reader while keeping Seek
+type readSeekNopCloser struct {
+	*bytes.Reader
+}
+
+func (readSeekNopCloser) Close() error {
+	return nil
+}
\ No newline at end of file
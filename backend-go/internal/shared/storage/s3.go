@@ -0,0 +1,392 @@
+package storage
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// S3Config describes a bucket on S3 or an S3-compatible store such as MinIO
+type S3Config struct {
+	Endpoint        string // e.g. "https://s3.eu-west-1.amazonaws.com" or "http://localhost:9000"
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	PathStyle       bool // Address the bucket in the path instead of the host name, as MinIO expects
+}
+
+type s3Blob struct {
+	config   S3Config
+	endpoint *url.URL
+	signer   Signer
+	client   *http.Client
+}
+
+// NewS3Blob stores content as objects in an S3-compatible bucket
+func NewS3Blob(config S3Config, client *http.Client) (Blob, error) {
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("invalid S3 endpoint: %q", config.Endpoint)
+	}
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("S3 bucket is required")
+	}
+	if client == nil {
+		// Transfers may take a while; each request is bounded by its context instead
+		client = &http.Client{}
+	}
+
+	return &s3Blob{
+		config:   config,
+		endpoint: endpoint,
+		signer: Signer{
+			AccessKeyID:     config.AccessKeyID,
+			SecretAccessKey: config.SecretAccessKey,
+			Region:          config.Region,
+			Service:         "s3",
+		},
+		client: client,
+	}, nil
+}
+
+func (b *s3Blob) Put(ctx context.Context, key string, content io.Reader, size int64, contentType string) error {
+	req, err := b.newRequest(ctx, http.MethodPut, key, io.LimitReader(content, size))
+	if err != nil {
+		return err
+	}
+	// S3 needs the length up front; it does not accept chunked uploads
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := b.do(req, UnsignedPayload)
+	if err != nil {
+		return fmt.Errorf("failed to upload object: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to upload object: %w", readS3Error(resp))
+	}
+	return nil
+}
+
+func (b *s3Blob) Get(ctx context.Context, key string) (*Object, error) {
+	resp, err := b.getObject(ctx, key, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	object := &s3Object{ctx: ctx, blob: b, key: key, size: resp.ContentLength, body: resp.Body}
+	return &Object{Content: object, Size: resp.ContentLength}, nil
+}
+
+func (b *s3Blob) Delete(ctx context.Context, key string) error {
+	req, err := b.newRequest(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := b.do(req, EmptyPayloadHash)
+	if err != nil {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Deleting a missing object is not an error
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to delete object: %w", readS3Error(resp))
+	}
+	return nil
+}
+
+func (b *s3Blob) Copy(ctx context.Context, srcKey, dstKey string) error {
+	if srcKey == "" || strings.HasPrefix(srcKey, "/") {
+		return fmt.Errorf("invalid storage key: %q", srcKey)
+	}
+	req, err := b.newRequest(ctx, http.MethodPut, dstKey, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Amz-Copy-Source", uriEncode("/"+b.config.Bucket+"/"+srcKey, false))
+
+	resp, err := b.do(req, EmptyPayloadHash)
+	if err != nil {
+		return fmt.Errorf("failed to copy object: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// A copy can still fail after S3 has sent the status line, in which
+		// case the body holds an error instead of the copy result
+		var body struct {
+			XMLName xml.Name
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if xml.Unmarshal(data, &body) == nil && body.XMLName.Local == "Error" {
+			return fmt.Errorf("failed to copy object: %s: %s", body.Code, body.Message)
+		}
+		return nil
+	case http.StatusNotFound:
+		return ErrNotFound
+	default:
+		return fmt.Errorf("failed to copy object: %w", readS3Error(resp))
+	}
+}
+
+func (b *s3Blob) List(ctx context.Context, fn func(ObjectInfo) error) error {
+	token := ""
+	for {
+		page, err := b.listObjects(ctx, token)
+		if err != nil {
+			return err
+		}
+		for _, object := range page.Contents {
+			if err := fn(ObjectInfo{Key: object.Key, Size: object.Size, ModifiedAt: object.LastModified}); err != nil {
+				return err
+			}
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// listObjectsPage is a page of a ListObjectsV2 response
+type listObjectsPage struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+}
+
+// listObjects reads the page of the bucket's objects that continues after token
+func (b *s3Blob) listObjects(ctx context.Context, token string) (*listObjectsPage, error) {
+	u := *b.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/"
+	if b.config.PathStyle {
+		u.Path += b.config.Bucket + "/"
+	} else {
+		u.Host = b.config.Bucket + "." + u.Host
+	}
+	u.RawPath = uriEncode(u.Path, false)
+	query := url.Values{"list-type": {"2"}}
+	if token != "" {
+		query.Set("continuation-token", token)
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := b.do(req, EmptyPayloadHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to list objects: %w", readS3Error(resp))
+	}
+
+	var page listObjectsPage
+	if err := xml.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("failed to read object list: %w", err)
+	}
+	return &page, nil
+}
+
+func (b *s3Blob) PresignPut(ctx context.Context, key, contentType string, expires time.Duration) (string, error) {
+	if err := checkPresignExpiry(expires); err != nil {
+		return "", err
+	}
+
+	req, err := b.newRequest(ctx, http.MethodPut, key, nil)
+	if err != nil {
+		return "", err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	return b.signer.Presign(req, expires, time.Now()), nil
+}
+
+func (b *s3Blob) PresignGet(ctx context.Context, key string, expires time.Duration, options GetOptions) (string, error) {
+	if err := checkPresignExpiry(expires); err != nil {
+		return "", err
+	}
+
+	req, err := b.newRequest(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return "", err
+	}
+
+	query := req.URL.Query()
+	if options.ContentType != "" {
+		query.Set("response-content-type", options.ContentType)
+	}
+	if options.ContentDisposition != "" {
+		query.Set("response-content-disposition", options.ContentDisposition)
+	}
+	if options.CacheControl != "" {
+		query.Set("response-cache-control", options.CacheControl)
+	}
+	req.URL.RawQuery = query.Encode()
+
+	return b.signer.Presign(req, expires, time.Now()), nil
+}
+
+// newRequest creates a request for the object stored under key
+func (b *s3Blob) newRequest(ctx context.Context, method, key string, body io.Reader) (*http.Request, error) {
+	if key == "" || strings.HasPrefix(key, "/") {
+		return nil, fmt.Errorf("invalid storage key: %q", key)
+	}
+
+	u := *b.endpoint
+	u.RawQuery = ""
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	if b.config.PathStyle {
+		u.Path = strings.TrimSuffix(b.endpoint.Path, "/") + "/" + b.config.Bucket + "/" + key
+	} else {
+		u.Host = b.config.Bucket + "." + u.Host
+	}
+	// Send the path exactly as it is signed
+	u.RawPath = uriEncode(u.Path, false)
+
+	return http.NewRequestWithContext(ctx, method, u.String(), body)
+}
+
+// getObject starts reading an object from offset to its end
+func (b *s3Blob) getObject(ctx context.Context, key string, offset int64) (*http.Response, error) {
+	req, err := b.newRequest(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := b.do(req, EmptyPayloadHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusPartialContent:
+		return resp, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrNotFound
+	default:
+		defer resp.Body.Close()
+		return nil, fmt.Errorf("failed to get object: %w", readS3Error(resp))
+	}
+}
+
+func (b *s3Blob) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	b.signer.Sign(req, payloadHash, time.Now())
+	return b.client.Do(req)
+}
+
+func checkPresignExpiry(expires time.Duration) error {
+	if expires < time.Second || expires > MaxPresignExpiry {
+		return fmt.Errorf("pre-signed URL expiry must be between 1s and %s", MaxPresignExpiry)
+	}
+	return nil
+}
+
+// readS3Error describes an S3 error response
+func readS3Error(resp *http.Response) error {
+	var body struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err := xml.Unmarshal(data, &body); err != nil || body.Code == "" {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return fmt.Errorf("%s: %s (status %d)", body.Code, body.Message, resp.StatusCode)
+}
+
+// s3Object reads an object from wherever it was last seeked to. The open
+// response is kept as long as reads continue where it is, and replaced by a
+// range request for the rest of the object otherwise.
+type s3Object struct {
+	ctx     context.Context
+	blob    *s3Blob
+	key     string
+	size    int64
+	pos     int64         // Where the next read starts
+	body    io.ReadCloser // Open response, or nil
+	bodyPos int64         // Where the next read from body starts
+}
+
+func (o *s3Object) Read(p []byte) (int, error) {
+	if o.pos >= o.size {
+		return 0, io.EOF
+	}
+	if o.body != nil && o.bodyPos != o.pos {
+		o.body.Close()
+		o.body = nil
+	}
+	if o.body == nil {
+		resp, err := o.blob.getObject(o.ctx, o.key, o.pos)
+		if err != nil {
+			return 0, err
+		}
+		o.body, o.bodyPos = resp.Body, o.pos
+	}
+
+	n, err := o.body.Read(p)
+	o.pos += int64(n)
+	o.bodyPos = o.pos
+	return n, err
+}
+
+func (o *s3Object) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = o.pos + offset
+	case io.SeekEnd:
+		pos = o.size + offset
+	default:
+		return 0, fmt.Errorf("invalid whence: %d", whence)
+	}
+	if pos < 0 {
+		return 0, fmt.Errorf("negative position: %d", pos)
+	}
+	o.pos = pos
+	return pos, nil
+}
+
+func (o *s3Object) Close() error {
+	if o.body == nil {
+		return nil
+	}
+	err := o.body.Close()
+	o.body = nil
+	return err
+}
\ No newline at end of file
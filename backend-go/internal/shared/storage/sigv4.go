@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	sigV4Algorithm = "AWS4-HMAC-SHA256"
+	amzDateFormat  = "20060102T150405Z"
+
+	// UnsignedPayload is sent as the payload hash when the body is not signed
+	UnsignedPayload = "UNSIGNED-PAYLOAD"
+	// EmptyPayloadHash is the hex SHA-256 of an empty body
+	EmptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// Signer signs requests with AWS Signature Version 4, as accepted by S3 and
+// S3-compatible stores
+type Signer struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	Region          string
+	Service         string
+}
+
+// Sign adds the authorization headers to req. payloadHash is the hex SHA-256
+// of the body, or UnsignedPayload.
+func (s Signer) Sign(req *http.Request, payloadHash string, now time.Time) {
+	now = now.UTC()
+	req.Header.Set("X-Amz-Date", now.Format(amzDateFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders, headers := canonicalHeaders(req)
+	signature := s.signature(now, strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL),
+		canonicalQuery(req.URL.Query()),
+		headers,
+		signedHeaders,
+		payloadHash,
+	}, "\n"))
+
+	req.Header.Set("Authorization", sigV4Algorithm+
+		" Credential="+s.AccessKeyID+"/"+s.scope(now)+
+		", SignedHeaders="+signedHeaders+
+		", Signature="+signature)
+}
+
+// Presign returns the URL of req with query string authorization valid for
+// expires. Headers already set on req, such as Content-Type, are signed and
+// must be sent unchanged by whoever uses the URL.
+func (s Signer) Presign(req *http.Request, expires time.Duration, now time.Time) string {
+	now = now.UTC()
+	signedHeaders, headers := canonicalHeaders(req)
+
+	query := req.URL.Query()
+	query.Set("X-Amz-Algorithm", sigV4Algorithm)
+	query.Set("X-Amz-Credential", s.AccessKeyID+"/"+s.scope(now))
+	query.Set("X-Amz-Date", now.Format(amzDateFormat))
+	query.Set("X-Amz-Expires", strconv.FormatInt(int64(expires/time.Second), 10))
+	query.Set("X-Amz-SignedHeaders", signedHeaders)
+	rawQuery := canonicalQuery(query)
+
+	signature := s.signature(now, strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL),
+		rawQuery,
+		headers,
+		signedHeaders,
+		UnsignedPayload,
+	}, "\n"))
+
+	u := *req.URL
+	u.RawQuery = rawQuery + "&X-Amz-Signature=" + signature
+	return u.String()
+}
+
+func (s Signer) scope(now time.Time) string {
+	return now.Format("20060102") + "/" + s.Region + "/" + s.Service + "/aws4_request"
+}
+
+func (s Signer) signature(now time.Time, canonicalRequest string) string {
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := sigV4Algorithm + "\n" +
+		now.Format(amzDateFormat) + "\n" +
+		s.scope(now) + "\n" +
+		hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), now.Format("20060102"))
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, s.Service)
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalHeaders returns the signed header names and the canonical header
+// block. Host, content headers, Range and all x-amz- headers are signed.
+func canonicalHeaders(req *http.Request) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	values := map[string]string{"host": host}
+
+	for name, vals := range req.Header {
+		name = strings.ToLower(name)
+		switch {
+		case name == "content-type", name == "content-md5", name == "range", strings.HasPrefix(name, "x-amz-"):
+			trimmed := make([]string, len(vals))
+			for i, v := range vals {
+				trimmed[i] = strings.Join(strings.Fields(v), " ")
+			}
+			values[name] = strings.Join(trimmed, ",")
+		}
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name + ":" + values[name] + "\n")
+	}
+	return strings.Join(names, ";"), b.String()
+}
+
+// canonicalURI encodes the path once, as S3 expects, keeping slashes
+func canonicalURI(u *url.URL) string {
+	if u.Path == "" {
+		return "/"
+	}
+	return uriEncode(u.Path, false)
+}
+
+// canonicalQuery encodes and sorts query parameters by name, then value
+func canonicalQuery(query url.Values) string {
+	pairs := make([]string, 0, len(query))
+	for name, vals := range query {
+		for _, v := range vals {
+			pairs = append(pairs, uriEncode(name, true)+"="+uriEncode(v, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent-encodes every byte except unreserved characters and,
+// unless encodeSlash is set, slashes
+func uriEncode(s string, encodeSlash bool) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' ||
+			ch == '-' || ch == '.' || ch == '_' || ch == '~' || ch == '/' && !encodeSlash {
+			b.WriteByte(ch)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[ch>>4])
+		b.WriteByte(hex[ch&0x0f])
+	}
+	return b.String()
+}
\ No newline at end of file
@@ -0,0 +1,82 @@
+package throttle
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	failures     int
+	expiresAt    time.Time
+	blockedUntil time.Time
+}
+
+type memoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+}
+
+// NewMemoryStore creates a process-local store for tests and single-instance development
+func NewMemoryStore() Store {
+	return &memoryStore{
+		entries: make(map[string]*memoryEntry),
+	}
+}
+
+func (s *memoryStore) RecordFailure(ctx context.Context, key string, window time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for k, entry := range s.entries {
+		if !entry.expiresAt.After(now) && !entry.blockedUntil.After(now) {
+			delete(s.entries, k)
+		}
+	}
+
+	entry := s.entries[key]
+	if entry == nil {
+		entry = &memoryEntry{}
+		s.entries[key] = entry
+	}
+	if !entry.expiresAt.After(now) {
+		entry.failures = 0
+		entry.expiresAt = now.Add(window)
+	}
+
+	entry.failures++
+	return entry.failures, nil
+}
+
+func (s *memoryStore) Block(ctx context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.entries[key]
+	if entry == nil {
+		entry = &memoryEntry{}
+		s.entries[key] = entry
+	}
+
+	entry.blockedUntil = until
+	return nil
+}
+
+func (s *memoryStore) BlockedUntil(ctx context.Context, key string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry := s.entries[key]; entry != nil {
+		return entry.blockedUntil, nil
+	}
+	return time.Time{}, nil
+}
+
+func (s *memoryStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
\ No newline at end of file
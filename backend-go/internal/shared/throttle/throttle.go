@@ -0,0 +1,133 @@
+package throttle
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Policy decides how failed attempts are slowed down and locked out
+type Policy struct {
+	Window           time.Duration // How long failures are remembered after the first one
+	FreeAttempts     int           // Failures allowed before delays start
+	BaseDelay        time.Duration // Delay after the first counted failure, doubling with each further one
+	MaxDelay         time.Duration // Upper bound for progressive delays
+	LockoutThreshold int           // Failures that lock the key; zero never locks
+	LockoutDuration  time.Duration
+}
+
+// Store counts failures and keeps blocks, shared by every replica
+type Store interface {
+	// RecordFailure increments the failure count for a key and returns the new
+	// count; the count is forgotten once the window has passed
+	RecordFailure(ctx context.Context, key string, window time.Duration) (int, error)
+	// Block rejects attempts for a key until the given time
+	Block(ctx context.Context, key string, until time.Time) error
+	// BlockedUntil returns when a key is unblocked, or the zero time
+	BlockedUntil(ctx context.Context, key string) (time.Time, error)
+	// Reset forgets the failures and block of a key
+	Reset(ctx context.Context, key string) error
+}
+
+// BlockedError is returned for attempts made while a key is blocked
+type BlockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *BlockedError) Error() string {
+	return "too many failed attempts, please try again later"
+}
+
+// Limiter applies a policy to keys such as an account or a client IP. A nil
+// limiter allows everything.
+type Limiter struct {
+	store  Store
+	policy Policy
+	prefix string
+}
+
+// NewLimiter creates a limiter; the prefix keeps its keys apart from other limiters sharing the store
+func NewLimiter(store Store, policy Policy, prefix string) *Limiter {
+	return &Limiter{
+		store:  store,
+		policy: policy,
+		prefix: prefix,
+	}
+}
+
+// Check returns a BlockedError if the key may not make an attempt yet
+func (l *Limiter) Check(ctx context.Context, key string) error {
+	if l == nil {
+		return nil
+	}
+
+	until, err := l.store.BlockedUntil(ctx, l.prefix+key)
+	if err != nil {
+		return fmt.Errorf("failed to check attempts: %w", err)
+	}
+
+	if wait := time.Until(until); wait > 0 {
+		return &BlockedError{RetryAfter: wait}
+	}
+
+	return nil
+}
+
+// Fail records a failed attempt and blocks the key as the policy requires,
+// returning how long it is blocked for
+func (l *Limiter) Fail(ctx context.Context, key string) (time.Duration, error) {
+	if l == nil {
+		return 0, nil
+	}
+
+	failures, err := l.store.RecordFailure(ctx, l.prefix+key, l.policy.Window)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record attempt: %w", err)
+	}
+
+	delay := l.policy.Delay(failures)
+	if delay > 0 {
+		if err := l.store.Block(ctx, l.prefix+key, time.Now().Add(delay)); err != nil {
+			return 0, fmt.Errorf("failed to block attempts: %w", err)
+		}
+	}
+
+	return delay, nil
+}
+
+// Reset clears the failures of a key, after a successful attempt or an admin unlock
+func (l *Limiter) Reset(ctx context.Context, key string) error {
+	if l == nil {
+		return nil
+	}
+
+	if err := l.store.Reset(ctx, l.prefix+key); err != nil {
+		return fmt.Errorf("failed to reset attempts: %w", err)
+	}
+
+	return nil
+}
+
+// Delay returns how long to block after the given number of failures
+func (p Policy) Delay(failures int) time.Duration {
+	if p.LockoutThreshold > 0 && failures >= p.LockoutThreshold {
+		return p.LockoutDuration
+	}
+
+	if failures <= p.FreeAttempts || p.BaseDelay <= 0 {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < failures; i++ {
+		delay *= 2
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return delay
+}
\ No newline at end of file
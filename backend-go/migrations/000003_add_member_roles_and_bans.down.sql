@@ -0,0 +1,9 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_chat_room_bans_user_id;
+DROP INDEX IF EXISTS idx_chat_room_members_role;
+
+-- Drop tables
+DROP TABLE IF EXISTS chat_room_bans;
+
+-- Drop columns
+ALTER TABLE chat_room_members DROP COLUMN IF EXISTS role;
@@ -0,0 +1,24 @@
+-- Add role to chat room members
+ALTER TABLE chat_room_members
+    ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'member' CHECK (role IN ('owner', 'admin', 'member'));
+
+-- Existing creators become room owners
+UPDATE chat_room_members crm
+SET role = 'owner'
+FROM chat_rooms cr
+WHERE crm.chat_room_id = cr.id AND crm.user_id = cr.created_by;
+
+-- Create chat_room_bans table
+CREATE TABLE IF NOT EXISTS chat_room_bans (
+    chat_room_id VARCHAR(36) NOT NULL REFERENCES chat_rooms(id) ON DELETE CASCADE,
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    banned_by VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reason TEXT,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (chat_room_id, user_id)
+);
+
+-- Create indexes for better performance
+CREATE INDEX IF NOT EXISTS idx_chat_room_members_role ON chat_room_members(chat_room_id, role);
+CREATE INDEX IF NOT EXISTS idx_chat_room_bans_user_id ON chat_room_bans(user_id);
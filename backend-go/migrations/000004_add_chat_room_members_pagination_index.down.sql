@@ -0,0 +1,2 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_chat_room_members_room_joined_at;
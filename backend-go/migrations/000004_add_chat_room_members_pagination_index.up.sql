@@ -0,0 +1,2 @@
+-- Support cursor pagination of room members ordered by join time
+CREATE INDEX IF NOT EXISTS idx_chat_room_members_room_joined_at ON chat_room_members(chat_room_id, joined_at, user_id);
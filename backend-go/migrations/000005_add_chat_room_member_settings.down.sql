@@ -0,0 +1,9 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_chat_room_members_user_archived;
+
+-- Drop columns
+ALTER TABLE chat_room_members
+    DROP COLUMN IF EXISTS notification_level,
+    DROP COLUMN IF EXISTS archived,
+    DROP COLUMN IF EXISTS pinned,
+    DROP COLUMN IF EXISTS muted_until;
@@ -0,0 +1,9 @@
+-- Add per-member room preferences
+ALTER TABLE chat_room_members
+    ADD COLUMN IF NOT EXISTS muted_until TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS pinned BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS archived BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS notification_level VARCHAR(20) NOT NULL DEFAULT 'all' CHECK (notification_level IN ('all', 'mentions', 'none'));
+
+-- Create indexes for better performance
+CREATE INDEX IF NOT EXISTS idx_chat_room_members_user_archived ON chat_room_members(user_id, archived, pinned);
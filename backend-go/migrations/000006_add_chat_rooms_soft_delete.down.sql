@@ -0,0 +1,8 @@
+-- Permanently remove soft-deleted chat rooms
+DELETE FROM chat_rooms WHERE deleted_at IS NOT NULL;
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_chat_rooms_deleted_at;
+
+-- Drop columns
+ALTER TABLE chat_rooms DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,5 @@
+-- Soft-delete chat rooms so they can be restored during a grace period
+ALTER TABLE chat_rooms ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+-- Create indexes for better performance
+CREATE INDEX IF NOT EXISTS idx_chat_rooms_deleted_at ON chat_rooms(deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,7 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_messages_room_sender_created_at;
+
+-- Drop columns
+ALTER TABLE chat_rooms
+    DROP COLUMN IF EXISTS slow_mode_seconds,
+    DROP COLUMN IF EXISTS announcement_only;
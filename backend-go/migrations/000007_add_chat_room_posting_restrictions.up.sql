@@ -0,0 +1,7 @@
+-- Add announcement-only and slow mode settings to chat rooms
+ALTER TABLE chat_rooms
+    ADD COLUMN IF NOT EXISTS announcement_only BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS slow_mode_seconds INTEGER NOT NULL DEFAULT 0 CHECK (slow_mode_seconds >= 0);
+
+-- Support looking up a member's latest message for slow mode
+CREATE INDEX IF NOT EXISTS idx_messages_room_sender_created_at ON messages(chat_room_id, sender_id, created_at DESC);
@@ -0,0 +1,3 @@
+-- Drop two-factor tables
+DROP TABLE IF EXISTS two_factor_recovery_codes;
+DROP TABLE IF EXISTS user_two_factor;
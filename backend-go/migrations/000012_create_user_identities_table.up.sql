@@ -0,0 +1,14 @@
+-- Create user_identities table linking accounts at external OpenID Connect providers
+CREATE TABLE IF NOT EXISTS user_identities (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    issuer VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    last_login_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (issuer, subject)
+);
+
+-- Create indexes for better performance
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
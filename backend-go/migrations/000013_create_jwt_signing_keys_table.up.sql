@@ -0,0 +1,8 @@
+-- Create jwt_signing_keys table holding the asymmetric keys access tokens are signed with
+CREATE TABLE IF NOT EXISTS jwt_signing_keys (
+    id VARCHAR(36) PRIMARY KEY,
+    algorithm VARCHAR(10) NOT NULL,
+    private_key TEXT NOT NULL,
+    active_from TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
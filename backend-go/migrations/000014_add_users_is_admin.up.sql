@@ -0,0 +1,2 @@
+-- Add is_admin to users; admins are granted directly in the database
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_admin BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,8 @@
+-- Drop api_keys table and bot columns
+DROP TABLE IF EXISTS api_keys;
+
+ALTER TABLE messages DROP COLUMN IF EXISTS sent_by_bot;
+
+DROP INDEX IF EXISTS idx_users_owner_id;
+ALTER TABLE users DROP COLUMN IF EXISTS owner_id;
+ALTER TABLE users DROP COLUMN IF EXISTS is_bot;
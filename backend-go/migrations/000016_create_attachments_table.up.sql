@@ -0,0 +1,15 @@
+-- Create attachments table; file content lives in upload storage under the attachment id
+CREATE TABLE IF NOT EXISTS attachments (
+    id VARCHAR(36) PRIMARY KEY,
+    owner_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chat_room_id VARCHAR(36) NOT NULL REFERENCES chat_rooms(id) ON DELETE CASCADE,
+    original_name VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL CHECK (size >= 0),
+    mime_type VARCHAR(255) NOT NULL,
+    checksum VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes for better performance
+CREATE INDEX IF NOT EXISTS idx_attachments_chat_room_id ON attachments(chat_room_id);
+CREATE INDEX IF NOT EXISTS idx_attachments_owner_id ON attachments(owner_id);
@@ -0,0 +1,5 @@
+-- Drop attachment status; pending uploads were never completed
+DELETE FROM attachments WHERE status = 'pending';
+
+DROP INDEX IF EXISTS idx_attachments_pending_created_at;
+ALTER TABLE attachments DROP COLUMN IF EXISTS status;
@@ -0,0 +1,4 @@
+-- Direct uploads to storage are recorded as pending until the content is verified
+ALTER TABLE attachments ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'ready' CHECK (status IN ('pending', 'ready'));
+
+CREATE INDEX IF NOT EXISTS idx_attachments_pending_created_at ON attachments(created_at) WHERE status = 'pending';
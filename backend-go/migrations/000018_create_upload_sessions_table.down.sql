@@ -0,0 +1,2 @@
+-- Drop upload_sessions table
+DROP TABLE IF EXISTS upload_sessions;
@@ -0,0 +1,11 @@
+-- Create upload_sessions table for resumable uploads; chunks are stored as separate blobs
+CREATE TABLE IF NOT EXISTS upload_sessions (
+    attachment_id VARCHAR(36) PRIMARY KEY REFERENCES attachments(id) ON DELETE CASCADE,
+    upload_offset BIGINT NOT NULL DEFAULT 0 CHECK (upload_offset >= 0),
+    chunk_keys TEXT[] NOT NULL DEFAULT '{}',
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes for better performance
+CREATE INDEX IF NOT EXISTS idx_upload_sessions_expires_at ON upload_sessions(expires_at);
@@ -0,0 +1,4 @@
+-- Drop image dimensions and rendered thumbnail type from attachments
+ALTER TABLE attachments DROP COLUMN IF EXISTS render_type;
+ALTER TABLE attachments DROP COLUMN IF EXISTS height;
+ALTER TABLE attachments DROP COLUMN IF EXISTS width;
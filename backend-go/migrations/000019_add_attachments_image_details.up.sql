@@ -0,0 +1,4 @@
+-- Dimensions of uploaded images and the type of their rendered thumbnail and preview
+ALTER TABLE attachments ADD COLUMN IF NOT EXISTS width INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE attachments ADD COLUMN IF NOT EXISTS height INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE attachments ADD COLUMN IF NOT EXISTS render_type VARCHAR(50) NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+-- Drop the message reference from attachments
+DROP INDEX IF EXISTS idx_attachments_message_id;
+ALTER TABLE attachments DROP COLUMN IF EXISTS message_id;
@@ -0,0 +1,4 @@
+-- Message an attachment was sent with; an attachment can only be sent once
+ALTER TABLE attachments ADD COLUMN IF NOT EXISTS message_id VARCHAR(36) REFERENCES messages(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_attachments_message_id ON attachments(message_id);
@@ -0,0 +1,4 @@
+-- Drop storage usage accounting
+DROP TRIGGER IF EXISTS update_attachments_storage_usage ON attachments;
+DROP FUNCTION IF EXISTS update_storage_usage();
+DROP TABLE IF EXISTS storage_usage;
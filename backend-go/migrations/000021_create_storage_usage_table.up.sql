@@ -0,0 +1,46 @@
+-- Storage taken by each user's files and by the files in each chat room,
+-- including uploads in progress
+CREATE TABLE IF NOT EXISTS storage_usage (
+    scope VARCHAR(10) NOT NULL CHECK (scope IN ('user', 'room')),
+    owner_id VARCHAR(36) NOT NULL,
+    bytes BIGINT NOT NULL DEFAULT 0,
+    files INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (scope, owner_id)
+);
+
+-- Keep usage up to date as attachments are added, resized and removed,
+-- including when they are removed along with their user or chat room
+CREATE OR REPLACE FUNCTION update_storage_usage()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        UPDATE storage_usage SET bytes = bytes - OLD.size, files = files - 1
+        WHERE (scope = 'user' AND owner_id = OLD.owner_id)
+            OR (scope = 'room' AND owner_id = OLD.chat_room_id);
+    END IF;
+
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        INSERT INTO storage_usage (scope, owner_id, bytes, files)
+        VALUES ('user', NEW.owner_id, NEW.size, 1), ('room', NEW.chat_room_id, NEW.size, 1)
+        ON CONFLICT (scope, owner_id) DO UPDATE
+            SET bytes = storage_usage.bytes + EXCLUDED.bytes, files = storage_usage.files + 1;
+    END IF;
+
+    RETURN NULL;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS update_attachments_storage_usage ON attachments;
+CREATE TRIGGER update_attachments_storage_usage
+    AFTER INSERT OR DELETE OR UPDATE OF size, owner_id, chat_room_id ON attachments
+    FOR EACH ROW
+    EXECUTE FUNCTION update_storage_usage();
+
+-- Account for files uploaded before usage was tracked
+INSERT INTO storage_usage (scope, owner_id, bytes, files)
+SELECT 'user', owner_id, SUM(size), COUNT(*) FROM attachments GROUP BY owner_id
+ON CONFLICT (scope, owner_id) DO NOTHING;
+
+INSERT INTO storage_usage (scope, owner_id, bytes, files)
+SELECT 'room', chat_room_id, SUM(size), COUNT(*) FROM attachments GROUP BY chat_room_id
+ON CONFLICT (scope, owner_id) DO NOTHING;
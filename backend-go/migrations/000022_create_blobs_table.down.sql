@@ -0,0 +1,7 @@
+-- Drop content deduplication. Attachments sharing a blob lose their content
+-- location, so only roll back before any upload was deduplicated.
+DROP TRIGGER IF EXISTS update_attachments_blob_references ON attachments;
+DROP FUNCTION IF EXISTS update_blob_references();
+DROP TABLE IF EXISTS blobs;
+DROP INDEX IF EXISTS idx_attachments_storage_key;
+ALTER TABLE attachments DROP COLUMN IF EXISTS storage_key;
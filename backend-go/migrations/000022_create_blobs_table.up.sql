@@ -0,0 +1,59 @@
+-- Where the content of each attachment is stored. Uploads in progress and
+-- files uploaded before deduplication are stored under their own ID.
+ALTER TABLE attachments ADD COLUMN IF NOT EXISTS storage_key VARCHAR(100);
+UPDATE attachments SET storage_key = id WHERE storage_key IS NULL;
+ALTER TABLE attachments ALTER COLUMN storage_key SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_attachments_storage_key ON attachments(storage_key);
+
+-- Content stored once under its SHA-256 and shared by every attachment with
+-- the same bytes
+CREATE TABLE IF NOT EXISTS blobs (
+    key VARCHAR(100) PRIMARY KEY,
+    checksum VARCHAR(64) NOT NULL,
+    source_checksum VARCHAR(64) NOT NULL,
+    size BIGINT NOT NULL,
+    mime_type VARCHAR(100) NOT NULL,
+    width INTEGER NOT NULL DEFAULT 0,
+    height INTEGER NOT NULL DEFAULT 0,
+    render_type VARCHAR(100) NOT NULL DEFAULT '',
+    ref_count INTEGER NOT NULL DEFAULT 0,
+    orphaned_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_blobs_checksum ON blobs(checksum);
+CREATE INDEX IF NOT EXISTS idx_blobs_source_checksum ON blobs(source_checksum);
+CREATE INDEX IF NOT EXISTS idx_blobs_orphaned_at ON blobs(orphaned_at) WHERE ref_count = 0;
+
+-- Count the attachments referencing each blob, including when they are
+-- removed along with their user or chat room. A blob is marked orphaned
+-- when its last reference goes so its content can be purged later.
+CREATE OR REPLACE FUNCTION update_blob_references()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        UPDATE blobs SET ref_count = ref_count - 1,
+            orphaned_at = CASE WHEN ref_count = 1 THEN CURRENT_TIMESTAMP ELSE orphaned_at END
+        WHERE key = OLD.storage_key;
+    END IF;
+
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        UPDATE blobs SET ref_count = ref_count + 1, orphaned_at = NULL
+        WHERE key = NEW.storage_key;
+
+        -- Only content stored under the attachment's own ID has no blob
+        IF NOT FOUND AND NEW.storage_key <> NEW.id THEN
+            RAISE EXCEPTION 'blob % does not exist', NEW.storage_key;
+        END IF;
+    END IF;
+
+    RETURN NULL;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS update_attachments_blob_references ON attachments;
+CREATE TRIGGER update_attachments_blob_references
+    AFTER INSERT OR DELETE OR UPDATE OF storage_key ON attachments
+    FOR EACH ROW
+    EXECUTE FUNCTION update_blob_references();
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_attachments_scan_pending;
+ALTER TABLE attachments DROP COLUMN IF EXISTS threat;
+ALTER TABLE attachments DROP COLUMN IF EXISTS scan_status;
@@ -0,0 +1,7 @@
+-- Outcome of scanning each file for malware; empty for files uploaded while
+-- scanning was disabled
+ALTER TABLE attachments ADD COLUMN IF NOT EXISTS scan_status VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE attachments ADD COLUMN IF NOT EXISTS threat VARCHAR(255) NOT NULL DEFAULT '';
+
+-- Files waiting to be scanned are picked up oldest first
+CREATE INDEX IF NOT EXISTS idx_attachments_scan_pending ON attachments(created_at) WHERE scan_status = 'pending';
@@ -0,0 +1,3 @@
+-- Garbage collection looks for uploaded files that were never sent with a
+-- message, or whose message was deleted
+CREATE INDEX IF NOT EXISTS idx_attachments_unattached ON attachments(id) WHERE message_id IS NULL AND status = 'ready';
@@ -0,0 +1,3 @@
+-- Slow mode records when each member last sent a message, so the check and
+-- the update can be a single statement
+ALTER TABLE chat_room_members ADD COLUMN IF NOT EXISTS last_sent_at TIMESTAMP WITH TIME ZONE;
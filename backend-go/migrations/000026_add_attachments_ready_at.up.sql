@@ -0,0 +1,7 @@
+-- Garbage collection gives unattached files a grace period from when they
+-- became ready, as resumable uploads and scans may finish long after the
+-- file was created
+ALTER TABLE attachments ADD COLUMN IF NOT EXISTS ready_at TIMESTAMP WITH TIME ZONE;
+UPDATE attachments SET ready_at = created_at WHERE ready_at IS NULL;
+ALTER TABLE attachments ALTER COLUMN ready_at SET DEFAULT NOW();
+ALTER TABLE attachments ALTER COLUMN ready_at SET NOT NULL;
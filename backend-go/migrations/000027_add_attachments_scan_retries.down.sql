@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_attachments_scan_pending;
+CREATE INDEX IF NOT EXISTS idx_attachments_scan_pending ON attachments(created_at) WHERE scan_status = 'pending';
+
+ALTER TABLE attachments DROP COLUMN IF EXISTS next_scan_at;
+ALTER TABLE attachments DROP COLUMN IF EXISTS scan_attempts;
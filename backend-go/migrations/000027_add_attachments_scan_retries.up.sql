@@ -0,0 +1,8 @@
+-- Files that fail to scan are tried again later, waiting longer after each
+-- attempt so they do not hold up other files
+ALTER TABLE attachments ADD COLUMN IF NOT EXISTS scan_attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE attachments ADD COLUMN IF NOT EXISTS next_scan_at TIMESTAMP WITH TIME ZONE;
+
+-- Files waiting to be scanned are picked up once due, those never tried first
+DROP INDEX IF EXISTS idx_attachments_scan_pending;
+CREATE INDEX IF NOT EXISTS idx_attachments_scan_pending ON attachments(next_scan_at NULLS FIRST, created_at) WHERE scan_status = 'pending';
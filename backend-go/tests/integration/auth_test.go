@@ -24,7 +24,7 @@ func TestAuthIntegration(t *testing.T) {
 		payload := map[string]interface{}{
 			"username": "testuser",
 			"email":    "test@example.com",
-			"password": "password123",
+			"password": "Tr1cky-Passphrase",
 		}
 		
 		body, _ := json.Marshal(payload)
@@ -42,7 +42,7 @@ func TestAuthIntegration(t *testing.T) {
 		// Test user login
 		payload := map[string]interface{}{
 			"email":    "test@example.com",
-			"password": "password123",
+			"password": "Tr1cky-Passphrase",
 		}
 		
 		body, _ := json.Marshal(payload)
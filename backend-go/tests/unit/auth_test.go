@@ -0,0 +1,180 @@
+package unit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"backend-go/internal/application/auth"
+	"backend-go/internal/domain/user"
+	"backend-go/internal/shared/logger"
+	"backend-go/internal/shared/password"
+	"backend-go/internal/shared/throttle"
+	"backend-go/internal/shared/validation"
+)
+
+// memoryUsers keeps users by ID and finds them by email
+type memoryUsers struct {
+	user.Repository
+	users map[string]*user.User
+}
+
+func (r *memoryUsers) GetByID(ctx context.Context, id string) (*user.User, error) {
+	u, ok := r.users[id]
+	if !ok {
+		return nil, user.ErrUserNotFound
+	}
+	found := *u
+	return &found, nil
+}
+
+func (r *memoryUsers) GetByEmail(ctx context.Context, email string) (*user.User, error) {
+	for _, u := range r.users {
+		if u.Email == email {
+			found := *u
+			return &found, nil
+		}
+	}
+	return nil, user.ErrUserNotFound
+}
+
+func (r *memoryUsers) Update(ctx context.Context, u *user.User) error {
+	updated := *u
+	r.users[u.ID] = &updated
+	return nil
+}
+
+// memoryAccountTokens keeps account tokens by their hash
+type memoryAccountTokens struct {
+	user.AccountTokenRepository
+	tokens map[string]*user.AccountToken
+}
+
+func (r *memoryAccountTokens) Get(ctx context.Context, purpose, tokenHash string) (*user.AccountToken, error) {
+	token, ok := r.tokens[tokenHash]
+	if !ok || token.Purpose != purpose || token.UsedAt != nil || !token.ExpiresAt.After(time.Now()) {
+		return nil, user.ErrAccountTokenInvalid
+	}
+	found := *token
+	return &found, nil
+}
+
+func (r *memoryAccountTokens) Consume(ctx context.Context, purpose, tokenHash string) (*user.AccountToken, error) {
+	token, err := r.Get(ctx, purpose, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	r.tokens[tokenHash].UsedAt = &now
+	return token, nil
+}
+
+func (r *memoryAccountTokens) InvalidateForUser(ctx context.Context, userID, purpose string) error {
+	for _, token := range r.tokens {
+		if token.UserID == userID && token.Purpose == purpose && token.UsedAt == nil {
+			token.ExpiresAt = time.Now()
+		}
+	}
+	return nil
+}
+
+// noSessions has no sessions to revoke
+type noSessions struct {
+	user.SessionRepository
+}
+
+func (noSessions) RevokeAllExcept(ctx context.Context, userID, keepID string) ([]string, error) {
+	return nil, nil
+}
+
+// noRefreshTokens has no refresh tokens to revoke
+type noRefreshTokens struct {
+	user.RefreshTokenRepository
+}
+
+func (noRefreshTokens) RevokeAllForUser(ctx context.Context, userID string) error {
+	return nil
+}
+
+func TestPasswordReset(t *testing.T) {
+	ctx := context.Background()
+	hasher := password.NewHasher(4)
+	currentHash, err := hasher.Hash("Current-password-1")
+	require.NoError(t, err)
+	users := &memoryUsers{users: map[string]*user.User{
+		"user-1": user.NewUser("user-1", "jane", "jane@example.com", currentHash),
+	}}
+	sum := sha256.Sum256([]byte("reset-link"))
+	tokenHash := hex.EncodeToString(sum[:])
+	tokens := &memoryAccountTokens{tokens: map[string]*user.AccountToken{
+		tokenHash: user.NewAccountToken("token-1", "user-1", user.TokenPurposePasswordReset, tokenHash, time.Hour),
+	}}
+	passwords := auth.PasswordSettings{Policy: password.Policy{MinLength: 12, MaxLength: password.MaxBcryptLength}, Hasher: hasher}
+	uc := auth.NewUseCase(users, noRefreshTokens{}, noSessions{}, tokens, nil, nil, nil, nil, validation.New(), *logger.New("error", "json"),
+		time.Hour, "http://localhost:3000", auth.OIDCSettings{}, auth.LoginProtection{}, passwords)
+
+	reset := func(newPassword string) error {
+		_, err := uc.ResetPassword(ctx, auth.ResetPasswordInput{Token: "reset-link", NewPassword: newPassword})
+		return err
+	}
+
+	t.Run("Rejected passwords leave the link usable", func(t *testing.T) {
+		var policyErr *password.PolicyError
+		assert.ErrorAs(t, reset("short"), &policyErr)
+		assert.EqualError(t, reset("Current-password-1"), "new password must differ from the current password")
+		assert.Nil(t, tokens.tokens[tokenHash].UsedAt)
+	})
+
+	t.Run("The link is used once a password is accepted", func(t *testing.T) {
+		require.NoError(t, reset("A-much-better-password"))
+		assert.NotNil(t, tokens.tokens[tokenHash].UsedAt)
+		assert.NoError(t, hasher.Compare(users.users["user-1"].PasswordHash, "A-much-better-password"))
+
+		assert.Equal(t, user.ErrAccountTokenInvalid, reset("Another-good-password"))
+	})
+}
+
+func TestLoginUnlock(t *testing.T) {
+	ctx := context.Background()
+	hasher := password.NewHasher(4)
+	admin := user.NewUser("admin-1", "admin", "admin@example.com", "")
+	admin.IsAdmin = true
+	users := &memoryUsers{users: map[string]*user.User{
+		"admin-1": admin,
+		"user-1":  user.NewUser("user-1", "jane", "jane@example.com", ""),
+	}}
+	accounts := throttle.NewLimiter(throttle.NewMemoryStore(), throttle.Policy{
+		Window:           15 * time.Minute,
+		LockoutThreshold: 1,
+		LockoutDuration:  15 * time.Minute,
+	}, "account")
+	uc := auth.NewUseCase(users, nil, nil, nil, nil, nil, nil, nil, validation.New(), *logger.New("error", "json"),
+		time.Hour, "http://localhost:3000", auth.OIDCSettings{}, auth.LoginProtection{Accounts: accounts}, auth.PasswordSettings{Hasher: hasher})
+
+	login := func(email string) error {
+		_, err := uc.Login(ctx, auth.LoginInput{Email: email, Password: "wrong"})
+		return err
+	}
+
+	t.Run("Unlocking lifts every lockout of the account", func(t *testing.T) {
+		assert.Equal(t, auth.ErrInvalidCredentials, login("Jane@Example.com"))
+		var blocked *throttle.BlockedError
+		require.ErrorAs(t, login("jane@example.com"), &blocked)
+		for _, key := range []string{"2fa:user-1", "password:user-1"} {
+			_, err := accounts.Fail(ctx, key)
+			require.NoError(t, err)
+			require.Error(t, accounts.Check(ctx, key))
+		}
+
+		require.NoError(t, uc.UnlockLogin(ctx, auth.UnlockLoginInput{AdminID: "admin-1", Email: "jane@example.com"}))
+
+		for _, key := range []string{"jane@example.com", "2fa:user-1", "password:user-1"} {
+			assert.NoError(t, accounts.Check(ctx, key), key)
+		}
+		assert.Equal(t, auth.ErrInvalidCredentials, login("JANE@example.com"))
+	})
+}
\ No newline at end of file
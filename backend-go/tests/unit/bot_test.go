@@ -0,0 +1,117 @@
+package unit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"backend-go/internal/domain/user"
+	"backend-go/internal/infrastructure/http/middleware"
+	"backend-go/internal/shared/jwt"
+)
+
+func TestBotEntity(t *testing.T) {
+	t.Run("NewBot creates an owned bot without a password", func(t *testing.T) {
+		bot := user.NewBot("bot-id", "ci-bot", "owner-id")
+
+		assert.True(t, bot.IsBot)
+		assert.True(t, bot.IsActive)
+		assert.Empty(t, bot.PasswordHash)
+		assert.Equal(t, "bot-id@"+user.BotEmailDomain, bot.Email)
+		assert.True(t, bot.IsOwnedBy("owner-id"))
+		assert.False(t, bot.IsOwnedBy("someone-else"))
+	})
+
+	t.Run("Human users are not owned", func(t *testing.T) {
+		u := user.NewUser("user-id", "alice", "alice@example.com", "hash")
+		assert.False(t, u.IsOwnedBy(""))
+	})
+
+	t.Run("API key activity, scopes and rooms", func(t *testing.T) {
+		key := user.NewAPIKey("key-id", "bot-id", "CI", "wak_abcdefgh", "hash",
+			[]string{user.ScopeMessagesWrite}, []string{"room-1"}, nil)
+
+		assert.True(t, key.IsActive())
+		assert.True(t, key.HasScope(user.ScopeMessagesWrite))
+		assert.False(t, key.HasScope(user.ScopeMessagesRead))
+		assert.True(t, key.AllowsRoom("room-1"))
+		assert.False(t, key.AllowsRoom("room-2"))
+
+		past := time.Now().Add(-time.Minute)
+		key.ExpiresAt = &past
+		assert.False(t, key.IsActive())
+
+		key.ExpiresAt = nil
+		key.RevokedAt = &past
+		assert.False(t, key.IsActive())
+
+		unrestricted := user.NewAPIKey("key-id", "bot-id", "CI", "wak_abcdefgh", "hash",
+			[]string{user.ScopeMessagesWrite}, []string{}, nil)
+		assert.True(t, unrestricted.AllowsRoom("any-room"))
+	})
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const validKey = user.APIKeyTokenPrefix + "valid"
+	apiKeys := func(ctx context.Context, key string) (*middleware.APIKeyGrant, error) {
+		if key != validKey {
+			return nil, user.ErrAPIKeyInvalid
+		}
+		return &middleware.APIKeyGrant{
+			UserID:  "bot-id",
+			KeyID:   "key-id",
+			Scopes:  []string{user.ScopeMessagesWrite},
+			RoomIDs: []string{"room-1"},
+		}, nil
+	}
+
+	jwtService := jwt.NewService("test-secret", time.Hour, nil)
+	router := gin.New()
+	auth := middleware.Auth(jwtService, apiKeys)
+	handler := func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user_id": c.GetString("user_id"), "is_bot": c.GetBool("is_bot")})
+	}
+	router.POST("/api/v1/chatrooms/:id/messages", auth, handler)
+	router.GET("/api/v1/chatrooms/:id/messages", auth, handler)
+	router.GET("/api/v1/bots", auth, handler)
+
+	request := func(method, path, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Key with the scope works in its room", func(t *testing.T) {
+		w := request("POST", "/api/v1/chatrooms/room-1/messages", validKey)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"user_id":"bot-id","is_bot":true}`, w.Body.String())
+	})
+
+	t.Run("Key is rejected outside its rooms", func(t *testing.T) {
+		w := request("POST", "/api/v1/chatrooms/room-2/messages", validKey)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Key is rejected without the scope", func(t *testing.T) {
+		w := request("GET", "/api/v1/chatrooms/room-1/messages", validKey)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Key is rejected on routes not open to API keys", func(t *testing.T) {
+		w := request("GET", "/api/v1/bots", validKey)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Unknown key is unauthorized", func(t *testing.T) {
+		w := request("POST", "/api/v1/chatrooms/room-1/messages", user.APIKeyTokenPrefix+"unknown")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
\ No newline at end of file
@@ -1,10 +1,17 @@
 package unit
 
 import (
+	"context"
+	"sort"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	chatapp "backend-go/internal/application/chat"
 	"backend-go/internal/domain/chat"
+	"backend-go/internal/shared/logger"
+	"backend-go/internal/shared/validation"
 )
 
 func TestChatRoomEntity(t *testing.T) {
@@ -104,4 +111,221 @@ func TestChatRoomEntity(t *testing.T) {
 		assert.True(t, room.IsPrivate)
 		assert.True(t, room.UpdatedAt.After(oldUpdatedAt))
 	})
+
+	t.Run("CanRestore allows restore only within grace period", func(t *testing.T) {
+		room := chat.NewChatRoom("id", "name", "desc", "creator", false)
+		assert.False(t, room.CanRestore())
+
+		recent := time.Now().Add(-time.Hour)
+		room.DeletedAt = &recent
+		assert.True(t, room.CanRestore())
+
+		expired := time.Now().Add(-chat.RestoreGracePeriod - time.Hour)
+		room.DeletedAt = &expired
+		assert.False(t, room.CanRestore())
+	})
+}
+
+func TestChatRoomModeration(t *testing.T) {
+	t.Run("CanModerate follows role hierarchy", func(t *testing.T) {
+		assert.True(t, chat.CanModerate(chat.RoleOwner, chat.RoleAdmin))
+		assert.True(t, chat.CanModerate(chat.RoleOwner, chat.RoleMember))
+		assert.True(t, chat.CanModerate(chat.RoleAdmin, chat.RoleMember))
+
+		assert.False(t, chat.CanModerate(chat.RoleAdmin, chat.RoleAdmin))
+		assert.False(t, chat.CanModerate(chat.RoleAdmin, chat.RoleOwner))
+		assert.False(t, chat.CanModerate(chat.RoleMember, chat.RoleMember))
+	})
+
+	t.Run("IsAdminRole returns correct status", func(t *testing.T) {
+		assert.True(t, chat.IsAdminRole(chat.RoleOwner))
+		assert.True(t, chat.IsAdminRole(chat.RoleAdmin))
+		assert.False(t, chat.IsAdminRole(chat.RoleMember))
+		assert.False(t, chat.IsAdminRole("unknown"))
+	})
+
+	t.Run("CanSend restricts announcement-only rooms to admins", func(t *testing.T) {
+		room := chat.NewChatRoom("id", "name", "desc", "creator", false)
+		assert.True(t, room.CanSend(chat.RoleMember))
+
+		room.AnnouncementOnly = true
+		assert.True(t, room.CanSend(chat.RoleOwner))
+		assert.True(t, room.CanSend(chat.RoleAdmin))
+		assert.False(t, room.CanSend(chat.RoleMember))
+	})
+
+	t.Run("SlowModeWait throttles members but not admins", func(t *testing.T) {
+		room := chat.NewChatRoom("id", "name", "desc", "creator", false)
+		justNow := time.Now()
+		assert.Zero(t, room.SlowModeWait(chat.RoleMember, justNow))
+
+		room.SlowModeSeconds = 60
+		wait := room.SlowModeWait(chat.RoleMember, justNow)
+		assert.True(t, wait > 50*time.Second && wait <= 60*time.Second)
+		assert.Zero(t, room.SlowModeWait(chat.RoleAdmin, justNow))
+		assert.Zero(t, room.SlowModeWait(chat.RoleMember, time.Time{}))
+		assert.Zero(t, room.SlowModeWait(chat.RoleMember, justNow.Add(-2*time.Minute)))
+	})
+
+	t.Run("Ban without expiry is permanent", func(t *testing.T) {
+		ban := chat.NewBan("room", "user", "admin", "spam", nil)
+
+		assert.True(t, ban.IsActive())
+	})
+
+	t.Run("Ban expires after expiry time", func(t *testing.T) {
+		future := time.Now().Add(time.Hour)
+		past := time.Now().Add(-time.Hour)
+
+		assert.True(t, chat.NewBan("room", "user", "admin", "", &future).IsActive())
+		assert.False(t, chat.NewBan("room", "user", "admin", "", &past).IsActive())
+	})
+}
+
+func TestMemberSettings(t *testing.T) {
+	t.Run("NewMemberSettings notifies for all messages by default", func(t *testing.T) {
+		settings := chat.NewMemberSettings("room", "user")
+
+		assert.Equal(t, chat.NotifyAll, settings.NotificationLevel)
+		assert.False(t, settings.IsMuted())
+		assert.True(t, settings.ShouldNotify(false))
+	})
+
+	t.Run("Muted member is not notified until mute expires", func(t *testing.T) {
+		future := time.Now().Add(time.Hour)
+		past := time.Now().Add(-time.Hour)
+		settings := chat.NewMemberSettings("room", "user")
+
+		settings.MutedUntil = &future
+		assert.True(t, settings.IsMuted())
+		assert.False(t, settings.ShouldNotify(true))
+
+		settings.MutedUntil = &past
+		assert.False(t, settings.IsMuted())
+		assert.True(t, settings.ShouldNotify(false))
+	})
+
+	t.Run("ShouldNotify honours notification level", func(t *testing.T) {
+		settings := chat.NewMemberSettings("room", "user")
+
+		settings.NotificationLevel = chat.NotifyMentions
+		assert.True(t, settings.ShouldNotify(true))
+		assert.False(t, settings.ShouldNotify(false))
+
+		settings.NotificationLevel = chat.NotifyNone
+		assert.False(t, settings.ShouldNotify(true))
+	})
+}
+
+// memberList keeps the members of one room, paging through them in the
+// order the repository does
+type memberList struct {
+	chat.Repository
+	room    *chat.ChatRoom
+	members []*chat.Member
+}
+
+func (r *memberList) GetByID(ctx context.Context, id string) (*chat.ChatRoom, error) {
+	if id != r.room.ID {
+		return nil, chat.ErrChatRoomNotFound
+	}
+	return r.room, nil
+}
+
+func (r *memberList) IsMember(ctx context.Context, roomID, userID string) (bool, error) {
+	for _, member := range r.members {
+		if member.UserID == userID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memberList) ListMembers(ctx context.Context, roomID string, after *chat.MemberCursor, limit int) ([]*chat.Member, bool, error) {
+	sort.Slice(r.members, func(i, j int) bool {
+		a, b := r.members[i], r.members[j]
+		return a.JoinedAt.Before(b.JoinedAt) || (a.JoinedAt.Equal(b.JoinedAt) && a.UserID < b.UserID)
+	})
+	var page []*chat.Member
+	for _, member := range r.members {
+		if after != nil && (member.JoinedAt.Before(after.JoinedAt) ||
+			(member.JoinedAt.Equal(after.JoinedAt) && member.UserID <= after.UserID)) {
+			continue
+		}
+		page = append(page, member)
+	}
+	if len(page) > limit {
+		return page[:limit], true, nil
+	}
+	return page, false, nil
+}
+
+func (r *memberList) remove(userID string) {
+	for i, member := range r.members {
+		if member.UserID == userID {
+			r.members = append(r.members[:i], r.members[i+1:]...)
+			return
+		}
+	}
+}
+
+func TestListMembers(t *testing.T) {
+	ctx := context.Background()
+	joined := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	repo := &memberList{room: &chat.ChatRoom{ID: "room-1"}}
+	// Members joining together when the room is created share a join time
+	for _, userID := range []string{"owner", "b", "a", "c", "d"} {
+		repo.members = append(repo.members, &chat.Member{UserID: userID, Username: userID, Role: chat.RoleMember, JoinedAt: joined})
+	}
+	repo.members = append(repo.members, &chat.Member{UserID: "late", Username: "late", Role: chat.RoleMember, JoinedAt: joined.Add(time.Hour)})
+	uc := chatapp.NewUseCase(repo, nil, validation.New(), *logger.New("error", "json"))
+
+	list := func(t *testing.T, after string) *chatapp.ListMembersOutput {
+		result, err := uc.ListMembers(ctx, chatapp.ListMembersInput{RoomID: "room-1", UserID: "owner", After: after, Limit: 2})
+		require.NoError(t, err)
+		return result
+	}
+	userIDs := func(result *chatapp.ListMembersOutput) []string {
+		var ids []string
+		for _, member := range result.Members {
+			ids = append(ids, member.UserID)
+		}
+		return ids
+	}
+
+	t.Run("Pages follow join time then user ID", func(t *testing.T) {
+		var pages [][]string
+		result := list(t, "")
+		pages = append(pages, userIDs(result))
+		for result.HasMore {
+			result = list(t, result.NextCursor)
+			pages = append(pages, userIDs(result))
+		}
+		assert.Equal(t, [][]string{{"a", "b"}, {"c", "d"}, {"owner", "late"}}, pages)
+		assert.Empty(t, result.NextCursor)
+	})
+
+	t.Run("Paging continues after the cursor member leaves", func(t *testing.T) {
+		first := list(t, "")
+		assert.Equal(t, []string{"a", "b"}, userIDs(first))
+
+		repo.remove("b")
+		second := list(t, first.NextCursor)
+		assert.Equal(t, []string{"c", "d"}, userIDs(second))
+	})
+
+	t.Run("Malformed cursors are rejected", func(t *testing.T) {
+		for _, cursor := range []string{"not base64!", "YQ", chat.MemberCursor{UserID: "a"}.Encode()[:4]} {
+			_, err := uc.ListMembers(ctx, chatapp.ListMembersInput{RoomID: "room-1", UserID: "owner", After: cursor, Limit: 2})
+			assert.ErrorIs(t, err, chat.ErrInvalidCursor, cursor)
+		}
+	})
+
+	t.Run("Cursors round trip", func(t *testing.T) {
+		cursor := chat.MemberCursor{JoinedAt: joined.Add(123456 * time.Microsecond), UserID: "user|with|bars"}
+		parsed, err := chat.ParseMemberCursor(cursor.Encode())
+		require.NoError(t, err)
+		assert.True(t, cursor.JoinedAt.Equal(parsed.JoinedAt))
+		assert.Equal(t, cursor.UserID, parsed.UserID)
+	})
 }
\ No newline at end of file
@@ -0,0 +1,1626 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"backend-go/internal/application/file"
+	"backend-go/internal/domain/attachment"
+	"backend-go/internal/domain/chat"
+	"backend-go/internal/infrastructure/http/handlers"
+	"backend-go/internal/shared/logger"
+	"backend-go/internal/shared/media"
+	"backend-go/internal/shared/scan"
+	"backend-go/internal/shared/storage"
+	"backend-go/internal/shared/validation"
+)
+
+// memoryAttachmentRepo keeps attachments and blobs in maps. Blob references
+// are counted from the attachments, and only files of the user are found by
+// checksum unless members is set.
+type memoryAttachmentRepo struct {
+	attachments map[string]*attachment.Attachment
+	blobs       map[string]*attachment.Blob
+	members     *memberRoles
+}
+
+func (r *memoryAttachmentRepo) Create(ctx context.Context, a *attachment.Attachment, quota attachment.Quota) error {
+	user, _ := r.GetUsage(ctx, attachment.UsageScopeUser, a.OwnerID)
+	if quota.UserBytes > 0 && user.Bytes+a.Size > quota.UserBytes {
+		return attachment.ErrUserQuotaExceeded
+	}
+	room, _ := r.GetUsage(ctx, attachment.UsageScopeRoom, a.ChatRoomID)
+	if quota.RoomBytes > 0 && room.Bytes+a.Size > quota.RoomBytes {
+		return attachment.ErrRoomQuotaExceeded
+	}
+	stored := *a
+	r.attachments[a.ID] = &stored
+	return nil
+}
+
+func (r *memoryAttachmentRepo) GetByID(ctx context.Context, id string) (*attachment.Attachment, error) {
+	a, ok := r.attachments[id]
+	if !ok {
+		return nil, attachment.ErrAttachmentNotFound
+	}
+	loaded := *a
+	return &loaded, nil
+}
+
+func (r *memoryAttachmentRepo) Delete(ctx context.Context, id string) error {
+	a, ok := r.attachments[id]
+	if !ok {
+		return nil
+	}
+	delete(r.attachments, id)
+	if b, ok := r.blobs[a.StorageKey]; ok && r.refCount(b.Key) == 0 {
+		now := time.Now()
+		b.OrphanedAt = &now
+	}
+	return nil
+}
+
+func (r *memoryAttachmentRepo) MarkReady(ctx context.Context, a *attachment.Attachment) error {
+	stored, ok := r.attachments[a.ID]
+	if !ok || stored.IsReady() {
+		return attachment.ErrAttachmentNotFound
+	}
+	ready := *a
+	ready.Status = attachment.StatusReady
+	r.attachments[a.ID] = &ready
+	return nil
+}
+
+func (r *memoryAttachmentRepo) ListPendingBefore(ctx context.Context, before time.Time, limit int) ([]*attachment.Attachment, error) {
+	var pending []*attachment.Attachment
+	for _, a := range r.attachments {
+		if !a.IsReady() && a.CreatedAt.Before(before) && len(pending) < limit {
+			pending = append(pending, a)
+		}
+	}
+	return pending, nil
+}
+
+func (r *memoryAttachmentRepo) ListUnattachedBefore(ctx context.Context, before time.Time, afterID string, limit int) ([]*attachment.Attachment, error) {
+	var unattached []*attachment.Attachment
+	for _, a := range r.attachments {
+		if a.IsReady() && a.MessageID == "" && a.ScanStatus != attachment.ScanPending && a.ReadyAt.Before(before) && a.ID > afterID {
+			loaded := *a
+			unattached = append(unattached, &loaded)
+		}
+	}
+	sort.Slice(unattached, func(i, j int) bool { return unattached[i].ID < unattached[j].ID })
+	if len(unattached) > limit {
+		unattached = unattached[:limit]
+	}
+	return unattached, nil
+}
+
+func (r *memoryAttachmentRepo) DeleteUnattached(ctx context.Context, id string) error {
+	if a, ok := r.attachments[id]; !ok || a.MessageID != "" {
+		return attachment.ErrAttachmentNotFound
+	}
+	return r.Delete(ctx, id)
+}
+
+func (r *memoryAttachmentRepo) ListScanPending(ctx context.Context, before time.Time, limit int) ([]*attachment.Attachment, error) {
+	var pending []*attachment.Attachment
+	for _, a := range r.attachments {
+		if a.IsReady() && a.ScanStatus == attachment.ScanPending && (a.NextScanAt == nil || a.NextScanAt.Before(before)) {
+			loaded := *a
+			pending = append(pending, &loaded)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		if (pending[i].NextScanAt == nil) != (pending[j].NextScanAt == nil) {
+			return pending[i].NextScanAt == nil
+		}
+		if pending[i].NextScanAt != nil && !pending[i].NextScanAt.Equal(*pending[j].NextScanAt) {
+			return pending[i].NextScanAt.Before(*pending[j].NextScanAt)
+		}
+		return pending[i].CreatedAt.Before(pending[j].CreatedAt)
+	})
+	if len(pending) > limit {
+		pending = pending[:limit]
+	}
+	return pending, nil
+}
+
+func (r *memoryAttachmentRepo) PostponeScan(ctx context.Context, a *attachment.Attachment) error {
+	stored, ok := r.attachments[a.ID]
+	if !ok || stored.ScanStatus != attachment.ScanPending {
+		return attachment.ErrAttachmentNotFound
+	}
+	stored.ScanAttempts, stored.NextScanAt = a.ScanAttempts, a.NextScanAt
+	return nil
+}
+
+func (r *memoryAttachmentRepo) RecordScan(ctx context.Context, a *attachment.Attachment) error {
+	stored, ok := r.attachments[a.ID]
+	if !ok || stored.ScanStatus != attachment.ScanPending {
+		return attachment.ErrAttachmentNotFound
+	}
+	stored.ScanStatus, stored.Threat, stored.ReadyAt = a.ScanStatus, a.Threat, a.ReadyAt
+	return nil
+}
+
+func (r *memoryAttachmentRepo) AttachToMessage(ctx context.Context, messageID string, ids []string) error {
+	for _, id := range ids {
+		if a, ok := r.attachments[id]; !ok || a.MessageID != "" || !a.IsReady() {
+			return attachment.ErrAlreadyAttached
+		}
+	}
+	for _, id := range ids {
+		r.attachments[id].MessageID = messageID
+	}
+	return nil
+}
+
+func (r *memoryAttachmentRepo) ListByMessages(ctx context.Context, messageIDs []string) ([]*attachment.Attachment, error) {
+	var attachments []*attachment.Attachment
+	for _, a := range r.attachments {
+		for _, messageID := range messageIDs {
+			if a.MessageID == messageID {
+				loaded := *a
+				attachments = append(attachments, &loaded)
+			}
+		}
+	}
+	sort.Slice(attachments, func(i, j int) bool { return attachments[i].ID < attachments[j].ID })
+	return attachments, nil
+}
+
+func (r *memoryAttachmentRepo) GetUsage(ctx context.Context, scope, ownerID string) (*attachment.Usage, error) {
+	var usage attachment.Usage
+	for _, a := range r.attachments {
+		if (scope == attachment.UsageScopeUser && a.OwnerID == ownerID) || (scope == attachment.UsageScopeRoom && a.ChatRoomID == ownerID) {
+			usage.Bytes += a.Size
+			usage.Files++
+		}
+	}
+	return &usage, nil
+}
+
+func (r *memoryAttachmentRepo) ReserveBlob(ctx context.Context, b *attachment.Blob) (*attachment.Blob, error) {
+	if r.blobs == nil {
+		r.blobs = map[string]*attachment.Blob{}
+	}
+	stored, ok := r.blobs[b.Key]
+	if !ok {
+		copied := *b
+		stored = &copied
+		r.blobs[b.Key] = stored
+	}
+	if r.refCount(b.Key) == 0 {
+		now := time.Now()
+		stored.OrphanedAt = &now
+	}
+	return r.loadBlob(stored), nil
+}
+
+func (r *memoryAttachmentRepo) GetBlobByChecksum(ctx context.Context, checksum, userID string) (*attachment.Blob, error) {
+	for _, b := range r.blobs {
+		if b.Checksum != checksum && b.SourceChecksum != checksum {
+			continue
+		}
+		for _, a := range r.attachments {
+			if a.StorageKey != b.Key || !a.IsReady() || a.ScanStatus == attachment.ScanQuarantined {
+				continue
+			}
+			if a.IsUploadedBy(userID) || (r.members != nil && r.members.roles[a.ChatRoomID][userID] != "") {
+				return r.loadBlob(b), nil
+			}
+		}
+	}
+	return nil, attachment.ErrBlobNotFound
+}
+
+func (r *memoryAttachmentRepo) ListOrphanedBlobs(ctx context.Context, before time.Time, afterKey string, limit int) ([]*attachment.Blob, error) {
+	var orphaned []*attachment.Blob
+	for _, b := range r.blobs {
+		if r.refCount(b.Key) == 0 && b.OrphanedAt != nil && b.OrphanedAt.Before(before) && b.Key > afterKey {
+			orphaned = append(orphaned, r.loadBlob(b))
+		}
+	}
+	sort.Slice(orphaned, func(i, j int) bool { return orphaned[i].Key < orphaned[j].Key })
+	if len(orphaned) > limit {
+		orphaned = orphaned[:limit]
+	}
+	return orphaned, nil
+}
+
+func (r *memoryAttachmentRepo) DeleteOrphanedBlob(ctx context.Context, key string, before time.Time, deleteContent func() error) error {
+	b, ok := r.blobs[key]
+	if !ok || r.refCount(key) > 0 || b.OrphanedAt == nil || !b.OrphanedAt.Before(before) {
+		return attachment.ErrBlobNotFound
+	}
+	if err := deleteContent(); err != nil {
+		return err
+	}
+	delete(r.blobs, key)
+	return nil
+}
+
+func (r *memoryAttachmentRepo) ListReferencedKeys(ctx context.Context, keys []string) ([]string, error) {
+	var referenced []string
+	for _, key := range keys {
+		if _, ok := r.blobs[key]; ok || r.refCount(key) > 0 {
+			referenced = append(referenced, key)
+		}
+	}
+	return referenced, nil
+}
+
+// refCount counts the attachments stored in a blob, as the database trigger does
+func (r *memoryAttachmentRepo) refCount(key string) int {
+	count := 0
+	for _, a := range r.attachments {
+		if a.StorageKey == key {
+			count++
+		}
+	}
+	return count
+}
+
+func (r *memoryAttachmentRepo) loadBlob(b *attachment.Blob) *attachment.Blob {
+	loaded := *b
+	loaded.RefCount = r.refCount(b.Key)
+	return &loaded
+}
+
+func (r *memoryAttachmentRepo) ListRoomUsage(ctx context.Context, ownerID string) ([]*attachment.RoomUsage, error) {
+	rooms := map[string]*attachment.RoomUsage{}
+	var usage []*attachment.RoomUsage
+	for _, a := range r.attachments {
+		if a.OwnerID != ownerID {
+			continue
+		}
+		room, ok := rooms[a.ChatRoomID]
+		if !ok {
+			total, _ := r.GetUsage(ctx, attachment.UsageScopeRoom, a.ChatRoomID)
+			room = &attachment.RoomUsage{ChatRoomID: a.ChatRoomID, RoomBytes: total.Bytes}
+			rooms[a.ChatRoomID] = room
+			usage = append(usage, room)
+		}
+		room.Bytes += a.Size
+		room.Files++
+	}
+	return usage, nil
+}
+
+// memorySessionRepo keeps resumable upload sessions in a map, handing out
+// copies like a database would
+type memorySessionRepo struct {
+	sessions map[string]*attachment.UploadSession
+}
+
+func (r *memorySessionRepo) Create(ctx context.Context, session *attachment.UploadSession) error {
+	stored := *session
+	r.sessions[session.AttachmentID] = &stored
+	return nil
+}
+
+func (r *memorySessionRepo) GetByAttachmentID(ctx context.Context, attachmentID string) (*attachment.UploadSession, error) {
+	session, ok := r.sessions[attachmentID]
+	if !ok {
+		return nil, attachment.ErrSessionNotFound
+	}
+	loaded := *session
+	loaded.ChunkKeys = append([]string(nil), session.ChunkKeys...)
+	return &loaded, nil
+}
+
+func (r *memorySessionRepo) AppendChunk(ctx context.Context, attachmentID string, fromOffset, toOffset int64, chunkKey string, expiresAt time.Time) error {
+	session, ok := r.sessions[attachmentID]
+	if !ok || session.Offset != fromOffset {
+		return attachment.ErrOffsetConflict
+	}
+	session.Offset = toOffset
+	session.ChunkKeys = append(session.ChunkKeys, chunkKey)
+	session.ExpiresAt = expiresAt
+	return nil
+}
+
+func (r *memorySessionRepo) Delete(ctx context.Context, attachmentID string) error {
+	delete(r.sessions, attachmentID)
+	return nil
+}
+
+func (r *memorySessionRepo) ListExpired(ctx context.Context, before time.Time, limit int) ([]*attachment.UploadSession, error) {
+	var expired []*attachment.UploadSession
+	for _, session := range r.sessions {
+		if session.ExpiresAt.Before(before) && len(expired) < limit {
+			expired = append(expired, session)
+		}
+	}
+	return expired, nil
+}
+
+// memberRoles answers membership checks from a room -> user -> role map;
+// other chat repository methods are not used by the file use case
+type memberRoles struct {
+	chat.Repository
+	roles map[string]map[string]string
+}
+
+func (r *memberRoles) IsMember(ctx context.Context, roomID, userID string) (bool, error) {
+	_, ok := r.roles[roomID][userID]
+	return ok, nil
+}
+
+func (r *memberRoles) GetMemberRole(ctx context.Context, roomID, userID string) (string, error) {
+	role, ok := r.roles[roomID][userID]
+	if !ok {
+		return "", chat.ErrMemberNotFound
+	}
+	return role, nil
+}
+
+func TestAttachmentEntity(t *testing.T) {
+	t.Run("ContentType allows known extensions only", func(t *testing.T) {
+		contentType, ok := attachment.ContentType("Report.PDF")
+		assert.True(t, ok)
+		assert.Equal(t, "application/pdf", contentType)
+
+		contentType, ok = attachment.ContentType("logo.svg")
+		assert.True(t, ok)
+		assert.Equal(t, "image/svg+xml", contentType)
+		_, ok = attachment.ContentType("run.exe")
+		assert.False(t, ok)
+	})
+
+	t.Run("CleanFileName strips paths and control characters", func(t *testing.T) {
+		assert.Equal(t, "passwd.txt", attachment.CleanFileName("../../etc/passwd.txt"))
+		assert.Equal(t, "report.pdf", attachment.CleanFileName(`C:\Users\alice\report.pdf`))
+		assert.Equal(t, "a.txt", attachment.CleanFileName("a\r\n.txt"))
+		assert.Equal(t, "", attachment.CleanFileName(".."))
+
+		long := attachment.CleanFileName(strings.Repeat("é", 300) + ".png")
+		assert.LessOrEqual(t, len(long), attachment.MaxFileNameLength)
+		assert.True(t, strings.HasSuffix(long, ".png"))
+	})
+
+	t.Run("IsStorageKey matches the keys files are stored under", func(t *testing.T) {
+		content := attachment.ContentKey(strings.Repeat("ab", 32))
+		id := "0b8f4c1e-5d2a-4c6e-9f3b-7a1d2e4c6b8a"
+		session := attachment.NewUploadSession(id, time.Now().Add(time.Hour))
+		for _, key := range []string{
+			content,
+			attachment.VariantKey(content, attachment.VariantPreview),
+			id,
+			attachment.VariantKey(id, attachment.VariantThumbnail),
+			session.ChunkKey(1024, "9c1f0a2e-3b4d-4e5f-8a6b-7c8d9e0f1a2b"),
+		} {
+			assert.True(t, attachment.IsStorageKey(key), key)
+		}
+		for _, key := range []string{"backup.sql", "sha256-abc", content + ".txt", "avatars/" + id, id + ".chunk-1"} {
+			assert.False(t, attachment.IsStorageKey(key), key)
+		}
+	})
+}
+
+func TestFileUseCase(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryBlob()
+	repo := &memoryAttachmentRepo{attachments: map[string]*attachment.Attachment{}}
+	sessions := &memorySessionRepo{sessions: map[string]*attachment.UploadSession{}}
+	uc := file.NewUseCase(repo, sessions, testRoomMembers(), store, nil, validation.New(), *logger.New("error", "json"), file.UploadSettings{
+		MaxSize:   10,
+		URLExpiry: 15 * time.Minute,
+	})
+
+	upload := func(t *testing.T) *file.FileOutput {
+		result, err := uc.UploadFile(ctx, file.UploadFileInput{
+			UserID:     "uploader",
+			ChatRoomID: "room-1",
+			Filename:   "notes.txt",
+			Size:       5,
+			Content:    strings.NewReader("hello"),
+		})
+		require.NoError(t, err)
+		return result
+	}
+
+	t.Run("Upload records size, type and checksum", func(t *testing.T) {
+		result := upload(t)
+
+		assert.Equal(t, "notes.txt", result.OriginalName)
+		assert.Equal(t, int64(5), result.Size)
+		assert.Equal(t, "text/plain", result.MimeType)
+		assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", result.Checksum)
+	})
+
+	t.Run("Upload rejects oversized and truncated files and leaves nothing behind", func(t *testing.T) {
+		before := len(repo.attachments)
+		_, err := uc.UploadFile(ctx, file.UploadFileInput{
+			UserID:     "uploader",
+			ChatRoomID: "room-1",
+			Filename:   "big.txt",
+			Size:       19,
+			Content:    strings.NewReader("more than ten bytes"),
+		})
+		assert.Equal(t, &file.FileTooLargeError{MaxSize: 10}, err)
+
+		_, err = uc.UploadFile(ctx, file.UploadFileInput{
+			UserID:     "uploader",
+			ChatRoomID: "room-1",
+			Filename:   "short.txt",
+			Size:       8,
+			Content:    strings.NewReader("short"),
+		})
+		assert.Error(t, err)
+		assert.Len(t, repo.attachments, before)
+	})
+
+	t.Run("Upload requires membership and an allowed type", func(t *testing.T) {
+		_, err := uc.UploadFile(ctx, file.UploadFileInput{
+			UserID: "outsider", ChatRoomID: "room-1", Filename: "a.txt", Content: strings.NewReader("x"),
+		})
+		assert.Equal(t, chat.ErrChatRoomNotFound, err)
+
+		_, err = uc.UploadFile(ctx, file.UploadFileInput{
+			UserID: "uploader", ChatRoomID: "room-1", Filename: "a.html", Content: strings.NewReader("x"),
+		})
+		assert.Equal(t, attachment.ErrFileTypeNotAllowed, err)
+	})
+
+	t.Run("Only room members can download", func(t *testing.T) {
+		uploaded := upload(t)
+
+		opened, err := uc.OpenFile(ctx, file.GetFileInput{FileID: uploaded.ID, UserID: "member"})
+		require.NoError(t, err)
+		assert.Empty(t, opened.URL)
+		data, _ := io.ReadAll(opened.Content)
+		opened.Content.Close()
+		assert.Equal(t, "hello", string(data))
+
+		_, err = uc.OpenFile(ctx, file.GetFileInput{FileID: uploaded.ID, UserID: "outsider"})
+		assert.Equal(t, attachment.ErrAttachmentNotFound, err)
+		_, err = uc.GetFile(ctx, file.GetFileInput{FileID: uploaded.ID, UserID: "outsider"})
+		assert.Equal(t, attachment.ErrAttachmentNotFound, err)
+	})
+
+	t.Run("Only the uploader or a room admin can delete", func(t *testing.T) {
+		first := upload(t)
+		second := upload(t)
+
+		err := uc.DeleteFile(ctx, file.DeleteFileInput{FileID: first.ID, UserID: "member"})
+		assert.Equal(t, attachment.ErrNotAuthorized, err)
+		err = uc.DeleteFile(ctx, file.DeleteFileInput{FileID: first.ID, UserID: "outsider"})
+		assert.Equal(t, attachment.ErrAttachmentNotFound, err)
+
+		assert.NoError(t, uc.DeleteFile(ctx, file.DeleteFileInput{FileID: first.ID, UserID: "uploader"}))
+		assert.NoError(t, uc.DeleteFile(ctx, file.DeleteFileInput{FileID: second.ID, UserID: "admin"}))
+
+		_, err = store.Get(ctx, first.ID)
+		assert.Equal(t, storage.ErrNotFound, err)
+	})
+
+	t.Run("Download escapes the original file name", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		result, err := uc.UploadFile(ctx, file.UploadFileInput{
+			UserID:     "uploader",
+			ChatRoomID: "room-1",
+			Filename:   "a\"; filename=evil.exe; x=\"résumé.txt",
+			Size:       5,
+			Content:    strings.NewReader("hello"),
+		})
+		require.NoError(t, err)
+
+		handler := handlers.NewFileHandler(uc, file.UploadSettings{MaxSize: 10}, *logger.New("error", "json"))
+		router := gin.New()
+		router.GET("/files/:id/download", func(c *gin.Context) {
+			c.Set("user_id", "member")
+		}, handler.DownloadFile)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/files/"+result.ID+"/download", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "hello", w.Body.String())
+		assert.Equal(t, "text/plain", w.Header().Get("Content-Type"))
+		assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+		assert.Equal(t, "default-src 'none'; sandbox", w.Header().Get("Content-Security-Policy"))
+		assert.Equal(t, storage.ContentDisposition(result.OriginalName), w.Header().Get("Content-Disposition"))
+	})
+
+	t.Run("Direct uploads need storage that can pre-sign URLs", func(t *testing.T) {
+		_, err := uc.CreateUpload(ctx, file.CreateUploadInput{
+			UserID: "uploader", ChatRoomID: "room-1", Filename: "notes.txt", Size: 5,
+		})
+		assert.Equal(t, storage.ErrPresignNotSupported, err)
+	})
+}
+
+func TestFileDirectUpload(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeS3(t)
+	blob, err := storage.NewS3Blob(storage.S3Config{
+		Endpoint:        server.URL,
+		Region:          "us-east-1",
+		Bucket:          "uploads",
+		AccessKeyID:     "test-key",
+		SecretAccessKey: "test-secret",
+		PathStyle:       true,
+	}, server.Client())
+	require.NoError(t, err)
+
+	repo := &memoryAttachmentRepo{attachments: map[string]*attachment.Attachment{}}
+	sessions := &memorySessionRepo{sessions: map[string]*attachment.UploadSession{}}
+	uc := file.NewUseCase(repo, sessions, testRoomMembers(), blob, nil, validation.New(), *logger.New("error", "json"), file.UploadSettings{
+		MaxSize:   10,
+		URLExpiry: 15 * time.Minute,
+	})
+
+	put := func(t *testing.T, upload *file.CreateUploadOutput, body string) {
+		req, err := http.NewRequest(upload.Method, upload.UploadURL, strings.NewReader(body))
+		require.NoError(t, err)
+		for name, value := range upload.Headers {
+			req.Header.Set(name, value)
+		}
+		resp, err := server.Client().Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	t.Run("Upload is hidden until completed", func(t *testing.T) {
+		upload, err := uc.CreateUpload(ctx, file.CreateUploadInput{
+			UserID: "uploader", ChatRoomID: "room-1", Filename: "notes.txt", Size: 5,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, attachment.StatusPending, upload.File.Status)
+		assert.Equal(t, "text/plain", upload.Headers["Content-Type"])
+
+		_, err = uc.GetFile(ctx, file.GetFileInput{FileID: upload.File.ID, UserID: "member"})
+		assert.Equal(t, attachment.ErrAttachmentNotFound, err)
+
+		// Completing before the content is stored fails
+		_, err = uc.CompleteUpload(ctx, file.CompleteUploadInput{FileID: upload.File.ID, UserID: "uploader"})
+		assert.Equal(t, attachment.ErrIncompleteUpload, err)
+
+		put(t, upload, "hello")
+
+		_, err = uc.CompleteUpload(ctx, file.CompleteUploadInput{FileID: upload.File.ID, UserID: "member"})
+		assert.Equal(t, attachment.ErrAttachmentNotFound, err)
+
+		completed, err := uc.CompleteUpload(ctx, file.CompleteUploadInput{FileID: upload.File.ID, UserID: "uploader"})
+		require.NoError(t, err)
+		assert.Equal(t, attachment.StatusReady, completed.Status)
+		assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", completed.Checksum)
+
+		opened, err := uc.OpenFile(ctx, file.GetFileInput{FileID: upload.File.ID, UserID: "member"})
+		require.NoError(t, err)
+		assert.Contains(t, opened.URL, "X-Amz-Signature=")
+		assert.Contains(t, opened.URL, "response-content-disposition=")
+	})
+
+	t.Run("Content of the wrong size is rejected", func(t *testing.T) {
+		upload, err := uc.CreateUpload(ctx, file.CreateUploadInput{
+			UserID: "uploader", ChatRoomID: "room-1", Filename: "notes.txt", Size: 5,
+		})
+		require.NoError(t, err)
+
+		put(t, upload, "much longer than declared")
+
+		_, err = uc.CompleteUpload(ctx, file.CompleteUploadInput{FileID: upload.File.ID, UserID: "uploader"})
+		assert.Equal(t, attachment.ErrIncompleteUpload, err)
+		_, err = blob.Get(ctx, upload.File.ID)
+		assert.Equal(t, storage.ErrNotFound, err)
+	})
+
+	t.Run("Stale uploads are purged", func(t *testing.T) {
+		upload, err := uc.CreateUpload(ctx, file.CreateUploadInput{
+			UserID: "uploader", ChatRoomID: "room-1", Filename: "notes.txt", Size: 5,
+		})
+		require.NoError(t, err)
+		repo.attachments[upload.File.ID].CreatedAt = time.Now().Add(-24 * time.Hour)
+
+		purged, err := uc.PurgeStaleUploads(ctx)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, purged, 1)
+		assert.NotContains(t, repo.attachments, upload.File.ID)
+	})
+}
+
+// testRoomMembers has an uploader, a member and an admin in room-1
+func testRoomMembers() *memberRoles {
+	return &memberRoles{roles: map[string]map[string]string{
+		"room-1": {"uploader": chat.RoleMember, "member": chat.RoleMember, "admin": chat.RoleAdmin},
+	}}
+}
+
+// fileTestEnv is a file use case over in-memory fakes of room-1
+type fileTestEnv struct {
+	uc       file.UseCase
+	repo     *memoryAttachmentRepo
+	sessions *memorySessionRepo
+	members  *memberRoles
+	store    storage.Blob
+}
+
+func newFileTestUseCase(t *testing.T, settings file.UploadSettings, scanner scan.Scanner) *fileTestEnv {
+	t.Helper()
+	members := testRoomMembers()
+	env := &fileTestEnv{
+		repo:     &memoryAttachmentRepo{attachments: map[string]*attachment.Attachment{}, members: members},
+		sessions: &memorySessionRepo{sessions: map[string]*attachment.UploadSession{}},
+		members:  members,
+		store:    storage.NewMemoryBlob(),
+	}
+	env.uc = env.useCase(settings, scanner)
+	return env
+}
+
+// useCase builds another use case sharing the fakes of the environment
+func (e *fileTestEnv) useCase(settings file.UploadSettings, scanner scan.Scanner) file.UseCase {
+	return file.NewUseCase(e.repo, e.sessions, e.members, e.store, scanner, validation.New(), *logger.New("error", "json"), settings)
+}
+
+func uploadTestFile(uc file.UseCase, userID, name, content string) (*file.FileOutput, error) {
+	return uc.UploadFile(context.Background(), file.UploadFileInput{
+		UserID:     userID,
+		ChatRoomID: "room-1",
+		Filename:   name,
+		Size:       int64(len(content)),
+		Content:    strings.NewReader(content),
+	})
+}
+
+func mustUploadTestFile(t *testing.T, uc file.UseCase, userID, name, content string) *file.FileOutput {
+	t.Helper()
+	uploaded, err := uploadTestFile(uc, userID, name, content)
+	require.NoError(t, err)
+	return uploaded
+}
+
+func TestFileResumableUpload(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryBlob()
+	repo := &memoryAttachmentRepo{attachments: map[string]*attachment.Attachment{}}
+	sessions := &memorySessionRepo{sessions: map[string]*attachment.UploadSession{}}
+	settings := file.UploadSettings{
+		MaxSize:          4,
+		URLExpiry:        15 * time.Minute,
+		ResumableMaxSize: 20,
+		ChunkMaxSize:     4,
+		ResumableExpiry:  time.Hour,
+	}
+	uc := file.NewUseCase(repo, sessions, testRoomMembers(), store, nil, validation.New(), *logger.New("error", "json"), settings)
+
+	create := func(t *testing.T, size int64) string {
+		upload, err := uc.CreateResumableUpload(ctx, file.CreateUploadInput{
+			UserID: "uploader", ChatRoomID: "room-1", Filename: "clip.txt", Size: size,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), upload.Offset)
+		return upload.File.ID
+	}
+	write := func(id string, offset int64, chunk string) (*file.ResumableUploadOutput, error) {
+		return uc.WriteChunk(ctx, file.WriteChunkInput{
+			FileID:  id,
+			UserID:  "uploader",
+			Offset:  offset,
+			Size:    int64(len(chunk)),
+			Content: strings.NewReader(chunk),
+		})
+	}
+
+	t.Run("Chunks are joined into the file on completion", func(t *testing.T) {
+		id := create(t, 11)
+
+		for _, chunk := range []string{"hell", "o wo", "rld"} {
+			progress, err := uc.GetResumableUpload(ctx, file.ResumableUploadInput{FileID: id, UserID: "uploader"})
+			require.NoError(t, err)
+			_, err = write(id, progress.Offset, chunk)
+			require.NoError(t, err)
+		}
+
+		_, err := uc.GetFile(ctx, file.GetFileInput{FileID: id, UserID: "member"})
+		assert.Equal(t, attachment.ErrAttachmentNotFound, err)
+
+		completed, err := uc.CompleteResumableUpload(ctx, file.ResumableUploadInput{FileID: id, UserID: "uploader"})
+		require.NoError(t, err)
+		assert.Equal(t, attachment.StatusReady, completed.Status)
+		assert.Equal(t, "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9", completed.Checksum)
+		assert.NotContains(t, sessions.sessions, id)
+
+		opened, err := uc.OpenFile(ctx, file.GetFileInput{FileID: id, UserID: "member"})
+		require.NoError(t, err)
+		content, err := io.ReadAll(opened.Content)
+		opened.Content.Close()
+		require.NoError(t, err)
+		assert.Equal(t, "hello world", string(content))
+
+		// Completing again returns the finished file
+		_, err = uc.CompleteResumableUpload(ctx, file.ResumableUploadInput{FileID: id, UserID: "uploader"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Chunks must start at the current offset", func(t *testing.T) {
+		id := create(t, 8)
+		_, err := write(id, 0, "abcd")
+		require.NoError(t, err)
+
+		// A retried chunk that was already stored is reported with the real offset
+		_, err = write(id, 0, "abcd")
+		var offsetErr *file.OffsetMismatchError
+		require.ErrorAs(t, err, &offsetErr)
+		assert.Equal(t, int64(4), offsetErr.Offset)
+
+		_, err = uc.CompleteResumableUpload(ctx, file.ResumableUploadInput{FileID: id, UserID: "uploader"})
+		assert.Equal(t, attachment.ErrIncompleteUpload, err)
+	})
+
+	t.Run("Chunks are limited in size and by the declared length", func(t *testing.T) {
+		id := create(t, 6)
+		_, err := write(id, 0, "abcde")
+		assert.Equal(t, attachment.ErrChunkTooLarge, err)
+
+		_, err = write(id, 0, "abcd")
+		require.NoError(t, err)
+		_, err = write(id, 4, "efg")
+		assert.Equal(t, attachment.ErrChunkTooLarge, err)
+
+		_, err = uc.CreateResumableUpload(ctx, file.CreateUploadInput{
+			UserID: "uploader", ChatRoomID: "room-1", Filename: "clip.txt", Size: 21,
+		})
+		assert.Equal(t, &file.FileTooLargeError{MaxSize: 20}, err)
+	})
+
+	t.Run("Only the uploader can resume an upload", func(t *testing.T) {
+		id := create(t, 4)
+		_, err := uc.WriteChunk(ctx, file.WriteChunkInput{
+			FileID: id, UserID: "member", Offset: 0, Size: 4, Content: strings.NewReader("abcd"),
+		})
+		assert.Equal(t, attachment.ErrAttachmentNotFound, err)
+	})
+
+	t.Run("Expired uploads are purged with their chunks", func(t *testing.T) {
+		id := create(t, 8)
+		_, err := write(id, 0, "abcd")
+		require.NoError(t, err)
+		chunkKey := sessions.sessions[id].ChunkKeys[0]
+		sessions.sessions[id].ExpiresAt = time.Now().Add(-time.Minute)
+
+		_, err = write(id, 4, "efgh")
+		assert.Equal(t, attachment.ErrSessionNotFound, err)
+
+		purged, err := uc.PurgeStaleUploads(ctx)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, purged, 1)
+		assert.NotContains(t, repo.attachments, id)
+		assert.NotContains(t, sessions.sessions, id)
+		_, err = store.Get(ctx, chunkKey)
+		assert.Equal(t, storage.ErrNotFound, err)
+	})
+
+	t.Run("PATCH reports conflicts with the current offset", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		id := create(t, 8)
+		handler := handlers.NewFileHandler(uc, settings, *logger.New("error", "json"))
+		router := gin.New()
+		router.PATCH("/files/resumable/:id", func(c *gin.Context) {
+			c.Set("user_id", "uploader")
+		}, handler.WriteChunk)
+
+		patch := func(offset, body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("PATCH", "/files/resumable/"+id, strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/offset+octet-stream")
+			req.Header.Set("Upload-Offset", offset)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w
+		}
+
+		w := patch("0", "abcd")
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, "4", w.Header().Get("Upload-Offset"))
+
+		w = patch("0", "abcd")
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, "4", w.Header().Get("Upload-Offset"))
+
+		w = patch("4", "efghij")
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	})
+}
+
+func TestFileContentChecks(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryBlob()
+	repo := &memoryAttachmentRepo{attachments: map[string]*attachment.Attachment{}}
+	sessions := &memorySessionRepo{sessions: map[string]*attachment.UploadSession{}}
+	uc := file.NewUseCase(repo, sessions, testRoomMembers(), store, nil, validation.New(), *logger.New("error", "json"), file.UploadSettings{
+		MaxSize:          1 << 20,
+		URLExpiry:        15 * time.Minute,
+		ResumableMaxSize: 1 << 20,
+		ChunkMaxSize:     1 << 20,
+		ResumableExpiry:  time.Hour,
+	})
+
+	upload := func(name string, content []byte) (*file.FileOutput, error) {
+		return uc.UploadFile(ctx, file.UploadFileInput{
+			UserID:     "uploader",
+			ChatRoomID: "room-1",
+			Filename:   name,
+			Size:       int64(len(content)),
+			Content:    bytes.NewReader(content),
+		})
+	}
+
+	t.Run("Content must match the file type", func(t *testing.T) {
+		_, err := upload("cat.png", []byte("<html><script>alert(1)</script></html>"))
+		assert.Equal(t, attachment.ErrContentMismatch, err)
+		_, err = upload("notes.txt", testJPEG(t, 8, 8, 1, "x"))
+		assert.Equal(t, attachment.ErrContentMismatch, err)
+		assert.Empty(t, repo.attachments)
+
+		// The first chunk of a resumable upload is checked straight away
+		html := []byte(strings.Repeat("<html>", 100))
+		resumable, err := uc.CreateResumableUpload(ctx, file.CreateUploadInput{
+			UserID: "uploader", ChatRoomID: "room-1", Filename: "clip.mp4", Size: int64(len(html)),
+		})
+		require.NoError(t, err)
+		_, err = uc.WriteChunk(ctx, file.WriteChunkInput{
+			FileID: resumable.File.ID, UserID: "uploader", Size: int64(len(html)), Content: bytes.NewReader(html),
+		})
+		assert.Equal(t, attachment.ErrContentMismatch, err)
+	})
+
+	t.Run("Images are stored without metadata and with thumbnails", func(t *testing.T) {
+		result, err := upload("holiday.jpg", testJPEG(t, 600, 400, 8, "GPS 51.5N secret"))
+		require.NoError(t, err)
+		assert.Equal(t, 400, result.Width)
+		assert.Equal(t, 600, result.Height)
+		assert.Equal(t, "image/jpeg", result.RenderType)
+
+		opened, err := uc.OpenFile(ctx, file.GetFileInput{FileID: result.ID, UserID: "member"})
+		require.NoError(t, err)
+		content, err := io.ReadAll(opened.Content)
+		opened.Content.Close()
+		require.NoError(t, err)
+		assert.NotContains(t, string(content), "secret")
+		assert.Equal(t, int64(len(content)), result.Size)
+		assert.Equal(t, fmt.Sprintf("%x", sha256.Sum256(content)), result.Checksum)
+
+		thumbnail, err := uc.OpenThumbnail(ctx, file.OpenThumbnailInput{FileID: result.ID, UserID: "member", Variant: attachment.VariantThumbnail})
+		require.NoError(t, err)
+		decoded, err := jpeg.Decode(thumbnail.Content)
+		thumbnail.Content.Close()
+		require.NoError(t, err)
+		assert.Equal(t, image.Rect(0, 0, 213, media.ThumbnailSize), decoded.Bounds())
+
+		_, err = uc.OpenThumbnail(ctx, file.OpenThumbnailInput{FileID: result.ID, UserID: "outsider", Variant: attachment.VariantPreview})
+		assert.Equal(t, attachment.ErrAttachmentNotFound, err)
+
+		require.NoError(t, uc.DeleteFile(ctx, file.DeleteFileInput{FileID: result.ID, UserID: "uploader"}))
+		_, err = store.Get(ctx, result.ID+"."+attachment.VariantPreview)
+		assert.Equal(t, storage.ErrNotFound, err)
+	})
+
+	t.Run("SVG files are accepted without thumbnails", func(t *testing.T) {
+		result, err := upload("logo.svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`))
+		require.NoError(t, err)
+		assert.Equal(t, "image/svg+xml", result.MimeType)
+
+		_, err = uc.OpenThumbnail(ctx, file.OpenThumbnailInput{FileID: result.ID, UserID: "member", Variant: attachment.VariantThumbnail})
+		assert.Equal(t, attachment.ErrThumbnailNotFound, err)
+	})
+}
+
+func TestFileStorageQuota(t *testing.T) {
+	ctx := context.Background()
+	settings := file.UploadSettings{
+		MaxSize:          100,
+		URLExpiry:        15 * time.Minute,
+		ResumableMaxSize: 100,
+		ChunkMaxSize:     100,
+		ResumableExpiry:  time.Hour,
+		TypeMaxSizes:     map[string]int64{"text/plain": 8, "application": 20},
+		UserQuota:        12,
+		RoomQuota:        20,
+	}
+	uc := newFileTestUseCase(t, settings, nil).uc
+	upload := func(userID, name, content string) (*file.FileOutput, error) {
+		return uploadTestFile(uc, userID, name, content)
+	}
+
+	t.Run("Size limits can be lowered per type", func(t *testing.T) {
+		assert.Equal(t, int64(8), settings.SizeLimit("text/plain", 100))
+		assert.Equal(t, int64(20), settings.SizeLimit("application/pdf", 100))
+		assert.Equal(t, int64(10), settings.SizeLimit("application/pdf", 10))
+		assert.Equal(t, int64(100), settings.SizeLimit("image/png", 100))
+
+		_, err := upload("uploader", "notes.txt", "ninebytes")
+		assert.Equal(t, &file.FileTooLargeError{MaxSize: 8}, err)
+		assert.ErrorIs(t, err, attachment.ErrFileTooLarge)
+	})
+
+	t.Run("Uploads stop at the user quota", func(t *testing.T) {
+		_, err := upload("uploader", "a.txt", "hello")
+		require.NoError(t, err)
+		_, err = upload("uploader", "b.txt", "world")
+		require.NoError(t, err)
+
+		_, err = upload("uploader", "c.txt", "again")
+		assert.Equal(t, &file.QuotaExceededError{Scope: attachment.UsageScopeUser, Quota: 12, Used: 10, Size: 5}, err)
+		assert.ErrorIs(t, err, attachment.ErrUserQuotaExceeded)
+
+		var quotaErr *file.QuotaExceededError
+		require.ErrorAs(t, err, &quotaErr)
+		assert.Equal(t, int64(2), quotaErr.Remaining())
+	})
+
+	t.Run("Uploads in progress count towards the quota until removed", func(t *testing.T) {
+		resumable, err := uc.CreateResumableUpload(ctx, file.CreateUploadInput{
+			UserID: "uploader", ChatRoomID: "room-1", Filename: "tiny.txt", Size: 2,
+		})
+		require.NoError(t, err)
+
+		_, err = upload("uploader", "x.txt", "x")
+		assert.ErrorIs(t, err, attachment.ErrUserQuotaExceeded)
+
+		require.NoError(t, uc.DeleteFile(ctx, file.DeleteFileInput{FileID: resumable.File.ID, UserID: "uploader"}))
+		usage, err := uc.GetStorageUsage(ctx, file.GetStorageUsageInput{UserID: "uploader"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(10), usage.Used)
+	})
+
+	t.Run("Rejected content does not use the quota", func(t *testing.T) {
+		_, err := upload("member", "cat.png", "<html>")
+		assert.Equal(t, attachment.ErrContentMismatch, err)
+
+		usage, err := uc.GetStorageUsage(ctx, file.GetStorageUsageInput{UserID: "member"})
+		require.NoError(t, err)
+		assert.Zero(t, usage.Used)
+		assert.Empty(t, usage.Rooms)
+	})
+
+	t.Run("Uploads stop at the room quota", func(t *testing.T) {
+		_, err := upload("member", "m.txt", "member")
+		require.NoError(t, err)
+
+		_, err = upload("admin", "n.txt", "admin")
+		assert.Equal(t, &file.QuotaExceededError{Scope: attachment.UsageScopeRoom, Quota: 20, Used: 16, Size: 5}, err)
+		assert.ErrorIs(t, err, attachment.ErrRoomQuotaExceeded)
+	})
+
+	t.Run("Usage summary lists quota, rooms and limits", func(t *testing.T) {
+		usage, err := uc.GetStorageUsage(ctx, file.GetStorageUsageInput{UserID: "uploader"})
+		require.NoError(t, err)
+
+		remaining, roomRemaining := int64(2), int64(4)
+		assert.Equal(t, &file.StorageUsageOutput{
+			Used:      10,
+			Files:     2,
+			Quota:     12,
+			Remaining: &remaining,
+			Rooms: []*file.RoomStorageOutput{{
+				ChatRoomID:    "room-1",
+				Used:          10,
+				Files:         2,
+				RoomUsed:      16,
+				RoomQuota:     20,
+				RoomRemaining: &roomRemaining,
+			}},
+			Limits: file.StorageLimitsOutput{
+				MaxFileSize:      100,
+				ResumableMaxSize: 100,
+				TypeMaxSizes:     settings.TypeMaxSizes,
+			},
+		}, usage)
+	})
+
+	t.Run("Exceeding the quota responds 413 with what remains", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		handler := handlers.NewFileHandler(uc, settings, *logger.New("error", "json"))
+		router := gin.New()
+		router.POST("/files", func(c *gin.Context) {
+			c.Set("user_id", "uploader")
+		}, handler.UploadFile)
+		router.GET("/me/storage", func(c *gin.Context) {
+			c.Set("user_id", "uploader")
+		}, handler.GetStorageUsage)
+
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, err := form.CreateFormFile("file", "more.txt")
+		require.NoError(t, err)
+		part.Write([]byte("three"))
+		form.WriteField("room_id", "room-1")
+		form.Close()
+
+		req := httptest.NewRequest("POST", "/files", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "user", response["scope"])
+		assert.Equal(t, float64(2), response["remaining"])
+		assert.Equal(t, float64(12), response["quota"])
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/me/storage", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"remaining":2`)
+	})
+}
+
+func TestFileDeduplication(t *testing.T) {
+	ctx := context.Background()
+	settings := file.UploadSettings{MaxSize: 1 << 20, URLExpiry: 15 * time.Minute}
+	env := newFileTestUseCase(t, settings, nil)
+	env.members.roles["room-2"] = map[string]string{"outsider": chat.RoleMember}
+	uc, repo, store := env.uc, env.repo, env.store
+	upload := func(t *testing.T, userID, name string, content []byte) *file.FileOutput {
+		return mustUploadTestFile(t, uc, userID, name, string(content))
+	}
+	read := func(t *testing.T, id string) string {
+		opened, err := uc.OpenFile(ctx, file.GetFileInput{FileID: id, UserID: "member"})
+		require.NoError(t, err)
+		defer opened.Content.Close()
+		data, _ := io.ReadAll(opened.Content)
+		return string(data)
+	}
+	// orphan makes a blob old enough to be purged
+	orphan := func(key string) {
+		orphanedAt := time.Now().Add(-2 * time.Hour)
+		repo.blobs[key].OrphanedAt = &orphanedAt
+	}
+
+	t.Run("Identical uploads share one stored copy until the last is deleted", func(t *testing.T) {
+		first := upload(t, "uploader", "meme.txt", []byte("forwarded"))
+		second := upload(t, "member", "copy.txt", []byte("forwarded"))
+		key := attachment.ContentKey(first.Checksum)
+
+		assert.NotEqual(t, first.ID, second.ID)
+		assert.Equal(t, first.Checksum, second.Checksum)
+		assert.Len(t, repo.blobs, 1)
+		_, err := store.Get(ctx, first.ID)
+		assert.Equal(t, storage.ErrNotFound, err, "content is not kept under the attachment ID")
+		_, err = store.Get(ctx, second.ID)
+		assert.Equal(t, storage.ErrNotFound, err)
+
+		require.NoError(t, uc.DeleteFile(ctx, file.DeleteFileInput{FileID: first.ID, UserID: "uploader"}))
+		assert.Equal(t, "forwarded", read(t, second.ID))
+
+		require.NoError(t, uc.DeleteFile(ctx, file.DeleteFileInput{FileID: second.ID, UserID: "member"}))
+		_, err = uc.PurgeStaleUploads(ctx)
+		require.NoError(t, err)
+		_, err = store.Get(ctx, key)
+		assert.NoError(t, err, "orphaned content is kept for a while")
+
+		orphan(key)
+		_, err = uc.PurgeStaleUploads(ctx)
+		require.NoError(t, err)
+		_, err = store.Get(ctx, key)
+		assert.Equal(t, storage.ErrNotFound, err)
+		assert.Empty(t, repo.blobs)
+	})
+
+	t.Run("Uploading orphaned content again keeps it", func(t *testing.T) {
+		first := upload(t, "uploader", "again.txt", []byte("again"))
+		key := attachment.ContentKey(first.Checksum)
+		require.NoError(t, uc.DeleteFile(ctx, file.DeleteFileInput{FileID: first.ID, UserID: "uploader"}))
+		orphan(key)
+
+		second := upload(t, "uploader", "again.txt", []byte("again"))
+		_, err := uc.PurgeStaleUploads(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "again", read(t, second.ID))
+	})
+
+	t.Run("Images are matched by their content with or without metadata", func(t *testing.T) {
+		original := testJPEG(t, 40, 30, 0, "GPS 51.5N")
+		first := upload(t, "uploader", "photo.jpg", original)
+		second := upload(t, "member", "photo.jpg", testJPEG(t, 40, 30, 0, "GPS 48.8N"))
+		assert.Equal(t, first.Checksum, second.Checksum)
+		assert.NotEmpty(t, second.RenderType)
+
+		sum := sha256.Sum256(original)
+		reused, err := uc.UploadByChecksum(ctx, file.UploadByChecksumInput{
+			UserID: "admin", ChatRoomID: "room-1", Filename: "forwarded.jpg", Size: int64(len(original)), Checksum: fmt.Sprintf("%X", sum),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, first.Checksum, reused.Checksum)
+		assert.Equal(t, first.Size, reused.Size)
+		assert.Equal(t, first.RenderType, reused.RenderType)
+
+		thumbnail, err := uc.OpenThumbnail(ctx, file.OpenThumbnailInput{FileID: reused.ID, UserID: "member", Variant: attachment.VariantThumbnail})
+		require.NoError(t, err)
+		thumbnail.Content.Close()
+	})
+
+	t.Run("Upload by checksum only finds content the user can read", func(t *testing.T) {
+		uploaded := upload(t, "uploader", "report.txt", []byte("quarterly"))
+		input := file.UploadByChecksumInput{
+			UserID: "member", ChatRoomID: "room-1", Filename: "report.txt", Size: 9, Checksum: uploaded.Checksum,
+		}
+
+		reused, err := uc.UploadByChecksum(ctx, input)
+		require.NoError(t, err)
+		assert.Equal(t, "member", reused.OwnerID)
+		assert.Equal(t, attachment.StatusReady, reused.Status)
+		assert.Equal(t, "quarterly", read(t, reused.ID))
+
+		// Another type would skip the content check made for the original upload
+		input.Filename = "report.pdf"
+		_, err = uc.UploadByChecksum(ctx, input)
+		assert.Equal(t, attachment.ErrBlobNotFound, err)
+
+		_, err = uc.UploadByChecksum(ctx, file.UploadByChecksumInput{
+			UserID: "outsider", ChatRoomID: "room-2", Filename: "report.txt", Size: 9, Checksum: uploaded.Checksum,
+		})
+		assert.Equal(t, attachment.ErrBlobNotFound, err)
+	})
+
+	t.Run("Upload by checksum responds 404 when the content must be uploaded", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		uploaded := upload(t, "uploader", "list.txt", []byte("shopping"))
+		handler := handlers.NewFileHandler(uc, settings, *logger.New("error", "json"))
+		router := gin.New()
+		router.POST("/files/by-checksum", func(c *gin.Context) {
+			c.Set("user_id", "member")
+		}, handler.UploadByChecksum)
+
+		send := func(checksum string) *httptest.ResponseRecorder {
+			body := fmt.Sprintf(`{"room_id":"room-1","filename":"list.txt","size":8,"sha256":%q}`, checksum)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("POST", "/files/by-checksum", strings.NewReader(body)))
+			return w
+		}
+
+		w := send(uploaded.Checksum)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Contains(t, w.Body.String(), `"checksum":"`+uploaded.Checksum+`"`)
+
+		w = send(strings.Repeat("0", 64))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestFileDownloads(t *testing.T) {
+	ctx := context.Background()
+	gin.SetMode(gin.TestMode)
+	server := newFakeS3(t)
+	s3, err := storage.NewS3Blob(storage.S3Config{
+		Endpoint:        server.URL,
+		Region:          "us-east-1",
+		Bucket:          "uploads",
+		AccessKeyID:     "test-key",
+		SecretAccessKey: "test-secret",
+		PathStyle:       true,
+	}, server.Client())
+	require.NoError(t, err)
+
+	// newRouter serves downloads of one file from the given storage
+	newRouter := func(t *testing.T, blob storage.Blob, proxy bool) (*gin.Engine, *file.FileOutput) {
+		repo := &memoryAttachmentRepo{attachments: map[string]*attachment.Attachment{}}
+		sessions := &memorySessionRepo{sessions: map[string]*attachment.UploadSession{}}
+		settings := file.UploadSettings{MaxSize: 100, URLExpiry: 15 * time.Minute, ProxyDownloads: proxy}
+		uc := file.NewUseCase(repo, sessions, testRoomMembers(), blob, nil, validation.New(), *logger.New("error", "json"), settings)
+
+		uploaded, err := uc.UploadFile(ctx, file.UploadFileInput{
+			UserID: "uploader", ChatRoomID: "room-1", Filename: "clip.txt", Size: 10, Content: strings.NewReader("0123456789"),
+		})
+		require.NoError(t, err)
+
+		handler := handlers.NewFileHandler(uc, settings, *logger.New("error", "json"))
+		router := gin.New()
+		setUser := func(c *gin.Context) { c.Set("user_id", "member") }
+		router.GET("/files/:id/download", setUser, handler.DownloadFile)
+		router.HEAD("/files/:id/download", setUser, handler.DownloadFile)
+		return router, uploaded
+	}
+	get := func(router *gin.Engine, method, id string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/files/"+id+"/download", nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for name, blob := range map[string]storage.Blob{"memory": storage.NewMemoryBlob(), "proxied s3": s3} {
+		t.Run(name+" serves ranges with cache validators", func(t *testing.T) {
+			router, uploaded := newRouter(t, blob, true)
+			etag := `"` + uploaded.Checksum + `"`
+
+			w := get(router, "GET", uploaded.ID, nil)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "0123456789", w.Body.String())
+			assert.Equal(t, "bytes", w.Header().Get("Accept-Ranges"))
+			assert.Equal(t, etag, w.Header().Get("ETag"))
+			assert.Equal(t, file.ContentCacheControl, w.Header().Get("Cache-Control"))
+			assert.NotEmpty(t, w.Header().Get("Last-Modified"))
+
+			w = get(router, "GET", uploaded.ID, map[string]string{"Range": "bytes=4-6"})
+			assert.Equal(t, http.StatusPartialContent, w.Code)
+			assert.Equal(t, "456", w.Body.String())
+			assert.Equal(t, "bytes 4-6/10", w.Header().Get("Content-Range"))
+
+			w = get(router, "GET", uploaded.ID, map[string]string{"Range": "bytes=-3"})
+			assert.Equal(t, http.StatusPartialContent, w.Code)
+			assert.Equal(t, "789", w.Body.String())
+
+			w = get(router, "GET", uploaded.ID, map[string]string{"Range": "bytes=20-"})
+			assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, w.Code)
+
+			// A range is only sent if the client still has the same content
+			w = get(router, "GET", uploaded.ID, map[string]string{"Range": "bytes=4-6", "If-Range": etag})
+			assert.Equal(t, http.StatusPartialContent, w.Code)
+			w = get(router, "GET", uploaded.ID, map[string]string{"Range": "bytes=4-6", "If-Range": `"stale"`})
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "0123456789", w.Body.String())
+
+			w = get(router, "GET", uploaded.ID, map[string]string{"If-None-Match": `"other", ` + etag})
+			assert.Equal(t, http.StatusNotModified, w.Code)
+			assert.Empty(t, w.Body.String())
+			w = get(router, "GET", uploaded.ID, map[string]string{"If-Modified-Since": time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)})
+			assert.Equal(t, http.StatusNotModified, w.Code)
+
+			w = get(router, "HEAD", uploaded.ID, nil)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "10", w.Header().Get("Content-Length"))
+			assert.Empty(t, w.Body.String())
+		})
+	}
+
+	t.Run("Pre-signed downloads are cacheable and revalidated before redirecting", func(t *testing.T) {
+		router, uploaded := newRouter(t, s3, false)
+		etag := `"` + uploaded.Checksum + `"`
+
+		w := get(router, "GET", uploaded.ID, nil)
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+		assert.Contains(t, w.Header().Get("Location"), "response-cache-control=private")
+
+		w = get(router, "GET", uploaded.ID, map[string]string{"If-None-Match": etag})
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Empty(t, w.Header().Get("Location"))
+	})
+}
+
+// fakeScanner reports content containing "MALWARE" as infected, or fails
+// every scan while err is set
+type fakeScanner struct {
+	err error
+}
+
+func (s *fakeScanner) Scan(ctx context.Context, content io.Reader) (string, error) {
+	if s.err != nil {
+		return "", s.err
+	}
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return "", err
+	}
+	if strings.Contains(string(data), "MALWARE") {
+		return "Test-Signature", nil
+	}
+	return "", nil
+}
+
+func TestFileMalwareScanning(t *testing.T) {
+	ctx := context.Background()
+	scanner := &fakeScanner{}
+	settings := file.UploadSettings{MaxSize: 100, URLExpiry: 15 * time.Minute}
+	env := newFileTestUseCase(t, settings, scanner)
+	uc, repo := env.uc, env.repo
+	upload := func(t *testing.T, content string) *file.FileOutput {
+		return mustUploadTestFile(t, uc, "uploader", "notes.txt", content)
+	}
+
+	t.Run("Files are held back until they are scanned", func(t *testing.T) {
+		uploaded := upload(t, "meeting notes")
+		assert.Equal(t, attachment.StatusReady, uploaded.Status)
+		assert.Equal(t, attachment.ScanPending, uploaded.ScanStatus)
+
+		_, err := uc.OpenFile(ctx, file.GetFileInput{FileID: uploaded.ID, UserID: "uploader"})
+		assert.Equal(t, attachment.ErrScanPending, err)
+		_, err = uc.GetFile(ctx, file.GetFileInput{FileID: uploaded.ID, UserID: "member"})
+		assert.Equal(t, attachment.ErrAttachmentNotFound, err)
+		stored, _ := repo.GetByID(ctx, uploaded.ID)
+		assert.Equal(t, attachment.ErrScanPending, stored.CanAttachTo("uploader", "room-1"))
+
+		scanned, err := uc.ScanPendingFiles(ctx)
+		require.NoError(t, err)
+		require.Len(t, scanned, 1)
+		assert.Equal(t, uploaded.ID, scanned[0].ID)
+		assert.Equal(t, attachment.ScanClean, scanned[0].ScanStatus)
+
+		opened, err := uc.OpenFile(ctx, file.GetFileInput{FileID: uploaded.ID, UserID: "member"})
+		require.NoError(t, err)
+		content, _ := io.ReadAll(opened.Content)
+		opened.Content.Close()
+		assert.Equal(t, "meeting notes", string(content))
+
+		scanned, err = uc.ScanPendingFiles(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, scanned)
+	})
+
+	t.Run("Infected files are quarantined", func(t *testing.T) {
+		uploaded := upload(t, "MALWARE inside")
+		scanned, err := uc.ScanPendingFiles(ctx)
+		require.NoError(t, err)
+		require.Len(t, scanned, 1)
+		assert.Equal(t, attachment.ScanQuarantined, scanned[0].ScanStatus)
+		assert.Equal(t, "Test-Signature", scanned[0].Threat)
+
+		// The uploader sees why the file is unavailable; nobody else sees it at all
+		info, err := uc.GetFile(ctx, file.GetFileInput{FileID: uploaded.ID, UserID: "uploader"})
+		require.NoError(t, err)
+		assert.Equal(t, attachment.ScanQuarantined, info.ScanStatus)
+		_, err = uc.OpenFile(ctx, file.GetFileInput{FileID: uploaded.ID, UserID: "uploader"})
+		assert.Equal(t, attachment.ErrFileQuarantined, err)
+		_, err = uc.OpenFile(ctx, file.GetFileInput{FileID: uploaded.ID, UserID: "member"})
+		assert.Equal(t, attachment.ErrAttachmentNotFound, err)
+		err = uc.DeleteFile(ctx, file.DeleteFileInput{FileID: uploaded.ID, UserID: "admin"})
+		assert.Equal(t, attachment.ErrAttachmentNotFound, err)
+		stored, _ := repo.GetByID(ctx, uploaded.ID)
+		assert.Equal(t, attachment.ErrFileQuarantined, stored.CanAttachTo("uploader", "room-1"))
+
+		// Quarantined content cannot be shared by checksum either
+		_, err = uc.UploadByChecksum(ctx, file.UploadByChecksumInput{
+			UserID: "uploader", ChatRoomID: "room-1", Filename: "copy.txt", Size: uploaded.Size, Checksum: uploaded.Checksum,
+		})
+		assert.Equal(t, attachment.ErrBlobNotFound, err)
+
+		require.NoError(t, uc.DeleteFile(ctx, file.DeleteFileInput{FileID: uploaded.ID, UserID: "uploader"}))
+	})
+
+	t.Run("Files are scanned again later after the scanner fails", func(t *testing.T) {
+		uploaded := upload(t, "agenda")
+		scanner.err = errors.New("clamd is down")
+		scanned, err := uc.ScanPendingFiles(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, scanned)
+		stored, _ := repo.GetByID(ctx, uploaded.ID)
+		assert.Equal(t, attachment.ScanPending, stored.ScanStatus)
+		assert.Equal(t, 1, stored.ScanAttempts)
+		require.NotNil(t, stored.NextScanAt)
+		assert.WithinDuration(t, time.Now().Add(time.Minute), *stored.NextScanAt, 5*time.Second)
+
+		// Each further failure doubles the wait
+		repo.attachments[uploaded.ID].NextScanAt = nil
+		_, err = uc.ScanPendingFiles(ctx)
+		require.NoError(t, err)
+		stored, _ = repo.GetByID(ctx, uploaded.ID)
+		assert.Equal(t, 2, stored.ScanAttempts)
+		assert.WithinDuration(t, time.Now().Add(2*time.Minute), *stored.NextScanAt, 5*time.Second)
+
+		scanner.err = nil
+		scanned, err = uc.ScanPendingFiles(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, scanned)
+
+		due := time.Now().Add(-time.Second)
+		repo.attachments[uploaded.ID].NextScanAt = &due
+		scanned, err = uc.ScanPendingFiles(ctx)
+		require.NoError(t, err)
+		require.Len(t, scanned, 1)
+		assert.Equal(t, attachment.ScanClean, scanned[0].ScanStatus)
+	})
+
+	t.Run("Files that can never be scanned fail without holding up others", func(t *testing.T) {
+		for i := 0; i < 25; i++ {
+			upload(t, "too large")
+		}
+		scanner.err = fmt.Errorf("%w: INSTREAM size limit exceeded", scan.ErrScanFailed)
+
+		scanned, err := uc.ScanPendingFiles(ctx)
+		require.NoError(t, err)
+		require.Len(t, scanned, 20)
+		for _, f := range scanned {
+			assert.Equal(t, attachment.ScanFailed, f.ScanStatus)
+		}
+		failed := scanned[0].ID
+
+		// The rest are scanned on the next run
+		scanner.err = nil
+		later := upload(t, "scanned later")
+		scanned, err = uc.ScanPendingFiles(ctx)
+		require.NoError(t, err)
+		assert.Len(t, scanned, 6)
+		stored, _ := repo.GetByID(ctx, later.ID)
+		assert.Equal(t, attachment.ScanClean, stored.ScanStatus)
+
+		_, err = uc.OpenFile(ctx, file.GetFileInput{FileID: failed, UserID: "uploader"})
+		assert.Equal(t, attachment.ErrScanFailed, err)
+		stored, _ = repo.GetByID(ctx, failed)
+		assert.Equal(t, attachment.ErrScanFailed, stored.CanAttachTo("uploader", "room-1"))
+	})
+
+	t.Run("Files whose content is gone fail", func(t *testing.T) {
+		uploaded := upload(t, "vanished")
+		stored, _ := repo.GetByID(ctx, uploaded.ID)
+		require.NoError(t, env.store.Delete(ctx, stored.StorageKey))
+
+		scanned, err := uc.ScanPendingFiles(ctx)
+		require.NoError(t, err)
+		require.Len(t, scanned, 1)
+		assert.Equal(t, attachment.ScanFailed, scanned[0].ScanStatus)
+	})
+
+	t.Run("Shared content is scanned for each new file", func(t *testing.T) {
+		original := upload(t, "minutes")
+		_, err := uc.ScanPendingFiles(ctx)
+		require.NoError(t, err)
+
+		shared, err := uc.UploadByChecksum(ctx, file.UploadByChecksumInput{
+			UserID: "member", ChatRoomID: "room-1", Filename: "minutes.txt", Size: original.Size, Checksum: original.Checksum,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, attachment.ScanPending, shared.ScanStatus)
+	})
+
+	t.Run("Files are not held back when scanning is disabled", func(t *testing.T) {
+		uc := env.useCase(settings, nil)
+		uploaded := mustUploadTestFile(t, uc, "uploader", "notes.txt", "notes")
+		assert.Empty(t, uploaded.ScanStatus)
+
+		_, err := uc.OpenFile(ctx, file.GetFileInput{FileID: uploaded.ID, UserID: "member"})
+		assert.NoError(t, err)
+	})
+}
+
+func TestFileGarbageCollection(t *testing.T) {
+	ctx := context.Background()
+	settings := file.UploadSettings{
+		MaxSize:          1 << 20,
+		URLExpiry:        15 * time.Minute,
+		ResumableMaxSize: 1 << 20,
+		ChunkMaxSize:     1 << 20,
+		ResumableExpiry:  24 * time.Hour,
+		GarbageGrace:     time.Hour,
+	}
+	env := newFileTestUseCase(t, settings, nil)
+	patient, repo, store := env.uc, env.repo, env.store
+	// Without a grace period everything unreferenced is garbage at once
+	settings.GarbageGrace = 0
+	uc := env.useCase(settings, nil)
+	upload := func(t *testing.T, name, content string) *file.FileOutput {
+		return mustUploadTestFile(t, uc, "uploader", name, content)
+	}
+
+	sent := upload(t, "sent.txt", "sent with a message")
+	require.NoError(t, repo.AttachToMessage(ctx, "message-1", []string{sent.ID}))
+	draft := upload(t, "draft.txt", "never sent")
+	deleted := upload(t, "deleted.txt", "deleted by the uploader")
+	require.NoError(t, uc.DeleteFile(ctx, file.DeleteFileInput{FileID: deleted.ID, UserID: "uploader"}))
+	deletedKey := attachment.ContentKey(deleted.Checksum)
+	// Left behind by a chat room that was purged
+	stray := "0b8f4c1e-5d2a-4c6e-9f3b-7a1d2e4c6b8a"
+	strayThumbnail := attachment.VariantKey(stray, attachment.VariantThumbnail)
+	require.NoError(t, store.Put(ctx, stray, strings.NewReader("stray"), 5, "image/png"))
+	require.NoError(t, store.Put(ctx, strayThumbnail, strings.NewReader("th"), 2, "image/jpeg"))
+	// Stored by something else sharing the storage
+	require.NoError(t, store.Put(ctx, "backup.sql", strings.NewReader("backup"), 6, "application/sql"))
+
+	t.Run("Recent garbage is kept", func(t *testing.T) {
+		report, err := patient.CollectGarbage(ctx, file.CollectGarbageInput{})
+		require.NoError(t, err)
+		assert.Empty(t, report.Items)
+		assert.Equal(t, 6, report.Scanned)
+		assert.Contains(t, repo.attachments, draft.ID)
+	})
+
+	t.Run("A dry run reports garbage without removing it", func(t *testing.T) {
+		orphanedAt := time.Now().Add(-2 * time.Hour)
+		repo.blobs[deletedKey].OrphanedAt = &orphanedAt
+
+		report, err := uc.CollectGarbage(ctx, file.CollectGarbageInput{DryRun: true})
+		require.NoError(t, err)
+		assert.True(t, report.DryRun)
+		assert.Equal(t, []*file.GarbageItem{
+			{Kind: file.GarbageUnattachedFile, Key: draft.ID, Size: draft.Size},
+			{Kind: file.GarbageOrphanedBlob, Key: deletedKey, Size: deleted.Size},
+		}, report.Items[:2])
+		assert.ElementsMatch(t, []*file.GarbageItem{
+			{Kind: file.GarbageStrayObject, Key: stray, Size: 5},
+			{Kind: file.GarbageStrayObject, Key: strayThumbnail, Size: 2},
+		}, report.Items[2:])
+		assert.Equal(t, draft.Size+deleted.Size+7, report.Bytes)
+
+		assert.Contains(t, repo.attachments, draft.ID)
+		assert.Contains(t, repo.blobs, deletedKey)
+		_, err = store.Get(ctx, stray)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Garbage is removed", func(t *testing.T) {
+		metric := func(name string) int64 {
+			if v, ok := expvar.Get("garbage_collection").(*expvar.Map).Get(name).(*expvar.Int); ok {
+				return v.Value()
+			}
+			return 0
+		}
+		runs, strayObjects, freed := metric("runs"), metric("stray_objects"), metric("bytes")
+
+		report, err := uc.CollectGarbage(ctx, file.CollectGarbageInput{})
+		require.NoError(t, err)
+		assert.False(t, report.DryRun)
+		assert.Equal(t, map[string]int{
+			file.GarbageUnattachedFile: 1,
+			file.GarbageOrphanedBlob:   1,
+			file.GarbageStrayObject:    2,
+		}, report.Counts)
+		assert.Equal(t, runs+1, metric("runs"))
+		assert.Equal(t, strayObjects+2, metric("stray_objects"))
+		assert.Equal(t, freed+report.Bytes, metric("bytes"))
+
+		assert.NotContains(t, repo.attachments, draft.ID)
+		assert.NotContains(t, repo.blobs, deletedKey)
+		for _, key := range []string{deletedKey, stray, strayThumbnail} {
+			_, err = store.Get(ctx, key)
+			assert.Equal(t, storage.ErrNotFound, err, key)
+		}
+		_, err = store.Get(ctx, "backup.sql")
+		assert.NoError(t, err)
+
+		opened, err := uc.OpenFile(ctx, file.GetFileInput{FileID: sent.ID, UserID: "member"})
+		require.NoError(t, err)
+		opened.Content.Close()
+
+		// The content of the unsent file is only orphaned now, so it is kept for a while
+		_, err = store.Get(ctx, attachment.ContentKey(draft.Checksum))
+		assert.NoError(t, err)
+		report, err = uc.CollectGarbage(ctx, file.CollectGarbageInput{})
+		require.NoError(t, err)
+		assert.Empty(t, report.Items)
+	})
+
+	t.Run("Files of deleted messages are removed", func(t *testing.T) {
+		// Deleting a message detaches its files
+		repo.attachments[sent.ID].MessageID = ""
+
+		report, err := uc.CollectGarbage(ctx, file.CollectGarbageInput{})
+		require.NoError(t, err)
+		assert.Equal(t, []*file.GarbageItem{{Kind: file.GarbageUnattachedFile, Key: sent.ID, Size: sent.Size}}, report.Items)
+		assert.NotContains(t, repo.attachments, sent.ID)
+	})
+
+	t.Run("Grace is counted from when an upload completes", func(t *testing.T) {
+		upload, err := patient.CreateResumableUpload(ctx, file.CreateUploadInput{
+			UserID: "uploader", ChatRoomID: "room-1", Filename: "slow.txt", Size: 4,
+		})
+		require.NoError(t, err)
+		id := upload.File.ID
+		// The upload started long before the grace period
+		started := time.Now().Add(-2 * time.Hour)
+		repo.attachments[id].CreatedAt, repo.attachments[id].ReadyAt = started, started
+		_, err = patient.WriteChunk(ctx, file.WriteChunkInput{FileID: id, UserID: "uploader", Size: 4, Content: strings.NewReader("slow")})
+		require.NoError(t, err)
+		_, err = patient.CompleteResumableUpload(ctx, file.ResumableUploadInput{FileID: id, UserID: "uploader"})
+		require.NoError(t, err)
+
+		report, err := patient.CollectGarbage(ctx, file.CollectGarbageInput{})
+		require.NoError(t, err)
+		assert.Empty(t, report.Items)
+		assert.Contains(t, repo.attachments, id)
+	})
+}
\ No newline at end of file
@@ -0,0 +1,307 @@
+package unit
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	jwtlib "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"backend-go/internal/shared/jwt"
+	"backend-go/internal/shared/logger"
+)
+
+func TestTokenRevocation(t *testing.T) {
+	t.Run("Revoked token is rejected", func(t *testing.T) {
+		svc := jwt.NewService("test-secret", time.Hour, jwt.NewMemoryRevocationStore())
+
+		token, _, err := svc.GenerateToken("user-1", "user@example.com", "session-1")
+		require.NoError(t, err)
+
+		claims, err := svc.ValidateToken(token)
+		require.NoError(t, err)
+		assert.NotEmpty(t, claims["jti"])
+
+		require.NoError(t, svc.BlacklistToken(token))
+
+		_, err = svc.ValidateToken(token)
+		assert.Error(t, err)
+	})
+
+	t.Run("Revocation is shared between services using the same store", func(t *testing.T) {
+		store := jwt.NewMemoryRevocationStore()
+		issuer := jwt.NewService("test-secret", time.Hour, store)
+		validator := jwt.NewService("test-secret", time.Hour, store)
+
+		token, _, err := issuer.GenerateToken("user-1", "user@example.com", "session-1")
+		require.NoError(t, err)
+		require.NoError(t, issuer.BlacklistToken(token))
+
+		_, err = validator.ValidateToken(token)
+		assert.Error(t, err)
+	})
+
+	t.Run("Revoking one token leaves others valid", func(t *testing.T) {
+		svc := jwt.NewService("test-secret", time.Hour, jwt.NewMemoryRevocationStore())
+
+		first, _, err := svc.GenerateToken("user-1", "user@example.com", "session-1")
+		require.NoError(t, err)
+		second, _, err := svc.GenerateToken("user-1", "user@example.com", "session-1")
+		require.NoError(t, err)
+
+		require.NoError(t, svc.BlacklistToken(first))
+
+		_, err = svc.ValidateToken(second)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Memory store forgets entries once the token expires", func(t *testing.T) {
+		store := jwt.NewMemoryRevocationStore()
+		ctx := context.Background()
+
+		require.NoError(t, store.Revoke(ctx, "expired", time.Now().Add(-time.Minute)))
+		require.NoError(t, store.Revoke(ctx, "active", time.Now().Add(time.Hour)))
+
+		revoked, err := store.IsRevoked(ctx, "expired")
+		require.NoError(t, err)
+		assert.False(t, revoked)
+
+		revoked, err = store.IsRevoked(ctx, "active")
+		require.NoError(t, err)
+		assert.True(t, revoked)
+	})
+
+	t.Run("Revoking a session rejects all of its tokens", func(t *testing.T) {
+		svc := jwt.NewService("test-secret", time.Hour, jwt.NewMemoryRevocationStore())
+
+		first, _, err := svc.GenerateToken("user-1", "user@example.com", "session-1")
+		require.NoError(t, err)
+		second, _, err := svc.GenerateToken("user-1", "user@example.com", "session-1")
+		require.NoError(t, err)
+		other, _, err := svc.GenerateToken("user-1", "user@example.com", "session-2")
+		require.NoError(t, err)
+
+		require.NoError(t, svc.RevokeSession("session-1"))
+
+		_, err = svc.ValidateToken(first)
+		assert.Error(t, err)
+		_, err = svc.ValidateToken(second)
+		assert.Error(t, err)
+
+		claims, err := svc.ValidateToken(other)
+		require.NoError(t, err)
+		assert.Equal(t, "session-2", claims["sid"])
+	})
+
+	t.Run("Store outages are told apart from revocations", func(t *testing.T) {
+		store := &failingRevocationStore{RevocationStore: jwt.NewMemoryRevocationStore()}
+		svc := jwt.NewService("test-secret", time.Hour, store)
+
+		token, _, err := svc.GenerateToken("user-1", "user@example.com", "session-1")
+		require.NoError(t, err)
+
+		store.err = errors.New("connection refused")
+		_, err = svc.ValidateToken(token)
+		assert.ErrorIs(t, err, jwt.ErrRevocationUnavailable)
+
+		store.err = nil
+		require.NoError(t, svc.BlacklistToken(token))
+		_, err = svc.ValidateToken(token)
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, jwt.ErrRevocationUnavailable)
+	})
+}
+
+// failingRevocationStore fails revocation lookups while err is set
+type failingRevocationStore struct {
+	jwt.RevocationStore
+	err error
+}
+
+func (s *failingRevocationStore) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	if s.err != nil {
+		return false, s.err
+	}
+	return s.RevocationStore.IsRevoked(ctx, tokenID)
+}
+
+func TestChallengeToken(t *testing.T) {
+	t.Run("Challenge token is not accepted as an access token", func(t *testing.T) {
+		svc := jwt.NewService("test-secret", time.Hour, jwt.NewMemoryRevocationStore())
+
+		challenge, expiresAt, err := svc.GenerateChallengeToken("user-1")
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(jwt.ChallengeTokenDuration), expiresAt, time.Second)
+
+		_, err = svc.ValidateToken(challenge)
+		assert.Error(t, err)
+
+		userID, err := svc.ValidateChallengeToken(challenge)
+		require.NoError(t, err)
+		assert.Equal(t, "user-1", userID)
+	})
+
+	t.Run("Access token is not accepted as a challenge", func(t *testing.T) {
+		svc := jwt.NewService("test-secret", time.Hour, jwt.NewMemoryRevocationStore())
+
+		token, _, err := svc.GenerateToken("user-1", "user@example.com", "session-1")
+		require.NoError(t, err)
+
+		_, err = svc.ValidateChallengeToken(token)
+		assert.Error(t, err)
+	})
+
+	t.Run("Blacklisted challenge cannot be reused", func(t *testing.T) {
+		svc := jwt.NewService("test-secret", time.Hour, jwt.NewMemoryRevocationStore())
+
+		challenge, _, err := svc.GenerateChallengeToken("user-1")
+		require.NoError(t, err)
+
+		require.NoError(t, svc.BlacklistToken(challenge))
+
+		_, err = svc.ValidateChallengeToken(challenge)
+		assert.Error(t, err)
+	})
+}
+
+func TestKeyRing(t *testing.T) {
+	newKeyRing := func(t *testing.T, store jwt.KeyStore, algorithm string, retention time.Duration) *jwt.KeyRing {
+		ring, err := jwt.NewKeyRing(store, jwt.KeyRingConfig{
+			Algorithm:        algorithm,
+			RotationInterval: 24 * time.Hour,
+			Prepublish:       0,
+			Retention:        retention,
+		}, *logger.New("error", "json"))
+		require.NoError(t, err)
+		return ring
+	}
+
+	for _, algorithm := range []string{jwt.AlgorithmRS256, jwt.AlgorithmEdDSA} {
+		t.Run(algorithm+" tokens carry the key ID and validate", func(t *testing.T) {
+			ring := newKeyRing(t, jwt.NewMemoryKeyStore(), algorithm, time.Hour)
+			svc := jwt.NewKeyRingService(ring, "", time.Hour, jwt.NewMemoryRevocationStore())
+
+			token, _, err := svc.GenerateToken("user-1", "user@example.com", "session-1")
+			require.NoError(t, err)
+
+			parsed, _, err := jwtlib.NewParser().ParseUnverified(token, jwtlib.MapClaims{})
+			require.NoError(t, err)
+			assert.Equal(t, algorithm, parsed.Header["alg"])
+
+			set := ring.JWKS()
+			require.Len(t, set.Keys, 1)
+			assert.Equal(t, set.Keys[0].KeyID, parsed.Header["kid"])
+
+			claims, err := svc.ValidateToken(token)
+			require.NoError(t, err)
+			assert.Equal(t, "user-1", claims["user_id"])
+		})
+	}
+
+	t.Run("Tokens verify with the published RSA key alone", func(t *testing.T) {
+		ring := newKeyRing(t, jwt.NewMemoryKeyStore(), jwt.AlgorithmRS256, time.Hour)
+		svc := jwt.NewKeyRingService(ring, "", time.Hour, jwt.NewMemoryRevocationStore())
+
+		token, _, err := svc.GenerateToken("user-1", "user@example.com", "session-1")
+		require.NoError(t, err)
+
+		jwk := ring.JWKS().Keys[0]
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		require.NoError(t, err)
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		require.NoError(t, err)
+		publicKey := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+
+		_, err = jwtlib.Parse(token, func(*jwtlib.Token) (interface{}, error) {
+			return publicKey, nil
+		}, jwtlib.WithValidMethods([]string{"RS256"}))
+		assert.NoError(t, err)
+	})
+
+	t.Run("Tokens signed before a rotation stay valid", func(t *testing.T) {
+		ring := newKeyRing(t, jwt.NewMemoryKeyStore(), jwt.AlgorithmEdDSA, time.Hour)
+		svc := jwt.NewKeyRingService(ring, "", time.Hour, jwt.NewMemoryRevocationStore())
+
+		before, _, err := svc.GenerateToken("user-1", "user@example.com", "session-1")
+		require.NoError(t, err)
+
+		rotated, err := ring.Rotate(context.Background())
+		require.NoError(t, err)
+		assert.Len(t, ring.JWKS().Keys, 2)
+
+		after, _, err := svc.GenerateToken("user-1", "user@example.com", "session-1")
+		require.NoError(t, err)
+
+		parsed, _, err := jwtlib.NewParser().ParseUnverified(after, jwtlib.MapClaims{})
+		require.NoError(t, err)
+		assert.Equal(t, rotated.ID, parsed.Header["kid"])
+
+		_, err = svc.ValidateToken(before)
+		assert.NoError(t, err)
+		_, err = svc.ValidateToken(after)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Replaced keys are retired after the retention period", func(t *testing.T) {
+		ring := newKeyRing(t, jwt.NewMemoryKeyStore(), jwt.AlgorithmEdDSA, 10*time.Millisecond)
+		svc := jwt.NewKeyRingService(ring, "", time.Hour, jwt.NewMemoryRevocationStore())
+
+		old, _, err := svc.GenerateToken("user-1", "user@example.com", "session-1")
+		require.NoError(t, err)
+
+		_, err = ring.Rotate(context.Background())
+		require.NoError(t, err)
+
+		time.Sleep(20 * time.Millisecond)
+		require.NoError(t, ring.Maintain(context.Background()))
+
+		assert.Len(t, ring.JWKS().Keys, 1)
+		_, err = svc.ValidateToken(old)
+		assert.Error(t, err)
+	})
+
+	t.Run("Replicas sharing a key store verify each other's tokens", func(t *testing.T) {
+		store := jwt.NewMemoryKeyStore()
+		revocations := jwt.NewMemoryRevocationStore()
+
+		first := jwt.NewKeyRingService(newKeyRing(t, store, jwt.AlgorithmRS256, time.Hour), "", time.Hour, revocations)
+		secondRing := newKeyRing(t, store, jwt.AlgorithmRS256, time.Hour)
+		second := jwt.NewKeyRingService(secondRing, "", time.Hour, revocations)
+
+		token, _, err := first.GenerateToken("user-1", "user@example.com", "session-1")
+		require.NoError(t, err)
+
+		_, err = second.ValidateToken(token)
+		assert.NoError(t, err)
+		assert.Len(t, secondRing.JWKS().Keys, 1)
+	})
+
+	t.Run("Legacy HS256 tokens are only accepted with the legacy secret", func(t *testing.T) {
+		revocations := jwt.NewMemoryRevocationStore()
+		legacy := jwt.NewService("old-secret", time.Hour, revocations)
+
+		token, _, err := legacy.GenerateToken("user-1", "user@example.com", "session-1")
+		require.NoError(t, err)
+
+		ring := newKeyRing(t, jwt.NewMemoryKeyStore(), jwt.AlgorithmRS256, time.Hour)
+
+		_, err = jwt.NewKeyRingService(ring, "old-secret", time.Hour, revocations).ValidateToken(token)
+		assert.NoError(t, err)
+
+		_, err = jwt.NewKeyRingService(ring, "", time.Hour, revocations).ValidateToken(token)
+		assert.Error(t, err)
+	})
+
+	t.Run("Unsupported algorithms are rejected", func(t *testing.T) {
+		_, err := jwt.NewKeyRing(jwt.NewMemoryKeyStore(), jwt.KeyRingConfig{
+			Algorithm:        "none",
+			RotationInterval: time.Hour,
+		}, *logger.New("error", "json"))
+		assert.Error(t, err)
+	})
+}
\ No newline at end of file
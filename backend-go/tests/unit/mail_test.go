@@ -0,0 +1,64 @@
+package unit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"backend-go/internal/shared/logger"
+	"backend-go/internal/shared/mail"
+)
+
+func TestLogSender(t *testing.T) {
+	t.Run("Writes each message to the output directory", func(t *testing.T) {
+		dir := t.TempDir()
+		sender := mail.NewLogSender(*logger.New("error", "json"), dir)
+
+		err := sender.Send(context.Background(), mail.Message{
+			To:      "user@example.com",
+			Subject: "Reset your password",
+			Body:    "https://app.example.com/reset-password?token=abc",
+		})
+		require.NoError(t, err)
+
+		files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+
+		content, err := os.ReadFile(files[0])
+		require.NoError(t, err)
+		assert.Contains(t, string(content), "To: user@example.com")
+		assert.Contains(t, string(content), "Subject: Reset your password")
+		assert.Contains(t, string(content), "token=abc")
+	})
+
+	t.Run("Strips line breaks from headers", func(t *testing.T) {
+		dir := t.TempDir()
+		sender := mail.NewLogSender(*logger.New("error", "json"), dir)
+
+		err := sender.Send(context.Background(), mail.Message{
+			To:      "user@example.com",
+			Subject: "Hello\r\nBcc: attacker@example.com",
+			Body:    "body",
+		})
+		require.NoError(t, err)
+
+		files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+
+		content, err := os.ReadFile(files[0])
+		require.NoError(t, err)
+		assert.NotContains(t, string(content), "\nBcc:")
+	})
+
+	t.Run("Only logs when no directory is set", func(t *testing.T) {
+		sender := mail.NewLogSender(*logger.New("error", "json"), "")
+
+		err := sender.Send(context.Background(), mail.Message{To: "user@example.com", Subject: "Hi", Body: "body"})
+		assert.NoError(t, err)
+	})
+}
\ No newline at end of file
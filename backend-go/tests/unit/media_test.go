@@ -0,0 +1,187 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"backend-go/internal/domain/attachment"
+	"backend-go/internal/shared/media"
+)
+
+// testJPEG encodes a w x h JPEG carrying an EXIF orientation, an EXIF image
+// description and a comment; the last two hold secret
+func testJPEG(t *testing.T, w, h, orientation int, secret string) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h)), nil))
+	encoded := buf.Bytes()
+
+	// Little-endian TIFF with an image description and the orientation
+	description := append([]byte(secret), 0)
+	tiff := []byte{'I', 'I', 42, 0, 8, 0, 0, 0, 2, 0}
+	tiff = binary.LittleEndian.AppendUint16(tiff, 0x010E)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 2)
+	tiff = binary.LittleEndian.AppendUint32(tiff, uint32(len(description)))
+	tiff = binary.LittleEndian.AppendUint32(tiff, 38)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 3)
+	tiff = binary.LittleEndian.AppendUint32(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint32(tiff, uint32(orientation))
+	tiff = append(tiff, 0, 0, 0, 0)
+	tiff = append(tiff, description...)
+
+	segment := func(marker byte, payload []byte) []byte {
+		out := []byte{0xFF, marker}
+		out = binary.BigEndian.AppendUint16(out, uint16(len(payload)+2))
+		return append(out, payload...)
+	}
+
+	out := append([]byte(nil), encoded[:2]...)
+	out = append(out, segment(0xE1, append([]byte("Exif\x00\x00"), tiff...))...)
+	out = append(out, segment(0xFE, []byte(secret))...)
+	return append(out, encoded[2:]...)
+}
+
+// testPNG encodes a w x h PNG with a text chunk holding secret
+func testPNG(t *testing.T, w, h int, transparent bool, secret string) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			alpha := uint8(255)
+			if transparent && x < w/2 {
+				alpha = 0
+			}
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 100, A: alpha})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	encoded := buf.Bytes()
+
+	// Insert a tEXt chunk after IHDR; its CRC is not checked when stripping
+	text := append([]byte("Comment\x00"), secret...)
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(text)))
+	chunk = append(append(append(chunk, "tEXt"...), text...), 0, 0, 0, 0)
+	ihdrEnd := 8 + 12 + 13
+	return append(append(append([]byte(nil), encoded[:ihdrEnd]...), chunk...), encoded[ihdrEnd:]...)
+}
+
+func TestDetectContentType(t *testing.T) {
+	tar := make([]byte, 512)
+	copy(tar[257:], "ustar")
+
+	tests := []struct {
+		name     string
+		head     []byte
+		expected string
+	}{
+		{"JPEG", []byte("\xFF\xD8\xFF\xE0\x00\x10JFIF"), "image/jpeg"},
+		{"PNG", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR"), "image/png"},
+		{"PDF", []byte("%PDF-1.7\n"), "application/pdf"},
+		{"ZIP", []byte("PK\x03\x04\x14\x00"), "application/zip"},
+		{"Legacy Office", []byte("\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1\x00"), media.OLEStorage},
+		{"MP4", []byte("\x00\x00\x00\x18ftypisom\x00\x00\x02\x00"), "video/mp4"},
+		{"QuickTime", []byte("\x00\x00\x00\x14ftypqt  \x00\x00\x02\x00"), "video/quicktime"},
+		{"M4A", []byte("\x00\x00\x00\x1cftypM4A \x00\x00\x00\x00"), "audio/mp4"},
+		{"MP3 with ID3", []byte("ID3\x04\x00\x00"), "audio/mpeg"},
+		{"MP3 frame", []byte("\xFF\xFB\x90\x64"), "audio/mpeg"},
+		{"Tar", tar, "application/x-tar"},
+		{"Text", []byte("hello world"), "text/plain"},
+		{"HTML", []byte("<!DOCTYPE html><html><script>"), "text/html"},
+		{"SVG", []byte("<svg xmlns=\"http://www.w3.org/2000/svg\">"), "image/svg+xml"},
+		{"SVG with prolog", []byte("\xEF\xBB\xBF<?xml version=\"1.0\"?>\n<!-- logo -->\n<!DOCTYPE svg>\n<svg>"), "image/svg+xml"},
+		{"HTML with inline SVG", []byte("<html><body><svg></svg>"), "text/html"},
+		{"Unknown binary", []byte("\x00\x01\x02\x03"), media.OctetStream},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, media.DetectContentType(tt.head))
+		})
+	}
+}
+
+func TestMatchesContent(t *testing.T) {
+	assert.True(t, attachment.MatchesContent("image/png", "image/png"))
+	assert.True(t, attachment.MatchesContent("application/vnd.openxmlformats-officedocument.wordprocessingml.document", "application/zip"))
+	assert.True(t, attachment.MatchesContent("application/vnd.ms-excel", media.OLEStorage))
+	assert.True(t, attachment.MatchesContent("video/quicktime", "video/mp4"))
+
+	assert.False(t, attachment.MatchesContent("image/png", "text/html"))
+	assert.False(t, attachment.MatchesContent("text/plain", "image/svg+xml"))
+	assert.False(t, attachment.MatchesContent("application/zip", "application/vnd.openxmlformats-officedocument.wordprocessingml.document"))
+}
+
+func TestProcessImage(t *testing.T) {
+	t.Run("JPEG metadata is removed except the orientation", func(t *testing.T) {
+		data := testJPEG(t, 40, 20, 6, "GPS 51.5N secret")
+
+		result, err := media.ProcessImage(data, "image/jpeg")
+		require.NoError(t, err)
+		assert.NotContains(t, string(result.Data), "secret")
+		assert.Less(t, len(result.Data), len(data))
+
+		// Turned a quarter, the image is displayed in portrait
+		assert.Equal(t, 20, result.Width)
+		assert.Equal(t, 40, result.Height)
+		assert.Equal(t, "image/jpeg", result.RenderType)
+
+		preview, err := jpeg.Decode(bytes.NewReader(result.Preview))
+		require.NoError(t, err)
+		assert.Equal(t, image.Rect(0, 0, 20, 40), preview.Bounds())
+
+		// Processing again still finds the orientation
+		again, err := media.ProcessImage(result.Data, "image/jpeg")
+		require.NoError(t, err)
+		assert.Equal(t, result.Data, again.Data)
+		assert.Equal(t, 20, again.Width)
+	})
+
+	t.Run("PNG text is removed and transparency kept", func(t *testing.T) {
+		result, err := media.ProcessImage(testPNG(t, 1000, 500, true, "secret"), "image/png")
+		require.NoError(t, err)
+		assert.NotContains(t, string(result.Data), "secret")
+		assert.Equal(t, 1000, result.Width)
+		assert.Equal(t, 500, result.Height)
+		assert.Equal(t, "image/png", result.RenderType)
+
+		_, err = png.Decode(bytes.NewReader(result.Data))
+		require.NoError(t, err)
+		thumbnail, err := png.Decode(bytes.NewReader(result.Thumbnail))
+		require.NoError(t, err)
+		assert.Equal(t, image.Rect(0, 0, media.ThumbnailSize, media.ThumbnailSize/2), thumbnail.Bounds())
+		assert.False(t, thumbnail.(interface{ Opaque() bool }).Opaque())
+	})
+
+	t.Run("WebP metadata is removed without rendering", func(t *testing.T) {
+		vp8l := []byte{0x2F, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+		binary.LittleEndian.PutUint32(vp8l[1:], uint32(640-1)|uint32(480-1)<<14)
+		data := []byte("RIFF\x00\x00\x00\x00WEBP")
+		data = binary.LittleEndian.AppendUint32(append(data, "VP8L"...), uint32(len(vp8l)))
+		data = append(data, vp8l...)
+		data = binary.LittleEndian.AppendUint32(append(data, "EXIF"...), 6)
+		data = append(data, "secret"...)
+		binary.LittleEndian.PutUint32(data[4:], uint32(len(data)-8))
+
+		result, err := media.ProcessImage(data, "image/webp")
+		require.NoError(t, err)
+		assert.NotContains(t, string(result.Data), "secret")
+		assert.Equal(t, uint32(len(result.Data)-8), binary.LittleEndian.Uint32(result.Data[4:]))
+		assert.Equal(t, 640, result.Width)
+		assert.Equal(t, 480, result.Height)
+		assert.Nil(t, result.Thumbnail)
+	})
+
+	t.Run("Content that is not an image is rejected", func(t *testing.T) {
+		_, err := media.ProcessImage([]byte("<html></html>"), "image/jpeg")
+		assert.Equal(t, media.ErrInvalidImage, err)
+		_, err = media.ProcessImage([]byte("\xFF\xD8\xFF\xE0\x00"), "image/jpeg")
+		assert.Equal(t, media.ErrInvalidImage, err)
+	})
+}
\ No newline at end of file
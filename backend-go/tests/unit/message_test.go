@@ -0,0 +1,338 @@
+package unit
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"backend-go/internal/application/message"
+	"backend-go/internal/domain/attachment"
+	"backend-go/internal/domain/chat"
+	domainmessage "backend-go/internal/domain/message"
+	"backend-go/internal/shared/logger"
+	"backend-go/internal/shared/validation"
+)
+
+// memoryMessageRepo keeps messages in insertion order; other message
+// repository methods are not used by these tests
+type memoryMessageRepo struct {
+	domainmessage.Repository
+	messages []*domainmessage.Message
+}
+
+func (r *memoryMessageRepo) Create(ctx context.Context, msg *domainmessage.Message) error {
+	r.messages = append(r.messages, msg)
+	return nil
+}
+
+func (r *memoryMessageRepo) GetByID(ctx context.Context, id string) (*domainmessage.Message, error) {
+	for _, msg := range r.messages {
+		if msg.ID == id {
+			return msg, nil
+		}
+	}
+	return nil, domainmessage.ErrMessageNotFound
+}
+
+func (r *memoryMessageRepo) GetByChatRoom(ctx context.Context, chatRoomID string, limit, offset int) ([]*domainmessage.Message, int, error) {
+	var messages []*domainmessage.Message
+	for _, msg := range r.messages {
+		if msg.ChatRoomID == chatRoomID {
+			messages = append(messages, msg)
+		}
+	}
+	return messages, len(messages), nil
+}
+
+func (r *memoryMessageRepo) Delete(ctx context.Context, id string) error {
+	for i, msg := range r.messages {
+		if msg.ID == id {
+			r.messages = append(r.messages[:i], r.messages[i+1:]...)
+			return nil
+		}
+	}
+	return domainmessage.ErrMessageNotFound
+}
+
+// messageRooms extends memberRoles with the room lookups made when sending
+type messageRooms struct {
+	*memberRoles
+	settings        []*chat.MemberSettings
+	slowModeSeconds int
+	lastSentAt      map[string]time.Time
+}
+
+func (r *messageRooms) GetByID(ctx context.Context, id string) (*chat.ChatRoom, error) {
+	if _, ok := r.roles[id]; !ok {
+		return nil, chat.ErrChatRoomNotFound
+	}
+	return &chat.ChatRoom{ID: id, SlowModeSeconds: r.slowModeSeconds}, nil
+}
+
+func (r *messageRooms) ClaimSendSlot(ctx context.Context, roomID, userID string, interval time.Duration) (bool, time.Time, error) {
+	if r.lastSentAt == nil {
+		r.lastSentAt = map[string]time.Time{}
+	}
+	last, ok := r.lastSentAt[roomID+"/"+userID]
+	if ok && time.Since(last) < interval {
+		return false, last, nil
+	}
+	r.lastSentAt[roomID+"/"+userID] = time.Now()
+	return true, time.Time{}, nil
+}
+
+// ListNotificationRecipients filters settings as the query does
+func (r *messageRooms) ListNotificationRecipients(ctx context.Context, roomID, senderID string, mentions []string) ([]*chat.MemberSettings, error) {
+	var recipients []*chat.MemberSettings
+	for _, settings := range r.settings {
+		if settings.ChatRoomID != roomID || settings.UserID == senderID || settings.IsMuted() {
+			continue
+		}
+		mentioned := false
+		for _, username := range mentions {
+			mentioned = mentioned || username == strings.ToLower(settings.Username)
+		}
+		if settings.NotificationLevel == chat.NotifyAll || (settings.NotificationLevel == chat.NotifyMentions && mentioned) {
+			recipients = append(recipients, settings)
+		}
+	}
+	return recipients, nil
+}
+
+// racingAttachments loses every attempt to attach files, as if another
+// message claimed them after they were checked
+type racingAttachments struct {
+	*memoryAttachmentRepo
+}
+
+func (r *racingAttachments) AttachToMessage(ctx context.Context, messageID string, ids []string) error {
+	return attachment.ErrAlreadyAttached
+}
+
+func testMessageAttachments() *memoryAttachmentRepo {
+	photo := attachment.NewAttachment("photo", "uploader", "room-1", "cat.jpg", 2048, "image/jpeg", "sum")
+	photo.Width, photo.Height, photo.RenderType = 640, 480, "image/jpeg"
+	report := attachment.NewAttachment("report", "uploader", "room-1", "report.pdf", 4096, "application/pdf", "sum")
+	elsewhere := attachment.NewAttachment("elsewhere", "uploader", "room-2", "notes.txt", 10, "text/plain", "sum")
+	theirs := attachment.NewAttachment("theirs", "member", "room-1", "theirs.txt", 10, "text/plain", "sum")
+	pending := attachment.NewPendingAttachment("pending", "uploader", "room-1", "big.zip", 1<<20, "application/zip")
+
+	repo := &memoryAttachmentRepo{attachments: map[string]*attachment.Attachment{}}
+	for _, a := range []*attachment.Attachment{photo, report, elsewhere, theirs, pending} {
+		repo.attachments[a.ID] = a
+	}
+	return repo
+}
+
+func TestMessageAttachments(t *testing.T) {
+	ctx := context.Background()
+	rooms := &messageRooms{memberRoles: testRoomMembers()}
+	rooms.roles["room-2"] = map[string]string{"uploader": chat.RoleMember}
+
+	newUseCase := func(attachments attachment.Repository) (message.UseCase, *memoryMessageRepo) {
+		messages := &memoryMessageRepo{}
+		return message.NewUseCase(messages, rooms, attachments, validation.New(), *logger.New("error", "json")), messages
+	}
+
+	send := func(uc message.UseCase, content, messageType string, ids ...string) (*message.SendMessageOutput, error) {
+		return uc.SendMessage(ctx, message.SendMessageInput{
+			ChatRoomID:    "room-1",
+			SenderID:      "uploader",
+			Content:       content,
+			Type:          messageType,
+			AttachmentIDs: ids,
+		})
+	}
+
+	t.Run("text messages need content", func(t *testing.T) {
+		uc, _ := newUseCase(testMessageAttachments())
+
+		out, err := send(uc, "hello", "text")
+		require.NoError(t, err)
+		assert.Equal(t, "text", out.Type)
+		assert.Empty(t, out.Attachments)
+
+		_, err = send(uc, "", "text")
+		assert.Error(t, err)
+
+		_, err = send(uc, "look", "image")
+		assert.Error(t, err)
+	})
+
+	t.Run("images are sent with a caption and their details", func(t *testing.T) {
+		attachments := testMessageAttachments()
+		uc, _ := newUseCase(attachments)
+
+		out, err := send(uc, "my cat", "text", "photo")
+		require.NoError(t, err)
+		assert.Equal(t, "image", out.Type)
+		assert.Equal(t, "my cat", out.Content)
+		require.Len(t, out.Attachments, 1)
+		assert.Equal(t, &message.AttachmentOutput{
+			ID:           "photo",
+			OriginalName: "cat.jpg",
+			Size:         2048,
+			MimeType:     "image/jpeg",
+			Width:        640,
+			Height:       480,
+			HasThumbnail: true,
+		}, out.Attachments[0])
+		assert.Equal(t, out.ID, attachments.attachments["photo"].MessageID)
+	})
+
+	t.Run("any other file makes a file message", func(t *testing.T) {
+		uc, _ := newUseCase(testMessageAttachments())
+
+		out, err := send(uc, "", "text", "report", "photo")
+		require.NoError(t, err)
+		assert.Equal(t, "file", out.Type)
+		require.Len(t, out.Attachments, 2)
+		assert.Equal(t, "report", out.Attachments[0].ID)
+		assert.False(t, out.Attachments[0].HasThumbnail)
+	})
+
+	t.Run("attachments must be the sender's, ready and uploaded to the room", func(t *testing.T) {
+		uc, messages := newUseCase(testMessageAttachments())
+
+		_, err := send(uc, "", "file", "theirs")
+		assert.Equal(t, attachment.ErrAttachmentNotFound, err)
+
+		_, err = send(uc, "", "file", "pending")
+		assert.Equal(t, attachment.ErrAttachmentNotFound, err)
+
+		_, err = send(uc, "", "file", "missing")
+		assert.Equal(t, attachment.ErrAttachmentNotFound, err)
+
+		_, err = send(uc, "", "file", "elsewhere")
+		assert.Equal(t, attachment.ErrWrongChatRoom, err)
+
+		_, err = send(uc, "", "file", "report", "report")
+		assert.Error(t, err)
+
+		_, err = uc.SendMessage(ctx, message.SendMessageInput{
+			ChatRoomID: "room-1", SenderID: "uploader", Type: "file", AttachmentIDs: []string{},
+		})
+		assert.Error(t, err)
+
+		assert.Empty(t, messages.messages)
+	})
+
+	t.Run("attachments are only sent once", func(t *testing.T) {
+		uc, messages := newUseCase(testMessageAttachments())
+
+		_, err := send(uc, "", "file", "report")
+		require.NoError(t, err)
+
+		_, err = send(uc, "again", "file", "report")
+		assert.Equal(t, attachment.ErrAlreadyAttached, err)
+		assert.Len(t, messages.messages, 1)
+	})
+
+	t.Run("message losing a race for its attachments is removed", func(t *testing.T) {
+		uc, messages := newUseCase(&racingAttachments{testMessageAttachments()})
+
+		_, err := send(uc, "", "file", "report")
+		assert.Equal(t, attachment.ErrAlreadyAttached, err)
+		assert.Empty(t, messages.messages)
+	})
+
+	t.Run("messages are read back with their attachments", func(t *testing.T) {
+		uc, _ := newUseCase(testMessageAttachments())
+
+		sent, err := send(uc, "my cat", "text", "photo")
+		require.NoError(t, err)
+		_, err = send(uc, "no files", "text")
+		require.NoError(t, err)
+
+		one, err := uc.GetMessage(ctx, message.GetMessageInput{MessageID: sent.ID, UserID: "member"})
+		require.NoError(t, err)
+		assert.Equal(t, sent.Attachments, one.Attachments)
+
+		page, err := uc.GetMessages(ctx, message.GetMessagesInput{ChatRoomID: "room-1", UserID: "member", Page: 1, Limit: 50})
+		require.NoError(t, err)
+		require.Len(t, page.Messages, 2)
+		assert.Equal(t, sent.Attachments, page.Messages[0].Attachments)
+		assert.Empty(t, page.Messages[1].Attachments)
+	})
+}
+
+func TestMessageMentions(t *testing.T) {
+	assert.Equal(t, []string{"bob", "alice", "carol"}, domainmessage.Mentions("@Bob, ask (@alice) and @carol! @bob again"))
+	assert.Empty(t, domainmessage.Mentions("mail bob@example.com, not @ anyone"))
+	assert.Equal(t, []string{"bobby"}, domainmessage.Mentions("hi @bobby."))
+}
+
+func TestMessageNotifications(t *testing.T) {
+	ctx := context.Background()
+	rooms := &messageRooms{memberRoles: testRoomMembers()}
+	rooms.roles["room-1"]["bob"] = chat.RoleMember
+	rooms.roles["room-1"]["bobby"] = chat.RoleMember
+	rooms.roles["room-1"]["muted"] = chat.RoleMember
+	settings := func(userID, level string) *chat.MemberSettings {
+		s := chat.NewMemberSettings("room-1", userID)
+		s.Username, s.NotificationLevel = userID, level
+		return s
+	}
+	muted := settings("muted", chat.NotifyAll)
+	until := time.Now().Add(time.Hour)
+	muted.MutedUntil = &until
+	rooms.settings = []*chat.MemberSettings{
+		settings("uploader", chat.NotifyAll),
+		settings("member", chat.NotifyAll),
+		settings("admin", chat.NotifyNone),
+		settings("bob", chat.NotifyMentions),
+		settings("bobby", chat.NotifyMentions),
+		muted,
+	}
+	uc := message.NewUseCase(&memoryMessageRepo{}, rooms, testMessageAttachments(), validation.New(), *logger.New("error", "json"))
+
+	recipients := func(t *testing.T, content string) []string {
+		out, err := uc.SendMessage(ctx, message.SendMessageInput{ChatRoomID: "room-1", SenderID: "uploader", Content: content, Type: "text"})
+		require.NoError(t, err)
+		return out.NotifyUserIDs
+	}
+
+	t.Run("members are notified according to their settings", func(t *testing.T) {
+		assert.ElementsMatch(t, []string{"member"}, recipients(t, "hello"))
+	})
+
+	t.Run("mentions only notify the member named in full", func(t *testing.T) {
+		assert.ElementsMatch(t, []string{"member", "bobby"}, recipients(t, "thanks @bobby"))
+		assert.ElementsMatch(t, []string{"member", "bob"}, recipients(t, "@BOB, look"))
+		assert.ElementsMatch(t, []string{"member"}, recipients(t, "@muted @admin"))
+	})
+}
+
+func TestMessageSlowMode(t *testing.T) {
+	ctx := context.Background()
+	rooms := &messageRooms{memberRoles: testRoomMembers(), slowModeSeconds: 60}
+	uc := message.NewUseCase(&memoryMessageRepo{}, rooms, testMessageAttachments(), validation.New(), *logger.New("error", "json"))
+
+	send := func(senderID string, ids ...string) error {
+		_, err := uc.SendMessage(ctx, message.SendMessageInput{
+			ChatRoomID: "room-1", SenderID: senderID, Content: "hi", Type: "text", AttachmentIDs: ids,
+		})
+		return err
+	}
+
+	t.Run("members wait between messages", func(t *testing.T) {
+		require.NoError(t, send("member"))
+
+		var slowMode *message.SlowModeError
+		require.ErrorAs(t, send("member"), &slowMode)
+		assert.InDelta(t, 60, slowMode.RetryAfterSeconds(), 1)
+	})
+
+	t.Run("admins are not throttled", func(t *testing.T) {
+		require.NoError(t, send("admin"))
+		require.NoError(t, send("admin"))
+	})
+
+	t.Run("rejected messages do not count", func(t *testing.T) {
+		assert.Equal(t, attachment.ErrAttachmentNotFound, send("uploader", "missing"))
+		require.NoError(t, send("uploader"))
+	})
+}
\ No newline at end of file
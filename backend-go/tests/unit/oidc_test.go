@@ -0,0 +1,347 @@
+package unit
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	jwtlib "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"backend-go/internal/application/auth"
+	"backend-go/internal/domain/user"
+	"backend-go/internal/shared/logger"
+	"backend-go/internal/shared/oidc"
+	"backend-go/internal/shared/validation"
+)
+
+// stubIdP is a minimal OpenID Connect provider that issues one code per authorization
+type stubIdP struct {
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	clientID string
+	audience string
+	pending  map[string]url.Values // code -> authorization request parameters
+
+	emailVerified bool
+}
+
+func newStubIdP(t *testing.T, clientID string) *stubIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	idp := &stubIdP{
+		key:      key,
+		clientID: clientID,
+		audience: clientID,
+		pending:  make(map[string]url.Values),
+
+		emailVerified: true,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test-key",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+
+		auth, ok := idp.pending[r.PostForm.Get("code")]
+		delete(idp.pending, r.PostForm.Get("code"))
+		if !ok || oidc.CodeChallenge(r.PostForm.Get("code_verifier")) != auth.Get("code_challenge") {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		token := jwtlib.NewWithClaims(jwtlib.SigningMethodRS256, jwtlib.MapClaims{
+			"iss":                idp.server.URL,
+			"sub":                "subject-1",
+			"aud":                idp.audience,
+			"exp":                time.Now().Add(time.Hour).Unix(),
+			"iat":                time.Now().Unix(),
+			"nonce":              auth.Get("nonce"),
+			"email":              "jane@example.com",
+			"email_verified":     idp.emailVerified,
+			"preferred_username": "jane",
+		})
+		token.Header["kid"] = "test-key"
+
+		signed, err := token.SignedString(key)
+		require.NoError(t, err)
+
+		json.NewEncoder(w).Encode(map[string]string{"id_token": signed, "token_type": "Bearer"})
+	})
+
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+
+	return idp
+}
+
+// authorize simulates the user signing in at the provider and returns the issued code
+func (idp *stubIdP) authorize(t *testing.T, authorizationURL string) string {
+	parsed, err := url.Parse(authorizationURL)
+	require.NoError(t, err)
+
+	code := "code-" + parsed.Query().Get("state")
+	idp.pending[code] = parsed.Query()
+	return code
+}
+
+func (idp *stubIdP) provider() *oidc.Provider {
+	return oidc.NewProvider(oidc.Config{
+		IssuerURL:   idp.server.URL,
+		ClientID:    idp.clientID,
+		RedirectURL: "http://localhost:3000/sso/callback",
+		Scopes:      []string{"email", "profile"},
+	}, idp.server.Client())
+}
+
+func TestOIDCProvider(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Authorization code flow with PKCE returns the verified identity", func(t *testing.T) {
+		idp := newStubIdP(t, "chat-app")
+		provider := idp.provider()
+
+		verifier, err := oidc.NewCodeVerifier()
+		require.NoError(t, err)
+
+		authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", verifier)
+		require.NoError(t, err)
+
+		parsed, err := url.Parse(authURL)
+		require.NoError(t, err)
+		assert.Equal(t, "code", parsed.Query().Get("response_type"))
+		assert.Equal(t, "S256", parsed.Query().Get("code_challenge_method"))
+		assert.Equal(t, oidc.CodeChallenge(verifier), parsed.Query().Get("code_challenge"))
+		assert.Equal(t, "openid email profile", parsed.Query().Get("scope"))
+
+		code := idp.authorize(t, authURL)
+
+		identity, err := provider.Exchange(ctx, code, verifier, "nonce-1")
+		require.NoError(t, err)
+		assert.Equal(t, idp.server.URL, identity.Issuer)
+		assert.Equal(t, "subject-1", identity.Subject)
+		assert.Equal(t, "jane@example.com", identity.Email)
+		assert.True(t, identity.EmailVerified)
+		assert.Equal(t, "jane", identity.PreferredUsername)
+	})
+
+	t.Run("Exchange fails with the wrong code verifier", func(t *testing.T) {
+		idp := newStubIdP(t, "chat-app")
+		provider := idp.provider()
+
+		verifier, err := oidc.NewCodeVerifier()
+		require.NoError(t, err)
+		authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", verifier)
+		require.NoError(t, err)
+
+		code := idp.authorize(t, authURL)
+
+		_, err = provider.Exchange(ctx, code, "some-other-verifier", "nonce-1")
+		assert.Error(t, err)
+	})
+
+	t.Run("ID token with a different nonce is rejected", func(t *testing.T) {
+		idp := newStubIdP(t, "chat-app")
+		provider := idp.provider()
+
+		verifier, err := oidc.NewCodeVerifier()
+		require.NoError(t, err)
+		authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", verifier)
+		require.NoError(t, err)
+
+		code := idp.authorize(t, authURL)
+
+		_, err = provider.Exchange(ctx, code, verifier, "nonce-2")
+		assert.Error(t, err)
+	})
+
+	t.Run("ID token for another client is rejected", func(t *testing.T) {
+		idp := newStubIdP(t, "chat-app")
+		idp.audience = "another-app"
+		provider := idp.provider()
+
+		verifier, err := oidc.NewCodeVerifier()
+		require.NoError(t, err)
+		authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", verifier)
+		require.NoError(t, err)
+
+		code := idp.authorize(t, authURL)
+
+		_, err = provider.Exchange(ctx, code, verifier, "nonce-1")
+		assert.Error(t, err)
+	})
+
+	t.Run("Mismatched issuer is rejected at discovery", func(t *testing.T) {
+		idp := newStubIdP(t, "chat-app")
+		provider := oidc.NewProvider(oidc.Config{
+			IssuerURL: idp.server.URL + "/",
+			ClientID:  "chat-app",
+		}, idp.server.Client())
+
+		_, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", "verifier")
+		assert.Error(t, err)
+	})
+}
+
+func TestOIDCStateStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Login state can only be taken once", func(t *testing.T) {
+		store := oidc.NewMemoryStateStore()
+
+		require.NoError(t, store.Save(ctx, "state-1", oidc.LoginState{CodeVerifier: "verifier", Nonce: "nonce"}, time.Minute))
+
+		login, err := store.Take(ctx, "state-1")
+		require.NoError(t, err)
+		assert.Equal(t, "verifier", login.CodeVerifier)
+		assert.Equal(t, "nonce", login.Nonce)
+
+		_, err = store.Take(ctx, "state-1")
+		assert.Equal(t, oidc.ErrStateNotFound, err)
+	})
+
+	t.Run("Expired login state is not returned", func(t *testing.T) {
+		store := oidc.NewMemoryStateStore()
+
+		require.NoError(t, store.Save(ctx, "state-1", oidc.LoginState{}, time.Millisecond))
+		time.Sleep(5 * time.Millisecond)
+
+		_, err := store.Take(ctx, "state-1")
+		assert.Equal(t, oidc.ErrStateNotFound, err)
+	})
+}
+
+// unknownIdentities has no linked identities and counts the lookups, which
+// are only made once a login passes the domain restriction
+type unknownIdentities struct {
+	user.ExternalIdentityRepository
+	lookups int
+}
+
+func (r *unknownIdentities) GetBySubject(ctx context.Context, issuer, subject string) (*user.ExternalIdentity, error) {
+	r.lookups++
+	return nil, errors.New("lookup reached")
+}
+
+func TestOIDCLoginDomainRestriction(t *testing.T) {
+	ctx := context.Background()
+	idp := newStubIdP(t, "chat-app")
+	identities := &unknownIdentities{}
+	settings := auth.OIDCSettings{
+		Provider:       idp.provider(),
+		States:         oidc.NewMemoryStateStore(),
+		AllowedDomains: []string{"example.com"},
+		AutoProvision:  true,
+	}
+	uc := auth.NewUseCase(nil, nil, nil, nil, nil, identities, nil, nil, validation.New(), *logger.New("error", "json"),
+		time.Hour, "http://localhost:3000", settings, auth.LoginProtection{}, auth.PasswordSettings{})
+
+	login := func(t *testing.T) error {
+		started, err := uc.StartOIDCLogin(ctx, auth.StartOIDCLoginInput{})
+		require.NoError(t, err)
+		_, err = uc.CompleteOIDCLogin(ctx, auth.CompleteOIDCLoginInput{
+			Code:  idp.authorize(t, started.AuthorizationURL),
+			State: started.State,
+		})
+		return err
+	}
+
+	t.Run("Verified email at an allowed domain passes", func(t *testing.T) {
+		idp.emailVerified = true
+		err := login(t)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "lookup reached")
+		assert.Equal(t, 1, identities.lookups)
+	})
+
+	t.Run("Unverified email at an allowed domain is rejected", func(t *testing.T) {
+		idp.emailVerified = false
+		err := login(t)
+		require.Error(t, err)
+		assert.Equal(t, "email domain is not allowed", err.Error())
+		assert.Equal(t, 1, identities.lookups, "no account is looked up or provisioned")
+	})
+}
+
+// linkableIdentities has no linked identities and records the links created
+type linkableIdentities struct {
+	user.ExternalIdentityRepository
+	created []*user.ExternalIdentity
+}
+
+func (r *linkableIdentities) GetBySubject(ctx context.Context, issuer, subject string) (*user.ExternalIdentity, error) {
+	return nil, user.ErrExternalIdentityNotFound
+}
+
+func (r *linkableIdentities) Create(ctx context.Context, identity *user.ExternalIdentity) error {
+	r.created = append(r.created, identity)
+	return nil
+}
+
+func TestOIDCLoginAccountLinking(t *testing.T) {
+	ctx := context.Background()
+	idp := newStubIdP(t, "chat-app")
+	existing := user.NewUser("user-1", "jane", "jane@example.com", "hash")
+	// Deactivated, so a login stops right after the identity is linked
+	existing.IsActive = false
+	users := &memoryUsers{users: map[string]*user.User{"user-1": existing}}
+	identities := &linkableIdentities{}
+	settings := auth.OIDCSettings{Provider: idp.provider(), States: oidc.NewMemoryStateStore()}
+	uc := auth.NewUseCase(users, nil, nil, nil, nil, identities, nil, nil, validation.New(), *logger.New("error", "json"),
+		time.Hour, "http://localhost:3000", settings, auth.LoginProtection{}, auth.PasswordSettings{})
+
+	login := func(t *testing.T) error {
+		started, err := uc.StartOIDCLogin(ctx, auth.StartOIDCLoginInput{})
+		require.NoError(t, err)
+		_, err = uc.CompleteOIDCLogin(ctx, auth.CompleteOIDCLoginInput{
+			Code:  idp.authorize(t, started.AuthorizationURL),
+			State: started.State,
+		})
+		return err
+	}
+
+	t.Run("Accounts with an unverified email are not linked", func(t *testing.T) {
+		err := login(t)
+		require.Error(t, err)
+		assert.Equal(t, "the existing account must verify its email address before single sign-on", err.Error())
+		assert.Empty(t, identities.created)
+	})
+
+	t.Run("Accounts with a verified email are linked", func(t *testing.T) {
+		existing.VerifyEmail()
+		err := login(t)
+		require.Error(t, err)
+		assert.Equal(t, "account is deactivated", err.Error())
+		require.Len(t, identities.created, 1)
+		assert.Equal(t, "user-1", identities.created[0].UserID)
+	})
+}
\ No newline at end of file
@@ -0,0 +1,96 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"backend-go/internal/shared/password"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	policy := password.Policy{
+		MinLength:    8,
+		RequireUpper: true,
+		RequireLower: true,
+		RequireDigit: true,
+	}
+
+	t.Run("Valid password passes", func(t *testing.T) {
+		assert.NoError(t, policy.Validate("Tr1cky-Passphrase", "alice", "alice@example.com"))
+	})
+
+	t.Run("Every broken rule is reported", func(t *testing.T) {
+		err := policy.Validate("abc")
+
+		var policyErr *password.PolicyError
+		require.True(t, errors.As(err, &policyErr))
+		assert.Equal(t, []string{
+			"must be at least 8 characters",
+			"must contain an uppercase letter",
+			"must contain a digit",
+		}, policyErr.Problems)
+		assert.Equal(t, "password must be at least 8 characters, must contain an uppercase letter, must contain a digit", err.Error())
+	})
+
+	t.Run("Personal values are rejected case-insensitively", func(t *testing.T) {
+		err := policy.Validate("MyAlice2024", "alice", "alice@example.com")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "must not contain your username or email")
+
+		// Too short to be meaningful
+		assert.NoError(t, policy.Validate("Tr1cky-Passphrase", "ph"))
+	})
+
+	t.Run("Passwords longer than bcrypt supports are rejected", func(t *testing.T) {
+		long := "Aa1" + string(make([]byte, password.MaxBcryptLength))
+		err := policy.Validate(long)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "must be at most 72 bytes")
+	})
+}
+
+func TestBreachedPasswords(t *testing.T) {
+	// SHA-1 of "password" is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "5BAA6.txt"),
+		[]byte("003D68EB55068C33ACE09247EE4C639306B:3\r\n1E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824\r\n"), 0644))
+
+	checker := password.NewRangeFileChecker(dir)
+	ctx := context.Background()
+
+	t.Run("Listed password is breached", func(t *testing.T) {
+		breached, err := checker.IsBreached(ctx, "password")
+		require.NoError(t, err)
+		assert.True(t, breached)
+	})
+
+	t.Run("Password without a range file is not breached", func(t *testing.T) {
+		breached, err := checker.IsBreached(ctx, "Tr1cky-Passphrase")
+		require.NoError(t, err)
+		assert.False(t, breached)
+	})
+}
+
+func TestPasswordHasher(t *testing.T) {
+	hasher := password.NewHasher(4)
+
+	hash, err := hasher.Hash("Tr1cky-Passphrase")
+	require.NoError(t, err)
+
+	t.Run("Compare matches only the right password", func(t *testing.T) {
+		assert.NoError(t, hasher.Compare(hash, "Tr1cky-Passphrase"))
+		assert.Error(t, hasher.Compare(hash, "wrong"))
+		assert.Error(t, hasher.Compare("", "Tr1cky-Passphrase"))
+	})
+
+	t.Run("Hashes at another cost need a rehash", func(t *testing.T) {
+		assert.False(t, hasher.NeedsRehash(hash))
+		assert.True(t, password.NewHasher(5).NeedsRehash(hash))
+		assert.False(t, hasher.NeedsRehash(""))
+	})
+}
\ No newline at end of file
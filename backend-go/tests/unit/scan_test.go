@@ -0,0 +1,139 @@
+package unit
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"backend-go/internal/shared/scan"
+)
+
+// eicar is the standard antivirus test file
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// serveFakeClamd answers INSTREAM commands on the listener like clamd does,
+// finding the EICAR test file and rejecting streams longer than maxSize
+func serveFakeClamd(t *testing.T, listener net.Listener, maxSize int) {
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				command, err := reader.ReadString(0)
+				if err != nil || command != "zINSTREAM\x00" {
+					io.WriteString(conn, "UNKNOWN COMMAND\x00")
+					return
+				}
+
+				var content []byte
+				for {
+					var size uint32
+					if err := binary.Read(reader, binary.BigEndian, &size); err != nil {
+						return
+					}
+					if size == 0 {
+						break
+					}
+					if len(content)+int(size) > maxSize {
+						io.WriteString(conn, "INSTREAM size limit exceeded. ERROR\x00")
+						return
+					}
+					chunk := make([]byte, size)
+					if _, err := io.ReadFull(reader, chunk); err != nil {
+						return
+					}
+					content = append(content, chunk...)
+				}
+
+				if strings.Contains(string(content), eicar) {
+					io.WriteString(conn, "stream: Eicar-Test-Signature FOUND\x00")
+					return
+				}
+				io.WriteString(conn, "stream: OK\x00")
+			}()
+		}
+	}()
+}
+
+func TestClamAVScanner(t *testing.T) {
+	ctx := context.Background()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	serveFakeClamd(t, listener, 1<<20)
+	scanner := scan.NewClamAVScanner("tcp://"+listener.Addr().String(), 5*time.Second)
+
+	t.Run("Clean content has no threat", func(t *testing.T) {
+		threat, err := scanner.Scan(ctx, strings.NewReader("quarterly report"))
+		require.NoError(t, err)
+		assert.Empty(t, threat)
+	})
+
+	t.Run("Reports the malware found", func(t *testing.T) {
+		threat, err := scanner.Scan(ctx, strings.NewReader("header "+eicar))
+		require.NoError(t, err)
+		assert.Equal(t, "Eicar-Test-Signature", threat)
+	})
+
+	t.Run("Streams content larger than a chunk", func(t *testing.T) {
+		content := strings.Repeat("a", 200<<10) + eicar
+		threat, err := scanner.Scan(ctx, strings.NewReader(content))
+		require.NoError(t, err)
+		assert.Equal(t, "Eicar-Test-Signature", threat)
+	})
+
+	t.Run("Fails for content clamd rejects", func(t *testing.T) {
+		_, err := scanner.Scan(ctx, strings.NewReader(strings.Repeat("a", 2<<20)))
+		assert.True(t, errors.Is(err, scan.ErrScanFailed), "got %v", err)
+	})
+
+	t.Run("Connects over a Unix socket", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "clamd.sock")
+		unixListener, err := net.Listen("unix", path)
+		require.NoError(t, err)
+		serveFakeClamd(t, unixListener, 1<<20)
+
+		threat, err := scan.NewClamAVScanner("unix://"+path, 5*time.Second).Scan(ctx, strings.NewReader(eicar))
+		require.NoError(t, err)
+		assert.Equal(t, "Eicar-Test-Signature", threat)
+	})
+
+	t.Run("Fails when clamd cannot be reached", func(t *testing.T) {
+		closed, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		address := closed.Addr().String()
+		closed.Close()
+
+		_, err = scan.NewClamAVScanner(address, 5*time.Second).Scan(ctx, strings.NewReader("report"))
+		assert.Error(t, err)
+	})
+
+	t.Run("Gives up when clamd does not reply in time", func(t *testing.T) {
+		silent, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer silent.Close()
+		go func() {
+			conn, err := silent.Accept()
+			if err == nil {
+				defer conn.Close()
+				io.Copy(io.Discard, conn)
+			}
+		}()
+
+		_, err = scan.NewClamAVScanner(silent.Addr().String(), 100*time.Millisecond).Scan(ctx, strings.NewReader("report"))
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
\ No newline at end of file
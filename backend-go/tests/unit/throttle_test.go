@@ -0,0 +1,80 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"backend-go/internal/shared/throttle"
+)
+
+func TestThrottle(t *testing.T) {
+	policy := throttle.Policy{
+		Window:           15 * time.Minute,
+		FreeAttempts:     2,
+		BaseDelay:        time.Second,
+		MaxDelay:         5 * time.Second,
+		LockoutThreshold: 8,
+		LockoutDuration:  15 * time.Minute,
+	}
+
+	t.Run("Delay grows progressively up to the cap", func(t *testing.T) {
+		assert.Zero(t, policy.Delay(1))
+		assert.Zero(t, policy.Delay(2))
+		assert.Equal(t, time.Second, policy.Delay(3))
+		assert.Equal(t, 2*time.Second, policy.Delay(4))
+		assert.Equal(t, 4*time.Second, policy.Delay(5))
+		assert.Equal(t, 5*time.Second, policy.Delay(6))
+		assert.Equal(t, 5*time.Second, policy.Delay(7))
+	})
+
+	t.Run("Delay locks out at the threshold", func(t *testing.T) {
+		assert.Equal(t, 15*time.Minute, policy.Delay(8))
+		assert.Equal(t, 15*time.Minute, policy.Delay(20))
+	})
+
+	t.Run("Limiter blocks after failures and resets", func(t *testing.T) {
+		ctx := context.Background()
+		limiter := throttle.NewLimiter(throttle.NewMemoryStore(), policy, "account:")
+
+		for i := 0; i < 2; i++ {
+			delay, err := limiter.Fail(ctx, "user@example.com")
+			require.NoError(t, err)
+			assert.Zero(t, delay)
+		}
+		assert.NoError(t, limiter.Check(ctx, "user@example.com"))
+
+		delay, err := limiter.Fail(ctx, "user@example.com")
+		require.NoError(t, err)
+		assert.Equal(t, time.Second, delay)
+
+		err = limiter.Check(ctx, "user@example.com")
+		var blocked *throttle.BlockedError
+		require.True(t, errors.As(err, &blocked))
+		assert.True(t, blocked.RetryAfter > 0 && blocked.RetryAfter <= time.Second)
+
+		// Other keys are unaffected
+		assert.NoError(t, limiter.Check(ctx, "other@example.com"))
+
+		require.NoError(t, limiter.Reset(ctx, "user@example.com"))
+		assert.NoError(t, limiter.Check(ctx, "user@example.com"))
+
+		delay, err = limiter.Fail(ctx, "user@example.com")
+		require.NoError(t, err)
+		assert.Zero(t, delay)
+	})
+
+	t.Run("Nil limiter allows everything", func(t *testing.T) {
+		var limiter *throttle.Limiter
+		ctx := context.Background()
+
+		delay, err := limiter.Fail(ctx, "key")
+		assert.NoError(t, err)
+		assert.Zero(t, delay)
+		assert.NoError(t, limiter.Check(ctx, "key"))
+		assert.NoError(t, limiter.Reset(ctx, "key"))
+	})
+}
\ No newline at end of file
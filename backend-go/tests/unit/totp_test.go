@@ -0,0 +1,77 @@
+package unit
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"backend-go/internal/shared/totp"
+)
+
+func TestTOTP(t *testing.T) {
+	// RFC 6238 test secret "12345678901234567890" in base32
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	t.Run("Code matches the RFC 6238 test vectors", func(t *testing.T) {
+		code, err := totp.Code(secret, time.Unix(59, 0))
+		require.NoError(t, err)
+		assert.Equal(t, "287082", code)
+
+		code, err = totp.Code(secret, time.Unix(1111111109, 0))
+		require.NoError(t, err)
+		assert.Equal(t, "081804", code)
+	})
+
+	t.Run("Validate accepts codes within the allowed skew", func(t *testing.T) {
+		now := time.Unix(1111111109, 0)
+
+		for _, offset := range []time.Duration{-totp.Period, 0, totp.Period} {
+			code, err := totp.Code(secret, now.Add(offset))
+			require.NoError(t, err)
+
+			step, ok := totp.Validate(secret, code, now)
+			assert.True(t, ok)
+			assert.Equal(t, totp.Step(now.Add(offset)), step)
+		}
+	})
+
+	t.Run("Validate rejects old and malformed codes", func(t *testing.T) {
+		now := time.Unix(1111111109, 0)
+
+		old, err := totp.Code(secret, now.Add(-3*totp.Period))
+		require.NoError(t, err)
+
+		_, ok := totp.Validate(secret, old, now)
+		assert.False(t, ok)
+
+		_, ok = totp.Validate(secret, "12345", now)
+		assert.False(t, ok)
+
+		_, ok = totp.Validate("not base32!", "123456", now)
+		assert.False(t, ok)
+	})
+
+	t.Run("GenerateSecret returns distinct usable secrets", func(t *testing.T) {
+		a, err := totp.GenerateSecret()
+		require.NoError(t, err)
+		b, err := totp.GenerateSecret()
+		require.NoError(t, err)
+
+		assert.NotEqual(t, a, b)
+
+		code, err := totp.Code(a, time.Now())
+		require.NoError(t, err)
+		assert.Len(t, code, totp.Digits)
+	})
+
+	t.Run("URI carries the secret and issuer", func(t *testing.T) {
+		uri := totp.URI("WhatsApp Chat", "user@example.com", secret)
+
+		assert.True(t, strings.HasPrefix(uri, "otpauth://totp/"))
+		assert.Contains(t, uri, "secret="+secret)
+		assert.Contains(t, uri, "issuer=WhatsApp+Chat")
+		assert.Contains(t, uri, "user@example.com")
+	})
+}
\ No newline at end of file
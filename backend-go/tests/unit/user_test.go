@@ -69,4 +69,102 @@ func TestUserEntity(t *testing.T) {
 		u.LastSeenAt = &oldTime
 		assert.False(t, u.IsOnline())
 	})
+}
+
+func TestRefreshTokenEntity(t *testing.T) {
+	t.Run("NewRefreshToken sets expiry from TTL", func(t *testing.T) {
+		token := user.NewRefreshToken("id", "user-id", "family-id", "hash", time.Hour)
+
+		assert.Equal(t, "user-id", token.UserID)
+		assert.Equal(t, "family-id", token.FamilyID)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), token.ExpiresAt, time.Second)
+		assert.False(t, token.IsExpired())
+		assert.False(t, token.IsRevoked())
+	})
+
+	t.Run("IsExpired and IsRevoked report token state", func(t *testing.T) {
+		token := user.NewRefreshToken("id", "user-id", "family-id", "hash", -time.Minute)
+		assert.True(t, token.IsExpired())
+
+		now := time.Now()
+		token.RevokedAt = &now
+		assert.True(t, token.IsRevoked())
+	})
+}
+
+func TestSessionEntity(t *testing.T) {
+	t.Run("NewSession creates an active session", func(t *testing.T) {
+		expiresAt := time.Now().Add(time.Hour)
+		s := user.NewSession("session-id", "user-id", "Pixel 8", "Mozilla/5.0", "203.0.113.7", expiresAt)
+
+		assert.Equal(t, "session-id", s.ID)
+		assert.Equal(t, "user-id", s.UserID)
+		assert.Equal(t, "Pixel 8", s.DeviceName)
+		assert.Equal(t, "Mozilla/5.0", s.UserAgent)
+		assert.Equal(t, "203.0.113.7", s.IPAddress)
+		assert.Equal(t, expiresAt, s.ExpiresAt)
+		assert.Nil(t, s.RevokedAt)
+		assert.True(t, s.IsActive())
+	})
+
+	t.Run("NewSession falls back to a default device name", func(t *testing.T) {
+		s := user.NewSession("session-id", "user-id", "", "", "", time.Now().Add(time.Hour))
+
+		assert.Equal(t, "Unknown device", s.DeviceName)
+	})
+
+	t.Run("Revoked or expired sessions are inactive", func(t *testing.T) {
+		revoked := user.NewSession("s1", "user-id", "Phone", "", "", time.Now().Add(time.Hour))
+		now := time.Now()
+		revoked.RevokedAt = &now
+		assert.False(t, revoked.IsActive())
+
+		expired := user.NewSession("s2", "user-id", "Phone", "", "", time.Now().Add(-time.Minute))
+		assert.False(t, expired.IsActive())
+	})
+}
+
+func TestAccountFlows(t *testing.T) {
+	t.Run("VerifyEmail marks the email as verified", func(t *testing.T) {
+		u := user.NewUser("id", "testuser", "test@example.com", "hash")
+		assert.False(t, u.IsEmailVerified())
+
+		u.VerifyEmail()
+
+		assert.True(t, u.IsEmailVerified())
+		assert.NotNil(t, u.EmailVerifiedAt)
+	})
+
+	t.Run("ChangePassword replaces the hash", func(t *testing.T) {
+		u := user.NewUser("id", "testuser", "test@example.com", "old-hash")
+		before := u.UpdatedAt
+
+		time.Sleep(time.Millisecond)
+		u.ChangePassword("new-hash")
+
+		assert.Equal(t, "new-hash", u.PasswordHash)
+		assert.True(t, u.UpdatedAt.After(before))
+	})
+
+	t.Run("NewAccountToken expires after its TTL", func(t *testing.T) {
+		token := user.NewAccountToken("token-id", "user-id", user.TokenPurposePasswordReset, "hash", user.PasswordResetTTL)
+
+		assert.Equal(t, user.TokenPurposePasswordReset, token.Purpose)
+		assert.Nil(t, token.UsedAt)
+		assert.WithinDuration(t, token.CreatedAt.Add(time.Hour), token.ExpiresAt, time.Second)
+	})
+}
+
+func TestTwoFactorEntity(t *testing.T) {
+	t.Run("NewTwoFactor is not enabled until confirmed", func(t *testing.T) {
+		twoFactor := user.NewTwoFactor("user-id", "SECRET")
+
+		assert.Equal(t, "user-id", twoFactor.UserID)
+		assert.Equal(t, "SECRET", twoFactor.Secret)
+		assert.False(t, twoFactor.IsEnabled())
+
+		now := time.Now()
+		twoFactor.ConfirmedAt = &now
+		assert.True(t, twoFactor.IsEnabled())
+	})
 }
\ No newline at end of file